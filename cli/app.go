@@ -92,6 +92,8 @@ type advancedAppServices interface {
 	maybeInitializeUpdateCheck(ctx context.Context, co *connectOptions)
 	removeUpdateState()
 	passwordPersistenceStrategy() passwordpersist.Strategy
+	passwordProviderConfig() (*repo.PasswordProviderConfig, error)
+	loadPasswordProvider() error
 	getPasswordFromFlags(ctx context.Context, isNew, allowPersistent bool) (string, error)
 	optionsFromFlags(ctx context.Context) *repo.Options
 
@@ -103,6 +105,7 @@ type App struct {
 	// global flags
 	enableAutomaticMaintenance    bool
 	pf                            profileFlags
+	pp                            passwordProviderFlags
 	mt                            memoryTracker
 	progress                      *cliProgress
 	initialUpdateCheckDelay       time.Duration
@@ -120,24 +123,25 @@ type App struct {
 	currentAction string
 
 	// subcommands
-	blob        commandBlob
-	benchmark   commandBenchmark
-	cache       commandCache
-	content     commandContent
-	diff        commandDiff
-	index       commandIndex
-	list        commandList
-	server      commandServer
-	session     commandSession
-	policy      commandPolicy
-	restore     commandRestore
-	show        commandShow
-	snapshot    commandSnapshot
-	manifest    commandManifest
-	mount       commandMount
-	maintenance commandMaintenance
-	repository  commandRepository
-	logs        commandLogs
+	blob         commandBlob
+	benchmark    commandBenchmark
+	cache        commandCache
+	content      commandContent
+	diff         commandDiff
+	index        commandIndex
+	list         commandList
+	server       commandServer
+	session      commandSession
+	policy       commandPolicy
+	restore      commandRestore
+	show         commandShow
+	snapshot     commandSnapshot
+	manifest     commandManifest
+	mount        commandMount
+	maintenance  commandMaintenance
+	notification commandNotification
+	repository   commandRepository
+	logs         commandLogs
 
 	// testability hooks
 	osExit       func(int) // allows replacing os.Exit() with custom code
@@ -159,6 +163,11 @@ func (c *App) stderr() io.Writer {
 }
 
 func (c *App) passwordPersistenceStrategy() passwordpersist.Strategy {
+	if c.pp.strategy != nil {
+		// password is managed by the external provider.
+		return c.pp.strategy
+	}
+
 	if !c.persistCredentials {
 		return passwordpersist.None
 	}
@@ -206,6 +215,7 @@ func (c *App) setup(app *kingpin.Application) {
 	app.Flag("advanced-commands", "Enable advanced (and potentially dangerous) commands.").Hidden().Envar("KOPIA_ADVANCED_COMMANDS").StringVar(&c.AdvancedCommands)
 
 	c.setupOSSpecificKeychainFlags(app)
+	c.pp.setup(app)
 
 	_ = app.Flag("caching", "Enables caching of objects (disable with --no-caching)").Default("true").Hidden().Action(
 		deprecatedFlag(c.stderrWriter, "The '--caching' flag is deprecated and has no effect, use 'kopia cache set' instead."),
@@ -236,6 +246,7 @@ func (c *App) setup(app *kingpin.Application) {
 	c.policy.setup(c, app)
 	c.mount.setup(c, app)
 	c.maintenance.setup(c, app)
+	c.notification.setup(c, app)
 	c.repository.setup(c, app)
 }
 
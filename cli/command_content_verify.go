@@ -57,7 +57,10 @@ func readBlobMap(ctx context.Context, br blob.Reader) (map[blob.ID]blob.Metadata
 func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepository) error {
 	blobMap := map[blob.ID]blob.Metadata{}
 
-	if !c.contentVerifyFull {
+	// without the private key, contents encrypted using the public key can only be checked for presence.
+	canDecrypt := rep.Crypter().CanDecryptContents()
+
+	if !c.contentVerifyFull || !canDecrypt {
 		m, err := readBlobMap(ctx, rep.BlobReader())
 		if err != nil {
 			return err
@@ -70,6 +73,7 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 	successCount := new(int32)
 	errorCount := new(int32)
 	totalCount := new(int32)
+	presenceOnlyCount := new(int32)
 	subctx, cancel := context.WithCancel(ctx)
 
 	var wg sync.WaitGroup
@@ -98,7 +102,14 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 		Parallel:       c.contentVerifyParallel,
 		IncludeDeleted: c.contentVerifyIncludeDeleted,
 	}, func(ci content.Info) error {
-		if err := c.contentVerify(ctx, rep.ContentReader(), ci, blobMap); err != nil {
+		full := c.contentVerifyFull
+		if full && !canDecrypt && ci.GetEncryptionKeyID() == content.PublicKeyEncryptionKeyID {
+			full = false
+
+			atomic.AddInt32(presenceOnlyCount, 1)
+		}
+
+		if err := c.contentVerify(ctx, rep.ContentReader(), ci, full, blobMap); err != nil {
 			log(ctx).Errorf("error %v", err)
 			atomic.AddInt32(errorCount, 1)
 		} else {
@@ -131,6 +142,16 @@ func (c *commandContentVerify) run(ctx context.Context, rep repo.DirectRepositor
 
 	log(ctx).Infof("Finished verifying %v contents, found %v errors.", atomic.LoadInt32(verifiedCount), atomic.LoadInt32(errorCount))
 
+	if n := atomic.LoadInt32(presenceOnlyCount); n > 0 {
+		log(ctx).Infof("WARNING: %v contents encrypted using the public key were only checked for presence, full verification requires the private key.", n)
+	}
+
+	if ecr, ok := rep.(repo.ErrorCorrectingRepository); ok {
+		if repaired := ecr.RepairedBlobs(); len(repaired) > 0 {
+			log(ctx).Infof("Repaired %v damaged blobs using error correction codes: %v", len(repaired), repaired)
+		}
+	}
+
 	ec := atomic.LoadInt32(errorCount)
 	if ec == 0 {
 		return nil
@@ -160,8 +181,8 @@ func (c *commandContentVerify) getTotalContentCount(ctx context.Context, rep rep
 	atomic.StoreInt32(totalCount, tc)
 }
 
-func (c *commandContentVerify) contentVerify(ctx context.Context, r content.Reader, ci content.Info, blobMap map[blob.ID]blob.Metadata) error {
-	if c.contentVerifyFull {
+func (c *commandContentVerify) contentVerify(ctx context.Context, r content.Reader, ci content.Info, full bool, blobMap map[blob.ID]blob.Metadata) error {
+	if full {
 		if _, err := r.GetContent(ctx, ci.GetContentID()); err != nil {
 			return errors.Wrapf(err, "content %v is invalid", ci.GetContentID())
 		}
@@ -17,7 +17,9 @@ type commandDiff struct {
 	diffSecondObjectPath string
 	diffCompareFiles     bool
 	diffCommandCommand   string
+	diffSummaryOnly      bool
 
+	jo  jsonOutput
 	out textOutput
 }
 
@@ -27,12 +29,22 @@ func (c *commandDiff) setup(svc appServices, parent commandParent) {
 	cmd.Arg("object-path2", "Second object/path").Required().StringVar(&c.diffSecondObjectPath)
 	cmd.Flag("files", "Compare files by launching diff command for all pairs of (old,new)").Short('f').BoolVar(&c.diffCompareFiles)
 	cmd.Flag("diff-command", "Displays differences between two repository objects (files or directories)").Default(defaultDiffCommand()).Envar("KOPIA_DIFF").StringVar(&c.diffCommandCommand)
+	cmd.Flag("summary", "Only display the number of changed entries, without reading file contents").BoolVar(&c.diffSummaryOnly)
 	cmd.Action(svc.repositoryReaderAction(c.run))
 
+	c.jo.setup(svc, cmd)
 	c.out.setup(svc)
 }
 
 func (c *commandDiff) run(ctx context.Context, rep repo.Repository) error {
+	if c.diffCompareFiles && c.jo.jsonOutput {
+		return errors.New("--files can't be used with --json")
+	}
+
+	if c.diffCompareFiles && c.diffSummaryOnly {
+		return errors.New("--files can't be used with --summary")
+	}
+
 	ent1, err := snapshotfs.FilesystemEntryFromIDWithPath(ctx, rep, c.diffFirstObjectPath, false)
 	if err != nil {
 		return errors.Wrapf(err, "error getting filesystem entry for %v", c.diffFirstObjectPath)
@@ -62,11 +74,39 @@ func (c *commandDiff) run(ctx context.Context, rep repo.Repository) error {
 		d.DiffArguments = parts[1:]
 	}
 
-	if isDir1 {
-		return errors.Wrap(d.Compare(ctx, ent1, ent2), "error comparing directories")
+	d.SummaryOnly = c.diffSummaryOnly
+
+	if c.jo.jsonOutput {
+		// emit one JSON object per line.
+		d.ChangeCallback = func(ch *diff.EntryChange) error {
+			c.out.printStdout("%s\n", c.jo.jsonBytes(ch))
+			return nil
+		}
+	}
+
+	if !isDir1 {
+		return errors.New("comparing files not implemented yet")
+	}
+
+	if err := d.Compare(ctx, ent1, ent2); err != nil {
+		return errors.Wrap(err, "error comparing directories")
+	}
+
+	if c.diffSummaryOnly {
+		c.printSummary(d.Stats())
+	}
+
+	return nil
+}
+
+func (c *commandDiff) printSummary(s diff.Stats) {
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(s))
+		return
 	}
 
-	return errors.New("comparing files not implemented yet")
+	c.out.printStdout("Files:       %v added, %v removed, %v modified\n", s.FilesAdded, s.FilesRemoved, s.FilesModified)
+	c.out.printStdout("Directories: %v added, %v removed, %v modified\n", s.DirectoriesAdded, s.DirectoriesRemoved, s.DirectoriesModified)
 }
 
 func defaultDiffCommand() string {
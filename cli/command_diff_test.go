@@ -0,0 +1,101 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/diff"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestDiffJSON(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "unchanged.txt"), []byte("same"), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "modified.txt"), []byte("old"), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "sub", "removed.txt"), []byte("gone"), 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	var snap1, snap2 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json"), &snap1)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "modified.txt"), []byte("new contents"), 0o600))
+	require.NoError(t, os.Remove(filepath.Join(dir, "sub", "removed.txt")))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "added"), 0o755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "added", "new.txt"), []byte("hello"), 0o600))
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json"), &snap2)
+
+	oid1, oid2 := string(snap1.RootObjectID()), string(snap2.RootObjectID())
+
+	var changes []*diff.EntryChange
+
+	for _, l := range env.RunAndExpectSuccess(t, "diff", oid1, oid2, "--json") {
+		var ch diff.EntryChange
+
+		require.NoError(t, json.Unmarshal([]byte(l), &ch), l)
+
+		changes = append(changes, &ch)
+	}
+
+	byPath := map[string]*diff.EntryChange{}
+
+	var paths []string
+
+	for _, ch := range changes {
+		if ch.Old != nil && ch.New != nil && ch.Old.Type == diff.EntryTypeDirectory && ch.New.Type == diff.EntryTypeDirectory {
+			// directory metadata changes depend on filesystem timestamps.
+			continue
+		}
+
+		byPath[ch.Path] = ch
+		paths = append(paths, ch.Path)
+	}
+
+	sort.Strings(paths)
+	require.Equal(t, []string{"added", "added/new.txt", "modified.txt", "sub/removed.txt"}, paths)
+
+	require.Equal(t, diff.ChangeAdded, byPath["added"].Change)
+	require.Equal(t, diff.EntryTypeDirectory, byPath["added"].New.Type)
+	require.Nil(t, byPath["added"].Old)
+
+	require.Equal(t, diff.ChangeAdded, byPath["added/new.txt"].Change)
+	require.EqualValues(t, 5, byPath["added/new.txt"].New.Size)
+
+	m := byPath["modified.txt"]
+	require.Equal(t, diff.ChangeModified, m.Change)
+	require.EqualValues(t, 3, m.Old.Size)
+	require.EqualValues(t, len("new contents"), m.New.Size)
+	require.NotEqual(t, m.Old.ObjectID, m.New.ObjectID)
+	require.Equal(t, "0600", m.New.Mode)
+
+	require.Equal(t, diff.ChangeRemoved, byPath["sub/removed.txt"].Change)
+	require.Nil(t, byPath["sub/removed.txt"].New)
+
+	var stats diff.Stats
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "diff", oid1, oid2, "--summary", "--json"), &stats)
+	require.Equal(t, 1, stats.FilesAdded)
+	require.Equal(t, 1, stats.FilesRemoved)
+	require.Equal(t, 1, stats.FilesModified)
+	require.Equal(t, 1, stats.DirectoriesAdded)
+	require.Equal(t, 0, stats.DirectoriesRemoved)
+
+	require.Len(t, env.RunAndExpectSuccess(t, "diff", oid1, oid2, "--summary"), 2)
+
+	// comparing file contents produces text output, which can't be combined with JSON or summary.
+	env.RunAndExpectFailure(t, "diff", oid1, oid2, "--files", "--json")
+	env.RunAndExpectFailure(t, "diff", oid1, oid2, "--files", "--summary")
+}
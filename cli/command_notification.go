@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"github.com/alecthomas/kingpin"
+
+	"github.com/kopia/kopia/internal/notification"
+)
+
+type commandNotification struct {
+	configure commandNotificationConfigure
+	delete    commandNotificationDelete
+	list      commandNotificationList
+	set       commandNotificationSet
+	test      commandNotificationTest
+}
+
+func (c *commandNotification) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("notification", "Commands to manage notifications about snapshot and maintenance events.").Alias("notifications")
+
+	c.configure.setup(svc, cmd)
+	c.delete.setup(svc, cmd)
+	c.list.setup(svc, cmd)
+	c.set.setup(svc, cmd)
+	c.test.setup(svc, cmd)
+}
+
+// notificationProfileFlags are flags common to all notification profile types.
+type notificationProfileFlags struct {
+	profileName string
+	events      []string
+}
+
+func (c *notificationProfileFlags) setup(cmd *kingpin.CmdClause) {
+	var eventNames []string
+	for _, e := range notification.AllEventTypes {
+		eventNames = append(eventNames, string(e))
+	}
+
+	cmd.Flag("profile", "Name of the notification profile").Required().StringVar(&c.profileName)
+	cmd.Flag("event", "Event to send notifications about (can be specified multiple times, defaults to all events)").EnumsVar(&c.events, eventNames...)
+}
+
+func (c *notificationProfileFlags) profile() *notification.ProfileConfig {
+	p := &notification.ProfileConfig{
+		Name: c.profileName,
+	}
+
+	for _, e := range c.events {
+		p.Events = append(p.Events, notification.EventType(e))
+	}
+
+	return p
+}
@@ -0,0 +1,152 @@
+package cli
+
+import (
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandNotificationConfigure struct {
+	webhook commandNotificationConfigureWebhook
+	email   commandNotificationConfigureEmail
+}
+
+func (c *commandNotificationConfigure) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("configure", "Configure notification profile.")
+
+	c.webhook.setup(svc, cmd)
+	c.email.setup(svc, cmd)
+}
+
+type commandNotificationConfigureWebhook struct {
+	notificationProfileFlags
+
+	url              string
+	method           string
+	headers          []string
+	bodyTemplate     string
+	bodyTemplateFile string
+
+	svc appServices
+}
+
+func (c *commandNotificationConfigureWebhook) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("webhook", "Send notifications to a HTTP endpoint.")
+	c.notificationProfileFlags.setup(cmd)
+	cmd.Flag("url", "Webhook URL").Required().StringVar(&c.url)
+	cmd.Flag("method", "HTTP method").Default("POST").StringVar(&c.method)
+	cmd.Flag("header", "HTTP header in the 'Name: Value' format, stored only in the local configuration of this client (can be specified multiple times)").StringsVar(&c.headers)
+	cmd.Flag("body-template", "Go template producing request body from the event, defaults to JSON-encoded event").StringVar(&c.bodyTemplate)
+	cmd.Flag("body-template-file", "File containing body template").ExistingFileVar(&c.bodyTemplateFile)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandNotificationConfigureWebhook) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	opt := &notification.WebhookOptions{
+		URL:          c.url,
+		Method:       c.method,
+		BodyTemplate: c.bodyTemplate,
+	}
+
+	if c.bodyTemplateFile != "" {
+		b, err := ioutil.ReadFile(c.bodyTemplateFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to read body template")
+		}
+
+		opt.BodyTemplate = string(b)
+	}
+
+	for _, h := range c.headers {
+		p := strings.Index(h, ":")
+		if p < 0 {
+			return errors.Errorf("invalid header %q, must be 'Name: Value'", h)
+		}
+
+		if opt.Headers == nil {
+			opt.Headers = map[string]string{}
+		}
+
+		opt.Headers[strings.TrimSpace(h[0:p])] = strings.TrimSpace(h[p+1:])
+	}
+
+	p := c.profile()
+	p.Webhook = opt
+
+	if err := saveNotificationProfile(ctx, rep, p); err != nil {
+		return err
+	}
+
+	if err := repo.SetNotificationHeaders(ctx, c.svc.repositoryConfigFileName(), p.Name, opt.Headers); err != nil {
+		return errors.Wrap(err, "unable to save webhook headers")
+	}
+
+	return nil
+}
+
+type commandNotificationConfigureEmail struct {
+	notificationProfileFlags
+
+	opt notification.EmailOptions
+	svc appServices
+}
+
+func (c *commandNotificationConfigureEmail) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("email", "Send notifications by email.")
+	c.notificationProfileFlags.setup(cmd)
+	cmd.Flag("smtp-server", "SMTP server").Required().StringVar(&c.opt.SMTPServer)
+	cmd.Flag("smtp-port", "SMTP port").Default("587").IntVar(&c.opt.SMTPPort)
+	cmd.Flag("smtp-username", "SMTP username").StringVar(&c.opt.SMTPUsername)
+	cmd.Flag("smtp-password", "SMTP password, stored only in the local configuration of this client").Envar("KOPIA_SMTP_PASSWORD").StringVar(&c.opt.SMTPPassword)
+	cmd.Flag("from", "Sender email address").Required().StringVar(&c.opt.From)
+	cmd.Flag("to", "Recipient email address (can be specified multiple times)").Required().StringsVar(&c.opt.To)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandNotificationConfigureEmail) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	opt := c.opt
+
+	p := c.profile()
+	p.Email = &opt
+
+	if err := saveNotificationProfile(ctx, rep, p); err != nil {
+		return err
+	}
+
+	if err := repo.SetNotificationPassword(ctx, c.svc.repositoryConfigFileName(), p.Name, opt.SMTPPassword); err != nil {
+		return errors.Wrap(err, "unable to save SMTP password")
+	}
+
+	return nil
+}
+
+func saveNotificationProfile(ctx context.Context, rep repo.RepositoryWriter, p *notification.ProfileConfig) error {
+	// validate the profile before saving it.
+	if _, err := p.Sender(); err != nil {
+		return errors.Wrap(err, "invalid notification profile")
+	}
+
+	cfg, err := notification.GetConfig(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get notification configuration")
+	}
+
+	cfg.SetProfile(p)
+
+	if err := notification.SetConfig(ctx, rep, cfg); err != nil {
+		return errors.Wrap(err, "unable to save notification configuration")
+	}
+
+	log(ctx).Infof("Notification profile %q saved.", p.Name)
+
+	return nil
+}
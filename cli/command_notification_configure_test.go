@@ -0,0 +1,119 @@
+package cli_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestNotification(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	var (
+		mu       sync.Mutex
+		received []map[string]interface{}
+		auth     []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]interface{}
+
+		json.NewDecoder(r.Body).Decode(&v)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		received = append(received, v)
+		auth = append(auth, r.Header.Get("Authorization"))
+	}))
+	defer srv.Close()
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	// no profiles.
+	env.RunAndExpectFailure(t, "notification", "test")
+
+	env.RunAndExpectSuccess(t, "notification", "configure", "webhook", "--profile=hook", "--url", srv.URL,
+		"--header", "Authorization: Bearer xyz", "--event=snapshot-failed", "--event=maintenance-failed")
+	env.RunAndExpectSuccess(t, "notification", "configure", "webhook", "--profile=templated", "--url", srv.URL,
+		"--body-template", `{"text":{{json .Subject}}}`)
+
+	// invalid profiles are rejected.
+	env.RunAndExpectFailure(t, "notification", "configure", "webhook", "--profile=bad", "--url", srv.URL, "--body-template", "{{")
+	env.RunAndExpectFailure(t, "notification", "configure", "webhook", "--profile=bad", "--url", srv.URL, "--event=no-such-event")
+
+	var profiles []map[string]string
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "notification", "list", "--json"), &profiles)
+	require.Len(t, profiles, 2)
+	require.Equal(t, "hook", profiles[0]["name"])
+	require.Equal(t, "snapshot-failed,maintenance-failed", profiles[0]["events"])
+	require.Equal(t, "all", profiles[1]["events"])
+
+	env.RunAndExpectSuccess(t, "notification", "test", "--profile=hook")
+	require.Len(t, received, 1)
+	require.Equal(t, "test", received[0]["type"])
+	require.Equal(t, "Bearer xyz", auth[0])
+
+	// webhook headers are only stored in the local configuration.
+	lc, err := repo.LoadConfigFromFile(filepath.Join(env.ConfigDir, ".kopia.config"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]map[string]string{"hook": {"Authorization": "Bearer xyz"}}, lc.NotificationHeaders)
+
+	env.RunAndExpectSuccess(t, "notification", "test")
+	require.Len(t, received, 3)
+	require.Equal(t, map[string]interface{}{"text": "Test notification"}, received[2])
+
+	env.RunAndExpectFailure(t, "notification", "test", "--profile=no-such-profile")
+
+	env.RunAndExpectSuccess(t, "notification", "set", "--min-free-space-mb=100")
+	env.RunAndExpectFailure(t, "notification", "set")
+
+	env.RunAndExpectSuccess(t, "notification", "delete", "--profile=templated")
+	env.RunAndExpectFailure(t, "notification", "delete", "--profile=templated")
+	env.RunAndExpectSuccess(t, "notification", "list")
+
+	// SMTP password is only stored in the local configuration.
+	env.RunAndExpectSuccess(t, "notification", "configure", "email", "--profile=mail", "--smtp-server=localhost",
+		"--smtp-username=user", "--smtp-password=smtp-secret", "--from=kopia@example.com", "--to=admin@example.com")
+
+	lc, err = repo.LoadConfigFromFile(filepath.Join(env.ConfigDir, ".kopia.config"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"mail": "smtp-secret"}, lc.NotificationPasswords)
+
+	var manifests []map[string]interface{}
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "manifest", "list", "--filter=type:notifications", "--json"), &manifests)
+	require.Len(t, manifests, 1)
+
+	manifestJSON := strings.Join(env.RunAndExpectSuccess(t, "manifest", "show", manifests[0]["id"].(string)), "\n")
+	require.NotContains(t, manifestJSON, "smtp-secret")
+	require.NotContains(t, manifestJSON, "Bearer xyz")
+
+	env.RunAndExpectSuccess(t, "notification", "delete", "--profile=mail")
+
+	lc, err = repo.LoadConfigFromFile(filepath.Join(env.ConfigDir, ".kopia.config"))
+	require.NoError(t, err)
+	require.Empty(t, lc.NotificationPasswords)
+
+	env.RunAndExpectSuccess(t, "notification", "delete", "--profile=hook")
+
+	lc, err = repo.LoadConfigFromFile(filepath.Join(env.ConfigDir, ".kopia.config"))
+	require.NoError(t, err)
+	require.Empty(t, lc.NotificationHeaders)
+
+	// unreachable endpoint fails the test.
+	env.RunAndExpectSuccess(t, "notification", "configure", "webhook", "--profile=hook", "--url", srv.URL)
+	srv.Close()
+	env.RunAndExpectFailure(t, "notification", "test")
+}
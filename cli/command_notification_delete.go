@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandNotificationDelete struct {
+	profileName string
+
+	svc appServices
+}
+
+func (c *commandNotificationDelete) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("delete", "Delete notification profile.").Alias("remove").Alias("rm")
+	cmd.Flag("profile", "Name of the notification profile").Required().StringVar(&c.profileName)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandNotificationDelete) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	cfg, err := notification.GetConfig(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get notification configuration")
+	}
+
+	if !cfg.RemoveProfile(c.profileName) {
+		return errors.Errorf("notification profile %q not found", c.profileName)
+	}
+
+	if err := notification.SetConfig(ctx, rep, cfg); err != nil {
+		return errors.Wrap(err, "unable to save notification configuration")
+	}
+
+	if err := repo.SetNotificationPassword(ctx, c.svc.repositoryConfigFileName(), c.profileName, ""); err != nil {
+		return errors.Wrap(err, "unable to remove SMTP password")
+	}
+
+	return errors.Wrap(repo.SetNotificationHeaders(ctx, c.svc.repositoryConfigFileName(), c.profileName, nil), "unable to remove webhook headers")
+}
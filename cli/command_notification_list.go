@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandNotificationList struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandNotificationList) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("list", "List notification profiles.").Alias("ls")
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandNotificationList) run(ctx context.Context, rep repo.Repository) error {
+	cfg, err := notification.GetConfig(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get notification configuration")
+	}
+
+	if c.jo.jsonOutput {
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		for _, p := range cfg.Profiles {
+			jl.emit(notificationProfileSummary(p))
+		}
+
+		return nil
+	}
+
+	for _, p := range cfg.Profiles {
+		s := notificationProfileSummary(p)
+		c.out.printStdout("%-20v %-8v %v events:%v\n", s.Name, s.Type, s.Destination, s.Events)
+	}
+
+	if cfg.MinFreeSpace > 0 {
+		c.out.printStdout("Minimum free space: %v\n", units.BytesStringBase10(cfg.MinFreeSpace))
+	}
+
+	return nil
+}
+
+// notificationProfileInfo is a summary of a notification profile that does not include secrets.
+type notificationProfileInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Destination string `json:"destination"`
+	Events      string `json:"events"`
+}
+
+func notificationProfileSummary(p *notification.ProfileConfig) notificationProfileInfo {
+	s := notificationProfileInfo{
+		Name:   p.Name,
+		Type:   p.Type(),
+		Events: "all",
+	}
+
+	if len(p.Events) > 0 {
+		var ev []string
+		for _, e := range p.Events {
+			ev = append(ev, string(e))
+		}
+
+		s.Events = strings.Join(ev, ",")
+	}
+
+	switch {
+	case p.Webhook != nil:
+		s.Destination = p.Webhook.URL
+	case p.Email != nil:
+		s.Destination = strings.Join(p.Email.To, ",")
+	}
+
+	return s
+}
@@ -0,0 +1,42 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandNotificationSet struct {
+	minFreeSpaceMB int64
+}
+
+func (c *commandNotificationSet) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set", "Set notification parameters.")
+	cmd.Flag("min-free-space-mb", "Send notification when free space on local volumes used by the repository drops below the provided size (0 disables)").Default("-1").Int64Var(&c.minFreeSpaceMB)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandNotificationSet) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	cfg, err := notification.GetConfig(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get notification configuration")
+	}
+
+	if c.minFreeSpaceMB == -1 {
+		return errors.Errorf("no changes specified")
+	}
+
+	cfg.MinFreeSpace = c.minFreeSpaceMB << 20 // nolint:gomnd
+
+	if cfg.MinFreeSpace > 0 {
+		log(ctx).Infof("Setting minimum free space to %v.", units.BytesStringBase2(cfg.MinFreeSpace))
+	} else {
+		log(ctx).Infof("Disabling low free space notifications.")
+	}
+
+	return errors.Wrap(notification.SetConfig(ctx, rep, cfg), "unable to save notification configuration")
+}
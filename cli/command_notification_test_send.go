@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/repo"
+)
+
+type commandNotificationTest struct {
+	profileName string
+
+	out textOutput
+	svc appServices
+}
+
+func (c *commandNotificationTest) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("test", "Send test notification.")
+	cmd.Flag("profile", "Name of the notification profile to test, defaults to all profiles").StringVar(&c.profileName)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandNotificationTest) run(ctx context.Context, rep repo.Repository) error {
+	cfg, err := notification.GetConfig(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get notification configuration")
+	}
+
+	profiles := cfg.Profiles
+
+	if c.profileName != "" {
+		p := cfg.FindProfile(c.profileName)
+		if p == nil {
+			return errors.Errorf("notification profile %q not found", c.profileName)
+		}
+
+		profiles = []*notification.ProfileConfig{p}
+	}
+
+	if len(profiles) == 0 {
+		return errors.Errorf("no notification profiles configured")
+	}
+
+	var failed int
+
+	for _, p := range profiles {
+		ev := notification.NewEvent(notification.EventTest, "Test notification")
+		ev.Message = "This is a test notification from Kopia."
+		ev.Hostname = rep.ClientOptions().Hostname
+		ev.Details["Profile"] = p.Name
+
+		if err := notification.SendToProfile(ctx, c.svc.repositoryConfigFileName(), p, ev); err != nil {
+			log(ctx).Errorf("%v", err)
+
+			failed++
+
+			continue
+		}
+
+		c.out.printStdout("Sent test notification to %q.\n", p.Name)
+	}
+
+	if failed > 0 {
+		return errors.Errorf("failed to send %v test notifications", failed)
+	}
+
+	return nil
+}
@@ -41,13 +41,17 @@
   #   "noParentDotFiles": true
   #   "noParentIgnore": true
   #   "oneFileSystem": false
+  #   "extendedAttributes": false
+  #   "specialFiles": false
 `
 
 const policyEditSchedulingHelpText = `
   # Snapshot scheduling options. Options include:
   #   "intervalSeconds": number /* 86400-day, 3600-hour, 60-minute */
   #   "timeOfDay": [{"hour":H,"min":M},{"hour":H,"min":M}]
-  #   "manual": false /* Only create snapshots manually if set to true. NOTE: cannot be used with the above two fields */
+  #   "cron": ["minute hour day-of-month month day-of-week"] /* e.g. "0 2 * * mon-fri" */
+  #   "runMissed": false /* Run missed time-of-day or cron snapshots shortly after startup or resume */
+  #   "manual": false /* Only create snapshots manually if set to true. NOTE: cannot be used with the above fields */
 `
 
 type commandPolicyEdit struct {
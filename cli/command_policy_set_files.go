@@ -25,6 +25,12 @@ type policyFilesFlags struct {
 	policyOneFileSystem string
 
 	policyIgnoreCacheDirs string
+
+	// Capture extended attributes and ACLs.
+	policyExtendedAttributes string
+
+	// Capture device nodes, named pipes and sockets.
+	policySpecialFiles string
 }
 
 func (c *policyFilesFlags) setup(cmd *kingpin.CmdClause) {
@@ -43,6 +49,12 @@ func (c *policyFilesFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("one-file-system", "Stay in parent filesystem when finding files ('true', 'false', 'inherit')").EnumVar(&c.policyOneFileSystem, booleanEnumValues...)
 
 	cmd.Flag("ignore-cache-dirs", "Ignore cache directories ('true', 'false', 'inherit')").EnumVar(&c.policyIgnoreCacheDirs, booleanEnumValues...)
+
+	// Capture extended attributes and ACLs.
+	cmd.Flag("extended-attributes", "Include extended attributes and ACLs in snapshots ('true', 'false', 'inherit')").EnumVar(&c.policyExtendedAttributes, booleanEnumValues...)
+
+	// Capture device nodes, named pipes and sockets.
+	cmd.Flag("special-files", "Include device nodes, named pipes and sockets in snapshots ('true', 'false', 'inherit')").EnumVar(&c.policySpecialFiles, booleanEnumValues...)
 }
 
 func (c *policyFilesFlags) setFilesPolicyFromFlags(ctx context.Context, fp *policy.FilesPolicy, changeCount *int) error {
@@ -57,5 +69,13 @@ func (c *policyFilesFlags) setFilesPolicyFromFlags(ctx context.Context, fp *poli
 		return err
 	}
 
-	return applyPolicyBoolPtr(ctx, "one filesystem", &fp.OneFileSystem, c.policyOneFileSystem, changeCount)
+	if err := applyPolicyBoolPtr(ctx, "one filesystem", &fp.OneFileSystem, c.policyOneFileSystem, changeCount); err != nil {
+		return err
+	}
+
+	if err := applyPolicyBoolPtr(ctx, "extended attributes", &fp.ExtendedAttributes, c.policyExtendedAttributes, changeCount); err != nil {
+		return err
+	}
+
+	return applyPolicyBoolPtr(ctx, "special files", &fp.SpecialFiles, c.policySpecialFiles, changeCount)
 }
@@ -2,8 +2,10 @@
 
 import (
 	"context"
+	"sort"
 
 	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/snapshot/policy"
 )
@@ -15,6 +17,11 @@ type policyRetentionFlags struct {
 	policySetKeepWeekly  string
 	policySetKeepMonthly string
 	policySetKeepAnnual  string
+	policySetKeepWithin  string
+
+	policySetAddKeepTag    []string
+	policySetRemoveKeepTag []string
+	policySetClearKeepTags bool
 }
 
 func (c *policyRetentionFlags) setup(cmd *kingpin.CmdClause) {
@@ -24,6 +31,10 @@ func (c *policyRetentionFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("keep-weekly", "Number of most-recent weekly backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepWeekly)
 	cmd.Flag("keep-monthly", "Number of most-recent monthly backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepMonthly)
 	cmd.Flag("keep-annual", "Number of most-recent annual backups to keep per source (or 'inherit')").PlaceHolder("N").StringVar(&c.policySetKeepAnnual)
+	cmd.Flag("keep-within", "Keep all backups taken within given duration before the latest one, such as 14d (or 'inherit')").PlaceHolder("DURATION").StringVar(&c.policySetKeepWithin)
+	cmd.Flag("add-keep-tag", "Keep backups with matching tag, optionally only those taken within given duration before the latest one, such as release:*=2y").PlaceHolder("KEY:PATTERN[=DURATION]").StringsVar(&c.policySetAddKeepTag)
+	cmd.Flag("remove-keep-tag", "Remove tag retention rule for given tag pattern").PlaceHolder("KEY:PATTERN").StringsVar(&c.policySetRemoveKeepTag)
+	cmd.Flag("clear-keep-tags", "Clear all tag retention rules").BoolVar(&c.policySetClearKeepTags)
 }
 
 func (c *policyRetentionFlags) setRetentionPolicyFromFlags(ctx context.Context, rp *policy.RetentionPolicy, changeCount *int) error {
@@ -46,5 +57,88 @@ func (c *policyRetentionFlags) setRetentionPolicyFromFlags(ctx context.Context,
 		}
 	}
 
+	if err := applyPolicyRetentionDuration(ctx, "duration of backups to keep", &rp.KeepWithin, c.policySetKeepWithin, changeCount); err != nil {
+		return err
+	}
+
+	return applyTagRetentionRules(ctx, &rp.KeepTags, c.policySetAddKeepTag, c.policySetRemoveKeepTag, c.policySetClearKeepTags, changeCount)
+}
+
+func applyPolicyRetentionDuration(ctx context.Context, desc string, val **policy.RetentionDuration, str string, changeCount *int) error {
+	if str == "" {
+		// not changed
+		return nil
+	}
+
+	if str == inheritPolicyString || str == defaultPolicyString {
+		*changeCount++
+
+		log(ctx).Infof(" - resetting %q to a default value inherited from parent.\n", desc)
+
+		*val = nil
+
+		return nil
+	}
+
+	d, err := policy.ParseRetentionDuration(str)
+	if err != nil {
+		return errors.Wrapf(err, "can't parse the %v %q", desc, str)
+	}
+
+	*changeCount++
+
+	log(ctx).Infof(" - setting %q to %v.\n", desc, d)
+	*val = &d
+
+	return nil
+}
+
+func applyTagRetentionRules(ctx context.Context, val *[]policy.TagRetentionRule, add, remove []string, clear bool, changeCount *int) error {
+	if clear {
+		log(ctx).Infof(" - removing all tag retention rules\n")
+
+		*changeCount++
+
+		*val = nil
+
+		return nil
+	}
+
+	rules := map[string]policy.TagRetentionRule{}
+	for _, r := range *val {
+		rules[r.Tag] = r
+	}
+
+	for _, s := range add {
+		r, err := policy.ParseTagRetentionRule(s)
+		if err != nil {
+			return errors.Wrap(err, "invalid tag retention rule")
+		}
+
+		*changeCount++
+
+		log(ctx).Infof(" - adding tag retention rule %q\n", r)
+
+		rules[r.Tag] = r
+	}
+
+	for _, s := range remove {
+		*changeCount++
+
+		log(ctx).Infof(" - removing tag retention rule for %q\n", s)
+		delete(rules, s)
+	}
+
+	var result []policy.TagRetentionRule
+	for _, r := range rules {
+		result = append(result, r)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Tag < result[j].Tag
+	})
+
+	*val = result
+
 	return nil
 }
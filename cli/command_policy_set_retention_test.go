@@ -0,0 +1,51 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestPolicySetRetentionByTagAndDuration(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--tags=release:v1")
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	env.RunAndExpectSuccess(t, "policy", "set", dir, "--keep-latest=1", "--keep-hourly=0", "--keep-daily=0",
+		"--keep-weekly=0", "--keep-monthly=0", "--keep-annual=0", "--add-keep-tag=release:*=2y", "--keep-within=0")
+
+	lines := env.RunAndExpectSuccess(t, "policy", "show", dir)
+	require.Contains(t, strings.Join(lines, "\n"), "release:*=2y")
+
+	env.RunAndExpectFailure(t, "policy", "set", dir, "--add-keep-tag=release")
+	env.RunAndExpectFailure(t, "policy", "set", dir, "--keep-within=2x")
+
+	env.RunAndExpectSuccess(t, "snapshot", "expire", dir, "--delete")
+
+	var manifests []*snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Len(t, manifests, 2)
+
+	// keeping all snapshots within a day retains everything.
+	env.RunAndExpectSuccess(t, "policy", "set", dir, "--keep-within=1d", "--remove-keep-tag=release:*")
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	lines = env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--retention")
+	require.Contains(t, strings.Join(lines, "\n"), "within-1d")
+
+	env.RunAndExpectSuccess(t, "snapshot", "expire", dir, "--delete")
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Len(t, manifests, 3)
+}
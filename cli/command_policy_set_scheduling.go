@@ -8,18 +8,23 @@
 	"github.com/alecthomas/kingpin"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/cron"
 	"github.com/kopia/kopia/snapshot/policy"
 )
 
 type policySchedulingFlags struct {
 	policySetInterval   []time.Duration // not a list, just optional duration
 	policySetTimesOfDay []string
+	policySetCron       []string
+	policySetRunMissed  string
 	policySetManual     bool
 }
 
 func (c *policySchedulingFlags) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("snapshot-interval", "Interval between snapshots").DurationListVar(&c.policySetInterval)
 	cmd.Flag("snapshot-time", "Times of day when to take snapshot (HH:mm)").StringsVar(&c.policySetTimesOfDay)
+	cmd.Flag("snapshot-schedule-cron", "Cron expression describing when to take snapshots, e.g. '0 2 * * mon-fri' (can be repeated)").StringsVar(&c.policySetCron)
+	cmd.Flag("run-missed", "Run missed time-of-day or cron snapshots shortly after startup or resume ('true', 'false', 'inherit')").EnumVar(&c.policySetRunMissed, booleanEnumValues...)
 	cmd.Flag("manual", "Only create snapshots manually").BoolVar(&c.policySetManual)
 }
 
@@ -71,6 +76,37 @@ func (c *policySchedulingFlags) setScheduleFromFlags(ctx context.Context, sp *po
 		}
 	}
 
+	if len(c.policySetCron) > 0 {
+		var expressions []string
+
+		for _, expr := range c.policySetCron {
+			if expr == inheritPolicyString {
+				expressions = nil
+				break
+			}
+
+			if _, err := cron.Parse(expr); err != nil {
+				return errors.Wrap(err, "unable to parse cron expression")
+			}
+
+			expressions = append(expressions, expr)
+		}
+
+		*changeCount++
+
+		sp.Cron = expressions
+
+		if expressions == nil {
+			log(ctx).Infof(" - resetting snapshot cron expressions to default\n")
+		} else {
+			log(ctx).Infof(" - setting snapshot cron expressions to %q\n", expressions)
+		}
+	}
+
+	if err := applyPolicyBoolPtr(ctx, "run missed snapshots", &sp.RunMissed, c.policySetRunMissed, changeCount); err != nil {
+		return errors.Wrap(err, "run missed snapshots")
+	}
+
 	if sp.Manual {
 		*changeCount++
 
@@ -84,7 +120,7 @@ func (c *policySchedulingFlags) setScheduleFromFlags(ctx context.Context, sp *po
 
 func (c *policySchedulingFlags) setManualFromFlags(ctx context.Context, sp *policy.SchedulingPolicy, changeCount *int) error {
 	// Cannot set both schedule and manual setting
-	if len(c.policySetInterval) > 0 || len(c.policySetTimesOfDay) > 0 {
+	if len(c.policySetInterval) > 0 || len(c.policySetTimesOfDay) > 0 || len(c.policySetCron) > 0 || c.policySetRunMissed != "" {
 		return errors.New("cannot set manual field when scheduling snapshots")
 	}
 
@@ -105,6 +141,22 @@ func (c *policySchedulingFlags) setManualFromFlags(ctx context.Context, sp *poli
 		log(ctx).Infof(" - resetting snapshot times of day to default\n")
 	}
 
+	if len(sp.Cron) > 0 {
+		*changeCount++
+
+		sp.Cron = nil
+
+		log(ctx).Infof(" - resetting snapshot cron expressions to default\n")
+	}
+
+	if sp.RunMissed != nil {
+		*changeCount++
+
+		sp.RunMissed = nil
+
+		log(ctx).Infof(" - resetting run missed snapshots to default\n")
+	}
+
 	*changeCount++
 
 	sp.Manual = c.policySetManual
@@ -4,9 +4,11 @@
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
@@ -44,13 +46,41 @@ func (c *commandPolicyShow) run(ctx context.Context, rep repo.Repository) error
 		if c.jo.jsonOutput {
 			c.out.printStdout("%s\n", c.jo.jsonBytes(effective))
 		} else {
-			printPolicy(&c.out, effective, policies)
+			lastSnapshotTime, err := lastSnapshotStartTime(ctx, rep, target)
+			if err != nil {
+				return err
+			}
+
+			printPolicy(&c.out, effective, policies, lastSnapshotTime)
 		}
 	}
 
 	return nil
 }
 
+// lastSnapshotStartTime returns the start time of the most recent snapshot of the provided source
+// or zero time if there are none or the target is not a snapshot source.
+func lastSnapshotStartTime(ctx context.Context, rep repo.Repository, target snapshot.SourceInfo) (time.Time, error) {
+	if target.Path == "" {
+		return time.Time{}, nil
+	}
+
+	snapshots, err := snapshot.ListSnapshots(ctx, rep, target)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "unable to list snapshots of %v", target)
+	}
+
+	var result time.Time
+
+	for _, m := range snapshots {
+		if m.StartTime.After(result) {
+			result = m.StartTime
+		}
+	}
+
+	return result, nil
+}
+
 func getDefinitionPoint(target snapshot.SourceInfo, parents []*policy.Policy, match func(p *policy.Policy) bool) string {
 	for _, p := range parents {
 		if match(p) {
@@ -79,7 +109,7 @@ func containsString(s []string, v string) bool {
 	return false
 }
 
-func printPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
+func printPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy, lastSnapshotTime time.Time) {
 	out.printStdout("Policy for %v:\n\n", p.Target())
 
 	printRetentionPolicy(out, p, parents)
@@ -88,7 +118,7 @@ func printPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
 	out.printStdout("\n")
 	printErrorHandlingPolicy(out, p, parents)
 	out.printStdout("\n")
-	printSchedulingPolicy(out, p, parents)
+	printSchedulingPolicy(out, p, parents, lastSnapshotTime)
 	out.printStdout("\n")
 	printCompressionPolicy(out, p, parents)
 	out.printStdout("\n")
@@ -127,6 +157,31 @@ func printRetentionPolicy(out *textOutput, p *policy.Policy, parents []*policy.P
 		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
 			return pol.RetentionPolicy.KeepLatest != nil
 		}))
+
+	if kw := p.RetentionPolicy.KeepWithin; kw != nil {
+		out.printStdout("  Keep within:       %3v           %v\n",
+			kw,
+			getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+				return pol.RetentionPolicy.KeepWithin != nil
+			}))
+	}
+
+	if len(p.RetentionPolicy.KeepTags) > 0 {
+		out.printStdout("  Keep tagged:\n")
+	}
+
+	for _, tr := range p.RetentionPolicy.KeepTags {
+		tr := tr
+		out.printStdout("    %-30v %v\n", tr, getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			for _, r := range pol.RetentionPolicy.KeepTags {
+				if r.Tag == tr.Tag {
+					return true
+				}
+			}
+
+			return false
+		}))
+	}
 }
 
 func printFilesPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
@@ -175,6 +230,18 @@ func printFilesPolicy(out *textOutput, p *policy.Policy, parents []*policy.Polic
 		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
 			return pol.FilesPolicy.OneFileSystem != nil
 		}))
+
+	out.printStdout("  Extended attributes and ACLs:   %5v       %v\n",
+		p.FilesPolicy.ExtendedAttributesOrDefault(false),
+		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.ExtendedAttributes != nil
+		}))
+
+	out.printStdout("  Special files:                  %5v       %v\n",
+		p.FilesPolicy.SpecialFilesOrDefault(false),
+		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.FilesPolicy.SpecialFiles != nil
+		}))
 }
 
 func printErrorHandlingPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
@@ -199,7 +266,7 @@ func printErrorHandlingPolicy(out *textOutput, p *policy.Policy, parents []*poli
 		}))
 }
 
-func printSchedulingPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy) {
+func printSchedulingPolicy(out *textOutput, p *policy.Policy, parents []*policy.Policy, lastSnapshotTime time.Time) {
 	out.printStdout("Scheduling policy:\n")
 
 	any := false
@@ -233,10 +300,33 @@ func printSchedulingPolicy(out *textOutput, p *policy.Policy, parents []*policy.
 		any = true
 	}
 
+	if len(p.SchedulingPolicy.Cron) > 0 {
+		out.printStdout("    Cron expressions:\n")
+
+		for _, expr := range p.SchedulingPolicy.Cron {
+			expr := expr
+			out.printStdout("      %-30v %v\n", expr, getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+				return containsString(pol.SchedulingPolicy.Cron, expr)
+			}))
+		}
+
+		any = true
+	}
+
 	if !any {
 		out.printStdout("    None\n")
 	}
 
+	out.printStdout("  Run missed snapshots:      %5v   %v\n",
+		p.SchedulingPolicy.RunMissedOrDefault(false),
+		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
+			return pol.SchedulingPolicy.RunMissed != nil
+		}))
+
+	if nt, ok := p.SchedulingPolicy.NextSnapshotTime(lastSnapshotTime, clock.Now()); ok && !p.SchedulingPolicy.Manual {
+		out.printStdout("  Next snapshot:             %v\n", formatTimestamp(nt))
+	}
+
 	out.printStdout("  Manual snapshot:           %5v   %v\n",
 		p.SchedulingPolicy.Manual,
 		getDefinitionPoint(p.Target(), parents, func(pol *policy.Policy) bool {
@@ -0,0 +1,31 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestPolicyShowNextSnapshotTime(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+	env.RunAndExpectSuccess(t, "policy", "set", dir, "--snapshot-schedule-cron=0 2 * * *")
+
+	lines := env.RunAndExpectSuccess(t, "policy", "show", dir)
+	require.Contains(t, strings.Join(lines, "\n"), "Next snapshot:")
+
+	// manual snapshots are never scheduled.
+	env.RunAndExpectSuccess(t, "policy", "set", dir, "--manual")
+
+	lines = env.RunAndExpectSuccess(t, "policy", "show", dir)
+	require.NotContains(t, strings.Join(lines, "\n"), "Next snapshot:")
+}
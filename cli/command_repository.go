@@ -8,8 +8,11 @@ type commandRepository struct {
 	setClient        commandRepositorySetClient
 	setParameters    commandRepositorySetParameters
 	changePassword   commandRepositoryChangePassword
+	key              commandRepositoryKey
+	rotateKey        commandRepositoryRotateKey
 	status           commandRepositoryStatus
 	syncTo           commandRepositorySyncTo
+	throttle         commandRepositoryThrottle
 	validateProvider commandRepositoryValidateProvider
 }
 
@@ -24,6 +27,9 @@ func (c *commandRepository) setup(svc advancedAppServices, parent commandParent)
 	c.setParameters.setup(svc, cmd)
 	c.status.setup(svc, cmd)
 	c.syncTo.setup(svc, cmd)
+	c.throttle.setup(svc, cmd)
 	c.changePassword.setup(svc, cmd)
+	c.key.setup(svc, cmd)
+	c.rotateKey.setup(svc, cmd)
 	c.validateProvider.setup(svc, cmd)
 }
@@ -11,18 +11,31 @@
 type commandRepositoryChangePassword struct {
 	newPassword string
 
+	kdf keyDerivationFlags
 	svc advancedAppServices
 }
 
 func (c *commandRepositoryChangePassword) setup(svc advancedAppServices, parent commandParent) {
 	cmd := parent.Command("change-password", "Change repository password")
 	cmd.Flag("new-password", "New password").Envar("KOPIA_NEW_PASSWORD").StringVar(&c.newPassword)
+	c.kdf.setup(cmd)
 
 	c.svc = svc
 	cmd.Action(svc.directRepositoryWriteAction(c.run))
 }
 
 func (c *commandRepositoryChangePassword) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	var kd *repo.KeyDerivationOptions
+
+	if c.kdf.specified() {
+		opt, err := c.kdf.keyDerivationOptions()
+		if err != nil {
+			return err
+		}
+
+		kd = &opt
+	}
+
 	var newPass string
 
 	if c.newPassword == "" {
@@ -36,7 +49,7 @@ func (c *commandRepositoryChangePassword) run(ctx context.Context, rep repo.Dire
 		newPass = c.newPassword
 	}
 
-	if err := rep.ChangePassword(ctx, newPass); err != nil {
+	if err := rep.ChangePasswordWithKeyDerivation(ctx, newPass, kd); err != nil {
 		return errors.Wrap(err, "unable to change password")
 	}
 
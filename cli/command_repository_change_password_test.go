@@ -1,8 +1,12 @@
 package cli_test
 
 import (
+	"io/ioutil"
+	"path/filepath"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+
 	"github.com/kopia/kopia/tests/testenv"
 )
 
@@ -44,3 +48,52 @@ func TestRepositoryChangePassword_LegacDisallowed(t *testing.T) {
 	env1.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache", "--no-enable-password-change")
 	env1.RunAndExpectFailure(t, "repo", "change-password", "--new-password", "newPass")
 }
+
+func TestRepositoryChangePassword_MigrateToArgon2id(t *testing.T) {
+	r1 := testenv.NewInProcRunner(t)
+	env1 := testenv.NewCLITest(t, r1)
+
+	env1.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env1.RepoDir, "--key-derivation=argon2id", "--argon2-threads=0")
+	env1.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+	require.NotContains(t, readFormatBlobFile(t, env1.RepoDir), "argon2id")
+
+	env1.RunAndExpectFailure(t, "repo", "change-password", "--new-password", "newPass", "--argon2-time=1")
+	env1.RunAndExpectSuccess(t, "repo", "change-password", "--new-password", "newPass",
+		"--key-derivation=argon2id", "--argon2-time=1", "--argon2-memory-mb=1", "--argon2-threads=1")
+	require.Contains(t, readFormatBlobFile(t, env1.RepoDir), "argon2id")
+
+	r2 := testenv.NewInProcRunner(t)
+	r2.RepoPassword = "newPass"
+
+	env2 := testenv.NewCLITest(t, r2)
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+	env2.RunAndExpectSuccess(t, "snapshot", "ls")
+}
+
+func TestRepositoryCreate_Argon2id(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	// parameters out of range or without the algorithm.
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--key-derivation=argon2id", "--argon2-memory-mb=1025")
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--key-derivation=argon2id", "--argon2-memory-mb=4194305")
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--key-derivation=argon2id", "--argon2-time=49")
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--argon2-time=1")
+	env.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--key-derivation=scrypt-65536-8-1", "--argon2-threads=1")
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir,
+		"--key-derivation=argon2id", "--argon2-time=1", "--argon2-memory-mb=1", "--argon2-threads=1")
+	require.Contains(t, readFormatBlobFile(t, env.RepoDir), `"keyAlgo": "argon2id"`)
+
+	env.RunAndExpectSuccess(t, "repo", "disconnect")
+	env.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "snapshot", "ls")
+}
+
+func readFormatBlobFile(t *testing.T, repoDir string) string {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(filepath.Join(repoDir, "kopia.repository.f"))
+	require.NoError(t, err)
+
+	return string(b)
+}
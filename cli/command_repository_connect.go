@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"path/filepath"
 	"time"
 
 	"github.com/alecthomas/kingpin"
@@ -14,7 +15,8 @@
 )
 
 type commandRepositoryConnect struct {
-	co connectOptions
+	co          connectOptions
+	pointInTime string
 
 	server commandRepositoryConnectServer
 }
@@ -25,6 +27,8 @@ func (c *commandRepositoryConnect) setup(svc advancedAppServices, parent command
 	c.co.setup(cmd)
 	c.server.setup(svc, cmd, &c.co)
 
+	cmd.Flag("point-in-time", "Connect to a read-only view of the repository as it existed at the provided time (requires storage with object versioning)").PlaceHolder(time.RFC3339).StringVar(&c.pointInTime)
+
 	for _, prov := range storageProviders {
 		// Set up 'connect' subcommand
 		f := prov.newFlags()
@@ -32,6 +36,11 @@ func (c *commandRepositoryConnect) setup(svc advancedAppServices, parent command
 		f.setup(svc, cc)
 		cc.Action(func(_ *kingpin.ParseContext) error {
 			ctx := svc.rootContext()
+
+			if err := c.applyPointInTime(f); err != nil {
+				return err
+			}
+
 			st, err := f.connect(ctx, false)
 			if err != nil {
 				return errors.Wrap(err, "can't connect to storage")
@@ -43,6 +52,30 @@ func (c *commandRepositoryConnect) setup(svc advancedAppServices, parent command
 	}
 }
 
+// applyPointInTime configures the storage flags to connect to a point-in-time view of the storage, if requested.
+func (c *commandRepositoryConnect) applyPointInTime(f storageFlags) error {
+	if c.pointInTime == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, c.pointInTime)
+	if err != nil {
+		return errors.Wrap(err, "invalid point in time")
+	}
+
+	pf, ok := f.(pointInTimeStorageFlags)
+	if !ok {
+		return errors.Errorf("point-in-time view is not supported by this storage type")
+	}
+
+	pf.setPointInTime(t)
+
+	// point-in-time views are always read-only.
+	c.co.connectReadonly = true
+
+	return nil
+}
+
 type connectOptions struct {
 	connectCacheDirectory         string
 	connectMaxCacheSizeMB         int64
@@ -54,6 +87,7 @@ type connectOptions struct {
 	connectReadonly               bool
 	connectDescription            string
 	connectEnableActions          bool
+	privateKeyFile                string
 
 	formatBlobCacheDuration time.Duration
 	disableFormatBlobCache  bool
@@ -72,6 +106,7 @@ func (c *connectOptions) setup(cmd *kingpin.CmdClause) {
 	cmd.Flag("readonly", "Make repository read-only to avoid accidental changes").BoolVar(&c.connectReadonly)
 	cmd.Flag("description", "Human-readable description of the repository").StringVar(&c.connectDescription)
 	cmd.Flag("enable-actions", "Allow snapshot actions").BoolVar(&c.connectEnableActions)
+	cmd.Flag("private-key-file", "Private key file used to decrypt contents encrypted with public key. Clients connected without it can't read file contents and directory listings. Connect using the password of a write-only key slot to also withhold the master key.").PlaceHolder("PATH").StringVar(&c.privateKeyFile)
 	cmd.Flag("repository-format-cache-duration", "Duration of kopia.repository format blob cache").Hidden().DurationVar(&c.formatBlobCacheDuration)
 	cmd.Flag("disable-repository-format-cache", "Disable caching of kopia.repository format blob").Hidden().BoolVar(&c.disableFormatBlobCache)
 }
@@ -99,10 +134,25 @@ func (c *connectOptions) toRepoConnectOptions() *repo.ConnectOptions {
 			Description:             c.connectDescription,
 			EnableActions:           c.connectEnableActions,
 			FormatBlobCacheDuration: c.getFormatBlobCacheDuration(),
+			PrivateKeyFile:          absolutePathOrEmpty(c.privateKeyFile),
 		},
 	}
 }
 
+// absolutePathOrEmpty returns the absolute path of the provided file, so that it does not depend on
+// the working directory of future invocations.
+func absolutePathOrEmpty(fname string) string {
+	if fname == "" {
+		return ""
+	}
+
+	if p, err := filepath.Abs(fname); err == nil {
+		return p
+	}
+
+	return fname
+}
+
 func (c *App) runConnectCommandWithStorage(ctx context.Context, co *connectOptions, st blob.Storage) error {
 	pass, err := c.getPasswordFromFlags(ctx, false, false)
 	if err != nil {
@@ -114,8 +164,17 @@ func (c *App) runConnectCommandWithStorage(ctx context.Context, co *connectOptio
 
 func (c *App) runConnectCommandWithStorageAndPassword(ctx context.Context, co *connectOptions, st blob.Storage, password string) error {
 	configFile := c.repositoryConfigFileName()
+
+	pp, err := c.passwordProviderConfig()
+	if err != nil {
+		return err
+	}
+
+	opt := co.toRepoConnectOptions()
+	opt.PasswordProvider = pp
+
 	if err := passwordpersist.OnSuccess(
-		ctx, repo.Connect(ctx, configFile, st, password, co.toRepoConnectOptions()),
+		ctx, repo.Connect(ctx, configFile, st, password, opt),
 		c.passwordPersistenceStrategy(), configFile, password); err != nil {
 		return errors.Wrap(err, "error connecting to repository")
 	}
@@ -41,7 +41,13 @@ func (c *commandRepositoryConnectServer) run(ctx context.Context) error {
 	}
 
 	configFile := c.svc.repositoryConfigFileName()
+	pp, err := c.svc.passwordProviderConfig()
+	if err != nil {
+		return err
+	}
+
 	opt := c.co.toRepoConnectOptions()
+	opt.PasswordProvider = pp
 
 	u := opt.Username
 	if u == "" {
@@ -0,0 +1,19 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryConnectPointInTimeUnsupported(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	env.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// filesystem storage does not support point-in-time views.
+	env.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--point-in-time", "2021-01-01T00:00:00Z")
+	env.RunAndExpectFailure(t, "repo", "connect", "filesystem", "--path", env.RepoDir, "--point-in-time", "yesterday")
+	env.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env.RepoDir)
+}
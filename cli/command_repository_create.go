@@ -10,6 +10,7 @@
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/hashing"
 	"github.com/kopia/kopia/repo/object"
@@ -26,12 +27,17 @@
 type commandRepositoryCreate struct {
 	createBlockHashFormat       string
 	createBlockEncryptionFormat string
+	createECCAlgorithm          string
+	createECCOverheadPercent    int
+	createPublicKeyEncryption   string
 	createSplitter              string
+	createSparseFiles           bool
 	createOnly                  bool
 	createIndexVersion          int
 	createIndexEpochs           bool
 	enablePasswordChange        bool
 
+	kdf keyDerivationFlags
 	co  connectOptions
 	svc advancedAppServices
 	out textOutput
@@ -42,11 +48,16 @@ func (c *commandRepositoryCreate) setup(svc advancedAppServices, parent commandP
 
 	cmd.Flag("block-hash", "Content hash algorithm.").PlaceHolder("ALGO").Default(hashing.DefaultAlgorithm).EnumVar(&c.createBlockHashFormat, hashing.SupportedAlgorithms()...)
 	cmd.Flag("encryption", "Content encryption algorithm.").PlaceHolder("ALGO").Default(encryption.DefaultAlgorithm).EnumVar(&c.createBlockEncryptionFormat, encryption.SupportedAlgorithms(false)...)
+	cmd.Flag("ecc", "Error correction algorithm applied to blobs written to storage.").PlaceHolder("ALGO").Default(ecc.DefaultAlgorithm).EnumVar(&c.createECCAlgorithm, ecc.SupportedAlgorithms()...)
+	cmd.Flag("ecc-overhead-percent", "Space overhead of error correction codes, 0 disables error correction. Codes are computed for entire blobs after encryption, small blobs have higher overhead.").PlaceHolder("PERCENT").Default("0").IntVar(&c.createECCOverheadPercent)
+	cmd.Flag("public-key-encryption", "Encrypt file contents and directory listings using a public key, so that they can't be read by clients without the private key. Indexes and manifests are encrypted using a separate metadata key, so that clients connected using a write-only key slot (see 'repository key add --write-only') can create snapshots without the master key. Requires --private-key-file, where the generated private key will be stored.").PlaceHolder("ALGO").EnumVar(&c.createPublicKeyEncryption, encryption.SupportedPublicKeyAlgorithms()...)
 	cmd.Flag("object-splitter", "The splitter to use for new objects in the repository").Default(splitter.DefaultAlgorithm).EnumVar(&c.createSplitter, splitter.SupportedAlgorithms()...)
+	cmd.Flag("sparse-files", "Store holes in sparse files without writing their data. Repositories created with this option can't be opened by older versions of Kopia.").BoolVar(&c.createSparseFiles)
 	cmd.Flag("create-only", "Create repository, but don't connect to it.").Short('c').BoolVar(&c.createOnly)
 	cmd.Flag("enable-password-change", "Enable password change").Hidden().Default("true").BoolVar(&c.enablePasswordChange)
 	cmd.Flag("index-version", "Force particular index version").Hidden().Envar("KOPIA_CREATE_INDEX_VERSION").IntVar(&c.createIndexVersion)
 	cmd.Flag("enable-index-epochs", "Enable index epochs").Hidden().BoolVar(&c.createIndexEpochs)
+	c.kdf.setup(cmd)
 
 	c.co.setup(cmd)
 	c.svc = svc
@@ -81,8 +92,13 @@ func (c *commandRepositoryCreate) epochParametersFromFlags() epoch.Parameters {
 	return epoch.DefaultParameters
 }
 
-func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() *repo.NewRepositoryOptions {
-	return &repo.NewRepositoryOptions{
+func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() (*repo.NewRepositoryOptions, error) {
+	kd, err := c.kdf.keyDerivationOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	opt := &repo.NewRepositoryOptions{
 		BlockFormat: content.FormattingOptions{
 			Hash:       c.createBlockHashFormat,
 			Encryption: c.createBlockEncryptionFormat,
@@ -94,9 +110,41 @@ func (c *commandRepositoryCreate) newRepositoryOptionsFromFlags() *repo.NewRepos
 		},
 
 		ObjectFormat: object.Format{
-			Splitter: c.createSplitter,
+			Splitter:      c.createSplitter,
+			SparseObjects: c.createSparseFiles,
 		},
+
+		KeyDerivation: kd,
+	}
+
+	if c.createECCOverheadPercent > 0 {
+		opt.BlockFormat.ECC = c.createECCAlgorithm
+		opt.BlockFormat.ECCOverheadPercent = c.createECCOverheadPercent
+	}
+
+	return opt, nil
+}
+
+// generateKeyPair generates the key pair for public-key encryption, stores the private key
+// in a file and the public key in the repository options.
+func (c *commandRepositoryCreate) generateKeyPair(opt *repo.NewRepositoryOptions) error {
+	if c.co.privateKeyFile == "" {
+		return errors.Errorf("--public-key-encryption requires --private-key-file")
+	}
+
+	publicKey, privateKey, err := encryption.GenerateKeyPair(c.createPublicKeyEncryption)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate key pair")
+	}
+
+	if err := repo.WritePrivateKeyFile(c.co.privateKeyFile, c.createPublicKeyEncryption, privateKey); err != nil {
+		return errors.Wrap(err, "unable to save private key")
 	}
+
+	opt.BlockFormat.PublicKeyEncryption = c.createPublicKeyEncryption
+	opt.BlockFormat.PublicKey = publicKey
+
+	return nil
 }
 
 func (c *commandRepositoryCreate) ensureEmpty(ctx context.Context, s blob.Storage) error {
@@ -119,7 +167,10 @@ func (c *commandRepositoryCreate) runCreateCommandWithStorage(ctx context.Contex
 		return errors.Wrap(err, "unable to get repository storage")
 	}
 
-	options := c.newRepositoryOptionsFromFlags()
+	options, err := c.newRepositoryOptionsFromFlags()
+	if err != nil {
+		return err
+	}
 
 	pass, err := c.svc.getPasswordFromFlags(ctx, true, false)
 	if err != nil {
@@ -131,6 +182,24 @@ func (c *commandRepositoryCreate) runCreateCommandWithStorage(ctx context.Contex
 	log(ctx).Infof("  encryption:          %v", options.BlockFormat.Encryption)
 	log(ctx).Infof("  splitter:            %v", options.ObjectFormat.Splitter)
 
+	if options.BlockFormat.ECC != "" {
+		log(ctx).Infof("  error correction:    %v (%v%% overhead)", options.BlockFormat.ECC, options.BlockFormat.ECCOverheadPercent)
+	}
+
+	if c.createPublicKeyEncryption != "" {
+		if err := c.generateKeyPair(options); err != nil {
+			return err
+		}
+
+		log(ctx).Infof("  public key:          %v (private key saved to %v)", options.BlockFormat.PublicKeyEncryption, c.co.privateKeyFile)
+	}
+
+	if p := options.KeyDerivation.Argon2; p != nil {
+		log(ctx).Infof("  key derivation:      %v (time %v, memory %v KiB, threads %v)", options.KeyDerivation.Algorithm, p.Time, p.Memory, p.Threads)
+	} else if options.KeyDerivation.Algorithm != "" {
+		log(ctx).Infof("  key derivation:      %v", options.KeyDerivation.Algorithm)
+	}
+
 	if err := repo.Initialize(ctx, st, options, pass); err != nil {
 		return errors.Wrap(err, "cannot initialize repository")
 	}
@@ -0,0 +1,78 @@
+package cli_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo/encryption"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryCreate_PublicKeyEncryption(t *testing.T) {
+	env1 := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	keyFile := filepath.Join(testutil.TempDirectory(t), "private.key")
+
+	env1.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", env1.RepoDir,
+		"--public-key-encryption", encryption.DefaultPublicKeyAlgorithm)
+	env1.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env1.RepoDir,
+		"--public-key-encryption", encryption.DefaultPublicKeyAlgorithm, "--private-key-file", keyFile,
+		"--max-list-cache-duration=0s")
+
+	dir1 := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir1, "file1.txt"), []byte("secret data 1"), 0o600))
+
+	var snap1 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env1.RunAndExpectSuccess(t, "snapshot", "create", dir1, "--json"), &snap1)
+	env1.RunAndExpectSuccess(t, "show", string(snap1.RootObjectID()))
+
+	// add a write-only key slot and connect a client using it, without the private key.
+	env1.RunAndExpectSuccess(t, "repo", "key", "add", "--name=writer", "--write-only", "--new-password=writerPass")
+
+	r2 := testenv.NewInProcRunner(t)
+	r2.RepoPassword = "writerPass"
+	env2 := testenv.NewCLITest(t, r2)
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env1.RepoDir)
+
+	status := strings.Join(env2.RunAndExpectSuccess(t, "repo", "status"), "\n")
+	require.Contains(t, status, "no private key")
+	require.Contains(t, status, "write-only (key slot writer)")
+
+	// the client can't manage the repository.
+	env2.RunAndExpectFailure(t, "repo", "key", "add", "--name=other", "--new-password=otherPass")
+	env2.RunAndExpectFailure(t, "repo", "rotate-key")
+
+	// the client can write new snapshots and list existing ones.
+	dir2 := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir2, "file2.txt"), []byte("secret data 2"), 0o600))
+
+	var snap2 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env2.RunAndExpectSuccess(t, "snapshot", "create", dir2, "--json"), &snap2)
+	env2.RunAndExpectSuccess(t, "snapshot", "create", dir2)
+	env2.RunAndExpectSuccess(t, "snapshot", "list", "--all")
+
+	// but can't read any of them.
+	env2.RunAndExpectFailure(t, "show", string(snap1.RootObjectID()))
+	env2.RunAndExpectFailure(t, "show", string(snap2.RootObjectID()))
+
+	// full maintenance and full verification need the private key.
+	_, stderr, err := env2.Run(t, true, "maintenance", "run", "--full", "--force", "--safety=none")
+	require.Error(t, err)
+	require.Contains(t, strings.Join(stderr, "\n"), "snapshot GC must be run by a client connected with the private key")
+	env2.RunAndExpectSuccess(t, "content", "verify", "--full")
+
+	// the client with the private key can read snapshots of both clients.
+	env1.RunAndExpectSuccess(t, "show", string(snap2.RootObjectID()))
+
+	// providing the private key later allows reading.
+	env2.RunAndExpectSuccess(t, "repo", "set-client", "--private-key-file", keyFile)
+	env2.RunAndExpectSuccess(t, "show", string(snap1.RootObjectID()))
+}
@@ -22,6 +22,11 @@ func (c *commandRepositoryDisconnect) setup(svc advancedAppServices, parent comm
 func (c *commandRepositoryDisconnect) run(ctx context.Context) error {
 	c.svc.removeUpdateState()
 
+	// the password provider is needed to clear its cached password, load it before removing the configuration.
+	if err := c.svc.loadPasswordProvider(); err != nil {
+		return err
+	}
+
 	if err := repo.Disconnect(ctx, c.svc.repositoryConfigFileName()); err != nil {
 		return errors.Wrap(err, "unable to disconnect from repository")
 	}
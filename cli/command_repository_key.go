@@ -0,0 +1,15 @@
+package cli
+
+type commandRepositoryKey struct {
+	add    commandRepositoryKeyAdd
+	list   commandRepositoryKeyList
+	remove commandRepositoryKeyRemove
+}
+
+func (c *commandRepositoryKey) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("key", "Manage key slots that can be used to unlock the repository.").Alias("keys")
+
+	c.add.setup(svc, cmd)
+	c.list.setup(svc, cmd)
+	c.remove.setup(svc, cmd)
+}
@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+const (
+	recoveryKeyLength    = 20
+	recoveryKeyGroupSize = 4
+)
+
+type commandRepositoryKeyAdd struct {
+	id          string
+	description string
+	password    string
+	generate    bool
+	writeOnly   bool
+
+	kdf keyDerivationFlags
+	out textOutput
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryKeyAdd) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("add", "Add a key slot allowing the repository to be unlocked with another password. Repositories converted to key slots can't be opened by older Kopia versions.")
+	cmd.Flag("name", "Name of the key slot").StringVar(&c.id)
+	cmd.Flag("description", "Description of the key slot").StringVar(&c.description)
+	cmd.Flag("new-password", "Password for the key slot").Envar("KOPIA_NEW_PASSWORD").StringVar(&c.password)
+	cmd.Flag("generate", "Generate and print a random recovery key instead of using a password").BoolVar(&c.generate)
+	cmd.Flag("write-only", "Only allow adding data to the repository using public-key encryption, without access to the master key").BoolVar(&c.writeOnly)
+	c.kdf.setup(cmd)
+
+	c.svc = svc
+	c.out.setup(svc)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryKeyAdd) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	var kd *repo.KeyDerivationOptions
+
+	if c.kdf.specified() {
+		opt, err := c.kdf.keyDerivationOptions()
+		if err != nil {
+			return err
+		}
+
+		kd = &opt
+	}
+
+	pass, err := c.getPassword()
+	if err != nil {
+		return err
+	}
+
+	addKeySlot := rep.AddKeySlot
+	if c.writeOnly {
+		addKeySlot = rep.AddWriteOnlyKeySlot
+	}
+
+	if err := addKeySlot(ctx, c.id, c.description, pass, kd); err != nil {
+		return errors.Wrap(err, "unable to add key slot")
+	}
+
+	if c.generate {
+		c.out.printStdout("Recovery key: %v\n", pass)
+		log(ctx).Infof("NOTE: Store the recovery key in a safe place, it will not be shown again.")
+	}
+
+	log(ctx).Infof("Key slot added.")
+
+	return nil
+}
+
+func (c *commandRepositoryKeyAdd) getPassword() (string, error) {
+	switch {
+	case c.generate && c.password != "":
+		return "", errors.Errorf("--generate and --new-password are mutually exclusive")
+
+	case c.generate:
+		return generateRecoveryKey()
+
+	case c.password != "":
+		return c.password, nil
+
+	default:
+		return askForChangedRepositoryPassword(c.svc.stdout())
+	}
+}
+
+// generateRecoveryKey returns a random key formatted as groups of base32 characters.
+func generateRecoveryKey() (string, error) {
+	b := make([]byte, recoveryKeyLength)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", errors.Wrap(err, "error generating recovery key")
+	}
+
+	s := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	var groups []string
+
+	for len(s) > recoveryKeyGroupSize {
+		groups = append(groups, s[0:recoveryKeyGroupSize])
+		s = s[recoveryKeyGroupSize:]
+	}
+
+	groups = append(groups, s)
+
+	return strings.Join(groups, "-"), nil
+}
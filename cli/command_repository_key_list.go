@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositoryKeyList struct {
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandRepositoryKeyList) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("list", "List key slots").Alias("ls")
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryKeyList) run(ctx context.Context, rep repo.DirectRepository) error {
+	slots := rep.KeySlots()
+
+	if c.jo.jsonOutput {
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		for _, s := range slots {
+			jl.emit(s)
+		}
+
+		return nil
+	}
+
+	if len(slots) == 0 {
+		c.out.printStdout("Repository does not use key slots, it can only be unlocked with the repository password.\n")
+		return nil
+	}
+
+	for _, s := range slots {
+		current := ""
+		if s.WriteOnly {
+			current += " (write-only)"
+		}
+
+		if s.Current {
+			current += " (current)"
+		}
+
+		c.out.printStdout("%-16v %v %-20v %v%v\n", s.ID, formatTimestamp(s.CreatedTime), s.KeyDerivationAlgorithm, s.Description, current)
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+type commandRepositoryKeyRemove struct {
+	id string
+}
+
+func (c *commandRepositoryKeyRemove) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("remove", "Remove a key slot, revoking the corresponding password. The format encryption key is rotated, but the master key remains valid, use 'repository rotate-key' to protect data written afterwards.").Alias("rm").Alias("delete")
+	cmd.Arg("name", "Name of the key slot to remove").Required().StringVar(&c.id)
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryKeyRemove) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if err := rep.RemoveKeySlot(ctx, c.id); err != nil {
+		return errors.Wrap(err, "unable to remove key slot")
+	}
+
+	log(ctx).Infof("Key slot %v removed.", c.id)
+	log(ctx).Infof("WARNING: Anyone who has used the removed password could have obtained the master key, which remains valid. Run 'kopia repository rotate-key' to prevent them from decrypting data written from now on.")
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package cli_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryKeySlots(t *testing.T) {
+	env1 := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env1.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+	env1.RunAndExpectSuccess(t, "repo", "key", "list")
+	env1.RunAndExpectSuccess(t, "repo", "key", "add", "--name", "backup", "--description", "backup admin", "--new-password", "backupPass")
+	env1.RunAndExpectFailure(t, "repo", "key", "add", "--name", "backup", "--new-password", "otherPass")
+
+	var recoveryKey string
+
+	for _, l := range env1.RunAndExpectSuccess(t, "repo", "key", "add", "--name", "recovery", "--generate") {
+		if strings.HasPrefix(l, "Recovery key: ") {
+			recoveryKey = strings.TrimPrefix(l, "Recovery key: ")
+		}
+	}
+
+	require.NotEmpty(t, recoveryKey)
+
+	var slots []repo.KeySlotInfo
+
+	testutil.MustParseJSONLines(t, env1.RunAndExpectSuccess(t, "repo", "key", "list", "--json"), &slots)
+	require.Len(t, slots, 3)
+	require.Equal(t, repo.DefaultKeySlotID, slots[0].ID)
+	require.True(t, slots[0].Current)
+	require.Equal(t, "backup", slots[1].ID)
+	require.Equal(t, "recovery", slots[2].ID)
+
+	// connect using each of the additional passwords.
+	r2 := testenv.NewInProcRunner(t)
+	r2.RepoPassword = "backupPass"
+	env2 := testenv.NewCLITest(t, r2)
+	env2.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+	env2.RunAndExpectSuccess(t, "snapshot", "ls")
+
+	// slot used by the current connection can't be removed.
+	env2.RunAndExpectFailure(t, "repo", "key", "remove", "backup")
+
+	r3 := testenv.NewInProcRunner(t)
+	r3.RepoPassword = recoveryKey
+	env3 := testenv.NewCLITest(t, r3)
+	env3.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", env1.RepoDir, "--disable-repository-format-cache")
+
+	// revoking the backup password disconnects clients using it.
+	env1.RunAndExpectSuccess(t, "repo", "key", "remove", "backup")
+	env1.RunAndExpectFailure(t, "repo", "key", "remove", "backup")
+	env2.RunAndExpectFailure(t, "snapshot", "ls")
+	env3.RunAndExpectSuccess(t, "snapshot", "ls")
+	env1.RunAndExpectSuccess(t, "snapshot", "ls")
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+type commandRepositoryRotateKey struct {
+	svc advancedAppServices
+}
+
+func (c *commandRepositoryRotateKey) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("rotate-key", "Rotate repository master key. Existing data is re-encrypted during full maintenance. Repositories with rotated keys can't be opened by older Kopia versions.")
+
+	c.svc = svc
+	cmd.Action(svc.directRepositoryWriteAction(c.run))
+}
+
+func (c *commandRepositoryRotateKey) run(ctx context.Context, rep repo.DirectRepositoryWriter) error {
+	if v := rep.ContentReader().ContentFormat().IndexVersion; v < content.MinKeyRotationIndexVersion {
+		return errors.Errorf("master key rotation requires index version %v or newer (currently %v), upgrade using 'kopia repository set-parameters --index-version=%v'", content.MinKeyRotationIndexVersion, v, content.MinKeyRotationIndexVersion)
+	}
+
+	// maintenance schedule is encrypted using a key derived from the master key,
+	// read it before rotation so that it can be re-encrypted using the new key.
+	sched, err := maintenance.GetSchedule(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to get maintenance schedule")
+	}
+
+	gen, err := rep.RotateMasterKey(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to rotate master key")
+	}
+
+	if err := c.reencryptSchedule(ctx, sched); err != nil {
+		return err
+	}
+
+	log(ctx).Infof("Rotated master key, current generation is %v.", gen)
+	log(ctx).Infof("Existing data will be re-encrypted during full maintenance, after which previous master keys will be retired.")
+	log(ctx).Infof("NOTE: Other clients start using the new master key when they refresh the repository, previous master keys are not retired while sessions started before that are active.")
+
+	return nil
+}
+
+func (c *commandRepositoryRotateKey) reencryptSchedule(ctx context.Context, sched *maintenance.Schedule) error {
+	// reopen the repository to use the new master key.
+	r, err := c.svc.openRepository(ctx, true)
+	if err != nil {
+		return errors.Wrap(err, "unable to reopen repository")
+	}
+
+	defer r.Close(ctx) //nolint:errcheck
+
+	dr, ok := r.(repo.DirectRepository)
+	if !ok {
+		return errors.Errorf("key rotation only supports directly-connected repositories")
+	}
+
+	// nolint:wrapcheck
+	return repo.DirectWriteSession(ctx, dr, repo.WriteSessionOptions{
+		Purpose: "rotate-key",
+	}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		return maintenance.SetSchedule(ctx, w, sched)
+	})
+}
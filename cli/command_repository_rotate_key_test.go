@@ -0,0 +1,69 @@
+package cli_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryRotateKey(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir, "--index-version=1")
+	env.RunAndExpectSuccess(t, "snapshot", "create", rotateKeyTestDir(t))
+
+	// key rotation requires v2 index.
+	env.RunAndExpectFailure(t, "repo", "rotate-key")
+	env.RunAndExpectSuccess(t, "repo", "set-parameters", "--index-version=2")
+
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none")
+	env.RunAndExpectSuccess(t, "repo", "rotate-key")
+
+	require.Contains(t, statusLine(t, env, "Master key:"), "generation 1 (1 previous pending retirement)")
+
+	// data written before and after rotation is readable and maintenance schedule is preserved.
+	env.RunAndExpectSuccess(t, "snapshot", "create", rotateKeyTestDir(t))
+	env.RunAndExpectSuccess(t, "snapshot", "verify")
+	require.Contains(t, strings.Join(env.RunAndExpectSuccess(t, "maintenance", "info"), "\n"), "full-delete-blobs")
+
+	// make sure re-encrypted contents are newer than the originals.
+	time.Sleep(time.Second)
+
+	// full maintenance re-encrypts the data and retires the previous key.
+	env.RunAndExpectSuccess(t, "maintenance", "run", "--full", "--safety=none")
+	require.Contains(t, statusLine(t, env, "Master key:"), "generation 1 (0 previous pending retirement)")
+	require.Contains(t, strings.Join(env.RunAndExpectSuccess(t, "maintenance", "info"), "\n"), "retire-master-keys")
+
+	env.RunAndExpectSuccess(t, "snapshot", "verify")
+}
+
+func rotateKeyTestDir(t *testing.T) string {
+	t.Helper()
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte(uuid.NewString()), 0o600))
+
+	return dir
+}
+
+func statusLine(t *testing.T, env *testenv.CLITest, prefix string) string {
+	t.Helper()
+
+	for _, l := range env.RunAndExpectSuccess(t, "repo", "status") {
+		if strings.HasPrefix(l, prefix) {
+			return l
+		}
+	}
+
+	t.Fatalf("status line %q not found", prefix)
+
+	return ""
+}
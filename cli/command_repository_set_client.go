@@ -15,6 +15,7 @@ type commandRepositorySetClient struct {
 	repoClientOptionsDescription []string
 	repoClientOptionsUsername    []string
 	repoClientOptionsHostname    []string
+	privateKeyFile               []string
 
 	formatBlobCacheDuration time.Duration
 	disableFormatBlobCache  bool
@@ -30,6 +31,7 @@ func (c *commandRepositorySetClient) setup(svc appServices, parent commandParent
 	cmd.Flag("description", "Change description").StringsVar(&c.repoClientOptionsDescription)
 	cmd.Flag("username", "Change username").StringsVar(&c.repoClientOptionsUsername)
 	cmd.Flag("hostname", "Change hostname").StringsVar(&c.repoClientOptionsHostname)
+	cmd.Flag("private-key-file", "Change private key file used to decrypt contents encrypted with public key, empty to remove").PlaceHolder("PATH").StringsVar(&c.privateKeyFile)
 	cmd.Flag("repository-format-cache-duration", "Duration of kopia.repository format blob cache").DurationVar(&c.formatBlobCacheDuration)
 	cmd.Flag("disable-repository-format-cache", "Disable caching of kopia.repository format blob").BoolVar(&c.disableFormatBlobCache)
 	cmd.Action(svc.repositoryReaderAction(c.run))
@@ -85,6 +87,13 @@ func (c *commandRepositorySetClient) run(ctx context.Context, rep repo.Repositor
 		log(ctx).Infof("Setting local hostname to %v", opt.Hostname)
 	}
 
+	if v := c.privateKeyFile; len(v) > 0 {
+		opt.PrivateKeyFile = absolutePathOrEmpty(v[0])
+		anyChange = true
+
+		log(ctx).Infof("Setting private key file to %q", opt.PrivateKeyFile)
+	}
+
 	if v := c.formatBlobCacheDuration; v != 0 {
 		opt.FormatBlobCacheDuration = v
 		anyChange = true
@@ -12,6 +12,8 @@
 	"github.com/kopia/kopia/internal/scrubber"
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
 )
 
 type commandRepositoryStatus struct {
@@ -60,11 +62,40 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 		c.out.printStdout("Storage config:      %v\n", string(cjson))
 	}
 
+	if scc, ok := ci.Config.(blob.StorageClassConfig); ok {
+		c.printStorageClasses(scc.BlobStorageClasses())
+	}
+
 	c.out.printStdout("\n")
 	c.out.printStdout("Unique ID:           %x\n", dr.UniqueID())
 	c.out.printStdout("Hash:                %v\n", dr.ContentReader().ContentFormat().Hash)
 	c.out.printStdout("Encryption:          %v\n", dr.ContentReader().ContentFormat().Encryption)
 	c.out.printStdout("Splitter:            %v\n", dr.ObjectFormat().Splitter)
+
+	if f := dr.ContentReader().ContentFormat(); f.ECC != "" {
+		c.out.printStdout("Error correction:    %v (%v%% overhead)\n", f.ECC, f.ECCOverheadPercent)
+	} else {
+		c.out.printStdout("Error correction:    disabled\n")
+	}
+
+	if f := dr.ContentReader().ContentFormat(); f.PublicKeyEncryption != "" {
+		if pk := dr.ClientOptions().PrivateKeyFile; pk != "" {
+			c.out.printStdout("Public key:          %v (private key: %v)\n", f.PublicKeyEncryption, pk)
+		} else {
+			c.out.printStdout("Public key:          %v (no private key, contents can't be read)\n", f.PublicKeyEncryption)
+		}
+	}
+
+	for _, ks := range dr.KeySlots() {
+		if ks.Current && ks.WriteOnly {
+			c.out.printStdout("Access:              write-only (key slot %v)\n", ks.ID)
+		}
+	}
+
+	if f := dr.ContentReader().ContentFormat(); f.KeyGeneration != 0 {
+		c.out.printStdout("Master key:          generation %v (%v previous pending retirement)\n", f.KeyGeneration, len(f.PreviousMasterKeys))
+	}
+
 	c.out.printStdout("Format version:      %v\n", dr.ContentReader().ContentFormat().Version)
 	c.out.printStdout("Content compression: %v\n", dr.ContentReader().SupportsContentCompression())
 	c.out.printStdout("Password changes:    %v\n", dr.ContentReader().ContentFormat().EnablePasswordChange)
@@ -119,6 +150,37 @@ func (c *commandRepositoryStatus) run(ctx context.Context, rep repo.Repository)
 	return nil
 }
 
+// storageClassBlobPrefixes are well-known blob ID prefixes for which effective storage classes are shown.
+// nolint:gochecknoglobals
+var storageClassBlobPrefixes = []struct {
+	prefix      blob.ID
+	description string
+}{
+	{content.PackBlobIDPrefixRegular, "data packs"},
+	{content.PackBlobIDPrefixSpecial, "metadata packs"},
+	{content.IndexBlobPrefix, "indexes"},
+	{"x", "epoch indexes"},
+	{content.BlobIDPrefixSession, "sessions"},
+	{repo.FormatBlobID, "format"},
+}
+
+func (c *commandRepositoryStatus) printStorageClasses(m blob.StorageClassMap) {
+	if len(m) == 0 {
+		return
+	}
+
+	c.out.printStdout("Storage classes:\n")
+
+	for _, p := range storageClassBlobPrefixes {
+		class := m.ForBlob(p.prefix)
+		if class == "" {
+			class = "(provider default)"
+		}
+
+		c.out.printStdout("  %-28v %v\n", string(p.prefix)+" ("+p.description+")", class)
+	}
+}
+
 func scanCacheDir(dirname string) (fileCount int, totalFileLength int64, err error) {
 	entries, err := ioutil.ReadDir(dirname)
 	if err != nil {
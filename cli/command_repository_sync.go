@@ -73,7 +73,13 @@ func (c *commandRepositorySyncTo) setup(svc advancedAppServices, parent commandP
 				return errors.Errorf("sync only supports directly-connected repositories")
 			}
 
-			return c.runSyncWithStorage(ctx, dr.BlobReader(), st)
+			src := dr.BlobReader()
+			if ecr, ok := dr.(repo.ErrorCorrectingRepository); ok {
+				// copy blobs as stored, including error correction codes.
+				src = ecr.EncodedBlobReader()
+			}
+
+			return c.runSyncWithStorage(ctx, src, st)
 		})
 	}
 }
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/throttling"
+)
+
+type commandRepositoryThrottle struct {
+	get commandRepositoryThrottleGet
+	set commandRepositoryThrottleSet
+}
+
+func (c *commandRepositoryThrottle) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("throttle", "Manage bandwidth throttling schedule of the repository connection.")
+
+	c.get.setup(svc, cmd)
+	c.set.setup(svc, cmd)
+}
+
+type commandRepositoryThrottleGet struct {
+	out textOutput
+}
+
+func (c *commandRepositoryThrottleGet) setup(svc advancedAppServices, parent commandParent) {
+	cmd := parent.Command("get", "Show bandwidth throttling schedule and the limits currently in effect.")
+	c.out.setup(svc)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandRepositoryThrottleGet) run(ctx context.Context, rep repo.DirectRepository) error {
+	tr, ok := rep.(repo.ThrottlingRepository)
+	if !ok {
+		return errors.Errorf("throttling is not supported by this repository connection")
+	}
+
+	c.out.printStdout("Schedule:       %v\n", tr.Throttler().Schedule())
+	c.out.printStdout("Current limits: %v\n", tr.Throttler().CurrentLimits())
+
+	return nil
+}
+
+type commandRepositoryThrottleSet struct {
+	uploadLimit   int64
+	downloadLimit int64
+	windows       []string
+	clear         bool
+
+	svc appServices
+}
+
+func (c *commandRepositoryThrottleSet) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("set", "Replace bandwidth throttling schedule of the repository connection. Takes effect when the repository is opened.")
+	cmd.Flag("upload-limit", "Default upload limit in bytes per second (0 means unlimited)").Int64Var(&c.uploadLimit)
+	cmd.Flag("download-limit", "Default download limit in bytes per second (0 means unlimited)").Int64Var(&c.downloadLimit)
+	cmd.Flag("window", "Limits in effect during a time window, first matching window applies (HH:MM-HH:MM[@day,...]=UPLOAD/DOWNLOAD, e.g. 09:00-17:00@mon,tue,wed,thu,fri=100000/0)").StringsVar(&c.windows)
+	cmd.Flag("clear", "Remove the schedule, leaving only the limits specified in storage options").BoolVar(&c.clear)
+	cmd.Action(svc.noRepositoryAction(c.run))
+
+	c.svc = svc
+}
+
+func (c *commandRepositoryThrottleSet) run(ctx context.Context) error {
+	if c.clear {
+		if c.uploadLimit != 0 || c.downloadLimit != 0 || len(c.windows) > 0 {
+			return errors.Errorf("--clear can't be combined with limits")
+		}
+
+		log(ctx).Infof("Removing throttling schedule.")
+
+		// nolint:wrapcheck
+		return repo.SetThrottlingSchedule(ctx, c.svc.repositoryConfigFileName(), nil)
+	}
+
+	s := &throttling.Schedule{
+		Default: throttling.Limits{
+			UploadBytesPerSecond:   c.uploadLimit,
+			DownloadBytesPerSecond: c.downloadLimit,
+		},
+	}
+
+	for _, v := range c.windows {
+		w, err := parseThrottlingWindow(v)
+		if err != nil {
+			return err
+		}
+
+		s.Windows = append(s.Windows, w)
+	}
+
+	if err := s.Validate(); err != nil {
+		return errors.Wrap(err, "invalid throttling schedule")
+	}
+
+	log(ctx).Infof("Setting throttling schedule: %v", s)
+
+	// nolint:wrapcheck
+	return repo.SetThrottlingSchedule(ctx, c.svc.repositoryConfigFileName(), s)
+}
+
+// parseThrottlingWindow parses window specification in HH:MM-HH:MM[@day,...]=UPLOAD/DOWNLOAD format.
+func parseThrottlingWindow(v string) (throttling.Window, error) {
+	var w throttling.Window
+
+	invalid := errors.Errorf("invalid window %q, must be HH:MM-HH:MM[@day,...]=UPLOAD/DOWNLOAD", v)
+
+	times, limits := splitPair(v, "=")
+	if limits == "" {
+		return w, invalid
+	}
+
+	times, days := splitPair(times, "@")
+	if days != "" {
+		w.Days = strings.Split(days, ",")
+	}
+
+	w.Start, w.End = splitPair(times, "-")
+	if w.End == "" {
+		return w, invalid
+	}
+
+	upload, download := splitPair(limits, "/")
+
+	var err error
+
+	if w.UploadBytesPerSecond, err = strconv.ParseInt(upload, 10, 64); err != nil {
+		return w, invalid
+	}
+
+	if w.DownloadBytesPerSecond, err = strconv.ParseInt(download, 10, 64); err != nil {
+		return w, invalid
+	}
+
+	return w, nil
+}
+
+// splitPair splits the string at the first occurrence of the separator, returning empty second part if not found.
+func splitPair(s, sep string) (before, after string) {
+	p := strings.Index(s, sep)
+	if p < 0 {
+		return s, ""
+	}
+
+	return s[0:p], s[p+len(sep):]
+}
@@ -0,0 +1,32 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestRepositoryThrottle(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+	require.Contains(t, env.RunAndExpectSuccess(t, "repository", "throttle", "get"), "Schedule:       unlimited")
+
+	// failure cases
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--upload-limit=-1")
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--window=09:00-17:00")
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--window=09:00=1/2")
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--window=25:00-17:00=1/2")
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--window=09:00-17:00@xyz=1/2")
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--window=09:00-17:00=x/2")
+	env.RunAndExpectFailure(t, "repository", "throttle", "set", "--clear", "--upload-limit=1")
+
+	env.RunAndExpectSuccess(t, "repository", "throttle", "set", "--upload-limit=5000", "--window=09:00-17:00@mon,tue=1000/2000")
+	require.Contains(t, env.RunAndExpectSuccess(t, "repository", "throttle", "get"),
+		"Schedule:       default upload:5000 B/s download:unlimited; 09:00-17:00 on mon,tue upload:1000 B/s download:2000 B/s")
+
+	env.RunAndExpectSuccess(t, "repository", "throttle", "set", "--clear")
+	require.Contains(t, env.RunAndExpectSuccess(t, "repository", "throttle", "get"), "Schedule:       unlimited")
+}
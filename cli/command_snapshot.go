@@ -5,12 +5,18 @@ type commandSnapshot struct {
 	moveHistory commandSnapshotCopyMoveHistory
 	create      commandSnapshotCreate
 	delete      commandSnapshotDelete
+	edit        commandSnapshotEdit
 	estimate    commandSnapshotEstimate
 	expire      commandSnapshotExpire
+	find        commandSnapshotFind
+	history     commandSnapshotHistory
 	gc          commandSnapshotGC
 	list        commandSnapshotList
 	migrate     commandSnapshotMigrate
+	pin         commandSnapshotPin
+	unpin       commandSnapshotPin
 	restore     commandSnapshotRestore
+	usage       commandSnapshotUsage
 	verify      commandSnapshotVerify
 }
 
@@ -20,11 +26,17 @@ func (c *commandSnapshot) setup(svc advancedAppServices, parent commandParent) {
 	c.moveHistory.setup(svc, cmd, true)
 	c.create.setup(svc, cmd)
 	c.delete.setup(svc, cmd)
+	c.edit.setup(svc, cmd)
 	c.estimate.setup(svc, cmd)
 	c.expire.setup(svc, cmd)
+	c.find.setup(svc, cmd)
+	c.history.setup(svc, cmd)
 	c.gc.setup(svc, cmd)
 	c.list.setup(svc, cmd)
 	c.migrate.setup(svc, cmd)
+	c.pin.setup(svc, cmd, false)
+	c.unpin.setup(svc, cmd, true)
 	c.restore.setup(svc, cmd)
+	c.usage.setup(svc, cmd)
 	c.verify.setup(svc, cmd)
 }
@@ -139,9 +139,23 @@ func (c *commandSnapshotCreate) run(ctx context.Context, rep repo.RepositoryWrit
 }
 
 func getTags(tagStrings []string) (map[string]string, error) {
+	userTags, err := parseUserTags(tagStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+
+	for k, v := range userTags {
+		tags[snapshot.TagKeyPrefix+k] = v
+	}
+
+	return tags, nil
+}
+
+// parseUserTags parses a list of <key>:<value> strings into a map of user-defined tags without TagKeyPrefix.
+func parseUserTags(tagStrings []string) (map[string]string, error) {
 	numberOfPartsInTagString := 2
-	// tagKeyPrefix is the prefix for user defined tag keys.
-	tagKeyPrefix := "tag:"
 
 	tags := map[string]string{}
 
@@ -151,12 +165,15 @@ func getTags(tagStrings []string) (map[string]string, error) {
 			return nil, errors.New("Invalid tag format. Requires <key>:<value>")
 		}
 
-		key := tagKeyPrefix + parts[0]
-		if _, ok := tags[key]; ok {
+		if _, ok := tags[parts[0]]; ok {
 			return nil, errors.Errorf("Duplicate tag <key> found. (%s)", parts[0])
 		}
 
-		tags[key] = parts[1]
+		tags[parts[0]] = parts[1]
+	}
+
+	if err := snapshot.ValidateUserTags(tags); err != nil {
+		return nil, errors.Wrap(err, "invalid tag")
 	}
 
 	return tags, nil
@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+type commandSnapshotEdit struct {
+	ids         []string
+	description string
+	addTags     []string
+	removeTags  []string
+
+	descriptionSet bool
+}
+
+func (c *commandSnapshotEdit) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("edit", "Edit description and tags of snapshots.")
+	cmd.Arg("id", "Snapshot ID").Required().StringsVar(&c.ids)
+	cmd.Flag("description", "New description of the snapshot").IsSetByUser(&c.descriptionSet).StringVar(&c.description)
+	cmd.Flag("add-tags", "Tags to add or replace, specified as <key>:<value> (can be specified multiple times)").StringsVar(&c.addTags)
+	cmd.Flag("remove-tags", "Keys of tags to remove (can be specified multiple times)").StringsVar(&c.removeTags)
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandSnapshotEdit) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	addTags, err := parseUserTags(c.addTags)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range c.ids {
+		m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(id))
+		if err != nil {
+			return errors.Wrapf(err, "error loading snapshot %v", id)
+		}
+
+		changed := m.UpdateTags(addTags, c.removeTags)
+
+		if c.descriptionSet && m.Description != c.description {
+			m.Description = c.description
+			changed = true
+		}
+
+		if !changed {
+			log(ctx).Infof("Snapshot %v unchanged.", id)
+			continue
+		}
+
+		newID, err := snapshot.UpdateSnapshot(ctx, rep, m)
+		if err != nil {
+			return errors.Wrapf(err, "error updating snapshot %v", id)
+		}
+
+		log(ctx).Infof("Updated snapshot %v (new ID %v)", id, newID)
+	}
+
+	return nil
+}
@@ -0,0 +1,47 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotEdit(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+
+	var man snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json", "--tags=env:test", "--tags=owner:alice"), &man)
+
+	env.RunAndExpectSuccess(t, "snapshot", "edit", string(man.ID), "--description=new description", "--add-tags=env:prod", "--add-tags=team:ops", "--remove-tags=owner")
+
+	var manifests []*snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Len(t, manifests, 1)
+	require.NotEqual(t, man.ID, manifests[0].ID)
+	require.Equal(t, "new description", manifests[0].Description)
+	require.Equal(t, map[string]string{"tag:env": "prod", "tag:team": "ops"}, manifests[0].Tags)
+
+	// edited tags are usable as filters.
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json", "--tags=team:ops"), &manifests)
+	require.Len(t, manifests, 1)
+
+	// description can be cleared.
+	env.RunAndExpectSuccess(t, "snapshot", "edit", string(manifests[0].ID), "--description=")
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Empty(t, manifests[0].Description)
+	require.Len(t, manifests[0].Tags, 2)
+
+	env.RunAndExpectFailure(t, "snapshot", "edit", string(manifests[0].ID), "--add-tags=invalid")
+	env.RunAndExpectFailure(t, "snapshot", "edit", string(manifests[0].ID), "--add-tags=:empty-key")
+	env.RunAndExpectFailure(t, "snapshot", "edit", "no-such-snapshot", "--description=x")
+}
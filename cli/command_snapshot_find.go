@@ -0,0 +1,332 @@
+package cli
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	atunits "github.com/alecthomas/units"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/wcmatch"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+const (
+	findTypeAny  = "any"
+	findTypeFile = "file"
+	findTypeDir  = "dir"
+)
+
+// nolint:gochecknoglobals
+var findTimeFormats = []string{
+	time.RFC3339,
+	timeFormat,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+type commandSnapshotFind struct {
+	source         string
+	snapshotIDs    []string
+	names          []string
+	ignoreCase     bool
+	entryType      string
+	minSize        atunits.Base2Bytes
+	maxSize        atunits.Base2Bytes
+	modifiedAfter  string
+	modifiedBefore string
+	tags           []string
+	humanReadable  bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+// findMatch describes a single entry found in a snapshot.
+type findMatch struct {
+	SnapshotID   manifest.ID         `json:"snapshotID"`
+	SnapshotTime time.Time           `json:"snapshotTime"`
+	Source       snapshot.SourceInfo `json:"source"`
+	Path         string              `json:"path"`
+	Type         string              `json:"type"`
+	Size         int64               `json:"size"`
+	ModTime      time.Time           `json:"mtime"`
+	ObjectID     object.ID           `json:"obj,omitempty"`
+}
+
+type findCriteria struct {
+	matchers       []*wcmatch.WildcardMatcher
+	entryType      string
+	minSize        int64
+	maxSize        int64
+	modifiedAfter  time.Time
+	modifiedBefore time.Time
+}
+
+func (c *commandSnapshotFind) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("find", "Find files and directories in snapshots.")
+	cmd.Arg("source", "Source or a directory within a source to search snapshots of (default: all sources).").StringVar(&c.source)
+	cmd.Flag("snapshot", "Only search snapshots with given IDs.").StringsVar(&c.snapshotIDs)
+	cmd.Flag("name", "Wildcard pattern to match (.gitignore syntax, matched against the path relative to snapshot root), can be repeated.").StringsVar(&c.names)
+	cmd.Flag("ignore-case", "Match patterns case-insensitively.").BoolVar(&c.ignoreCase)
+	cmd.Flag("type", "Type of entries to find.").Default(findTypeAny).EnumVar(&c.entryType, findTypeAny, findTypeFile, findTypeDir)
+	cmd.Flag("min-size", "Minimum entry size.").BytesVar(&c.minSize)
+	cmd.Flag("max-size", "Maximum entry size.").BytesVar(&c.maxSize)
+	cmd.Flag("modified-after", "Only find entries modified at or after the provided time (YYYY-MM-DD [HH:MM[:SS]]).").StringVar(&c.modifiedAfter)
+	cmd.Flag("modified-before", "Only find entries modified before the provided time (YYYY-MM-DD [HH:MM[:SS]]).").StringVar(&c.modifiedBefore)
+	cmd.Flag("tags", "Tag filters to apply on the snapshots. Must be provided in the <key>:<value> format.").StringsVar(&c.tags)
+	cmd.Flag("human-readable", "Show human-readable units").Default("true").BoolVar(&c.humanReadable)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func parseFindTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range findTimeFormats {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, errors.Errorf("invalid time %q, expected YYYY-MM-DD [HH:MM[:SS]]", s)
+}
+
+func (c *commandSnapshotFind) criteria() (*findCriteria, error) {
+	fc := &findCriteria{
+		entryType: c.entryType,
+		minSize:   int64(c.minSize),
+		maxSize:   int64(c.maxSize),
+	}
+
+	for _, n := range c.names {
+		m, err := wcmatch.NewWildcardMatcher(n, wcmatch.IgnoreCase(c.ignoreCase))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q", n)
+		}
+
+		fc.matchers = append(fc.matchers, m)
+	}
+
+	var err error
+
+	if fc.modifiedAfter, err = parseFindTime(c.modifiedAfter); err != nil {
+		return nil, err
+	}
+
+	if fc.modifiedBefore, err = parseFindTime(c.modifiedBefore); err != nil {
+		return nil, err
+	}
+
+	return fc, nil
+}
+
+// matches determines whether the entry at the provided slash-separated path relative to snapshot root
+// satisfies the criteria.
+func (fc *findCriteria) matches(e fs.Entry, entryPath string) bool {
+	switch fc.entryType {
+	case findTypeFile:
+		if e.IsDir() {
+			return false
+		}
+	case findTypeDir:
+		if !e.IsDir() {
+			return false
+		}
+	}
+
+	if fc.minSize > 0 && e.Size() < fc.minSize {
+		return false
+	}
+
+	if fc.maxSize > 0 && e.Size() > fc.maxSize {
+		return false
+	}
+
+	if !fc.modifiedAfter.IsZero() && e.ModTime().Before(fc.modifiedAfter) {
+		return false
+	}
+
+	if !fc.modifiedBefore.IsZero() && !e.ModTime().Before(fc.modifiedBefore) {
+		return false
+	}
+
+	if len(fc.matchers) == 0 {
+		return true
+	}
+
+	for _, m := range fc.matchers {
+		if m.Match("/"+entryPath, e.IsDir()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *commandSnapshotFind) run(ctx context.Context, rep repo.Repository) error {
+	fc, err := c.criteria()
+	if err != nil {
+		return err
+	}
+
+	manifests, relPath, err := c.findSnapshots(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	var jl jsonList
+
+	jl.begin(&c.jo)
+	defer jl.end()
+
+	for _, m := range manifests {
+		matches, err := findInSnapshot(ctx, rep, m, relPath, fc)
+		if err != nil {
+			return errors.Wrapf(err, "error searching snapshot %v", m.ID)
+		}
+
+		for _, fm := range matches {
+			if c.jo.jsonOutput {
+				jl.emit(fm)
+				continue
+			}
+
+			c.out.printStdout("%v %v %10v %v %v\n",
+				fm.SnapshotID,
+				formatTimestamp(fm.ModTime),
+				maybeHumanReadableBytes(c.humanReadable, fm.Size),
+				fm.Source.Path+"/"+fm.Path,
+				fm.ObjectID,
+			)
+		}
+	}
+
+	return nil
+}
+
+// findSnapshots returns snapshots to search and the path within them.
+func (c *commandSnapshotFind) findSnapshots(ctx context.Context, rep repo.Repository) ([]*snapshot.Manifest, string, error) {
+	tags, err := getTags(c.tags)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ids, relPath, err := findManifestIDs(ctx, rep, c.source, tags)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if len(c.snapshotIDs) > 0 {
+		ids = filterManifestIDs(ids, c.snapshotIDs)
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to load snapshots")
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		if a, b := manifests[i].Source.String(), manifests[j].Source.String(); a != b {
+			return a < b
+		}
+
+		return manifests[i].StartTime.Before(manifests[j].StartTime)
+	})
+
+	return manifests, strings.TrimPrefix(relPath, "/"), nil
+}
+
+func filterManifestIDs(ids []manifest.ID, allowed []string) []manifest.ID {
+	var result []manifest.ID
+
+	for _, id := range ids {
+		for _, a := range allowed {
+			if string(id) == a {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// findInSnapshot walks the snapshot starting at the provided relative path and returns entries matching
+// the criteria sorted by path.
+func findInSnapshot(ctx context.Context, rep repo.Repository, m *snapshot.Manifest, relPath string, fc *findCriteria) ([]findMatch, error) {
+	root, err := snapshotfs.SnapshotRoot(rep, m)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get snapshot root")
+	}
+
+	if relPath != "" {
+		root, err = snapshotfs.GetNestedEntry(ctx, root, strings.Split(relPath, "/"))
+		if err != nil {
+			log(ctx).Debugf("%v not found in snapshot %v: %v", relPath, m.ID, err)
+			return nil, nil
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		matches []findMatch
+	)
+
+	w := snapshotfs.NewTreeWalker()
+	w.RootEntries = []fs.Entry{root}
+	w.EntryPathCallback = func(e fs.Entry, entryPath string) error {
+		entryPath = path.Join(relPath, entryPath)
+
+		if entryPath == "." || !fc.matches(e, entryPath) {
+			return nil
+		}
+
+		fm := findMatch{
+			SnapshotID:   m.ID,
+			SnapshotTime: m.StartTime,
+			Source:       m.Source,
+			Path:         entryPath,
+			Type:         findTypeFile,
+			Size:         e.Size(),
+			ModTime:      e.ModTime(),
+		}
+
+		if e.IsDir() {
+			fm.Type = findTypeDir
+		}
+
+		if h, ok := e.(object.HasObjectID); ok {
+			fm.ObjectID = h.ObjectID()
+		}
+
+		mu.Lock()
+		matches = append(matches, fm)
+		mu.Unlock()
+
+		return nil
+	}
+
+	if err := w.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "error walking snapshot tree")
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Path < matches[j].Path
+	})
+
+	return matches, nil
+}
@@ -0,0 +1,107 @@
+package cli_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotFind(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "mail", "archive"), 0o755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mail", "inbox.pst"), bytes.Repeat([]byte{1}, 20000), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mail", "archive", "2020.PST"), bytes.Repeat([]byte{2}, 5000), 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "notes.txt"), []byte{3}, 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	var snap1 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json"), &snap1)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "mail", "sent.pst"), bytes.Repeat([]byte{4}, 30000), 0o600))
+
+	var snap2 snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json"), &snap2)
+
+	type match struct {
+		SnapshotID   string              `json:"snapshotID"`
+		SnapshotTime time.Time           `json:"snapshotTime"`
+		Source       snapshot.SourceInfo `json:"source"`
+		Path         string              `json:"path"`
+		Type         string              `json:"type"`
+		Size         int64               `json:"size"`
+		ModTime      time.Time           `json:"mtime"`
+		ObjectID     string              `json:"obj"`
+	}
+
+	find := func(args ...string) []string {
+		var matches []match
+
+		testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, append([]string{"snapshot", "find", "--json"}, args...)...), &matches)
+
+		var result []string
+		for _, m := range matches {
+			result = append(result, m.SnapshotID+":"+m.Path)
+		}
+
+		return result
+	}
+
+	id1, id2 := string(snap1.ID), string(snap2.ID)
+
+	require.Equal(t, []string{
+		id1 + ":mail/inbox.pst",
+		id2 + ":mail/inbox.pst",
+		id2 + ":mail/sent.pst",
+	}, find(dir, "--name", "*.pst"))
+
+	require.Equal(t, []string{
+		id1 + ":mail/archive/2020.PST",
+		id1 + ":mail/inbox.pst",
+		id2 + ":mail/archive/2020.PST",
+		id2 + ":mail/inbox.pst",
+		id2 + ":mail/sent.pst",
+	}, find(dir, "--name", "*.pst", "--ignore-case"))
+
+	require.Equal(t, []string{
+		id2 + ":mail/sent.pst",
+	}, find(dir, "--name", "*.pst", "--min-size", "25KB"))
+
+	require.Equal(t, []string{
+		id1 + ":mail/archive/2020.PST",
+		id2 + ":mail/archive/2020.PST",
+	}, find(dir, "--type", "file", "--max-size", "10KB", "--name", "/mail/**"))
+
+	// like find(1), the starting directory itself is included.
+	require.Equal(t, []string{
+		id1 + ":mail",
+		id1 + ":mail/archive",
+		id2 + ":mail",
+		id2 + ":mail/archive",
+	}, find(filepath.Join(dir, "mail"), "--type", "dir"))
+
+	require.Equal(t, []string{
+		id1 + ":notes.txt",
+	}, find(dir, "--name", "notes.txt", "--snapshot", id1))
+
+	require.Empty(t, find(dir, "--name", "*.txt", "--modified-after", "2100-01-01"))
+
+	out := env.RunAndExpectSuccess(t, "snapshot", "find", dir, "--name", "sent.pst")
+	require.Len(t, out, 1)
+	require.True(t, strings.HasPrefix(out[0], id2+" "), out[0])
+	require.Contains(t, out[0], "mail/sent.pst")
+}
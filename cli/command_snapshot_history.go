@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type commandSnapshotHistory struct {
+	path          string
+	tags          []string
+	humanReadable bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotHistory) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("history", "Show distinct versions of a file or directory across all snapshots of its source.")
+	cmd.Arg("path", "File or directory to show history of.").Required().StringVar(&c.path)
+	cmd.Flag("tags", "Tag filters to apply on the snapshots. Must be provided in the <key>:<value> format.").StringsVar(&c.tags)
+	cmd.Flag("human-readable", "Show human-readable units").Default("true").BoolVar(&c.humanReadable)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.repositoryReaderAction(c.run))
+}
+
+func (c *commandSnapshotHistory) run(ctx context.Context, rep repo.Repository) error {
+	tags, err := getTags(c.tags)
+	if err != nil {
+		return err
+	}
+
+	si, err := snapshot.ParseSourceInfo(c.path, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+	if err != nil {
+		return errors.Wrapf(err, "invalid path: '%s'", c.path)
+	}
+
+	manifestIDs, relPath, err := snapshot.ListSnapshotManifestsForPath(ctx, rep, si, tags)
+	if err != nil {
+		return err
+	}
+
+	if len(manifestIDs) == 0 {
+		return errors.Errorf("no snapshots found containing %v", si)
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, manifestIDs)
+	if err != nil {
+		return errors.Wrap(err, "unable to load snapshots")
+	}
+
+	versions, err := snapshotfs.EntryHistory(ctx, rep, manifests, relPath)
+	if err != nil {
+		return errors.Wrap(err, "unable to determine history")
+	}
+
+	if c.jo.jsonOutput {
+		var jl jsonList
+
+		jl.begin(&c.jo)
+		defer jl.end()
+
+		for _, v := range versions {
+			jl.emit(v)
+		}
+
+		return nil
+	}
+
+	c.out.printStdout("%v\n", si)
+
+	for _, v := range versions {
+		c.out.printStdout("  %v %10v %v first:%v (%v) last:%v (%v) snapshots:%v\n",
+			v.ObjectID,
+			maybeHumanReadableBytes(c.humanReadable, v.Size),
+			formatTimestamp(v.ModTime),
+			formatTimestamp(v.FirstSnapshotTime),
+			v.FirstSnapshotID,
+			formatTimestamp(v.LastSnapshotTime),
+			v.LastSnapshotID,
+			v.SnapshotCount,
+		)
+	}
+
+	return nil
+}
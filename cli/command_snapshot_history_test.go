@@ -0,0 +1,82 @@
+package cli_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotHistory(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	dir := testutil.TempDirectory(t)
+	reportFile := filepath.Join(dir, "docs", "report.docx")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "docs"), 0o755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.txt"), []byte{1}, 0o600))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	snapshotWithContent := func(content string) manifestIDAndTime {
+		t.Helper()
+
+		if content == "" {
+			require.NoError(t, os.Remove(reportFile))
+		} else {
+			require.NoError(t, ioutil.WriteFile(reportFile, []byte(content), 0o600))
+		}
+
+		var man snapshot.Manifest
+
+		testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json"), &man)
+
+		return manifestIDAndTime{string(man.ID), man.StartTime}
+	}
+
+	s1 := snapshotWithContent("version 1")
+	snapshotWithContent("version 1")
+	s3 := snapshotWithContent("version 2")
+	snapshotWithContent("")
+	s5 := snapshotWithContent("version 1")
+
+	var versions []*snapshotfs.EntryVersion
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "history", reportFile, "--json"), &versions)
+
+	require.Len(t, versions, 2)
+
+	// version 1 appears in s1, s2 and again in s5 after the file was deleted.
+	require.EqualValues(t, s1.id, versions[0].FirstSnapshotID)
+	require.EqualValues(t, s5.id, versions[0].LastSnapshotID)
+	require.Equal(t, 3, versions[0].SnapshotCount)
+	require.EqualValues(t, len("version 1"), versions[0].Size)
+	require.True(t, versions[0].FirstSnapshotTime.Equal(s1.startTime))
+
+	require.EqualValues(t, s3.id, versions[1].FirstSnapshotID)
+	require.EqualValues(t, s3.id, versions[1].LastSnapshotID)
+	require.Equal(t, 1, versions[1].SnapshotCount)
+
+	out := env.RunAndExpectSuccess(t, "snapshot", "history", reportFile)
+	require.Len(t, out, 3)
+
+	// directories have history too.
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "history", filepath.Join(dir, "docs"), "--json"), &versions)
+	require.Greater(t, len(versions), 1)
+	require.True(t, versions[0].IsDir)
+
+	env.RunAndExpectFailure(t, "snapshot", "history", testutil.TempDirectory(t))
+}
+
+type manifestIDAndTime struct {
+	id        string
+	startTime time.Time
+}
@@ -3,7 +3,6 @@
 import (
 	"context"
 	"fmt"
-	"path/filepath"
 	"strings"
 	"time"
 
@@ -57,36 +56,6 @@ func (c *commandSnapshotList) setup(svc appServices, parent commandParent) {
 	cmd.Action(svc.repositoryReaderAction(c.run))
 }
 
-func findSnapshotsForSource(ctx context.Context, rep repo.Repository, sourceInfo snapshot.SourceInfo, tags map[string]string) (manifestIDs []manifest.ID, relPath string, err error) {
-	for len(sourceInfo.Path) > 0 {
-		list, err := snapshot.ListSnapshotManifests(ctx, rep, &sourceInfo, tags)
-		if err != nil {
-			return nil, "", errors.Wrapf(err, "error listing manifests for %v", sourceInfo)
-		}
-
-		if len(list) > 0 {
-			return list, relPath, nil
-		}
-
-		if len(relPath) > 0 {
-			relPath = filepath.Base(sourceInfo.Path) + "/" + relPath
-		} else {
-			relPath = filepath.Base(sourceInfo.Path)
-		}
-
-		log(ctx).Debugf("No snapshots of %v@%v:%v", sourceInfo.UserName, sourceInfo.Host, sourceInfo.Path)
-
-		parentPath := filepath.Dir(sourceInfo.Path)
-		if parentPath == sourceInfo.Path {
-			break
-		}
-
-		sourceInfo.Path = parentPath
-	}
-
-	return nil, "", nil
-}
-
 func findManifestIDs(ctx context.Context, rep repo.Repository, source string, tags map[string]string) ([]manifest.ID, string, error) {
 	if source == "" {
 		man, err := snapshot.ListSnapshotManifests(ctx, rep, nil, tags)
@@ -98,7 +67,7 @@ func findManifestIDs(ctx context.Context, rep repo.Repository, source string, ta
 		return nil, "", errors.Errorf("invalid directory: '%s': %s", source, err)
 	}
 
-	manifestIDs, relPath, err := findSnapshotsForSource(ctx, rep, si, tags)
+	manifestIDs, relPath, err := snapshot.ListSnapshotManifestsForPath(ctx, rep, si, tags)
 	if relPath != "" {
 		relPath = "/" + relPath
 	}
@@ -239,7 +208,8 @@ func (c *commandSnapshotList) outputManifestFromSingleSource(ctx context.Context
 		bits, col := c.entryBits(ctx, m, ent, lastTotalFileSize)
 
 		oid := ent.(object.HasObjectID).ObjectID()
-		if !c.snapshotListShowIdentical && oid == previousOID {
+		// pinned snapshots are always shown, even if identical to the previous one.
+		if !c.snapshotListShowIdentical && oid == previousOID && len(m.Pins) == 0 {
 			elidedCount++
 
 			maxElidedTime = m.StartTime
@@ -312,6 +282,10 @@ func (c *commandSnapshotList) entryBits(ctx context.Context, m *snapshot.Manifes
 		}
 	}
 
+	if len(m.Pins) > 0 {
+		bits = append(bits, "pins:"+strings.Join(m.Pins, ","))
+	}
+
 	return bits, col
 }
 
@@ -0,0 +1,73 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+)
+
+const defaultPinReason = "pinned"
+
+type commandSnapshotPin struct {
+	ids     []string
+	reasons []string
+
+	unpin bool
+}
+
+func (c *commandSnapshotPin) setup(svc appServices, parent commandParent, unpin bool) {
+	var cmd *kingpin.CmdClause
+
+	if unpin {
+		cmd = parent.Command("unpin", "Remove pins from snapshots, allowing retention policy to delete them.")
+		cmd.Flag("reason", "Pin to remove (can be specified multiple times, defaults to all pins)").StringsVar(&c.reasons)
+	} else {
+		cmd = parent.Command("pin", "Pin snapshots, preventing retention policy from deleting them.")
+		cmd.Flag("reason", "Reason for pinning the snapshot (can be specified multiple times)").Default(defaultPinReason).StringsVar(&c.reasons)
+	}
+
+	cmd.Arg("id", "Snapshot ID").Required().StringsVar(&c.ids)
+
+	c.unpin = unpin
+
+	cmd.Action(svc.repositoryWriterAction(c.run))
+}
+
+func (c *commandSnapshotPin) run(ctx context.Context, rep repo.RepositoryWriter) error {
+	for _, id := range c.ids {
+		m, err := snapshot.LoadSnapshot(ctx, rep, manifest.ID(id))
+		if err != nil {
+			return errors.Wrapf(err, "error loading snapshot %v", id)
+		}
+
+		var changed bool
+
+		switch {
+		case !c.unpin:
+			changed = m.UpdatePins(c.reasons, nil)
+		case len(c.reasons) == 0:
+			changed = m.UpdatePins(nil, m.Pins)
+		default:
+			changed = m.UpdatePins(nil, c.reasons)
+		}
+
+		if !changed {
+			log(ctx).Infof("Snapshot %v unchanged.", id)
+			continue
+		}
+
+		newID, err := snapshot.UpdateSnapshot(ctx, rep, m)
+		if err != nil {
+			return errors.Wrapf(err, "error updating snapshot %v", id)
+		}
+
+		log(ctx).Infof("Updated snapshot %v (new ID %v), pins: %v", id, newID, m.Pins)
+	}
+
+	return nil
+}
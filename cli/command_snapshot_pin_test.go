@@ -0,0 +1,76 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotPin(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	dir := testutil.TempDirectory(t)
+
+	var first snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "create", dir, "--json"), &first)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dir)
+
+	env.RunAndExpectSuccess(t, "snapshot", "pin", string(first.ID), "--reason=legal-hold", "--reason=audit")
+
+	var manifests []*snapshot.Manifest
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Len(t, manifests, 3)
+
+	pinned := findPinnedSnapshot(t, manifests)
+	require.Equal(t, []string{"audit", "legal-hold"}, pinned.Pins)
+	require.NotEqual(t, first.ID, pinned.ID)
+
+	// pinned snapshots are shown even when identical to the previous one.
+	lines := env.RunAndExpectSuccess(t, "snapshot", "list", dir)
+	require.Contains(t, lines[1], "pins:audit,legal-hold")
+
+	env.RunAndExpectSuccess(t, "policy", "set", dir, "--keep-latest=1", "--keep-hourly=0", "--keep-daily=0",
+		"--keep-weekly=0", "--keep-monthly=0", "--keep-annual=0")
+	env.RunAndExpectSuccess(t, "snapshot", "expire", dir, "--delete")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Len(t, manifests, 2)
+	require.Equal(t, pinned.ID, findPinnedSnapshot(t, manifests).ID)
+
+	env.RunAndExpectSuccess(t, "snapshot", "unpin", string(pinned.ID), "--reason=audit")
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+
+	pinned = findPinnedSnapshot(t, manifests)
+	require.Equal(t, []string{"legal-hold"}, pinned.Pins)
+
+	env.RunAndExpectSuccess(t, "snapshot", "unpin", string(pinned.ID))
+	env.RunAndExpectSuccess(t, "snapshot", "expire", dir, "--delete")
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "list", dir, "--json"), &manifests)
+	require.Len(t, manifests, 1)
+
+	env.RunAndExpectFailure(t, "snapshot", "pin", "no-such-snapshot")
+}
+
+func findPinnedSnapshot(t *testing.T, manifests []*snapshot.Manifest) *snapshot.Manifest {
+	t.Helper()
+
+	for _, m := range manifests {
+		if len(m.Pins) > 0 {
+			return m
+		}
+	}
+
+	t.Fatalf("pinned snapshot not found")
+
+	return nil
+}
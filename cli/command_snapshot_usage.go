@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
+)
+
+type commandSnapshotUsage struct {
+	source        string
+	showSnapshots bool
+	humanReadable bool
+
+	jo  jsonOutput
+	out textOutput
+}
+
+func (c *commandSnapshotUsage) setup(svc appServices, parent commandParent) {
+	cmd := parent.Command("usage", "Show storage space attributed to snapshots and sources, including space freed by deleting them.")
+	cmd.Arg("source", "Only show usage of the provided source.").StringVar(&c.source)
+	cmd.Flag("snapshots", "Show usage of individual snapshots").Default("true").BoolVar(&c.showSnapshots)
+	cmd.Flag("human-readable", "Show human-readable units").Default("true").BoolVar(&c.humanReadable)
+	c.jo.setup(svc, cmd)
+	c.out.setup(svc)
+	cmd.Action(svc.directRepositoryReadAction(c.run))
+}
+
+func (c *commandSnapshotUsage) run(ctx context.Context, rep repo.DirectRepository) error {
+	report, err := snapshotgc.ComputeUsage(ctx, rep)
+	if err != nil {
+		return errors.Wrap(err, "unable to compute usage")
+	}
+
+	if c.source != "" {
+		si, err := snapshot.ParseSourceInfo(c.source, rep.ClientOptions().Hostname, rep.ClientOptions().Username)
+		if err != nil {
+			return errors.Wrapf(err, "invalid source: '%s'", c.source)
+		}
+
+		report = filterUsageReport(report, si)
+	}
+
+	if c.jo.jsonOutput {
+		c.out.printStdout("%s\n", c.jo.jsonBytes(report))
+		return nil
+	}
+
+	for _, src := range report.Sources {
+		c.out.printStdout("%v\n", src.Source)
+		c.out.printStdout("  %v snapshots, referenced: %v, exclusive: %v\n",
+			src.SnapshotCount,
+			maybeHumanReadableBytes(c.humanReadable, src.ReferencedBytes),
+			maybeHumanReadableBytes(c.humanReadable, src.ExclusiveBytes))
+
+		if !c.showSnapshots {
+			continue
+		}
+
+		for _, s := range report.Snapshots {
+			if s.Source != src.Source {
+				continue
+			}
+
+			c.out.printStdout("  %v %v referenced: %10v exclusive: %10v\n",
+				s.ID,
+				formatTimestamp(s.StartTime),
+				maybeHumanReadableBytes(c.humanReadable, s.ReferencedBytes),
+				maybeHumanReadableBytes(c.humanReadable, s.ExclusiveBytes))
+		}
+	}
+
+	c.out.printStdout("\nReferenced by snapshots: %v\n", maybeHumanReadableBytes(c.humanReadable, report.ReferencedBytes))
+	c.out.printStdout("Shared between sources:  %v\n", maybeHumanReadableBytes(c.humanReadable, report.SharedBytes))
+	c.out.printStdout("Unreferenced:            %v\n", maybeHumanReadableBytes(c.humanReadable, report.UnreferencedBytes))
+	c.out.printStdout("Repository metadata:     %v\n", maybeHumanReadableBytes(c.humanReadable, report.SystemBytes))
+
+	return nil
+}
+
+// filterUsageReport returns a copy of the report that only includes the provided source.
+func filterUsageReport(report *snapshotgc.UsageReport, si snapshot.SourceInfo) *snapshotgc.UsageReport {
+	result := *report
+	result.Sources = nil
+	result.Snapshots = nil
+
+	for _, src := range report.Sources {
+		if src.Source == si {
+			result.Sources = append(result.Sources, src)
+		}
+	}
+
+	for _, s := range report.Snapshots {
+		if s.Source == si {
+			result.Snapshots = append(result.Snapshots, s)
+		}
+	}
+
+	return &result
+}
@@ -0,0 +1,101 @@
+package cli_test
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/snapshot/snapshotgc"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestSnapshotUsage(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	const uniqueSize = 100000
+
+	common := []byte("contents shared between both sources")
+
+	unique := make([]byte, uniqueSize)
+	rand.Read(unique)
+
+	dirA := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dirA, "common.txt"), common, 0o600))
+
+	dirB := testutil.TempDirectory(t)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dirB, "common.txt"), common, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dirB, "unique.bin"), unique, 0o600))
+
+	// nested directory snapshotted both as a part of B and as its own source.
+	nested := make([]byte, uniqueSize)
+	rand.Read(nested)
+
+	dirC := filepath.Join(dirB, "nested")
+	require.NoError(t, os.Mkdir(dirC, 0o700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dirC, "nested.bin"), nested, 0o600))
+
+	env.RunAndExpectSuccess(t, "snapshot", "create", dirA)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dirB)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dirB)
+	env.RunAndExpectSuccess(t, "snapshot", "create", dirC)
+
+	var report snapshotgc.UsageReport
+
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "usage", "--json"), &report)
+
+	require.Len(t, report.Sources, 3)
+	require.Len(t, report.Snapshots, 4)
+
+	var srcA, srcB, srcC *snapshotgc.SourceUsage
+
+	for _, src := range report.Sources {
+		switch src.Source.Path {
+		case dirA:
+			srcA = src
+		case dirB:
+			srcB = src
+		case dirC:
+			srcC = src
+		}
+	}
+
+	require.NotNil(t, srcA)
+	require.NotNil(t, srcB)
+	require.NotNil(t, srcC)
+	require.Equal(t, 1, srcA.SnapshotCount)
+	require.Equal(t, 2, srcB.SnapshotCount)
+	require.Equal(t, 1, srcC.SnapshotCount)
+
+	// the unique file is only referenced by B, the common file is shared.
+	require.GreaterOrEqual(t, srcB.ExclusiveBytes, int64(uniqueSize))
+	require.Less(t, srcA.ExclusiveBytes, int64(uniqueSize))
+	require.GreaterOrEqual(t, report.SharedBytes, int64(uniqueSize))
+
+	// everything in C is also referenced by B.
+	require.Zero(t, srcC.ExclusiveBytes)
+	require.GreaterOrEqual(t, srcC.ReferencedBytes, int64(uniqueSize))
+	require.GreaterOrEqual(t, srcB.ReferencedBytes, srcC.ReferencedBytes+int64(uniqueSize))
+
+	for _, s := range report.Snapshots {
+		require.LessOrEqual(t, s.ExclusiveBytes, s.ReferencedBytes)
+
+		// both snapshots of B are identical, so neither has exclusive contents.
+		if s.Source.Path == dirB {
+			require.Zero(t, s.ExclusiveBytes)
+		}
+	}
+
+	// filtering by source.
+	testutil.MustParseJSONLines(t, env.RunAndExpectSuccess(t, "snapshot", "usage", dirA, "--json"), &report)
+	require.Len(t, report.Sources, 1)
+	require.Len(t, report.Snapshots, 1)
+
+	env.RunAndExpectSuccess(t, "snapshot", "usage")
+}
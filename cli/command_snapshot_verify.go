@@ -139,6 +139,11 @@ func (v *verifier) doVerifyDirectory(ctx context.Context, oid object.ID, path st
 		}
 
 		objectID := e.(object.HasObjectID).ObjectID()
+		if objectID == "" {
+			// special files are stored without an object.
+			continue
+		}
+
 		childPath := path + "/" + e.Name()
 
 		if e.IsDir() {
@@ -3,6 +3,7 @@
 import (
 	"context"
 	"encoding/base64"
+	"strconv"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/pkg/errors"
@@ -11,12 +12,16 @@
 	"github.com/kopia/kopia/repo"
 )
 
+// argon2idPasswordHashVersion is the user password hash version using Argon2id.
+const argon2idPasswordHashVersion = 2
+
 type commandServerUserAddSet struct {
 	userAskPassword            bool
 	userSetName                string
 	userSetPassword            string
 	userSetPasswordHashVersion int
 	userSetPasswordHash        string
+	argon2                     argon2Flags
 
 	isNew bool // true == 'add', false == 'update'
 	out   textOutput
@@ -36,7 +41,8 @@ func (c *commandServerUserAddSet) setup(svc appServices, parent commandParent, i
 	cmd.Flag("ask-password", "Ask for user password").BoolVar(&c.userAskPassword)
 	cmd.Flag("user-password", "Password").StringVar(&c.userSetPassword)
 	cmd.Flag("user-password-hash", "Password hash").StringVar(&c.userSetPasswordHash)
-	cmd.Flag("user-password-hash-version", "Password hash version").Default("1").IntVar(&c.userSetPasswordHashVersion)
+	cmd.Flag("user-password-hash-version", "Password hash version (1 - scrypt, 2 - Argon2id)").Default(strconv.Itoa(user.DefaultPasswordHashVersion)).IntVar(&c.userSetPasswordHashVersion)
+	c.argon2.setup(cmd)
 	cmd.Arg("username", "Username").Required().StringVar(&c.userSetName)
 	cmd.Action(svc.repositoryWriterAction(c.runServerUserAddSet))
 
@@ -61,6 +67,19 @@ func (c *commandServerUserAddSet) getExistingOrNewUserProfile(ctx context.Contex
 	return up, errors.Wrap(err, "error getting user profile")
 }
 
+func (c *commandServerUserAddSet) setPassword(up *user.Profile, password string) error {
+	if c.argon2.specified() {
+		params, err := c.argon2.parameters()
+		if err != nil {
+			return err
+		}
+
+		return errors.Wrap(up.SetPasswordWithArgon2Parameters(password, params), "error setting password")
+	}
+
+	return errors.Wrap(up.SetPasswordWithHashVersion(password, c.userSetPasswordHashVersion), "error setting password")
+}
+
 func (c *commandServerUserAddSet) runServerUserAddSet(ctx context.Context, rep repo.RepositoryWriter) error {
 	username := c.userSetName
 
@@ -69,13 +88,17 @@ func (c *commandServerUserAddSet) runServerUserAddSet(ctx context.Context, rep r
 		return err
 	}
 
+	if c.argon2.specified() && c.userSetPasswordHashVersion != argon2idPasswordHashVersion {
+		return errors.Errorf("Argon2id parameters require --user-password-hash-version=%v", argon2idPasswordHashVersion)
+	}
+
 	changed := false
 
 	if p := c.userSetPassword; p != "" {
 		changed = true
 
-		if err := up.SetPassword(p); err != nil {
-			return errors.Wrap(err, "error setting password")
+		if err := c.setPassword(up, p); err != nil {
+			return err
 		}
 	}
 
@@ -107,8 +130,8 @@ func (c *commandServerUserAddSet) runServerUserAddSet(ctx context.Context, rep r
 
 		changed = true
 
-		if err := up.SetPassword(pwd); err != nil {
-			return errors.Wrap(err, "error setting password")
+		if err := c.setPassword(up, pwd); err != nil {
+			return err
 		}
 	}
 
@@ -0,0 +1,42 @@
+package cli_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestServerUserAddArgon2Parameters(t *testing.T) {
+	env := testenv.NewCLITest(t, testenv.NewInProcRunner(t))
+
+	env.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", env.RepoDir)
+
+	// Argon2id parameters require the Argon2id hash version and are subject to the repository limits.
+	env.RunAndExpectFailure(t, "server", "users", "add", "foo@bar", "--user-password=baz", "--argon2-time=1")
+	env.RunAndExpectFailure(t, "server", "users", "add", "foo@bar", "--user-password=baz", "--user-password-hash-version=2", "--argon2-time=1000")
+	env.RunAndExpectFailure(t, "server", "users", "add", "foo@bar", "--user-password=baz", "--user-password-hash-version=2", "--argon2-memory-mb=100000")
+
+	env.RunAndExpectSuccess(t, "server", "users", "add", "foo@bar", "--user-password=baz",
+		"--user-password-hash-version=2", "--argon2-time=1", "--argon2-memory-mb=2", "--argon2-threads=1")
+
+	var up user.Profile
+
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(env.RunAndExpectSuccess(t, "server", "users", "info", "foo@bar"), "\n")), &up))
+	require.Equal(t, 2, up.PasswordHashVersion)
+	require.Equal(t, uint32(1), binary.BigEndian.Uint32(up.PasswordHash[0:4]))
+	require.Equal(t, uint32(2<<10), binary.BigEndian.Uint32(up.PasswordHash[4:8]))
+	require.True(t, up.IsValidPassword("baz"))
+
+	env.RunAndExpectSuccess(t, "server", "users", "set", "foo@bar", "--user-password=baz2",
+		"--user-password-hash-version=2", "--argon2-time=2")
+
+	require.NoError(t, json.Unmarshal([]byte(strings.Join(env.RunAndExpectSuccess(t, "server", "users", "info", "foo@bar"), "\n")), &up))
+	require.Equal(t, uint32(2), binary.BigEndian.Uint32(up.PasswordHash[0:4]))
+	require.True(t, up.IsValidPassword("baz2"))
+}
@@ -52,6 +52,13 @@ func (c *App) openRepository(ctx context.Context, required bool) (repo.Repositor
 	}
 
 	r, err := repo.Open(ctx, c.repositoryConfigFileName(), pass, c.optionsFromFlags(ctx))
+	if errors.Is(err, repo.ErrInvalidPassword) && c.pp.strategy != nil {
+		// the cached password may be stale, make sure the next attempt fetches it from the provider again.
+		if derr := c.pp.strategy.DeletePassword(ctx, c.repositoryConfigFileName()); derr != nil {
+			log(ctx).Errorf("unable to clear cached password: %v", derr)
+		}
+	}
+
 	if os.IsNotExist(err) {
 		return nil, errors.New("not connected to a repository, use 'kopia connect'")
 	}
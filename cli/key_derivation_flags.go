@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+const maxArgon2Threads = 255
+
+// argon2Flags specifies tunable parameters of Argon2id, which are also used for hashing user passwords.
+type argon2Flags struct {
+	time      int
+	memoryMiB int
+	threads   int
+
+	timeSet    bool
+	memorySet  bool
+	threadsSet bool
+}
+
+func (c *argon2Flags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("argon2-time", "Number of Argon2id iterations").Default("3").IsSetByUser(&c.timeSet).IntVar(&c.time)
+	cmd.Flag("argon2-memory-mb", "Amount of memory used by Argon2id in MiB").Default("64").IsSetByUser(&c.memorySet).IntVar(&c.memoryMiB)
+	cmd.Flag("argon2-threads", "Degree of parallelism of Argon2id").Default("4").IsSetByUser(&c.threadsSet).IntVar(&c.threads)
+}
+
+func (c *argon2Flags) specified() bool {
+	return c.timeSet || c.memorySet || c.threadsSet
+}
+
+func (c *argon2Flags) parameters() (repo.Argon2Parameters, error) {
+	if c.time < 1 || c.time > repo.MaxArgon2Time {
+		return repo.Argon2Parameters{}, errors.Errorf("--argon2-time must be between 1 and %v", repo.MaxArgon2Time)
+	}
+
+	// validate before converting to KiB to avoid overflow.
+	if maxMiB := repo.MaxArgon2Memory >> 10; c.memoryMiB < 1 || c.memoryMiB > maxMiB { //nolint:gomnd
+		return repo.Argon2Parameters{}, errors.Errorf("--argon2-memory-mb must be between 1 and %v", maxMiB)
+	}
+
+	if c.threads < 1 || c.threads > maxArgon2Threads {
+		return repo.Argon2Parameters{}, errors.Errorf("--argon2-threads must be between 1 and %v", maxArgon2Threads)
+	}
+
+	return repo.Argon2Parameters{
+		Time:    uint32(c.time),
+		Memory:  uint32(c.memoryMiB) << 10, //nolint:gomnd
+		Threads: uint8(c.threads),
+	}, nil
+}
+
+type keyDerivationFlags struct {
+	algorithm string
+	argon2    argon2Flags
+}
+
+func (c *keyDerivationFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("key-derivation", "Algorithm used to derive the repository key from the password").PlaceHolder("ALGO").EnumVar(&c.algorithm, repo.SupportedKeyDerivationAlgorithms()...)
+	c.argon2.setup(cmd)
+}
+
+// specified returns true if the key derivation algorithm or any of its parameters was specified.
+func (c *keyDerivationFlags) specified() bool {
+	return c.algorithm != "" || c.argon2.specified()
+}
+
+func (c *keyDerivationFlags) keyDerivationOptions() (repo.KeyDerivationOptions, error) {
+	kd := repo.KeyDerivationOptions{
+		Algorithm: c.algorithm,
+	}
+
+	if c.algorithm != repo.KeyDerivationArgon2id {
+		if c.argon2.specified() {
+			return kd, errors.Errorf("Argon2id parameters require --key-derivation=%v", repo.KeyDerivationArgon2id)
+		}
+
+		return kd, nil
+	}
+
+	p, err := c.argon2.parameters()
+	if err != nil {
+		return kd, err
+	}
+
+	kd.Argon2 = &p
+
+	return kd, nil
+}
@@ -11,8 +11,21 @@
 	"golang.org/x/term"
 
 	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/repo"
 )
 
+// minProviderPasswordLength is the minimum length of passwords returned by external providers
+// for newly created repositories, which catches providers returning empty or truncated output.
+const minProviderPasswordLength = 8
+
+func validateProviderPassword(p string) error {
+	if len(p) < minProviderPasswordLength {
+		return errors.Errorf("password must be at least %v characters long", minProviderPasswordLength)
+	}
+
+	return nil
+}
+
 func askForNewRepositoryPassword(out io.Writer) (string, error) {
 	for {
 		p1, err := askPass(out, "Enter password to create new repository: ")
@@ -69,10 +82,20 @@ func (c *App) setPasswordFromToken(pwd string) {
 }
 
 func (c *App) getPasswordFromFlags(ctx context.Context, isNew, allowPersistent bool) (string, error) {
+	if c.password == "" && allowPersistent {
+		// use the password provider persisted when connecting unless one was specified by flags.
+		if err := c.loadPasswordProvider(); err != nil {
+			return "", err
+		}
+	}
+
 	switch {
 	case c.password != "":
 		// password provided via --password flag or KOPIA_PASSWORD environment variable
 		return strings.TrimSpace(c.password), nil
+	case c.pp.strategy != nil:
+		// password provided by the password command or secret endpoint, don't fall back to asking on failure.
+		return c.getPasswordFromProvider(ctx, isNew)
 	case isNew:
 		// this is a new repository, ask for password
 		return askForNewRepositoryPassword(c.stdoutWriter)
@@ -92,6 +115,56 @@ func (c *App) getPasswordFromFlags(ctx context.Context, isNew, allowPersistent b
 	return askForExistingRepositoryPassword(c.stdoutWriter)
 }
 
+// passwordProviderConfig returns the configuration of the password provider specified by flags, which
+// is persisted when connecting.
+func (c *App) passwordProviderConfig() (*repo.PasswordProviderConfig, error) {
+	return c.pp.persistedConfig()
+}
+
+// loadPasswordProvider loads the password provider persisted in the configuration file, if any.
+func (c *App) loadPasswordProvider() error {
+	return c.pp.loadFromConfigFile(c.repositoryConfigFileName())
+}
+
+// getPasswordFromProvider retrieves the password from the external provider. When creating a new repository,
+// the password is fetched twice bypassing the cache to confirm that the provider returns it consistently,
+// since the repository would be unrecoverable otherwise.
+func (c *App) getPasswordFromProvider(ctx context.Context, isNew bool) (string, error) {
+	configFile := c.repositoryConfigFileName()
+
+	fetch := func() (string, error) {
+		if isNew {
+			if err := c.pp.strategy.DeletePassword(ctx, configFile); err != nil {
+				return "", errors.Wrap(err, "error clearing cached password")
+			}
+		}
+
+		pass, err := c.pp.strategy.GetPassword(ctx, configFile)
+
+		return pass, errors.Wrap(err, "error getting password from external provider")
+	}
+
+	pass, err := fetch()
+	if err != nil || !isNew {
+		return pass, err
+	}
+
+	if err := validateProviderPassword(pass); err != nil {
+		return "", errors.Wrap(err, "invalid password returned by external provider")
+	}
+
+	confirm, err := fetch()
+	if err != nil {
+		return "", err
+	}
+
+	if confirm != pass {
+		return "", errors.Errorf("external provider returned different passwords on subsequent requests")
+	}
+
+	return pass, nil
+}
+
 // askPass presents a given prompt and asks the user for password.
 func askPass(out io.Writer, prompt string) (string, error) {
 	for i := 0; i < 5; i++ {
@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"encoding/csv"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/repo"
+)
+
+// passwordProviderFlags configures retrieving the repository password from an external secret source.
+type passwordProviderFlags struct {
+	command        string
+	commandTimeout time.Duration
+	url            string
+	urlHeaders     []string
+	urlJSONField   string
+	urlTimeout     time.Duration
+	cacheMode      string
+	cacheTTL       time.Duration
+
+	strategy passwordpersist.Strategy
+}
+
+func (c *passwordProviderFlags) setup(app *kingpin.Application) {
+	var cacheModes []string
+	for _, m := range passwordpersist.CacheModes {
+		cacheModes = append(cacheModes, string(m))
+	}
+
+	app.Flag("password-command", "Command that prints the repository password to standard output, remembered when connecting").Envar("KOPIA_PASSWORD_COMMAND").StringVar(&c.command)
+	app.Flag("password-command-timeout", "Timeout of the password command").Default(passwordpersist.DefaultCommandTimeout.String()).Envar("KOPIA_PASSWORD_COMMAND_TIMEOUT").DurationVar(&c.commandTimeout)
+	app.Flag("password-url", "URL of the HTTP secret endpoint returning the repository password, remembered when connecting").Envar("KOPIA_PASSWORD_URL").StringVar(&c.url)
+	app.Flag("password-url-header", "HTTP header to send to the secret endpoint (Name: value), only headers whose value references an environment variable (Name: $VAR) are remembered when connecting").Envar("KOPIA_PASSWORD_URL_HEADER").StringsVar(&c.urlHeaders)
+	app.Flag("password-url-json-field", "Dot-separated path of the password field in JSON response of the secret endpoint (e.g. data.data.password)").Envar("KOPIA_PASSWORD_URL_JSON_FIELD").StringVar(&c.urlJSONField)
+	app.Flag("password-url-timeout", "Timeout of requests to the secret endpoint").Default(passwordpersist.DefaultHTTPTimeout.String()).Envar("KOPIA_PASSWORD_URL_TIMEOUT").DurationVar(&c.urlTimeout)
+	app.Flag("password-cache", "Caching of passwords retrieved from the password command or secret endpoint").Default(string(passwordpersist.CacheNone)).Envar("KOPIA_PASSWORD_CACHE").EnumVar(&c.cacheMode, cacheModes...)
+	app.Flag("password-cache-ttl", "How long cached passwords remain valid (0 means until disconnected)").Default("5m").Envar("KOPIA_PASSWORD_CACHE_TTL").DurationVar(&c.cacheTTL)
+
+	app.PreAction(c.initialize)
+}
+
+// initialize creates the strategy retrieving the password from the provider specified by flags, if any.
+// The strategy is created once per invocation, so that passwords cached in memory can be reused.
+func (c *passwordProviderFlags) initialize(pc *kingpin.ParseContext) error {
+	cfg := c.config()
+	if cfg == nil {
+		return nil
+	}
+
+	s, err := newPasswordProviderStrategy(cfg)
+	if err != nil {
+		return err
+	}
+
+	c.strategy = s
+
+	return nil
+}
+
+// config returns the password provider configuration specified by flags or nil if none was specified.
+func (c *passwordProviderFlags) config() *repo.PasswordProviderConfig {
+	if c.command == "" && c.url == "" {
+		return nil
+	}
+
+	return &repo.PasswordProviderConfig{
+		Command:        c.command,
+		CommandTimeout: c.commandTimeout,
+		URL:            c.url,
+		URLHeaders:     c.urlHeaders,
+		URLJSONField:   c.urlJSONField,
+		URLTimeout:     c.urlTimeout,
+		CacheMode:      c.cacheMode,
+		CacheTTL:       c.cacheTTL,
+	}
+}
+
+// persistedConfig returns the password provider configuration to be persisted in the configuration file.
+// Header values usually carry credentials, so only references to environment variables may be persisted.
+func (c *passwordProviderFlags) persistedConfig() (*repo.PasswordProviderConfig, error) {
+	cfg := c.config()
+	if cfg == nil {
+		return nil, nil
+	}
+
+	for _, h := range cfg.URLHeaders {
+		name, value, err := parseHeader(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := envReference(value); !ok {
+			return nil, errors.Errorf("value of secret endpoint header %q would be stored in plain text, provide it in an environment variable referenced as '%v: $VAR'", name, name)
+		}
+	}
+
+	return cfg, nil
+}
+
+// loadFromConfigFile creates the strategy from the password provider persisted in the configuration file
+// when connecting, unless a provider was specified by flags.
+func (c *passwordProviderFlags) loadFromConfigFile(configFile string) error {
+	if c.strategy != nil {
+		return nil
+	}
+
+	lc, err := repo.LoadConfigFromFile(configFile)
+	if err != nil {
+		if os.IsNotExist(errors.Cause(err)) {
+			return nil
+		}
+
+		return errors.Wrap(err, "error loading password provider")
+	}
+
+	if lc.PasswordProvider == nil {
+		return nil
+	}
+
+	s, err := newPasswordProviderStrategy(lc.PasswordProvider)
+	if err != nil {
+		return errors.Wrap(err, "invalid password provider in configuration file")
+	}
+
+	c.strategy = s
+
+	return nil
+}
+
+func newPasswordProviderStrategy(cfg *repo.PasswordProviderConfig) (passwordpersist.Strategy, error) {
+	p, err := newPasswordProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return passwordpersist.External(p, passwordpersist.CachePolicy{
+		Mode: passwordpersist.CacheMode(cfg.CacheMode),
+		TTL:  cfg.CacheTTL,
+	}), nil
+}
+
+func newPasswordProvider(cfg *repo.PasswordProviderConfig) (passwordpersist.Provider, error) {
+	if cfg.Command != "" && cfg.URL != "" {
+		return nil, errors.Errorf("--password-command and --password-url are mutually exclusive")
+	}
+
+	if cfg.Command != "" {
+		// parse command as CSV as if space was the separator, this automatically takes care of quotations
+		r := csv.NewReader(strings.NewReader(cfg.Command))
+		r.Comma = ' ' // space
+
+		fields, err := r.Read()
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing password command")
+		}
+
+		return passwordpersist.CommandProvider{
+			Command:   fields[0],
+			Arguments: fields[1:],
+			Timeout:   cfg.CommandTimeout,
+		}, nil
+	}
+
+	headers := http.Header{}
+
+	for _, h := range cfg.URLHeaders {
+		name, value, err := parseHeader(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if envName, ok := envReference(value); ok {
+			value = os.Getenv(envName)
+			if value == "" {
+				return nil, errors.Errorf("environment variable %v referenced by secret endpoint header %q is not set", envName, name)
+			}
+		}
+
+		headers.Add(name, value)
+	}
+
+	return passwordpersist.HTTPProvider{
+		URL:       cfg.URL,
+		Headers:   headers,
+		JSONField: cfg.URLJSONField,
+		Timeout:   cfg.URLTimeout,
+	}, nil
+}
+
+// parseHeader parses the HTTP header specified as 'Name: value'.
+func parseHeader(h string) (name, value string, err error) {
+	p := strings.Index(h, ":")
+	if p <= 0 {
+		return "", "", errors.Errorf("invalid secret endpoint header, must be 'Name: value'")
+	}
+
+	return strings.TrimSpace(h[0:p]), strings.TrimSpace(h[p+1:]), nil
+}
+
+// envReference returns the name of the environment variable if the value is a reference to it
+// in the form of $VAR or ${VAR}.
+func envReference(value string) (string, bool) {
+	if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") && len(value) > len("${}") {
+		return value[2 : len(value)-1], true
+	}
+
+	if strings.HasPrefix(value, "$") && len(value) > 1 && !strings.ContainsAny(value, "{} ") {
+		return value[1:], true
+	}
+
+	return "", false
+}
@@ -0,0 +1,163 @@
+package cli_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/tests/testenv"
+)
+
+func TestPasswordCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not supported on windows")
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	passwordFile := filepath.Join(testutil.TempDirectory(t), "password.txt")
+	require.NoError(t, ioutil.WriteFile(passwordFile, []byte(testenv.TestRepoPassword+"\n"), 0o600))
+
+	runner.RepoPassword = ""
+
+	passwordCommand := "--password-command=cat " + passwordFile
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir, passwordCommand)
+
+	// the password is managed by the command and must not be persisted.
+	require.NoFileExists(t, filepath.Join(e.ConfigDir, ".kopia.config.kopia-password"))
+
+	e.RunAndExpectSuccess(t, "snapshot", "list", passwordCommand)
+
+	// the command is remembered when connecting.
+	e.RunAndExpectSuccess(t, "snapshot", "list")
+
+	// with file cache, the command is not needed until the cache expires.
+	e.RunAndExpectSuccess(t, "snapshot", "list", passwordCommand, "--password-cache=file", "--password-cache-ttl=1h")
+	require.NoError(t, os.Remove(passwordFile))
+	e.RunAndExpectSuccess(t, "snapshot", "list", passwordCommand, "--password-cache=file", "--password-cache-ttl=1h")
+
+	_, stderr, err := e.Run(t, true, "snapshot", "list", passwordCommand)
+	require.Error(t, err)
+	require.Contains(t, strings.Join(stderr, "\n"), `password command "cat" failed: exit status 1`)
+
+	// disconnecting clears the cache.
+	e.RunAndExpectSuccess(t, "repo", "disconnect", passwordCommand, "--password-cache=file")
+	require.NoFileExists(t, filepath.Join(e.ConfigDir, ".kopia.config.kopia-password-cache"))
+
+	e.RunAndExpectFailure(t, "snapshot", "list", "--password-command=cat "+passwordFile, "--password-url=http://localhost")
+}
+
+func TestPasswordCommandStaleCache(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not supported on windows")
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+
+	runner.RepoPassword = ""
+
+	cacheFlags := []string{
+		"--password-command=echo " + testenv.TestRepoPassword,
+		"--password-cache=file",
+		"--password-cache-ttl=0",
+	}
+
+	// simulate cached password which is no longer valid.
+	cacheFile := filepath.Join(e.ConfigDir, ".kopia.config.kopia-password-cache")
+	require.NoError(t, ioutil.WriteFile(cacheFile, []byte(`{"password":"d3Jvbmc="}`), 0o600))
+
+	e.RunAndExpectFailure(t, append([]string{"snapshot", "list"}, cacheFlags...)...)
+	require.NoFileExists(t, cacheFile)
+
+	e.RunAndExpectSuccess(t, append([]string{"snapshot", "list"}, cacheFlags...)...)
+	require.FileExists(t, cacheFile)
+}
+
+func TestPasswordCommandCreate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not supported on windows")
+	}
+
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	runner.RepoPassword = ""
+
+	// too short
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--password-command=echo short")
+
+	// not stable between invocations
+	e.RunAndExpectFailure(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--password-command=sh -c \"echo password-$$\"")
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--password-command=echo "+testenv.TestRepoPassword)
+}
+
+func TestPasswordURL(t *testing.T) {
+	runner := testenv.NewInProcRunner(t)
+	e := testenv.NewCLITest(t, runner)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir)
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "some-token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(`{"data":{"data":{"password":"` + testenv.TestRepoPassword + `"}}}`)) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	runner.RepoPassword = ""
+
+	urlFlags := []string{
+		"--password-url=" + srv.URL + "/v1/secret/data/kopia",
+		"--password-url-json-field=data.data.password",
+	}
+
+	_, _, err := e.Run(t, true, append([]string{"repo", "connect", "filesystem", "--path", e.RepoDir}, urlFlags...)...)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "/v1/secret/data/kopia\" returned 403 Forbidden")
+
+	// header values would be stored in plain text, so they can't be remembered when connecting.
+	e.RunAndExpectFailure(t, append([]string{"repo", "connect", "filesystem", "--path", e.RepoDir, "--password-url-header=X-Vault-Token: some-token"}, urlFlags...)...)
+
+	const tokenEnv = "KOPIA_TEST_PASSWORD_URL_TOKEN"
+
+	urlFlags = append(urlFlags, "--password-url-header=X-Vault-Token: ${"+tokenEnv+"}")
+
+	e.RunAndExpectFailure(t, append([]string{"repo", "connect", "filesystem", "--path", e.RepoDir}, urlFlags...)...)
+
+	os.Setenv(tokenEnv, "some-token")
+	defer os.Unsetenv(tokenEnv)
+
+	e.RunAndExpectSuccess(t, append([]string{"repo", "connect", "filesystem", "--path", e.RepoDir}, urlFlags...)...)
+	e.RunAndExpectSuccess(t, append([]string{"snapshot", "list"}, urlFlags...)...)
+	e.RunAndExpectSuccess(t, "snapshot", "list", "--password-url="+srv.URL+"/v1/secret/data/kopia", "--password-url-json-field=data.data.password", "--password-url-header=X-Vault-Token: some-token")
+
+	// the secret endpoint and references to environment variables with header values are remembered when connecting.
+	e.RunAndExpectSuccess(t, "snapshot", "list")
+
+	configData, err := ioutil.ReadFile(filepath.Join(e.ConfigDir, ".kopia.config"))
+	require.NoError(t, err)
+	require.NotContains(t, string(configData), "some-token")
+
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+}
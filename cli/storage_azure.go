@@ -10,7 +10,8 @@
 )
 
 type storageAzureFlags struct {
-	azOptions azure.Options
+	azOptions   azure.Options
+	accessTiers storageClassFlags
 }
 
 func (c *storageAzureFlags) setup(_ storageProviderServices, cmd *kingpin.CmdClause) {
@@ -22,9 +23,17 @@ func (c *storageAzureFlags) setup(_ storageProviderServices, cmd *kingpin.CmdCla
 	cmd.Flag("prefix", "Prefix to use for objects in the bucket").StringVar(&c.azOptions.Prefix)
 	cmd.Flag("max-download-speed", "Limit the download speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&c.azOptions.MaxDownloadSpeedBytesPerSecond)
 	cmd.Flag("max-upload-speed", "Limit the upload speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&c.azOptions.MaxUploadSpeedBytesPerSecond)
+	c.accessTiers.setup(cmd, "access-tier", "Azure access tier (Hot, Cool or Archive) to use for blobs", "Archive")
 }
 
 func (c *storageAzureFlags) connect(ctx context.Context, isNew bool) (blob.Storage, error) {
+	tiers, err := c.accessTiers.storageClassMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.azOptions.StorageClasses = tiers
+
 	// nolint:wrapcheck
 	return azure.New(ctx, &c.azOptions)
 }
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// storageClassFlags handles flags that assign storage classes to blobs by blob ID prefix.
+type storageClassFlags struct {
+	classes []string
+
+	// archiveClasses are classes from which blobs must be restored before they can be read.
+	archiveClasses []string
+}
+
+// setup registers the flag, archiveClasses are only allowed for data pack blob prefixes,
+// since other blobs must be readable at all times.
+func (c *storageClassFlags) setup(cmd *kingpin.CmdClause, name, help string, archiveClasses ...string) {
+	c.archiveClasses = archiveClasses
+
+	cmd.Flag(name, help+" in the form [PREFIX=]CLASS, where the longest matching blob ID prefix wins and CLASS alone sets the default, can be repeated").PlaceHolder("[PREFIX=]CLASS").StringsVar(&c.classes)
+}
+
+// storageClassMap returns the mapping of blob ID prefixes to storage classes or nil if none were specified.
+func (c *storageClassFlags) storageClassMap(ctx context.Context) (blob.StorageClassMap, error) {
+	if len(c.classes) == 0 {
+		return nil, nil
+	}
+
+	result := blob.StorageClassMap{}
+
+	for _, v := range c.classes {
+		var prefix, class string
+
+		if p := strings.Index(v, "="); p >= 0 {
+			prefix, class = v[0:p], v[p+1:]
+		} else {
+			class = v
+		}
+
+		if class == "" {
+			return nil, errors.Errorf("invalid storage class %q", v)
+		}
+
+		if _, ok := result[blob.ID(prefix)]; ok {
+			return nil, errors.Errorf("duplicate storage class for prefix %q", prefix)
+		}
+
+		if c.isArchiveClass(class) {
+			if !strings.HasPrefix(prefix, string(content.PackBlobIDPrefixRegular)) {
+				return nil, errors.Errorf("archive storage class %q can only be used for data pack blobs with prefix %q", class, content.PackBlobIDPrefixRegular)
+			}
+
+			log(ctx).Infof("WARNING: Blobs with prefix %q will use archive storage class %v and must be restored from the archive before any snapshot data stored in them can be read.", prefix, class)
+		}
+
+		result[blob.ID(prefix)] = class
+	}
+
+	return result, nil
+}
+
+func (c *storageClassFlags) isArchiveClass(class string) bool {
+	for _, ac := range c.archiveClasses {
+		if strings.EqualFold(ac, class) {
+			return true
+		}
+	}
+
+	return false
+}
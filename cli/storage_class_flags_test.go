@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestStorageClassFlags(t *testing.T) {
+	ctx := context.Background()
+
+	var c storageClassFlags
+
+	m, err := c.storageClassMap(ctx)
+	require.NoError(t, err)
+	require.Nil(t, m)
+
+	c.classes = []string{"STANDARD", "p=STANDARD_IA", "xn=ONEZONE_IA"}
+
+	m, err = c.storageClassMap(ctx)
+	require.NoError(t, err)
+	require.Equal(t, blob.StorageClassMap{
+		"":   "STANDARD",
+		"p":  "STANDARD_IA",
+		"xn": "ONEZONE_IA",
+	}, m)
+
+	c.classes = []string{"p="}
+
+	_, err = c.storageClassMap(ctx)
+	require.Error(t, err)
+
+	c.classes = []string{"p=A", "p=B"}
+
+	_, err = c.storageClassMap(ctx)
+	require.Error(t, err)
+
+	// archive classes are only allowed for data pack blobs.
+	c.archiveClasses = []string{"GLACIER", "DEEP_ARCHIVE"}
+
+	for _, classes := range [][]string{
+		{"GLACIER"},
+		{"q=DEEP_ARCHIVE"},
+		{"xn=glacier"},
+		{"STANDARD", "kopia=GLACIER"},
+	} {
+		c.classes = classes
+
+		_, err = c.storageClassMap(ctx)
+		require.Error(t, err, classes)
+	}
+
+	c.classes = []string{"STANDARD", "p=DEEP_ARCHIVE"}
+
+	m, err = c.storageClassMap(ctx)
+	require.NoError(t, err)
+	require.Equal(t, blob.StorageClassMap{
+		"":  "STANDARD",
+		"p": "DEEP_ARCHIVE",
+	}, m)
+}
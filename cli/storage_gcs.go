@@ -16,6 +16,7 @@ type storageGCSFlags struct {
 	options gcs.Options
 
 	embedCredentials bool
+	storageClasses   storageClassFlags
 }
 
 func (c *storageGCSFlags) setup(_ storageProviderServices, cmd *kingpin.CmdClause) {
@@ -26,9 +27,17 @@ func (c *storageGCSFlags) setup(_ storageProviderServices, cmd *kingpin.CmdClaus
 	cmd.Flag("max-download-speed", "Limit the download speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&c.options.MaxDownloadSpeedBytesPerSecond)
 	cmd.Flag("max-upload-speed", "Limit the upload speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&c.options.MaxUploadSpeedBytesPerSecond)
 	cmd.Flag("embed-credentials", "Embed GCS credentials JSON in Kopia configuration").BoolVar(&c.embedCredentials)
+	c.storageClasses.setup(cmd, "storage-class", "GCS storage class to use for blobs")
 }
 
 func (c *storageGCSFlags) connect(ctx context.Context, isNew bool) (blob.Storage, error) {
+	sc, err := c.storageClasses.storageClassMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.options.StorageClasses = sc
+
 	if c.embedCredentials {
 		data, err := ioutil.ReadFile(c.options.ServiceAccountCredentialsFile)
 		if err != nil {
@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 
@@ -17,6 +18,12 @@ type storageFlags interface {
 	connect(ctx context.Context, isNew bool) (blob.Storage, error)
 }
 
+// pointInTimeStorageFlags is implemented by storage flags of providers that can present a view of
+// the storage as it existed at a point in time.
+type pointInTimeStorageFlags interface {
+	setPointInTime(t time.Time)
+}
+
 type storageProvider struct {
 	name        string
 	description string
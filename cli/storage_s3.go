@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 
@@ -10,7 +11,8 @@
 )
 
 type storageS3Flags struct {
-	s3options s3.Options
+	s3options      s3.Options
+	storageClasses storageClassFlags
 }
 
 func (c *storageS3Flags) setup(_ storageProviderServices, cmd *kingpin.CmdClause) {
@@ -25,9 +27,23 @@ func (c *storageS3Flags) setup(_ storageProviderServices, cmd *kingpin.CmdClause
 	cmd.Flag("disable-tls-verification", "Disable TLS (HTTPS) certificate verification").BoolVar(&c.s3options.DoNotVerifyTLS)
 	cmd.Flag("max-download-speed", "Limit the download speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&c.s3options.MaxDownloadSpeedBytesPerSecond)
 	cmd.Flag("max-upload-speed", "Limit the upload speed.").PlaceHolder("BYTES_PER_SEC").IntVar(&c.s3options.MaxUploadSpeedBytesPerSecond)
+	cmd.Flag("retention-mode", "Object lock retention mode to apply to all blobs (requires bucket with object locking)").EnumVar(&c.s3options.RetentionMode, "GOVERNANCE", "COMPLIANCE")
+	cmd.Flag("retention-period", "Period for which blobs are protected from deletion, extended by full maintenance and must be longer than its interval").DurationVar(&c.s3options.RetentionPeriod)
+	c.storageClasses.setup(cmd, "storage-class", "S3 storage class to use for blobs", "GLACIER", "DEEP_ARCHIVE")
+}
+
+func (c *storageS3Flags) setPointInTime(t time.Time) {
+	c.s3options.PointInTime = &t
 }
 
 func (c *storageS3Flags) connect(ctx context.Context, isNew bool) (blob.Storage, error) {
+	sc, err := c.storageClasses.storageClassMap(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c.s3options.StorageClasses = sc
+
 	// nolint:wrapcheck
 	return s3.New(ctx, &c.s3options)
 }
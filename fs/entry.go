@@ -33,6 +33,37 @@ type DeviceInfo struct {
 	Rdev uint64 `json:"rdev"`
 }
 
+// HardLinkInfo describes the identity of a filesystem entry that may be reachable through multiple hard links.
+type HardLinkInfo struct {
+	Inode     uint64 `json:"ino"`
+	LinkCount uint64 `json:"nlink"`
+}
+
+// EntryWithHardLinkInfo is optionally implemented by Entry that provides hard link information.
+type EntryWithHardLinkInfo interface {
+	HardLinkInfo() HardLinkInfo
+}
+
+// ExtendedAttributes maps names of extended attributes of a filesystem entry to their values.
+// On Linux this includes POSIX ACLs, which are stored as system.posix_acl_access
+// and system.posix_acl_default attributes.
+type ExtendedAttributes map[string][]byte
+
+// EntryWithExtendedAttributes is optionally implemented by Entry that supports extended attributes.
+type EntryWithExtendedAttributes interface {
+	ExtendedAttributes(ctx context.Context) (ExtendedAttributes, error)
+}
+
+// GetExtendedAttributes returns extended attributes of the provided entry or nil if the entry does not support them.
+func GetExtendedAttributes(ctx context.Context, e Entry) (ExtendedAttributes, error) {
+	if x, ok := e.(EntryWithExtendedAttributes); ok {
+		// nolint:wrapcheck
+		return x.ExtendedAttributes(ctx)
+	}
+
+	return nil, nil
+}
+
 // Entries is a list of entries sorted by name.
 type Entries []Entry
 
@@ -44,6 +75,30 @@ type Reader interface {
 	Entry() (Entry, error)
 }
 
+// Region describes a range of bytes within a file.
+type Region struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// ReaderWithDataRegions is optionally implemented by Reader of files that may be sparse.
+// Local files implement it only on Linux, on other platforms sparse files are read and stored densely.
+type ReaderWithDataRegions interface {
+	// DataRegions returns regions of the file that contain data or nil if the file has no holes
+	// or holes cannot be determined. Bytes outside of the returned regions read as zeros.
+	DataRegions() ([]Region, error)
+}
+
+// GetDataRegions returns data regions of the file being read by the provided reader or nil if the file has no holes.
+func GetDataRegions(r Reader) ([]Region, error) {
+	if dr, ok := r.(ReaderWithDataRegions); ok {
+		// nolint:wrapcheck
+		return dr.DataRegions()
+	}
+
+	return nil, nil
+}
+
 // File represents an entry that is a file.
 type File interface {
 	Entry
@@ -56,6 +111,18 @@ type StreamingFile interface {
 	GetReader(ctx context.Context) (io.Reader, error)
 }
 
+// SpecialFile represents an entry that is a device node, named pipe or socket.
+// The device number of block and character devices is available in Device().Rdev.
+type SpecialFile interface {
+	Entry
+
+	// SpecialFileType returns os.ModeDevice, os.ModeDevice|os.ModeCharDevice, os.ModeNamedPipe or os.ModeSocket.
+	SpecialFileType() os.FileMode
+}
+
+// SpecialFileTypeMask is the mask of os.FileMode type bits that identify the type of SpecialFile.
+const SpecialFileTypeMask = os.ModeDevice | os.ModeCharDevice | os.ModeNamedPipe | os.ModeSocket
+
 // Directory represents contents of a directory.
 type Directory interface {
 	Entry
@@ -281,3 +281,18 @@ func TestEntriesRemove(t *testing.T) {
 		}
 	}
 }
+
+type denseReader struct {
+	Reader
+}
+
+func TestGetDataRegionsNotSupported(t *testing.T) {
+	regions, err := GetDataRegions(denseReader{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if regions != nil {
+		t.Errorf("unexpected regions: %v", regions)
+	}
+}
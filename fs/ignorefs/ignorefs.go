@@ -141,6 +141,14 @@ func (d *ignoreDirectory) DirEntryOrNil(ctx context.Context) (*snapshot.DirEntry
 	return nil, nil
 }
 
+// Make sure that ignoreDirectory implements EntryWithExtendedAttributes.
+var _ fs.EntryWithExtendedAttributes = &ignoreDirectory{}
+
+func (d *ignoreDirectory) ExtendedAttributes(ctx context.Context) (fs.ExtendedAttributes, error) {
+	// nolint:wrapcheck
+	return fs.GetExtendedAttributes(ctx, d.Directory)
+}
+
 func (d *ignoreDirectory) Readdir(ctx context.Context) (fs.Entries, error) {
 	entries, err := d.Directory.Readdir(ctx)
 	if err != nil {
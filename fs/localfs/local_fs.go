@@ -26,6 +26,7 @@ type filesystemEntry struct {
 	mode       os.FileMode
 	owner      fs.OwnerInfo
 	device     fs.DeviceInfo
+	links      fs.HardLinkInfo
 
 	parentDir string
 }
@@ -66,6 +67,10 @@ func (e *filesystemEntry) Device() fs.DeviceInfo {
 	return e.device
 }
 
+func (e *filesystemEntry) HardLinkInfo() fs.HardLinkInfo {
+	return e.links
+}
+
 func (e *filesystemEntry) LocalFilesystemPath() string {
 	return e.fullPath()
 }
@@ -80,6 +85,7 @@ func newEntry(fi os.FileInfo, parentDir string) filesystemEntry {
 		fi.Mode(),
 		platformSpecificOwnerInfo(fi),
 		platformSpecificDeviceInfo(fi),
+		platformSpecificHardLinkInfo(fi),
 		parentDir,
 	}
 }
@@ -96,6 +102,10 @@ type filesystemFile struct {
 	filesystemEntry
 }
 
+type filesystemSpecialFile struct {
+	filesystemEntry
+}
+
 type filesystemErrorEntry struct {
 	filesystemEntry
 	err error
@@ -247,12 +257,16 @@ func (fsl *filesystemSymlink) Readlink(ctx context.Context) (string, error) {
 	return os.Readlink(fsl.fullPath())
 }
 
+func (fsf *filesystemSpecialFile) SpecialFileType() os.FileMode {
+	return fsf.mode & fs.SpecialFileTypeMask
+}
+
 func (e *filesystemErrorEntry) ErrorInfo() error {
 	return e.err
 }
 
-// NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink
-// or fs.UnsupportedEntry.
+// NewEntry returns fs.Entry for the specified path, the result will be one of supported entry types: fs.File, fs.Directory, fs.Symlink,
+// fs.SpecialFile or fs.UnsupportedEntry.
 func NewEntry(path string) (fs.Entry, error) {
 	fi, err := os.Lstat(path)
 	if err != nil {
@@ -296,14 +310,29 @@ func entryFromChildFileInfo(fi os.FileInfo, parentDir string) fs.Entry {
 	case maskedmode == 0 && isplaceholder:
 		return &shallowFilesystemFile{newEntry(fi, parentDir)}
 
+	case isSpecialFileMode(maskedmode) && !isplaceholder:
+		return &filesystemSpecialFile{newEntry(fi, parentDir)}
+
 	default:
 		return &filesystemErrorEntry{newEntry(fi, parentDir), fs.ErrUnknown}
 	}
 }
 
+func isSpecialFileMode(maskedmode os.FileMode) bool {
+	switch maskedmode {
+	case os.ModeDevice, os.ModeDevice | os.ModeCharDevice, os.ModeNamedPipe, os.ModeSocket:
+		return true
+	default:
+		return false
+	}
+}
+
 var (
-	_ fs.Directory  = &filesystemDirectory{}
-	_ fs.File       = &filesystemFile{}
-	_ fs.Symlink    = &filesystemSymlink{}
-	_ fs.ErrorEntry = &filesystemErrorEntry{}
+	_ fs.Directory   = &filesystemDirectory{}
+	_ fs.File        = &filesystemFile{}
+	_ fs.Symlink     = &filesystemSymlink{}
+	_ fs.SpecialFile = &filesystemSpecialFile{}
+	_ fs.ErrorEntry  = &filesystemErrorEntry{}
+
+	_ fs.EntryWithHardLinkInfo = &filesystemFile{}
 )
@@ -29,3 +29,14 @@ func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
 
 	return oi
 }
+
+func platformSpecificHardLinkInfo(fi os.FileInfo) fs.HardLinkInfo {
+	var li fs.HardLinkInfo
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		// field widths vary between platforms.
+		li.Inode = uint64(stat.Ino)       // nolint:unconvert
+		li.LinkCount = uint64(stat.Nlink) // nolint:unconvert
+	}
+
+	return li
+}
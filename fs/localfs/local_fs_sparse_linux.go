@@ -0,0 +1,85 @@
+package localfs
+
+import (
+	"io"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+const statBlockSize = 512
+
+var _ fs.ReaderWithDataRegions = &fileWithMetadata{}
+
+// DataRegions enumerates data regions of the file using SEEK_DATA and SEEK_HOLE.
+// This is only implemented on Linux, on other platforms local files don't report holes.
+func (f *fileWithMetadata) DataRegions() ([]fs.Region, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to stat() local file")
+	}
+
+	size := fi.Size()
+
+	// files with enough blocks allocated to hold all their bytes have no holes.
+	if st, ok := fi.Sys().(*syscall.Stat_t); !ok || st.Blocks*statBlockSize >= size {
+		return nil, nil
+	}
+
+	regions := []fs.Region{}
+	fd := int(f.Fd())
+
+	for offset := int64(0); offset < size; {
+		dataStart, err := unix.Seek(fd, offset, unix.SEEK_DATA)
+		if errors.Is(err, unix.ENXIO) {
+			// no more data until the end of file.
+			break
+		}
+
+		if errors.Is(err, unix.EINVAL) {
+			// filesystem does not support SEEK_DATA.
+			return nil, f.rewind()
+		}
+
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to seek to data")
+		}
+
+		holeStart, err := unix.Seek(fd, dataStart, unix.SEEK_HOLE)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to seek to hole")
+		}
+
+		if holeStart > size {
+			holeStart = size
+		}
+
+		if holeStart <= dataStart {
+			break
+		}
+
+		regions = append(regions, fs.Region{Offset: dataStart, Length: holeStart - dataStart})
+		offset = holeStart
+	}
+
+	if err := f.rewind(); err != nil {
+		return nil, err
+	}
+
+	if len(regions) == 1 && regions[0].Offset == 0 && regions[0].Length == size {
+		return nil, nil
+	}
+
+	return regions, nil
+}
+
+func (f *fileWithMetadata) rewind() error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return errors.Wrap(err, "unable to seek to the beginning of file")
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package localfs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestDataRegions(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tmp := testutil.TempDirectory(t)
+
+	dense := filepath.Join(tmp, "dense")
+	require.NoError(t, ioutil.WriteFile(dense, make([]byte, 100000), 0o600))
+
+	sparse := filepath.Join(tmp, "sparse")
+	f, err := os.Create(sparse)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(4<<20))
+	_, err = f.WriteAt([]byte("hello"), 1<<20)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	regions := func(fname string) []fs.Region {
+		e, err := NewEntry(fname)
+		require.NoError(t, err)
+
+		r, err := e.(fs.File).Open(ctx)
+		require.NoError(t, err)
+
+		defer r.Close()
+
+		regions, err := fs.GetDataRegions(r)
+		require.NoError(t, err)
+
+		// regions must not affect subsequent reads.
+		b, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Len(t, b, int(e.Size()))
+
+		return regions
+	}
+
+	require.Nil(t, regions(dense))
+
+	rs := regions(sparse)
+	if rs == nil {
+		t.Skip("filesystem does not support sparse files")
+	}
+
+	// the data region is rounded to filesystem blocks.
+	require.Len(t, rs, 1)
+	require.LessOrEqual(t, rs[0].Offset, int64(1<<20))
+	require.GreaterOrEqual(t, rs[0].Offset+rs[0].Length, int64(1<<20+5))
+	require.Less(t, rs[0].Length, int64(1<<20))
+}
@@ -0,0 +1,40 @@
+// +build !linux
+
+package localfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestDataRegionsNotDetected(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	sparse := filepath.Join(testutil.TempDirectory(t), "sparse")
+	f, err := os.Create(sparse)
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(4<<20))
+	_, err = f.WriteAt([]byte("hello"), 1<<20)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	e, err := NewEntry(sparse)
+	require.NoError(t, err)
+
+	r, err := e.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	// holes are only detected on Linux, elsewhere the file is read densely.
+	regions, err := fs.GetDataRegions(r)
+	require.NoError(t, err)
+	require.Nil(t, regions)
+}
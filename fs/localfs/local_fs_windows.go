@@ -13,3 +13,7 @@ func platformSpecificOwnerInfo(fi os.FileInfo) fs.OwnerInfo {
 func platformSpecificDeviceInfo(fi os.FileInfo) fs.DeviceInfo {
 	return fs.DeviceInfo{}
 }
+
+func platformSpecificHardLinkInfo(fi os.FileInfo) fs.HardLinkInfo {
+	return fs.HardLinkInfo{}
+}
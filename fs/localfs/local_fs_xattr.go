@@ -0,0 +1,88 @@
+// +build linux darwin
+
+package localfs
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func (e *filesystemEntry) ExtendedAttributes(ctx context.Context) (fs.ExtendedAttributes, error) {
+	return ReadExtendedAttributes(e.fullPath())
+}
+
+// ReadExtendedAttributes returns extended attributes of the specified path without following symlinks.
+func ReadExtendedAttributes(path string) (fs.ExtendedAttributes, error) {
+	names, err := readXattrBuffer(func(buf []byte) (int, error) {
+		return unix.Llistxattr(path, buf)
+	})
+
+	switch {
+	case errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP):
+		return nil, nil
+	case err != nil:
+		return nil, errors.Wrapf(err, "unable to list extended attributes of %v", path)
+	}
+
+	var result fs.ExtendedAttributes
+
+	for _, name := range bytes.Split(names, []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		value, err := readXattrBuffer(func(buf []byte) (int, error) {
+			return unix.Lgetxattr(path, string(name), buf)
+		})
+
+		switch {
+		case errors.Is(err, errNoAttribute):
+			// attribute was removed after it was listed.
+			continue
+		case err != nil:
+			return nil, errors.Wrapf(err, "unable to read extended attribute %q of %v", name, path)
+		}
+
+		if result == nil {
+			result = fs.ExtendedAttributes{}
+		}
+
+		result[string(name)] = value
+	}
+
+	return result, nil
+}
+
+// readXattrBuffer invokes the provided function, which returns the number of bytes written to the
+// buffer or the required buffer size when the buffer is empty, until the buffer is large enough.
+func readXattrBuffer(f func(buf []byte) (int, error)) ([]byte, error) {
+	for {
+		n, err := f(nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			return []byte{}, nil
+		}
+
+		buf := make([]byte, n)
+
+		n, err = f(buf)
+		if errors.Is(err, unix.ERANGE) {
+			// value grew between calls, retry.
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		return buf[0:n], nil
+	}
+}
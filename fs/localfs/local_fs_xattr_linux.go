@@ -0,0 +1,5 @@
+package localfs
+
+import "golang.org/x/sys/unix"
+
+var errNoAttribute = unix.ENODATA
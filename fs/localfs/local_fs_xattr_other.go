@@ -0,0 +1,11 @@
+// +build !linux,!darwin
+
+package localfs
+
+import "github.com/kopia/kopia/fs"
+
+// ReadExtendedAttributes returns extended attributes of the specified path without following symlinks.
+func ReadExtendedAttributes(path string) (fs.ExtendedAttributes, error) {
+	// extended attributes are not supported on this platform.
+	return nil, nil
+}
@@ -0,0 +1,48 @@
+// +build linux darwin
+
+package localfs
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestExtendedAttributes(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	tmp := testutil.TempDirectory(t)
+	fname := filepath.Join(tmp, "f1")
+
+	require.NoError(t, ioutil.WriteFile(fname, []byte{1, 2, 3}, 0o600))
+
+	e, err := NewEntry(fname)
+	require.NoError(t, err)
+
+	got, err := fs.GetExtendedAttributes(ctx, e)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	if err = unix.Lsetxattr(fname, "user.kopia-test", []byte("some-value"), 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("extended attributes are not supported")
+		}
+
+		t.Fatal(err)
+	}
+
+	require.NoError(t, unix.Lsetxattr(fname, "user.kopia-empty", nil, 0))
+
+	got, err = fs.GetExtendedAttributes(ctx, e)
+	require.NoError(t, err)
+	require.Equal(t, []byte("some-value"), got["user.kopia-test"])
+	require.Equal(t, []byte{}, got["user.kopia-empty"])
+}
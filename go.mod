@@ -29,6 +29,7 @@ require (
 	github.com/klauspost/compress v1.12.2
 	github.com/klauspost/cpuid/v2 v2.0.5 // indirect
 	github.com/klauspost/pgzip v1.2.5
+	github.com/klauspost/reedsolomon v1.9.3
 	github.com/kr/text v0.2.0 // indirect
 	github.com/kylelemons/godebug v1.1.0
 	github.com/minio/md5-simd v1.1.2 // indirect
@@ -18,6 +18,7 @@ export class BeginRestore extends Component {
             restoreOwnership: true,
             restorePermissions: true,
             restoreModTimes: true,
+            restoreExtendedAttributes: true,
             uncompressedZip: true,
             overwriteFiles: false,
             overwriteDirectories: false,
@@ -58,6 +59,7 @@ export class BeginRestore extends Component {
                 skipOwners: !this.state.restoreOwnership,
                 skipPermissions: !this.state.restorePermissions,
                 skipTimes: !this.state.restoreModTimes,
+                skipExtendedAttributes: !this.state.restoreExtendedAttributes,
 
                 ignorePermissionErrors: this.state.ignorePermissionErrors,
                 overwriteFiles: this.state.overwriteFiles,
@@ -110,6 +112,9 @@ export class BeginRestore extends Component {
                 <Row>
                     {RequiredBoolean(this, "Restore File Modification Time", "restoreModTimes")}
                 </Row>
+                <Row>
+                    {RequiredBoolean(this, "Restore Extended Attributes and ACLs", "restoreExtendedAttributes")}
+                </Row>
                 <Row>
                     {RequiredBoolean(this, "Overwrite Files", "overwriteFiles")}
                 </Row>
@@ -219,6 +219,9 @@ export class PolicyEditor extends Component {
                         <Row>
                             {OptionalBoolean(this, "Scan only one filesystem", "policy.files.oneFileSystem", "inherit from parent")}
                         </Row>
+                        <Row>
+                            {OptionalBoolean(this, "Snapshot extended attributes and ACLs", "policy.files.extendedAttributes", "inherit from parent")}
+                        </Row>
                     </div>
                 </Tab>
                 <Tab eventKey="errors" title="Errors">
@@ -260,6 +263,12 @@ export class PolicyEditor extends Component {
                         <Row>
                             {OptionalNumberField(this, "Snapshot Interval", "policy.scheduling.intervalSeconds", { placeholder: "seconds" })}
                         </Row>
+                        <Row>
+                            {StringList(this, "Cron Expressions", "policy.scheduling.cron", "Take snapshots at times matching the above cron expressions, e.g. '0 2 * * mon-fri' (one expression per line)")}
+                        </Row>
+                        <Row>
+                            {OptionalBoolean(this, "Run missed snapshots shortly after startup or resume", "policy.scheduling.runMissed")}
+                        </Row>
                         <Row>
                             {OptionalBoolean(this, "Only create snapshots manually (disables scheduled snapshots)", "policy.scheduling.manual")}
                         </Row>
@@ -70,7 +70,8 @@ export class SnapshotsTable extends Component {
         let hiddenCount = 0;
 
         for (let i = 0; i < s.length; i++) {
-            if (s[i].rootID !== lastRootID) {
+            // pinned snapshots are never coalesced.
+            if (s[i].rootID !== lastRootID || s[i].pins) {
                 filteredSnapshots.push(s[i]);
             } else {
                 hiddenCount++;
@@ -127,6 +128,8 @@ export class SnapshotsTable extends Component {
             width: "",
             Cell: x => <span>{x.cell.value.map(l =>
                 <><Badge bg={pillVariant(l)}>{l}</Badge>{' '}</>
+            )}{(x.row.original.pins || []).map(l =>
+                <><Badge bg="secondary" title="Pinned snapshots are never deleted by retention policy">pin: {l}</Badge>{' '}</>
             )}</span>
         }, {
             Header: 'Size',
@@ -226,6 +226,10 @@ export class SourcesTable extends Component {
                         &nbsp;
                     <Badge bg="secondary">overdue</Badge>
                     </>}
+                    {x.row.original.nextSnapshotTrigger === "missed-schedule" && <>
+                        &nbsp;
+                    <Badge bg="info">missed</Badge>
+                    </>}
                 </p>
             </> : '',
         }, {
@@ -0,0 +1,310 @@
+// Package cron implements parsing and evaluation of cron expressions.
+//
+// Expressions consist of five space-separated fields: minute, hour, day of month, month and day of week.
+// Each field can be '*', a value, a range (a-b), a list of those separated by commas and can
+// include a step (*/n or a-b/n). Months and days of week can be specified using their
+// three-letter English names. In addition the following extensions are supported:
+//
+// - 'L' in day of month field matches the last day of the month,
+// - 'd#n' in day of week field matches n-th (1-5) occurrence of the day d in the month,
+// - @yearly, @monthly, @weekly, @daily and @hourly shortcuts.
+//
+// As in standard cron, when both day of month and day of week are restricted, the expression
+// matches days that satisfy either of them.
+package cron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxSearchYears is the number of years after which Next() gives up looking for a matching time.
+const maxSearchYears = 5
+
+// all occurrences of a day of week within a month (bits 1..5).
+const allOccurrences = 0b111110
+
+// nolint:gochecknoglobals
+var shortcuts = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// nolint:gochecknoglobals
+var (
+	monthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	dayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Schedule represents a parsed cron expression.
+type Schedule struct {
+	expr string
+
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  [7]uint8 // bitmask of occurrences within a month for each day of week
+
+	lastDayOfMonth bool
+	anyDayOfMonth  bool
+	anyDayOfWeek   bool
+}
+
+type fieldSpec struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+// nolint:gochecknoglobals,gomnd
+var (
+	minuteField = fieldSpec{"minute", 0, 59, nil}
+	hourField   = fieldSpec{"hour", 0, 23, nil}
+	domField    = fieldSpec{"day of month", 1, 31, nil}
+	monthField  = fieldSpec{"month", 1, 12, monthNames}
+	dowField    = fieldSpec{"day of week", 0, 7, dayNames}
+)
+
+// Parse parses the provided cron expression.
+func Parse(expr string) (*Schedule, error) {
+	normalized := strings.TrimSpace(expr)
+	if s, ok := shortcuts[strings.ToLower(normalized)]; ok {
+		normalized = s
+	}
+
+	fields := strings.Fields(normalized)
+	if len(fields) != 5 { // nolint:gomnd
+		return nil, errors.Errorf("invalid cron expression %q, expected 5 fields", expr)
+	}
+
+	s := &Schedule{
+		expr:          expr,
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*"),
+	}
+
+	var err error
+
+	if s.minutes, err = parseField(fields[0], minuteField); err != nil {
+		return nil, err
+	}
+
+	if s.hours, err = parseField(fields[1], hourField); err != nil {
+		return nil, err
+	}
+
+	if s.daysOfMonth, s.lastDayOfMonth, err = parseDayOfMonth(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if s.months, err = parseField(fields[3], monthField); err != nil {
+		return nil, err
+	}
+
+	if s.daysOfWeek, err = parseDayOfWeek(fields[4]); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// String returns the original cron expression.
+func (s *Schedule) String() string {
+	return s.expr
+}
+
+// Next returns the earliest time after t that matches the schedule, in the location of t.
+// Returns zero time if the schedule does not match any time in the foreseeable future.
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc).Add(time.Minute)
+	limit := t.AddDate(maxSearchYears, 0, 0)
+
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute) // nolint:gomnd
+			continue
+		}
+
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	day := t.Day()
+	lastDay := time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+
+	domMatch := s.daysOfMonth&(1<<uint(day)) != 0 || (s.lastDayOfMonth && day == lastDay)
+	dowMatch := s.daysOfWeek[t.Weekday()]&(1<<uint((day-1)/7+1)) != 0 // nolint:gomnd
+
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+func parseDayOfMonth(field string) (bits uint64, lastDay bool, err error) {
+	var parts []string
+
+	for _, p := range strings.Split(field, ",") {
+		if strings.EqualFold(p, "L") {
+			lastDay = true
+			continue
+		}
+
+		parts = append(parts, p)
+	}
+
+	if len(parts) == 0 {
+		return 0, lastDay, nil
+	}
+
+	bits, err = parseField(strings.Join(parts, ","), domField)
+
+	return bits, lastDay, err
+}
+
+func parseDayOfWeek(field string) ([7]uint8, error) {
+	var (
+		result [7]uint8
+		parts  []string
+	)
+
+	for _, p := range strings.Split(field, ",") {
+		hash := strings.Index(p, "#")
+		if hash < 0 {
+			parts = append(parts, p)
+			continue
+		}
+
+		day, err := parseValue(p[0:hash], dowField)
+		if err != nil {
+			return result, err
+		}
+
+		n, err := strconv.Atoi(p[hash+1:])
+		if err != nil || n < 1 || n > 5 {
+			return result, errors.Errorf("invalid occurrence in %q, must be between 1 and 5", p)
+		}
+
+		result[day%7] |= 1 << uint(n)
+	}
+
+	if len(parts) > 0 {
+		bits, err := parseField(strings.Join(parts, ","), dowField)
+		if err != nil {
+			return result, err
+		}
+
+		for d := 0; d <= dowField.max; d++ {
+			if bits&(1<<uint(d)) != 0 {
+				// both 0 and 7 represent Sunday.
+				result[d%7] |= allOccurrences
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func parseField(field string, spec fieldSpec) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			n, err := strconv.Atoi(part[slash+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.Errorf("invalid step in %v field: %q", spec.name, part)
+			}
+
+			rangePart, step = part[0:slash], n
+		}
+
+		lo, hi, err := parseRange(rangePart, spec)
+		if err != nil {
+			return 0, err
+		}
+
+		if step > 1 && lo == hi {
+			// 'a/n' is equivalent to 'a-max/n'
+			hi = spec.max
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseRange(s string, spec fieldSpec) (lo, hi int, err error) {
+	if s == "*" {
+		return spec.min, spec.max, nil
+	}
+
+	dash := strings.Index(s, "-")
+	if dash < 0 {
+		v, err := parseValue(s, spec)
+		return v, v, err
+	}
+
+	if lo, err = parseValue(s[0:dash], spec); err != nil {
+		return 0, 0, err
+	}
+
+	if hi, err = parseValue(s[dash+1:], spec); err != nil {
+		return 0, 0, err
+	}
+
+	if lo > hi {
+		return 0, 0, errors.Errorf("invalid range in %v field: %q", spec.name, s)
+	}
+
+	return lo, hi, nil
+}
+
+func parseValue(s string, spec fieldSpec) (int, error) {
+	for i, n := range spec.names {
+		if strings.EqualFold(s, n) {
+			return i + spec.min, nil
+		}
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < spec.min || v > spec.max {
+		return 0, errors.Errorf("invalid %v %q, must be between %v and %v", spec.name, s, spec.min, spec.max)
+	}
+
+	return v, nil
+}
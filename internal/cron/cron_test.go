@@ -0,0 +1,105 @@
+package cron_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/cron"
+)
+
+func TestNext(t *testing.T) {
+	// Friday
+	start := time.Date(2021, time.October, 15, 13, 37, 45, 0, time.UTC)
+
+	cases := []struct {
+		expr string
+		want []time.Time
+	}{
+		{"* * * * *", []time.Time{
+			time.Date(2021, time.October, 15, 13, 38, 0, 0, time.UTC),
+			time.Date(2021, time.October, 15, 13, 39, 0, 0, time.UTC),
+		}},
+		{"0 2 * * 1-5", []time.Time{
+			time.Date(2021, time.October, 18, 2, 0, 0, 0, time.UTC),
+			time.Date(2021, time.October, 19, 2, 0, 0, 0, time.UTC),
+		}},
+		{"0 2 * * mon-fri", []time.Time{
+			time.Date(2021, time.October, 18, 2, 0, 0, 0, time.UTC),
+		}},
+		{"30 4 * * SUN#1", []time.Time{
+			time.Date(2021, time.November, 7, 4, 30, 0, 0, time.UTC),
+			time.Date(2021, time.December, 5, 4, 30, 0, 0, time.UTC),
+		}},
+		{"*/20 9-17 * * *", []time.Time{
+			time.Date(2021, time.October, 15, 13, 40, 0, 0, time.UTC),
+			time.Date(2021, time.October, 15, 14, 0, 0, 0, time.UTC),
+			time.Date(2021, time.October, 15, 14, 20, 0, 0, time.UTC),
+		}},
+		{"15/30 * * * *", []time.Time{
+			time.Date(2021, time.October, 15, 13, 45, 0, 0, time.UTC),
+			time.Date(2021, time.October, 15, 14, 15, 0, 0, time.UTC),
+		}},
+		{"0 0 L * *", []time.Time{
+			time.Date(2021, time.October, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, time.November, 30, 0, 0, 0, 0, time.UTC),
+		}},
+		// day of month and day of week are combined with OR.
+		{"0 0 1 * 0", []time.Time{
+			time.Date(2021, time.October, 17, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, time.October, 24, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, time.October, 31, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, time.November, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 12 29 feb *", []time.Time{
+			time.Date(2024, time.February, 29, 12, 0, 0, 0, time.UTC),
+		}},
+		{"@monthly", []time.Time{
+			time.Date(2021, time.November, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		{"0 0 30 2 *", []time.Time{{}}},
+	}
+
+	for _, tc := range cases {
+		s, err := cron.Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("unable to parse %q: %v", tc.expr, err)
+		}
+
+		if got := s.String(); got != tc.expr {
+			t.Errorf("invalid String() %q, want %q", got, tc.expr)
+		}
+
+		cur := start
+
+		for _, want := range tc.want {
+			got := s.Next(cur)
+			if !got.Equal(want) {
+				t.Errorf("invalid Next(%v) for %q: %v, want %v", cur, tc.expr, got, want)
+			}
+
+			cur = got
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * * mon#6",
+		"* * * foo *",
+		"@never",
+	} {
+		if _, err := cron.Parse(expr); err == nil {
+			t.Errorf("expected error when parsing %q", expr)
+		}
+	}
+}
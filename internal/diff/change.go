@@ -0,0 +1,122 @@
+package diff
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// ChangeType describes the type of change to an entry.
+type ChangeType string
+
+// Supported change types.
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeRemoved  ChangeType = "removed"
+	ChangeModified ChangeType = "modified"
+)
+
+// Entry types reported in EntryInfo.
+const (
+	EntryTypeFile      = "file"
+	EntryTypeDirectory = "dir"
+	EntryTypeSymlink   = "symlink"
+	EntryTypeUnknown   = "unknown"
+)
+
+// EntryChange describes a single added, removed or modified entry.
+type EntryChange struct {
+	Path   string     `json:"path"`
+	Change ChangeType `json:"change"`
+	Old    *EntryInfo `json:"old,omitempty"`
+	New    *EntryInfo `json:"new,omitempty"`
+}
+
+// EntryInfo describes the state of an entry on one side of the comparison.
+type EntryInfo struct {
+	Type     string    `json:"type"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	Mode     string    `json:"mode"`
+	UserID   uint32    `json:"uid"`
+	GroupID  uint32    `json:"gid"`
+	ObjectID object.ID `json:"obj,omitempty"`
+}
+
+// Stats summarizes differences between two filesystems.
+type Stats struct {
+	FilesAdded          int `json:"filesAdded"`
+	FilesRemoved        int `json:"filesRemoved"`
+	FilesModified       int `json:"filesModified"`
+	DirectoriesAdded    int `json:"dirsAdded"`
+	DirectoriesRemoved  int `json:"dirsRemoved"`
+	DirectoriesModified int `json:"dirsModified"`
+}
+
+func (s *Stats) add(change ChangeType, e1, e2 fs.Entry) {
+	e := e2
+	if e == nil {
+		e = e1
+	}
+
+	_, isDir := e.(fs.Directory)
+
+	switch change {
+	case ChangeAdded:
+		if isDir {
+			s.DirectoriesAdded++
+		} else {
+			s.FilesAdded++
+		}
+
+	case ChangeRemoved:
+		if isDir {
+			s.DirectoriesRemoved++
+		} else {
+			s.FilesRemoved++
+		}
+
+	case ChangeModified:
+		if isDir {
+			s.DirectoriesModified++
+		} else {
+			s.FilesModified++
+		}
+	}
+}
+
+func entryType(e fs.Entry) string {
+	switch e.(type) {
+	case fs.Directory:
+		return EntryTypeDirectory
+	case fs.Symlink:
+		return EntryTypeSymlink
+	case fs.File:
+		return EntryTypeFile
+	default:
+		return EntryTypeUnknown
+	}
+}
+
+func newEntryInfo(e fs.Entry) *EntryInfo {
+	if e == nil {
+		return nil
+	}
+
+	ei := &EntryInfo{
+		Type:    entryType(e),
+		Size:    e.Size(),
+		ModTime: e.ModTime(),
+		Mode:    "0" + strconv.FormatUint(uint64(e.Mode().Perm()), 8),
+		UserID:  e.Owner().UserID,
+		GroupID: e.Owner().GroupID,
+	}
+
+	if h, ok := e.(object.HasObjectID); ok {
+		ei.ObjectID = h.ObjectID()
+	}
+
+	return ei
+}
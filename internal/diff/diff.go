@@ -9,6 +9,7 @@
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 
@@ -26,9 +27,28 @@
 type Comparer struct {
 	out    io.Writer
 	tmpDir string
+	stats  Stats
 
 	DiffCommand   string
 	DiffArguments []string
+
+	// ChangeCallback, when set, receives structured information about each changed entry
+	// instead of human-readable output being written.
+	ChangeCallback func(ch *EntryChange) error
+
+	// SummaryOnly disables all per-entry output, only Stats are collected.
+	// Files are compared by object IDs and their contents are never read.
+	SummaryOnly bool
+}
+
+// Stats returns statistics about differences found so far.
+func (c *Comparer) Stats() Stats {
+	return c.stats
+}
+
+// humanReadable returns true if the comparer writes human-readable output.
+func (c *Comparer) humanReadable() bool {
+	return c.ChangeCallback == nil && !c.SummaryOnly
 }
 
 // Compare compares two filesystem entries and emits their diff information.
@@ -79,6 +99,10 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 	}
 
 	if e1 == nil {
+		if err := c.reportChange(ChangeAdded, path, nil, e2); err != nil {
+			return err
+		}
+
 		if dir2, isDir2 := e2.(fs.Directory); isDir2 {
 			c.output("added directory %v\n", path)
 			return c.compareDirectories(ctx, nil, dir2, path)
@@ -96,6 +120,10 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 	}
 
 	if e2 == nil {
+		if err := c.reportChange(ChangeRemoved, path, e1, nil); err != nil {
+			return err
+		}
+
 		if dir1, isDir1 := e1.(fs.Directory); isDir1 {
 			c.output("removed directory %v\n", path)
 			return c.compareDirectories(ctx, dir1, nil, path)
@@ -112,11 +140,22 @@ func (c *Comparer) compareEntry(ctx context.Context, e1, e2 fs.Entry, path strin
 		return nil
 	}
 
-	compareEntry(e1, e2, path, c.out)
+	metadataOut := c.out
+	if !c.humanReadable() {
+		metadataOut = ioutil.Discard
+	}
 
 	dir1, isDir1 := e1.(fs.Directory)
 	dir2, isDir2 := e2.(fs.Directory)
 
+	// directories are reported as modified only when their own metadata differs,
+	// changes to their contents are reported for individual entries.
+	if !compareEntry(e1, e2, path, metadataOut) || !isDir1 || !isDir2 {
+		if err := c.reportChange(ChangeModified, path, e1, e2); err != nil {
+			return err
+		}
+	}
+
 	if isDir1 {
 		if !isDir2 {
 			// right is a non-directory, left is a directory
@@ -228,7 +267,7 @@ func (c *Comparer) compareDirectoryEntries(ctx context.Context, entries1, entrie
 }
 
 func (c *Comparer) compareFiles(ctx context.Context, f1, f2 fs.File, fname string) error {
-	if c.DiffCommand == "" {
+	if c.DiffCommand == "" || !c.humanReadable() {
 		return nil
 	}
 
@@ -293,9 +332,28 @@ func downloadFile(ctx context.Context, f fs.File, fname string) error {
 }
 
 func (c *Comparer) output(msg string, args ...interface{}) {
+	if !c.humanReadable() {
+		return
+	}
+
 	fmt.Fprintf(c.out, msg, args...)
 }
 
+func (c *Comparer) reportChange(change ChangeType, path string, e1, e2 fs.Entry) error {
+	c.stats.add(change, e1, e2)
+
+	if c.ChangeCallback == nil || c.SummaryOnly {
+		return nil
+	}
+
+	return c.ChangeCallback(&EntryChange{
+		Path:   strings.TrimPrefix(path, "./"),
+		Change: change,
+		Old:    newEntryInfo(e1),
+		New:    newEntryInfo(e2),
+	})
+}
+
 // NewComparer creates a comparer for a given repository that will output the results to a given writer.
 func NewComparer(out io.Writer) (*Comparer, error) {
 	tmp, err := ioutil.TempDir("", "kopia")
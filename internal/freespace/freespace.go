@@ -0,0 +1,7 @@
+// Package freespace reports available space on local volumes.
+package freespace
+
+import "github.com/pkg/errors"
+
+// ErrUnsupported is returned when free space cannot be determined on the current platform.
+var ErrUnsupported = errors.New("free space reporting is not supported on this platform")
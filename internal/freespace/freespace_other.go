@@ -0,0 +1,8 @@
+// +build !linux,!darwin,!freebsd,!windows
+
+package freespace
+
+// Available returns the number of bytes available on the volume containing the path.
+func Available(path string) (uint64, error) {
+	return 0, ErrUnsupported
+}
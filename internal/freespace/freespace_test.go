@@ -0,0 +1,27 @@
+package freespace
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+func TestAvailable(t *testing.T) {
+	n, err := Available(testutil.TempDirectory(t))
+	if errors.Is(err, ErrUnsupported) {
+		t.Skip(err)
+	}
+
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if n == 0 {
+		t.Fatalf("unexpected zero free space")
+	}
+
+	if _, err := Available("/no/such/path"); err == nil {
+		t.Fatalf("expected error for non-existent path")
+	}
+}
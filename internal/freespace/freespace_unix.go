@@ -0,0 +1,20 @@
+// +build linux darwin freebsd
+
+package freespace
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Available returns the number of bytes available to unprivileged users on the volume containing the path.
+func Available(path string) (uint64, error) {
+	var st unix.Statfs_t
+
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, errors.Wrapf(err, "unable to get free space of %v", path)
+	}
+
+	// nolint:unconvert
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
@@ -0,0 +1,22 @@
+package freespace
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows"
+)
+
+// Available returns the number of bytes available to the current user on the volume containing the path.
+func Available(path string) (uint64, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid path")
+	}
+
+	var available, total, free uint64
+
+	if err := windows.GetDiskFreeSpaceEx(p, &available, &total, &free); err != nil {
+		return 0, errors.Wrapf(err, "unable to get free space of %v", path)
+	}
+
+	return available, nil
+}
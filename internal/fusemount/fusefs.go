@@ -39,6 +39,10 @@ func populateAttributes(a *fuse.Attr, e fs.Entry) {
 	a.Uid = e.Owner().UserID
 	a.Gid = e.Owner().GroupID
 	a.Blocks = (a.Size + fakeBlockSize - 1) / fakeBlockSize
+
+	if _, ok := e.(fs.SpecialFile); ok {
+		a.Rdev = uint32(e.Device().Rdev)
+	}
 }
 
 func (n *fuseNode) Getattr(ctx context.Context, fh gofusefs.FileHandle, a *fuse.AttrOut) syscall.Errno {
@@ -171,18 +175,33 @@ func (sl *fuseSymlinkNode) Readlink(ctx context.Context) ([]byte, syscall.Errno)
 }
 
 func entryToFuseMode(e fs.Entry) uint32 {
-	switch e.(type) {
+	switch e := e.(type) {
 	case fs.File:
 		return fuse.S_IFREG
 	case fs.Directory:
 		return fuse.S_IFDIR
 	case fs.Symlink:
 		return fuse.S_IFLNK
+	case fs.SpecialFile:
+		return specialFileToFuseMode(e.SpecialFileType())
 	default:
 		return fuse.S_IFREG
 	}
 }
 
+func specialFileToFuseMode(t os.FileMode) uint32 {
+	switch t {
+	case os.ModeDevice:
+		return syscall.S_IFBLK
+	case os.ModeDevice | os.ModeCharDevice:
+		return syscall.S_IFCHR
+	case os.ModeNamedPipe:
+		return syscall.S_IFIFO
+	default:
+		return syscall.S_IFSOCK
+	}
+}
+
 func newFuseNode(e fs.Entry) (gofusefs.InodeEmbedder, error) {
 	switch e := e.(type) {
 	case fs.Directory:
@@ -191,6 +210,9 @@ func newFuseNode(e fs.Entry) (gofusefs.InodeEmbedder, error) {
 		return &fuseFileNode{fuseNode{entry: e}}, nil
 	case fs.Symlink:
 		return &fuseSymlinkNode{fuseNode{entry: e}}, nil
+	case fs.SpecialFile:
+		// special files have no contents, their type and device number are reported by Getattr.
+		return &fuseNode{entry: e}, nil
 	default:
 		return nil, errors.Errorf("entry type not supported: %v", e.Mode())
 	}
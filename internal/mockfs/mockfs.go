@@ -35,6 +35,7 @@ type entry struct {
 	modTime time.Time
 	owner   fs.OwnerInfo
 	device  fs.DeviceInfo
+	xattrs  fs.ExtendedAttributes
 }
 
 func (e *entry) Name() string {
@@ -73,6 +74,15 @@ func (e *entry) LocalFilesystemPath() string {
 	return ""
 }
 
+func (e *entry) ExtendedAttributes(ctx context.Context) (fs.ExtendedAttributes, error) {
+	return e.xattrs, nil
+}
+
+// SetExtendedAttributes changes the extended attributes of a given entry.
+func (e *entry) SetExtendedAttributes(xattrs fs.ExtendedAttributes) {
+	e.xattrs = xattrs
+}
+
 // Directory is mock in-memory implementation of fs.Directory.
 type Directory struct {
 	entry
@@ -327,4 +337,7 @@ func (e *ErrorEntry) ErrorInfo() error {
 	_ fs.File       = &File{}
 	_ fs.Symlink    = &inmemorySymlink{}
 	_ fs.ErrorEntry = &ErrorEntry{}
+
+	_ fs.EntryWithExtendedAttributes = &Directory{}
+	_ fs.EntryWithExtendedAttributes = &File{}
 )
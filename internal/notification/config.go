@@ -0,0 +1,148 @@
+package notification
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+)
+
+// nolint:gochecknoglobals
+var manifestLabels = map[string]string{
+	"type": "notifications",
+}
+
+// Config describes notification settings stored in the repository.
+type Config struct {
+	Profiles []*ProfileConfig `json:"profiles,omitempty"`
+
+	// MinFreeSpace is the amount of free space (in bytes) on local volumes used by the repository
+	// below which EventLowFreeSpace is sent.
+	MinFreeSpace int64 `json:"minFreeSpace,omitempty"`
+}
+
+// ProfileConfig describes a single named notification destination.
+type ProfileConfig struct {
+	Name string `json:"name"`
+
+	// Events that the profile is subscribed to, empty means all events.
+	Events []EventType `json:"events,omitempty"`
+
+	Webhook *WebhookOptions `json:"webhook,omitempty"`
+	Email   *EmailOptions   `json:"email,omitempty"`
+}
+
+// Type returns the type of the profile.
+func (p *ProfileConfig) Type() string {
+	switch {
+	case p.Webhook != nil:
+		return "webhook"
+	case p.Email != nil:
+		return "email"
+	default:
+		return "unknown"
+	}
+}
+
+// Subscribed returns true if the profile should receive events of the provided type.
+func (p *ProfileConfig) Subscribed(t EventType) bool {
+	if t == EventTest || len(p.Events) == 0 {
+		return true
+	}
+
+	for _, e := range p.Events {
+		if e == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Sender returns the sender for the profile.
+func (p *ProfileConfig) Sender() (Sender, error) {
+	switch {
+	case p.Webhook != nil:
+		return newWebhookSender(p.Webhook)
+	case p.Email != nil:
+		return newEmailSender(p.Email)
+	default:
+		return nil, errors.Errorf("profile %q has no destination", p.Name)
+	}
+}
+
+// FindProfile returns the profile with the provided name or nil if not found.
+func (c *Config) FindProfile(name string) *ProfileConfig {
+	for _, p := range c.Profiles {
+		if p.Name == name {
+			return p
+		}
+	}
+
+	return nil
+}
+
+// SetProfile adds or replaces the profile with the same name.
+func (c *Config) SetProfile(p *ProfileConfig) {
+	for i, existing := range c.Profiles {
+		if existing.Name == p.Name {
+			c.Profiles[i] = p
+			return
+		}
+	}
+
+	c.Profiles = append(c.Profiles, p)
+}
+
+// RemoveProfile removes the profile with the provided name and returns true if it was found.
+func (c *Config) RemoveProfile(name string) bool {
+	for i, p := range c.Profiles {
+		if p.Name == name {
+			c.Profiles = append(c.Profiles[0:i], c.Profiles[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetConfig returns notification configuration stored in the repository.
+func GetConfig(ctx context.Context, rep repo.Repository) (*Config, error) {
+	md, err := rep.FindManifests(ctx, manifestLabels)
+	if err != nil {
+		return nil, errors.Wrap(err, "error looking for notification manifest")
+	}
+
+	if len(md) == 0 {
+		return &Config{}, nil
+	}
+
+	c := &Config{}
+	if _, err := rep.GetManifest(ctx, manifest.PickLatestID(md), c); err != nil {
+		return nil, errors.Wrap(err, "error loading notification manifest")
+	}
+
+	return c, nil
+}
+
+// SetConfig stores notification configuration in the repository.
+func SetConfig(ctx context.Context, rep repo.RepositoryWriter, c *Config) error {
+	md, err := rep.FindManifests(ctx, manifestLabels)
+	if err != nil {
+		return errors.Wrap(err, "error looking for notification manifest")
+	}
+
+	if _, err := rep.PutManifest(ctx, manifestLabels, c); err != nil {
+		return errors.Wrap(err, "put manifest")
+	}
+
+	for _, m := range md {
+		if err := rep.DeleteManifest(ctx, m.ID); err != nil {
+			return errors.Wrap(err, "delete manifest")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSMTPPort = 587
+	emailTimeout    = 30 * time.Second
+)
+
+// EmailOptions describes SMTP server and addresses used to send notification emails.
+type EmailOptions struct {
+	SMTPServer   string `json:"smtpServer"`
+	SMTPPort     int    `json:"smtpPort,omitempty"`
+	SMTPUsername string `json:"smtpUsername,omitempty"`
+
+	// SMTPPassword is kept in the local client configuration, since the repository is readable by all its users.
+	SMTPPassword string `json:"-"`
+
+	From string   `json:"from"`
+	To   []string `json:"to"`
+}
+
+type emailSender struct {
+	opt *EmailOptions
+}
+
+func (s *emailSender) message(e *Event) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %v\r\n", s.opt.From)
+	fmt.Fprintf(&buf, "To: %v\r\n", strings.Join(s.opt.To, ", "))
+	fmt.Fprintf(&buf, "Subject: [kopia] %v\r\n", e.Subject)
+	fmt.Fprintf(&buf, "Date: %v\r\n", e.Time.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(e.Text(), "\n", "\r\n"))
+
+	return buf.Bytes()
+}
+
+// Send delivers the event, giving up when the context is canceled or after emailTimeout.
+func (s *emailSender) Send(ctx context.Context, e *Event) error {
+	ctx, cancel := context.WithTimeout(ctx, emailTimeout)
+	defer cancel()
+
+	port := s.opt.SMTPPort
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(s.opt.SMTPServer, strconv.Itoa(port)))
+	if err != nil {
+		return errors.Wrap(err, "unable to connect to SMTP server")
+	}
+
+	defer conn.Close() //nolint:errcheck
+
+	// the deadline applies to the entire SMTP conversation, cancellation aborts it by closing the connection.
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl) //nolint:errcheck
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close() //nolint:errcheck
+		case <-done:
+		}
+	}()
+
+	if err := s.sendMail(conn, s.message(e)); err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err(), "unable to send email")
+		}
+
+		return errors.Wrap(err, "unable to send email")
+	}
+
+	return nil
+}
+
+// sendMail sends the message over the provided connection, like smtp.SendMail() does.
+func (s *emailSender) sendMail(conn net.Conn, msg []byte) error {
+	c, err := smtp.NewClient(conn, s.opt.SMTPServer)
+	if err != nil {
+		return errors.Wrap(err, "SMTP handshake failed")
+	}
+
+	defer c.Close() //nolint:errcheck
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.opt.SMTPServer, MinVersion: tls.VersionTLS12}); err != nil {
+			return errors.Wrap(err, "STARTTLS failed")
+		}
+	}
+
+	if s.opt.SMTPUsername != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.opt.SMTPUsername, s.opt.SMTPPassword, s.opt.SMTPServer)); err != nil {
+			return errors.Wrap(err, "SMTP authentication failed")
+		}
+	}
+
+	if err := c.Mail(s.opt.From); err != nil {
+		return errors.Wrap(err, "invalid sender")
+	}
+
+	for _, to := range s.opt.To {
+		if err := c.Rcpt(to); err != nil {
+			return errors.Wrapf(err, "invalid recipient %q", to)
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return errors.Wrap(err, "DATA failed")
+	}
+
+	if _, err := w.Write(msg); err != nil {
+		return errors.Wrap(err, "error writing message")
+	}
+
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, "error writing message")
+	}
+
+	return errors.Wrap(c.Quit(), "QUIT failed")
+}
+
+func newEmailSender(opt *EmailOptions) (Sender, error) {
+	if opt.SMTPServer == "" {
+		return nil, errors.Errorf("SMTP server must be provided")
+	}
+
+	if opt.From == "" {
+		return nil, errors.Errorf("sender address must be provided")
+	}
+
+	if len(opt.To) == 0 {
+		return nil, errors.Errorf("at least one recipient must be provided")
+	}
+
+	// addresses are sent verbatim in SMTP commands and headers.
+	for _, a := range append([]string{opt.From}, opt.To...) {
+		if strings.ContainsAny(a, "\r\n") {
+			return nil, errors.Errorf("invalid email address %q", a)
+		}
+	}
+
+	return &emailSender{opt}, nil
+}
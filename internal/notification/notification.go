@@ -0,0 +1,94 @@
+// Package notification delivers notifications about snapshot and maintenance events to configured sinks.
+package notification
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// EventType identifies the kind of event being notified about.
+type EventType string
+
+// Supported event types.
+const (
+	EventSnapshotSucceeded  EventType = "snapshot-succeeded"
+	EventSnapshotFailed     EventType = "snapshot-failed"
+	EventSnapshotPartial    EventType = "snapshot-partial"
+	EventSnapshotCanceled   EventType = "snapshot-canceled"
+	EventMaintenanceFailed  EventType = "maintenance-failed"
+	EventMaintenanceOverdue EventType = "maintenance-overdue"
+	EventLowFreeSpace       EventType = "low-free-space"
+	EventTest               EventType = "test"
+)
+
+// AllEventTypes lists all event types that profiles can subscribe to.
+// nolint:gochecknoglobals
+var AllEventTypes = []EventType{
+	EventSnapshotSucceeded,
+	EventSnapshotFailed,
+	EventSnapshotPartial,
+	EventSnapshotCanceled,
+	EventMaintenanceFailed,
+	EventMaintenanceOverdue,
+	EventLowFreeSpace,
+}
+
+// Event describes a single notification.
+type Event struct {
+	Type     EventType         `json:"type"`
+	Time     time.Time         `json:"time"`
+	Hostname string            `json:"hostname"`
+	Subject  string            `json:"subject"`
+	Message  string            `json:"message"`
+	Details  map[string]string `json:"details,omitempty"`
+}
+
+// NewEvent creates a new event of the provided type with a formatted subject.
+func NewEvent(t EventType, subjectFormat string, args ...interface{}) *Event {
+	return &Event{
+		Type:    t,
+		Time:    clock.Now(),
+		Subject: fmt.Sprintf(subjectFormat, args...),
+		Details: map[string]string{},
+	}
+}
+
+// Text returns the plain-text rendering of the event including all details.
+func (e *Event) Text() string {
+	var sb strings.Builder
+
+	if e.Message != "" {
+		sb.WriteString(e.Message)
+		sb.WriteString("\n\n")
+	}
+
+	fmt.Fprintf(&sb, "Event: %v\n", e.Type)
+	fmt.Fprintf(&sb, "Time: %v\n", e.Time.Format(time.RFC3339))
+
+	if e.Hostname != "" {
+		fmt.Fprintf(&sb, "Host: %v\n", e.Hostname)
+	}
+
+	keys := make([]string, 0, len(e.Details))
+	for k := range e.Details {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(&sb, "%v: %v\n", k, e.Details[k])
+	}
+
+	return sb.String()
+}
+
+// Sender delivers events to a single destination.
+type Sender interface {
+	Send(ctx context.Context, e *Event) error
+}
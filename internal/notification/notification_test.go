@@ -0,0 +1,380 @@
+package notification_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo"
+)
+
+func TestWebhook(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	var (
+		mu     sync.Mutex
+		bodies []string
+		auth   []string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		bodies = append(bodies, string(b))
+		auth = append(auth, r.Header.Get("Authorization"))
+
+		if strings.Contains(string(b), "fail-me") {
+			http.Error(w, "failed", http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	ev := notification.NewEvent(notification.EventSnapshotFailed, "snapshot of %v failed", "\"quoted\" source")
+	ev.Details["error"] = "some error"
+
+	// default body is the JSON-encoded event.
+	require.NoError(t, notification.SendToProfile(ctx, "", &notification.ProfileConfig{
+		Name:    "default",
+		Webhook: &notification.WebhookOptions{URL: srv.URL},
+	}, ev))
+
+	var got notification.Event
+
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &got))
+	require.Equal(t, ev.Subject, got.Subject)
+	require.Equal(t, notification.EventSnapshotFailed, got.Type)
+	require.Equal(t, "some error", got.Details["error"])
+
+	// templated body, with headers.
+	require.NoError(t, notification.SendToProfile(ctx, "", &notification.ProfileConfig{
+		Name: "templated",
+		Webhook: &notification.WebhookOptions{
+			URL:          srv.URL,
+			Headers:      map[string]string{"Authorization": "Bearer secret"},
+			BodyTemplate: `{"text":{{json .Subject}},"error":{{json (index .Details "error")}}}`,
+		},
+	}, ev))
+
+	var templated map[string]string
+
+	require.NoError(t, json.Unmarshal([]byte(bodies[1]), &templated))
+	require.Equal(t, map[string]string{"text": ev.Subject, "error": "some error"}, templated)
+	require.Equal(t, "Bearer secret", auth[1])
+
+	// non-2xx responses are errors.
+	require.Error(t, notification.SendToProfile(ctx, "", &notification.ProfileConfig{
+		Name:    "failing",
+		Webhook: &notification.WebhookOptions{URL: srv.URL, BodyTemplate: "fail-me"},
+	}, ev))
+
+	// invalid template.
+	require.Error(t, notification.SendToProfile(ctx, "", &notification.ProfileConfig{
+		Name:    "invalid",
+		Webhook: &notification.WebhookOptions{URL: srv.URL, BodyTemplate: "{{"},
+	}, ev))
+}
+
+func TestEmail(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	smtpSrv := startFakeSMTPServer(t)
+
+	ev := notification.NewEvent(notification.EventMaintenanceFailed, "maintenance failed")
+	ev.Message = "Maintenance has failed."
+
+	host, port, err := net.SplitHostPort(smtpSrv.addr)
+	require.NoError(t, err)
+
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	require.NoError(t, notification.SendToProfile(ctx, "", &notification.ProfileConfig{
+		Name: "email",
+		Email: &notification.EmailOptions{
+			SMTPServer: host,
+			SMTPPort:   portNum,
+			From:       "kopia@example.com",
+			To:         []string{"admin@example.com"},
+		},
+	}, ev))
+
+	msgs := smtpSrv.messages()
+	require.Len(t, msgs, 1)
+	require.Contains(t, msgs[0], "Subject: [kopia] maintenance failed")
+	require.Contains(t, msgs[0], "Maintenance has failed.")
+	require.Contains(t, msgs[0], "Event: maintenance-failed")
+
+	_, err = (&notification.ProfileConfig{Name: "bad", Email: &notification.EmailOptions{SMTPServer: host}}).Sender()
+	require.Error(t, err)
+
+	_, err = (&notification.ProfileConfig{Name: "bad", Email: &notification.EmailOptions{
+		SMTPServer: host,
+		From:       "kopia@example.com",
+		To:         []string{"admin@example.com\r\nBcc: other@example.com"},
+	}}).Sender()
+	require.Error(t, err)
+}
+
+func TestEmailLocalPassword(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	smtpSrv := startFakeSMTPServer(t)
+
+	host, port, err := net.SplitHostPort(smtpSrv.addr)
+	require.NoError(t, err)
+
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	p := &notification.ProfileConfig{
+		Name: "email",
+		Email: &notification.EmailOptions{
+			SMTPServer:   host,
+			SMTPPort:     portNum,
+			SMTPUsername: "user",
+			SMTPPassword: "secret",
+			From:         "kopia@example.com",
+			To:           []string{"admin@example.com"},
+		},
+	}
+
+	// the password is not stored in the repository.
+	b, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.NotContains(t, string(b), "secret")
+
+	configFile := env.RepositoryWriter.ConfigFilename()
+	require.NoError(t, repo.SetNotificationPassword(ctx, configFile, "email", "secret"))
+
+	var stored notification.ProfileConfig
+
+	require.NoError(t, json.Unmarshal(b, &stored))
+	require.NoError(t, notification.SendToProfile(ctx, configFile, &stored, notification.NewEvent(notification.EventTest, "test")))
+	require.Equal(t, []string{"\x00user\x00secret"}, smtpSrv.credentials())
+
+	// the profile is not modified.
+	require.Empty(t, stored.Email.SMTPPassword)
+
+	require.NoError(t, repo.SetNotificationPassword(ctx, configFile, "email", ""))
+
+	lc, err := repo.LoadConfigFromFile(configFile)
+	require.NoError(t, err)
+	require.Empty(t, lc.NotificationPasswords)
+}
+
+func TestEmailCanceled(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	// server that accepts connections but never responds.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+
+	host, port, err := net.SplitHostPort(l.Addr().String())
+	require.NoError(t, err)
+
+	portNum, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	err = notification.SendToProfile(ctx, "", &notification.ProfileConfig{
+		Name: "email",
+		Email: &notification.EmailOptions{
+			SMTPServer: host,
+			SMTPPort:   portNum,
+			From:       "kopia@example.com",
+			To:         []string{"admin@example.com"},
+		},
+	}, notification.NewEvent(notification.EventTest, "test"))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestConfigAndSend(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	var (
+		mu       sync.Mutex
+		received []notification.EventType
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev notification.Event
+
+		json.NewDecoder(r.Body).Decode(&ev)
+
+		mu.Lock()
+		received = append(received, ev.Type)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	c, err := notification.GetConfig(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Empty(t, c.Profiles)
+
+	c.SetProfile(&notification.ProfileConfig{
+		Name:    "failures",
+		Events:  []notification.EventType{notification.EventSnapshotFailed},
+		Webhook: &notification.WebhookOptions{URL: srv.URL},
+	})
+	c.SetProfile(&notification.ProfileConfig{
+		Name:    "all",
+		Webhook: &notification.WebhookOptions{URL: srv.URL},
+	})
+
+	require.NoError(t, notification.SetConfig(ctx, env.RepositoryWriter, c))
+
+	c, err = notification.GetConfig(ctx, env.RepositoryWriter)
+	require.NoError(t, err)
+	require.Len(t, c.Profiles, 2)
+	require.NotNil(t, c.FindProfile("failures"))
+
+	require.NoError(t, notification.Send(ctx, env.RepositoryWriter, env.RepositoryWriter.ConfigFilename(), notification.NewEvent(notification.EventSnapshotSucceeded, "ok")))
+	require.Equal(t, []notification.EventType{notification.EventSnapshotSucceeded}, received)
+
+	require.NoError(t, notification.Send(ctx, env.RepositoryWriter, env.RepositoryWriter.ConfigFilename(), notification.NewEvent(notification.EventSnapshotFailed, "failed")))
+	require.Len(t, received, 3)
+
+	require.True(t, c.RemoveProfile("all"))
+	require.False(t, c.RemoveProfile("all"))
+	require.Len(t, c.Profiles, 1)
+}
+
+// fakeSMTPServer is a minimal SMTP server that accepts all messages.
+type fakeSMTPServer struct {
+	addr string
+
+	mu    sync.Mutex
+	msgs  []string
+	creds []string
+}
+
+func (s *fakeSMTPServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.msgs...)
+}
+
+func (s *fakeSMTPServer) credentials() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.creds...)
+}
+
+func (s *fakeSMTPServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case strings.HasPrefix(cmd, "AUTH PLAIN "):
+			cred, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(line)[len("AUTH PLAIN "):])
+
+			s.mu.Lock()
+			s.creds = append(s.creds, string(cred))
+			s.mu.Unlock()
+
+			reply("235 ok")
+		case strings.HasPrefix(cmd, "DATA"):
+			reply("354 go ahead")
+
+			var sb strings.Builder
+
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+
+				if l == ".\r\n" {
+					break
+				}
+
+				sb.WriteString(l)
+			}
+
+			s.mu.Lock()
+			s.msgs = append(s.msgs, sb.String())
+			s.mu.Unlock()
+
+			reply("250 ok")
+		case strings.HasPrefix(cmd, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func startFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { l.Close() })
+
+	s := &fakeSMTPServer{addr: l.Addr().String()}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.handle(conn)
+		}
+	}()
+
+	return s
+}
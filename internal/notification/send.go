@@ -0,0 +1,81 @@
+package notification
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+)
+
+// SendToProfile delivers the event to the provided profile using passwords and headers stored in the provided
+// configuration file, if any.
+func SendToProfile(ctx context.Context, configFile string, p *ProfileConfig, e *Event) error {
+	p, err := withLocalSecrets(configFile, p)
+	if err != nil {
+		return err
+	}
+
+	s, err := p.Sender()
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrapf(s.Send(ctx, e), "error sending notification to %q", p.Name)
+}
+
+// withLocalSecrets returns a copy of the profile with the SMTP password or webhook headers
+// from the local configuration file.
+func withLocalSecrets(configFile string, p *ProfileConfig) (*ProfileConfig, error) {
+	if (p.Email == nil && p.Webhook == nil) || configFile == "" {
+		return p, nil
+	}
+
+	lc, err := repo.LoadConfigFromFile(configFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load notification secrets")
+	}
+
+	result := *p
+
+	if password := lc.NotificationPasswords[p.Name]; password != "" && p.Email != nil {
+		eo := *p.Email
+		eo.SMTPPassword = password
+		result.Email = &eo
+	}
+
+	if headers := lc.NotificationHeaders[p.Name]; len(headers) > 0 && p.Webhook != nil {
+		wo := *p.Webhook
+		wo.Headers = headers
+		result.Webhook = &wo
+	}
+
+	return &result, nil
+}
+
+// Send delivers the event to all profiles configured in the repository that are subscribed to it.
+// Delivery is attempted to all profiles and the first error encountered is returned.
+func Send(ctx context.Context, rep repo.Repository, configFile string, e *Event) error {
+	c, err := GetConfig(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if e.Hostname == "" {
+		e.Hostname = rep.ClientOptions().Hostname
+	}
+
+	var firstErr error
+
+	for _, p := range c.Profiles {
+		if !p.Subscribed(e.Type) {
+			continue
+		}
+
+		if err := SendToProfile(ctx, configFile, p, e); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
@@ -0,0 +1,123 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	webhookTimeout        = 30 * time.Second
+	maxWebhookErrorLength = 1000
+)
+
+// WebhookOptions describes a HTTP endpoint that receives notifications.
+type WebhookOptions struct {
+	URL    string `json:"url"`
+	Method string `json:"method,omitempty"`
+
+	// Headers are kept in the local client configuration, since they usually carry credentials
+	// and the repository is readable by all its users.
+	Headers map[string]string `json:"-"`
+
+	// BodyTemplate is a Go text template producing the request body from the Event,
+	// when empty the event is sent as JSON.
+	BodyTemplate string `json:"bodyTemplate,omitempty"`
+}
+
+type webhookSender struct {
+	opt  *WebhookOptions
+	tmpl *template.Template
+	cli  *http.Client
+}
+
+// templateFuncs are available in webhook body templates.
+// nolint:gochecknoglobals
+var templateFuncs = template.FuncMap{
+	// json encodes the value as JSON, which allows strings to be safely embedded in JSON bodies.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func (s *webhookSender) body(e *Event) ([]byte, error) {
+	if s.tmpl == nil {
+		b, err := json.Marshal(e)
+		return b, errors.Wrap(err, "unable to serialize event")
+	}
+
+	var buf bytes.Buffer
+
+	if err := s.tmpl.Execute(&buf, e); err != nil {
+		return nil, errors.Wrap(err, "unable to execute body template")
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (s *webhookSender) Send(ctx context.Context, e *Event) error {
+	body, err := s.body(e)
+	if err != nil {
+		return err
+	}
+
+	method := s.opt.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, s.opt.URL, bytes.NewReader(body))
+	if err != nil {
+		return errors.Wrap(err, "unable to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range s.opt.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.cli.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "webhook request failed")
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode/100 != 2 { //nolint:gomnd
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxWebhookErrorLength))
+		return errors.Errorf("webhook returned %v: %s", resp.Status, msg)
+	}
+
+	return nil
+}
+
+func newWebhookSender(opt *WebhookOptions) (Sender, error) {
+	if opt.URL == "" {
+		return nil, errors.Errorf("webhook URL must be provided")
+	}
+
+	s := &webhookSender{
+		opt: opt,
+		cli: &http.Client{Timeout: webhookTimeout},
+	}
+
+	if opt.BodyTemplate != "" {
+		t, err := template.New("body").Funcs(templateFuncs).Parse(opt.BodyTemplate)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid body template")
+		}
+
+		s.tmpl = t
+	}
+
+	return s, nil
+}
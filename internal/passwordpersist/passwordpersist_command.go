@@ -0,0 +1,75 @@
+package passwordpersist
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultCommandTimeout is the default timeout of password helper commands.
+const DefaultCommandTimeout = 30 * time.Second
+
+// maxCommandErrorLength is the maximum length of helper command error output included in error messages.
+const maxCommandErrorLength = 200
+
+// CommandProvider is a Provider that runs a helper command and reads the password from its standard output.
+// The command receives the path of repository configuration file in KOPIA_CONFIG_PATH environment variable.
+type CommandProvider struct {
+	Command   string
+	Arguments []string
+
+	// Timeout specifies how long to wait for the command to complete, zero means DefaultCommandTimeout.
+	Timeout time.Duration
+}
+
+// FetchPassword implements Provider.
+func (p CommandProvider) FetchPassword(ctx context.Context, configFile string) (string, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultCommandTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+
+	c := exec.CommandContext(ctx, p.Command, p.Arguments...) // nolint:gosec
+	c.Env = append(os.Environ(), "KOPIA_CONFIG_PATH="+configFile)
+	c.Stdout = &stdout
+	c.Stderr = &stderr
+
+	if err := c.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", errors.Errorf("password command %q timed out after %v", p.Command, timeout)
+		}
+
+		if msg := truncateErrorOutput(stderr.String()); msg != "" {
+			return "", errors.Errorf("password command %q failed: %v: %v", p.Command, err, msg)
+		}
+
+		return "", errors.Errorf("password command %q failed: %v", p.Command, err)
+	}
+
+	// only strip the trailing newline, other whitespace may be a part of the password.
+	pass := strings.TrimRight(stdout.String(), "\r\n")
+	if pass == "" {
+		return "", errors.Errorf("password command %q did not return a password", p.Command)
+	}
+
+	return pass, nil
+}
+
+func truncateErrorOutput(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > maxCommandErrorLength {
+		s = s[0:maxCommandErrorLength] + "..."
+	}
+
+	return s
+}
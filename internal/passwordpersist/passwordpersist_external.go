@@ -0,0 +1,181 @@
+package passwordpersist
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// Provider retrieves passwords from an external secret source, such as a helper command or a local secret agent.
+type Provider interface {
+	// FetchPassword retrieves the password for the repository with a given configuration file.
+	FetchPassword(ctx context.Context, configFile string) (string, error)
+}
+
+// CacheMode determines where passwords retrieved from external providers are cached.
+type CacheMode string
+
+// Supported cache modes.
+const (
+	// CacheNone retrieves the password from the provider every time it is needed.
+	CacheNone CacheMode = "none"
+
+	// CacheMemory caches the password in memory of the current process.
+	CacheMemory CacheMode = "memory"
+
+	// CacheFile caches the password in a file next to repository config file, so that
+	// it can be reused by subsequent invocations.
+	CacheFile CacheMode = "file"
+)
+
+// CacheModes lists all supported cache modes.
+// nolint:gochecknoglobals
+var CacheModes = []CacheMode{CacheNone, CacheMemory, CacheFile}
+
+// CachePolicy determines whether and for how long passwords retrieved from external providers are cached.
+type CachePolicy struct {
+	Mode CacheMode
+
+	// TTL specifies how long the cached password remains valid, zero means until the cache is cleared.
+	TTL time.Duration
+}
+
+// External returns a Strategy that retrieves passwords from the provided Provider and caches
+// them according to the provided policy. Since the password is managed by the external source,
+// it is never persisted outside of the cache and deleting it only clears the cache.
+func External(p Provider, cache CachePolicy) Strategy {
+	return &externalStrategy{
+		provider: p,
+		cache:    cache,
+		memory:   map[string]cachedPassword{},
+	}
+}
+
+const cacheFileMode = 0o600
+
+type cachedPassword struct {
+	Password []byte    `json:"password"`
+	Expires  time.Time `json:"expires,omitempty"`
+}
+
+func (c cachedPassword) valid() bool {
+	return c.Expires.IsZero() || clock.Now().Before(c.Expires)
+}
+
+type externalStrategy struct {
+	provider Provider
+	cache    CachePolicy
+
+	mu     sync.Mutex
+	memory map[string]cachedPassword
+}
+
+func (s *externalStrategy) GetPassword(ctx context.Context, configFile string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cp, ok := s.getCached(ctx, configFile); ok {
+		log(ctx).Debugf("password for %v retrieved from cache", configFile)
+		return string(cp.Password), nil
+	}
+
+	pass, err := s.provider.FetchPassword(ctx, configFile)
+	if err != nil {
+		// nolint:wrapcheck
+		return "", err
+	}
+
+	log(ctx).Debugf("password for %v retrieved from external provider", configFile)
+
+	if err := s.putCached(configFile, pass); err != nil {
+		return "", err
+	}
+
+	return pass, nil
+}
+
+// PersistPassword only updates the cache, so that the password changed by the user is used
+// until the external source is updated.
+func (s *externalStrategy) PersistPassword(ctx context.Context, configFile, password string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	log(ctx).Debugf("password for %v is managed by external provider and won't be persisted", configFile)
+
+	return s.putCached(configFile, password)
+}
+
+func (s *externalStrategy) DeletePassword(ctx context.Context, configFile string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.memory, configFile)
+
+	if err := os.Remove(cacheFileName(configFile)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "error deleting password cache file")
+	}
+
+	return nil
+}
+
+func (s *externalStrategy) getCached(ctx context.Context, configFile string) (cachedPassword, bool) {
+	switch s.cache.Mode {
+	case CacheMemory:
+		cp, ok := s.memory[configFile]
+
+		return cp, ok && cp.valid()
+
+	case CacheFile:
+		var cp cachedPassword
+
+		b, err := ioutil.ReadFile(cacheFileName(configFile))
+		if err != nil {
+			return cp, false
+		}
+
+		if err := json.Unmarshal(b, &cp); err != nil {
+			log(ctx).Debugf("ignoring invalid password cache file: %v", err)
+			return cp, false
+		}
+
+		return cp, cp.valid()
+
+	default:
+		return cachedPassword{}, false
+	}
+}
+
+func (s *externalStrategy) putCached(configFile, password string) error {
+	cp := cachedPassword{Password: []byte(password)}
+	if s.cache.TTL > 0 {
+		cp.Expires = clock.Now().Add(s.cache.TTL)
+	}
+
+	switch s.cache.Mode {
+	case CacheMemory:
+		s.memory[configFile] = cp
+
+	case CacheFile:
+		b, err := json.Marshal(cp)
+		if err != nil {
+			return errors.Wrap(err, "error serializing cached password")
+		}
+
+		if err := writePrivateFile(cacheFileName(configFile), b, cacheFileMode); err != nil {
+			return errors.Wrap(err, "error writing password cache file")
+		}
+	}
+
+	return nil
+}
+
+func cacheFileName(configFile string) string {
+	return configFile + ".kopia-password-cache"
+}
@@ -0,0 +1,250 @@
+package passwordpersist_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/passwordpersist"
+	"github.com/kopia/kopia/internal/testutil"
+)
+
+type countingProvider struct {
+	password string
+	err      error
+	calls    int
+}
+
+func (p *countingProvider) FetchPassword(ctx context.Context, configFile string) (string, error) {
+	p.calls++
+
+	return p.password, p.err
+}
+
+func TestExternalCaching(t *testing.T) {
+	ctx := context.Background()
+
+	cases := []struct {
+		cache     passwordpersist.CachePolicy
+		wantCalls int
+	}{
+		{passwordpersist.CachePolicy{Mode: passwordpersist.CacheNone}, 3},
+		{passwordpersist.CachePolicy{Mode: passwordpersist.CacheMemory}, 1},
+		{passwordpersist.CachePolicy{Mode: passwordpersist.CacheMemory, TTL: time.Hour}, 1},
+		{passwordpersist.CachePolicy{Mode: passwordpersist.CacheMemory, TTL: time.Nanosecond}, 3},
+		{passwordpersist.CachePolicy{Mode: passwordpersist.CacheFile, TTL: time.Hour}, 1},
+		{passwordpersist.CachePolicy{Mode: passwordpersist.CacheFile, TTL: time.Nanosecond}, 3},
+	}
+
+	for _, tc := range cases {
+		configFile := filepath.Join(testutil.TempDirectory(t), "repository.config")
+		p := &countingProvider{password: "secret"}
+
+		s := passwordpersist.External(p, tc.cache)
+
+		for i := 0; i < 3; i++ {
+			pass, err := s.GetPassword(ctx, configFile)
+			require.NoError(t, err)
+			require.Equal(t, "secret", pass)
+		}
+
+		require.Equal(t, tc.wantCalls, p.calls, "%v", tc.cache)
+
+		// deleting clears the cache.
+		require.NoError(t, s.DeletePassword(ctx, configFile))
+
+		p.calls = 0
+
+		_, err := s.GetPassword(ctx, configFile)
+		require.NoError(t, err)
+		require.Equal(t, 1, p.calls, "%v", tc.cache)
+	}
+}
+
+func TestExternalFileCacheSharedBetweenInstances(t *testing.T) {
+	ctx := context.Background()
+	configFile := filepath.Join(testutil.TempDirectory(t), "repository.config")
+	cache := passwordpersist.CachePolicy{Mode: passwordpersist.CacheFile, TTL: time.Hour}
+
+	p1 := &countingProvider{password: "secret"}
+	_, err := passwordpersist.External(p1, cache).GetPassword(ctx, configFile)
+	require.NoError(t, err)
+
+	p2 := &countingProvider{err: errors.Errorf("should not be called")}
+	pass, err := passwordpersist.External(p2, cache).GetPassword(ctx, configFile)
+	require.NoError(t, err)
+	require.Equal(t, "secret", pass)
+	require.Zero(t, p2.calls)
+
+	// persisting replaces cached password.
+	s := passwordpersist.External(p2, cache)
+	require.NoError(t, s.PersistPassword(ctx, configFile, "new-secret"))
+
+	pass, err = s.GetPassword(ctx, configFile)
+	require.NoError(t, err)
+	require.Equal(t, "new-secret", pass)
+}
+
+func TestPasswordFilesArePrivate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not supported on windows")
+	}
+
+	ctx := context.Background()
+	dir := testutil.TempDirectory(t)
+	configFile := filepath.Join(dir, "repository.config")
+
+	// existing files readable by others are replaced with private ones.
+	for _, fn := range []string{configFile + ".kopia-password", configFile + ".kopia-password-cache"} {
+		require.NoError(t, ioutil.WriteFile(fn, []byte("{}"), 0o644))
+	}
+
+	require.NoError(t, passwordpersist.File.PersistPassword(ctx, configFile, "secret"))
+	require.NoError(t, passwordpersist.External(&countingProvider{}, passwordpersist.CachePolicy{Mode: passwordpersist.CacheFile}).PersistPassword(ctx, configFile, "secret"))
+
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	for _, e := range entries {
+		require.Equal(t, os.FileMode(0o600), e.Mode().Perm(), e.Name())
+	}
+}
+
+func TestExternalErrorsAreNotCached(t *testing.T) {
+	ctx := context.Background()
+	configFile := filepath.Join(testutil.TempDirectory(t), "repository.config")
+
+	p := &countingProvider{err: errors.Errorf("some error")}
+	s := passwordpersist.External(p, passwordpersist.CachePolicy{Mode: passwordpersist.CacheMemory})
+
+	_, err := s.GetPassword(ctx, configFile)
+	require.EqualError(t, err, "some error")
+
+	p.err = nil
+	p.password = "secret"
+
+	pass, err := s.GetPassword(ctx, configFile)
+	require.NoError(t, err)
+	require.Equal(t, "secret", pass)
+}
+
+func TestCommandProvider(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("not supported on windows")
+	}
+
+	ctx := context.Background()
+
+	cases := []struct {
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{args: []string{"-c", "echo 'my secret '"}, want: "my secret "},
+		{args: []string{"-c", "printf %s \"$KOPIA_CONFIG_PATH\""}, want: "/some/repository.config"},
+		{args: []string{"-c", "echo denied >&2; exit 3"}, wantErr: `password command "sh" failed: exit status 3: denied`},
+		{args: []string{"-c", "true"}, wantErr: `password command "sh" did not return a password`},
+		{args: []string{"-c", "exec sleep 10"}, wantErr: `password command "sh" timed out after 100ms`},
+	}
+
+	for _, tc := range cases {
+		p := passwordpersist.CommandProvider{
+			Command:   "sh",
+			Arguments: tc.args,
+			Timeout:   100 * time.Millisecond,
+		}
+
+		if tc.wantErr == "" {
+			p.Timeout = 0
+		}
+
+		pass, err := p.FetchPassword(ctx, "/some/repository.config")
+		if tc.wantErr != "" {
+			require.EqualError(t, err, tc.wantErr, "%v", tc.args)
+			continue
+		}
+
+		require.NoError(t, err, "%v", tc.args)
+		require.Equal(t, tc.want, pass)
+	}
+
+	_, err := passwordpersist.CommandProvider{Command: "no-such-password-command"}.FetchPassword(ctx, "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `password command "no-such-password-command" failed`)
+}
+
+func TestHTTPProvider(t *testing.T) {
+	ctx := context.Background()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain-secret\n")) //nolint:errcheck
+	})
+	mux.HandleFunc("/vault", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, "permission denied, token=token", http.StatusForbidden)
+			return
+		}
+
+		w.Write([]byte(`{"data":{"data":{"password":"vault-secret","count":1}}}`)) //nolint:errcheck
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	vaultHeaders := http.Header{}
+	vaultHeaders.Set("X-Vault-Token", "token")
+
+	cases := []struct {
+		provider passwordpersist.HTTPProvider
+		want     string
+		wantErr  string
+	}{
+		{provider: passwordpersist.HTTPProvider{URL: srv.URL + "/plain"}, want: "plain-secret"},
+		{provider: passwordpersist.HTTPProvider{URL: srv.URL + "/vault", Headers: vaultHeaders, JSONField: "data.data.password"}, want: "vault-secret"},
+		{
+			provider: passwordpersist.HTTPProvider{URL: srv.URL + "/vault", JSONField: "data.data.password"},
+			wantErr:  `secret endpoint "` + srv.URL + `/vault" returned 403 Forbidden`,
+		},
+		{
+			provider: passwordpersist.HTTPProvider{URL: srv.URL + "/vault", Headers: vaultHeaders, JSONField: "data.password"},
+			wantErr:  `invalid response from secret endpoint "` + srv.URL + `/vault": field "data.password" not found`,
+		},
+		{
+			provider: passwordpersist.HTTPProvider{URL: srv.URL + "/vault", Headers: vaultHeaders, JSONField: "data.data.count"},
+			wantErr:  `invalid response from secret endpoint "` + srv.URL + `/vault": field "data.data.count" is not a string`,
+		},
+		{
+			provider: passwordpersist.HTTPProvider{URL: srv.URL + "/plain", JSONField: "password"},
+			wantErr:  `invalid response from secret endpoint "` + srv.URL + `/plain": response is not valid JSON`,
+		},
+		{
+			provider: passwordpersist.HTTPProvider{URL: srv.URL + "/slow", Timeout: 100 * time.Millisecond},
+			wantErr:  `secret endpoint "` + srv.URL + `/slow" did not respond within 100ms`,
+		},
+	}
+
+	for _, tc := range cases {
+		pass, err := tc.provider.FetchPassword(ctx, "")
+		if tc.wantErr != "" {
+			require.EqualError(t, err, tc.wantErr)
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, tc.want, pass)
+	}
+}
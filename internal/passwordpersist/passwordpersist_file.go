@@ -5,6 +5,7 @@
 	"encoding/base64"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 )
@@ -40,8 +41,7 @@ func (filePasswordStorage) PersistPassword(ctx context.Context, configFile, pass
 	fn := passwordFileName(configFile)
 	log(ctx).Debugf("Saving password to file %v.", fn)
 
-	// nolint:wrapcheck
-	return ioutil.WriteFile(fn, []byte(base64.StdEncoding.EncodeToString([]byte(password))), passwordFileMode)
+	return writePrivateFile(fn, []byte(base64.StdEncoding.EncodeToString([]byte(password))), passwordFileMode)
 }
 
 func (filePasswordStorage) DeletePassword(ctx context.Context, configFile string) error {
@@ -56,3 +56,31 @@ func (filePasswordStorage) DeletePassword(ctx context.Context, configFile string
 func passwordFileName(configFile string) string {
 	return configFile + ".kopia-password"
 }
+
+// writePrivateFile replaces the contents of the file with the provided data by writing a temporary file
+// with the provided mode and renaming it, so that the mode also applies when the file already exists,
+// which ioutil.WriteFile() would keep.
+func writePrivateFile(fn string, data []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(fn), filepath.Base(fn)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+
+	defer os.Remove(f.Name()) //nolint:errcheck
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "unable to set file mode")
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "unable to write file")
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close file")
+	}
+
+	return errors.Wrap(os.Rename(f.Name(), fn), "unable to rename file")
+}
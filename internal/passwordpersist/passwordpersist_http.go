@@ -0,0 +1,125 @@
+package passwordpersist
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHTTPTimeout is the default timeout of requests to secret endpoints.
+const DefaultHTTPTimeout = 30 * time.Second
+
+// maxSecretResponseSize is the maximum size of secret endpoint response.
+const maxSecretResponseSize = 1 << 20
+
+// HTTPProvider is a Provider that retrieves the password from an HTTP secret endpoint, such as
+// a local Vault agent.
+type HTTPProvider struct {
+	URL string
+
+	// Headers are added to each request, for example to pass authentication tokens.
+	Headers http.Header
+
+	// JSONField is a dot-separated path of the field holding the password in JSON response,
+	// such as "data.data.password", when empty the entire response body is the password.
+	JSONField string
+
+	// Timeout specifies how long to wait for the response, zero means DefaultHTTPTimeout.
+	Timeout time.Duration
+
+	// Client is used to issue requests, nil means http.DefaultClient.
+	Client *http.Client
+}
+
+// FetchPassword implements Provider.
+func (p HTTPProvider) FetchPassword(ctx context.Context, configFile string) (string, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = DefaultHTTPTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid secret endpoint %q", p.URL)
+	}
+
+	for k, v := range p.Headers {
+		req.Header[k] = v
+	}
+
+	cli := p.Client
+	if cli == nil {
+		cli = http.DefaultClient
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", errors.Errorf("secret endpoint %q did not respond within %v", p.URL, timeout)
+		}
+
+		return "", errors.Wrapf(err, "unable to reach secret endpoint %q", p.URL)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSecretResponseSize))
+	if err != nil {
+		return "", errors.Wrapf(err, "error reading response from secret endpoint %q", p.URL)
+	}
+
+	// don't include the response body in the error, it may contain secrets.
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("secret endpoint %q returned %v", p.URL, resp.Status)
+	}
+
+	pass := strings.TrimRight(string(body), "\r\n")
+
+	if p.JSONField != "" {
+		pass, err = extractJSONField(body, p.JSONField)
+		if err != nil {
+			return "", errors.Wrapf(err, "invalid response from secret endpoint %q", p.URL)
+		}
+	}
+
+	if pass == "" {
+		return "", errors.Errorf("secret endpoint %q did not return a password", p.URL)
+	}
+
+	return pass, nil
+}
+
+// extractJSONField returns the string value of the field with a given dot-separated path.
+func extractJSONField(body []byte, field string) (string, error) {
+	var v interface{}
+
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", errors.Errorf("response is not valid JSON")
+	}
+
+	for _, name := range strings.Split(field, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return "", errors.Errorf("field %q not found", field)
+		}
+
+		if v, ok = m[name]; !ok {
+			return "", errors.Errorf("field %q not found", field)
+		}
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return "", errors.Errorf("field %q is not a string", field)
+	}
+
+	return s, nil
+}
@@ -219,6 +219,45 @@ func (s *Server) handleRepoSetDescription(ctx context.Context, r *http.Request,
 	return s.handleRepoStatus(ctx, r, nil)
 }
 
+func (s *Server) handleRepoGetThrottle(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	tr, ok := s.rep.(repo.ThrottlingRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorNotConnected, "throttling is only supported for direct repository connections")
+	}
+
+	return &serverapi.ThrottlingScheduleResponse{
+		Schedule:      tr.Throttler().Schedule(),
+		CurrentLimits: tr.Throttler().CurrentLimits(),
+	}, nil
+}
+
+func (s *Server) handleRepoSetThrottle(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	tr, ok := s.rep.(repo.ThrottlingRepository)
+	if !ok {
+		return nil, requestError(serverapi.ErrorNotConnected, "throttling is only supported for direct repository connections")
+	}
+
+	var req serverapi.ThrottlingScheduleRequest
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "unable to decode request: "+err.Error())
+	}
+
+	if err := req.Schedule.Validate(); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	if err := repo.SetThrottlingSchedule(ctx, s.options.ConfigFile, req.Schedule); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if err := tr.Throttler().SetSchedule(req.Schedule); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return s.handleRepoGetThrottle(ctx, r, nil)
+}
+
 func (s *Server) handleRepoSupportedAlgorithms(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
 	res := &serverapi.SupportedAlgorithmsResponse{
 		DefaultHashAlgorithm: hashing.DefaultAlgorithm,
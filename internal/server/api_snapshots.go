@@ -2,12 +2,20 @@
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/url"
+	"path/filepath"
 
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/auth"
 	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
 )
 
 func (s *Server) handleSnapshotList(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
@@ -45,6 +53,135 @@ func (s *Server) handleSnapshotList(ctx context.Context, r *http.Request, body [
 	return resp, nil
 }
 
+func (s *Server) handleSnapshotsEdit(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	var req serverapi.EditSnapshotsRequest
+
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "malformed request body")
+	}
+
+	if err := snapshot.ValidateUserTags(req.AddTags); err != nil {
+		return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+	}
+
+	var manifests []*snapshot.Manifest
+
+	for _, id := range req.Snapshots {
+		em, err := s.rep.GetManifest(ctx, id, nil)
+		if errors.Is(err, manifest.ErrNotFound) {
+			// don't reveal whether snapshots exist to users who can only edit their own.
+			if !requireUIUser(s, r) {
+				return nil, accessDeniedError()
+			}
+
+			return nil, notFoundError("snapshot not found")
+		}
+
+		if err != nil {
+			return nil, internalServerError(err)
+		}
+
+		// editing replaces the manifest, which requires full access to it.
+		if !requireUIUser(s, r) && !hasManifestAccess(s, r, em.Labels, auth.AccessLevelFull) {
+			return nil, accessDeniedError()
+		}
+
+		m, err := snapshot.LoadSnapshot(ctx, s.rep, id)
+		if err != nil {
+			return nil, requestError(serverapi.ErrorMalformedRequest, err.Error())
+		}
+
+		manifests = append(manifests, m)
+	}
+
+	resp := &serverapi.SnapshotsResponse{
+		Snapshots: []*serverapi.Snapshot{},
+	}
+
+	if err := repo.WriteSession(ctx, s.rep, repo.WriteSessionOptions{
+		Purpose: "handleSnapshotsEdit",
+	}, func(ctx context.Context, w repo.RepositoryWriter) error {
+		for _, m := range manifests {
+			changed := m.UpdateTags(req.AddTags, req.RemoveTags)
+
+			if req.NewDescription != nil && m.Description != *req.NewDescription {
+				m.Description = *req.NewDescription
+				changed = true
+			}
+
+			if changed {
+				if _, err := snapshot.UpdateSnapshot(ctx, w, m); err != nil {
+					return errors.Wrapf(err, "error updating snapshot %v", m.ID)
+				}
+			}
+
+			resp.Snapshots = append(resp.Snapshots, convertSnapshotManifest(m))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, internalServerError(err)
+	}
+
+	return resp, nil
+}
+
+func (s *Server) handleHistory(ctx context.Context, r *http.Request, body []byte) (interface{}, *apiError) {
+	q := r.URL.Query()
+
+	si := snapshot.SourceInfo{
+		Host:     q.Get("host"),
+		UserName: q.Get("userName"),
+		Path:     q.Get("path"),
+	}
+
+	if si.Path == "" {
+		return nil, requestError(serverapi.ErrorMalformedRequest, "path not provided")
+	}
+
+	// normalize the path the same way as source paths are normalized when taking snapshots.
+	si.Path = filepath.Clean(si.Path)
+
+	if si.Host == "" {
+		si.Host = s.rep.ClientOptions().Hostname
+	}
+
+	if si.UserName == "" {
+		si.UserName = s.rep.ClientOptions().Username
+	}
+
+	manifestIDs, relPath, err := snapshot.ListSnapshotManifestsForPath(ctx, s.rep, si, nil)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	if len(manifestIDs) == 0 {
+		return nil, notFoundError("no snapshots found containing the provided path")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, s.rep, manifestIDs)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	versions, err := snapshotfs.EntryHistory(ctx, s.rep, manifests, relPath)
+	if err != nil {
+		return nil, internalServerError(err)
+	}
+
+	resp := &serverapi.HistoryResponse{
+		Source:       manifests[0].Source,
+		RelativePath: relPath,
+		Versions:     versions,
+	}
+
+	if resp.Versions == nil {
+		resp.Versions = []*snapshotfs.EntryVersion{}
+	}
+
+	return resp, nil
+}
+
 func sourceMatchesURLFilter(src snapshot.SourceInfo, query url.Values) bool {
 	if v := query.Get("host"); v != "" && src.Host != v {
 		return false
@@ -71,6 +208,7 @@ func convertSnapshotManifest(m *snapshot.Manifest) *serverapi.Snapshot {
 		IncompleteReason: m.IncompleteReason,
 		RootEntry:        m.RootObjectID().String(),
 		RetentionReasons: m.RetentionReasons,
+		Pins:             m.Pins,
 	}
 
 	if re := m.RootEntry; re != nil {
@@ -0,0 +1,158 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/apiclient"
+	"github.com/kopia/kopia/internal/mockfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/serverapi"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+func TestSnapshotsEdit(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, env := repotesting.NewEnvironment(t)
+
+	ownID, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, &snapshot.Manifest{
+		Source:      snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname},
+		Description: "own",
+		Tags:        map[string]string{"tag:owner": "foo"},
+	})
+	require.NoError(t, err)
+
+	otherID, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, &snapshot.Manifest{
+		Source:      snapshot.SourceInfo{Host: "other-host", UserName: "other-user", Path: testPathname},
+		Description: "other",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	si := startServerForEnvironment(ctx, t, env)
+
+	remoteUserClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		Username:                            testUsername + "@" + testHostname,
+		Password:                            testPassword,
+	})
+	require.NoError(t, err)
+
+	uiUserClient, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		Username:                            testUIUsername,
+		Password:                            testUIPassword,
+	})
+	require.NoError(t, err)
+
+	newDescription := "edited"
+
+	resp, err := serverapi.EditSnapshots(ctx, remoteUserClient, &serverapi.EditSnapshotsRequest{
+		Snapshots:      []manifest.ID{ownID},
+		NewDescription: &newDescription,
+		AddTags:        map[string]string{"env": "prod"},
+		RemoveTags:     []string{"owner"},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Snapshots, 1)
+	require.Equal(t, "edited", resp.Snapshots[0].Description)
+	require.NotEqual(t, ownID, resp.Snapshots[0].ID)
+
+	m, err := snapshot.LoadSnapshot(ctx, env.Repository, resp.Snapshots[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"tag:env": "prod"}, m.Tags)
+
+	// remote user can't edit snapshots of other users.
+	var hsr apiclient.HTTPStatusError
+
+	_, err = serverapi.EditSnapshots(ctx, remoteUserClient, &serverapi.EditSnapshotsRequest{
+		Snapshots:      []manifest.ID{otherID},
+		NewDescription: &newDescription,
+	})
+	require.True(t, errors.As(err, &hsr))
+	require.Equal(t, http.StatusForbidden, hsr.HTTPStatusCode)
+
+	// remote user can't tell whether a snapshot exists.
+	_, err = serverapi.EditSnapshots(ctx, remoteUserClient, &serverapi.EditSnapshotsRequest{
+		Snapshots:      []manifest.ID{"no-such-snapshot"},
+		NewDescription: &newDescription,
+	})
+	require.True(t, errors.As(err, &hsr))
+	require.Equal(t, http.StatusForbidden, hsr.HTTPStatusCode)
+
+	// tags are validated like in the CLI.
+	for _, tags := range []map[string]string{
+		{"": "empty-key"},
+		{"env:prod": "value"},
+	} {
+		_, err = serverapi.EditSnapshots(ctx, remoteUserClient, &serverapi.EditSnapshotsRequest{
+			Snapshots: []manifest.ID{resp.Snapshots[0].ID},
+			AddTags:   tags,
+		})
+		require.True(t, errors.As(err, &hsr), tags)
+		require.Equal(t, http.StatusBadRequest, hsr.HTTPStatusCode, tags)
+	}
+
+	// UI user can edit all snapshots.
+	resp, err = serverapi.EditSnapshots(ctx, uiUserClient, &serverapi.EditSnapshotsRequest{
+		Snapshots:      []manifest.ID{otherID},
+		NewDescription: &newDescription,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "edited", resp.Snapshots[0].Description)
+
+	_, err = serverapi.EditSnapshots(ctx, uiUserClient, &serverapi.EditSnapshotsRequest{
+		Snapshots: []manifest.ID{otherID},
+	})
+	require.True(t, errors.As(err, &hsr))
+	require.Equal(t, http.StatusNotFound, hsr.HTTPStatusCode)
+}
+
+func TestHistoryNormalizesPath(t *testing.T) {
+	ctx := testlogging.Context(t)
+	_, env := repotesting.NewEnvironment(t)
+
+	src := snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: testPathname}
+
+	dir := mockfs.NewDirectory()
+	dir.AddDir("sub", 0o755).AddFile("file.txt", []byte("contents"), 0o644)
+
+	man, err := snapshotfs.NewUploader(env.RepositoryWriter).Upload(ctx, dir, nil, src)
+	require.NoError(t, err)
+
+	_, err = snapshot.SaveSnapshot(ctx, env.RepositoryWriter, man)
+	require.NoError(t, err)
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	si := startServerForEnvironment(ctx, t, env)
+
+	cli, err := apiclient.NewKopiaAPIClient(apiclient.Options{
+		BaseURL:                             si.BaseURL,
+		TrustedServerCertificateFingerprint: si.TrustedServerCertificateFingerprint,
+		Username:                            testUIUsername,
+		Password:                            testUIPassword,
+	})
+	require.NoError(t, err)
+
+	for _, p := range []string{
+		testPathname + "/sub/file.txt",
+		testPathname + "/./sub/file.txt",
+		testPathname + "//sub/../sub/file.txt",
+		testPathname + "/sub/file.txt/",
+		testPathname + "/sub/file.txt/.",
+	} {
+		resp, err := serverapi.GetHistory(ctx, cli, snapshot.SourceInfo{Host: testHostname, UserName: testUsername, Path: p})
+		require.NoError(t, err, p)
+		require.Equal(t, "sub/file.txt", resp.RelativePath, p)
+		require.Len(t, resp.Versions, 1, p)
+	}
+}
@@ -77,6 +77,8 @@ func (s *Server) APIHandlers(legacyAPI bool) http.Handler {
 
 	// snapshots
 	m.HandleFunc("/api/v1/snapshots", s.handleAPI(requireUIUser, s.handleSnapshotList)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/snapshots/edit", s.handleAPI(handlerWillCheckAuthorization, s.handleSnapshotsEdit)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/history", s.handleAPI(requireUIUser, s.handleHistory)).Methods(http.MethodGet)
 
 	m.HandleFunc("/api/v1/policy", s.handleAPI(requireUIUser, s.handlePolicyGet)).Methods(http.MethodGet)
 	m.HandleFunc("/api/v1/policy", s.handleAPI(requireUIUser, s.handlePolicyPut)).Methods(http.MethodPut)
@@ -100,6 +102,8 @@ func (s *Server) APIHandlers(legacyAPI bool) http.Handler {
 	m.HandleFunc("/api/v1/repo/exists", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleRepoExists)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/repo/create", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleRepoCreate)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/repo/description", s.handleAPI(requireUIUser, s.handleRepoSetDescription)).Methods(http.MethodPost)
+	m.HandleFunc("/api/v1/repo/throttle", s.handleAPI(requireUIUser, s.handleRepoGetThrottle)).Methods(http.MethodGet)
+	m.HandleFunc("/api/v1/repo/throttle", s.handleAPI(requireUIUser, s.handleRepoSetThrottle)).Methods(http.MethodPut)
 
 	m.HandleFunc("/api/v1/repo/disconnect", s.handleAPI(requireUIUser, s.handleRepoDisconnect)).Methods(http.MethodPost)
 	m.HandleFunc("/api/v1/repo/algorithms", s.handleAPIPossiblyNotConnected(requireUIUser, s.handleRepoSupportedAlgorithms)).Methods(http.MethodGet)
@@ -484,12 +488,19 @@ func (s *Server) refreshPeriodically(ctx context.Context, r repo.Repository) {
 }
 
 func (s *Server) periodicMaintenance(ctx context.Context, rep repo.Repository) {
+	health := &repositoryHealth{}
+
+	s.checkRepositoryHealth(ctx, rep, health)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 
 		case <-time.After(maintenanceAttemptFrequency):
+			// maintenance may be performed by other clients, refresh metrics from the schedule.
+			s.checkRepositoryHealth(ctx, rep, health)
+
 			if owned, err := maintenance.IsOwnedByThisUser(ctx, rep); err == nil && !owned {
 				// maintenance not owned by this user, don't run, but keep trying because
 				// maintenance ownership MAY change to this user in the future.
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/internal/freespace"
+	"github.com/kopia/kopia/internal/notification"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob/filesystem"
+	"github.com/kopia/kopia/repo/maintenance"
+)
+
+// maintenanceOverdueThreshold is the amount of time past the scheduled full maintenance
+// after which maintenance is considered to have stopped running.
+const maintenanceOverdueThreshold = 24 * time.Hour
+
+// notificationTimeout is the maximum amount of time spent sending a single notification to all profiles.
+const notificationTimeout = 2 * time.Minute
+
+// repositoryHealth keeps track of conditions that have already been notified about,
+// so that notifications are only sent once until the condition clears.
+type repositoryHealth struct {
+	maintenanceOverdue bool
+	lowFreeSpace       map[string]bool
+}
+
+// checkRepositoryHealth records maintenance metrics and sends notifications about overdue maintenance
+// and low free space on local volumes used by the repository.
+func (s *Server) checkRepositoryHealth(ctx context.Context, rep repo.Repository, h *repositoryHealth) {
+	dr, ok := rep.(repo.DirectRepository)
+	if !ok {
+		return
+	}
+
+	sched, err := maintenance.GetSchedule(ctx, dr)
+	if err != nil {
+		log(ctx).Debugf("unable to get maintenance schedule: %v", err)
+	} else {
+		maintenance.RecordScheduleMetrics(ctx, sched)
+		s.checkMaintenanceOverdue(ctx, dr, sched, h)
+	}
+
+	s.checkFreeSpace(ctx, dr, h)
+}
+
+func (s *Server) checkMaintenanceOverdue(ctx context.Context, dr repo.DirectRepository, sched *maintenance.Schedule, h *repositoryHealth) {
+	p, err := maintenance.GetParams(ctx, dr)
+	if err != nil {
+		log(ctx).Debugf("unable to get maintenance parameters: %v", err)
+		return
+	}
+
+	overdue := p.FullCycle.Enabled && !sched.NextFullMaintenanceTime.IsZero() &&
+		clock.Now().After(sched.NextFullMaintenanceTime.Add(maintenanceOverdueThreshold))
+
+	if overdue && !h.maintenanceOverdue {
+		ev := notification.NewEvent(notification.EventMaintenanceOverdue, "Maintenance of %v is overdue", dr.BlobReader().DisplayName())
+		ev.Message = "Full maintenance has not run as scheduled. Make sure maintenance owner is running it periodically."
+		ev.Details["Scheduled"] = sched.NextFullMaintenanceTime.Format(time.RFC3339)
+		ev.Details["Owner"] = p.Owner
+
+		s.sendNotification(ctx, dr, ev)
+	}
+
+	h.maintenanceOverdue = overdue
+}
+
+// localVolumePaths returns paths on local volumes used by the repository.
+func (s *Server) localVolumePaths(ctx context.Context, dr repo.DirectRepository) []string {
+	var paths []string
+
+	if fso, ok := dr.BlobReader().ConnectionInfo().Config.(*filesystem.Options); ok {
+		paths = append(paths, fso.Path)
+	}
+
+	if s.options.ConfigFile != "" {
+		if co, err := repo.GetCachingOptions(ctx, s.options.ConfigFile); err == nil && co.CacheDirectory != "" {
+			paths = append(paths, co.CacheDirectory)
+		}
+	}
+
+	return paths
+}
+
+func (s *Server) checkFreeSpace(ctx context.Context, dr repo.DirectRepository, h *repositoryHealth) {
+	c, err := notification.GetConfig(ctx, dr)
+	if err != nil || c.MinFreeSpace <= 0 {
+		return
+	}
+
+	if h.lowFreeSpace == nil {
+		h.lowFreeSpace = map[string]bool{}
+	}
+
+	for _, p := range s.localVolumePaths(ctx, dr) {
+		avail, err := freespace.Available(p)
+		if err != nil {
+			log(ctx).Debugf("unable to determine free space: %v", err)
+			continue
+		}
+
+		low := avail < uint64(c.MinFreeSpace)
+
+		if low && !h.lowFreeSpace[p] {
+			ev := notification.NewEvent(notification.EventLowFreeSpace, "Low free space on %v", p)
+			ev.Details["Path"] = p
+			ev.Details["Available"] = units.BytesStringBase10(int64(avail))
+			ev.Details["Minimum"] = units.BytesStringBase10(c.MinFreeSpace)
+
+			s.sendNotification(ctx, dr, ev)
+		}
+
+		h.lowFreeSpace[p] = low
+	}
+}
+
+// sendNotification sends the notification even if the operation that triggered it was canceled,
+// waiting at most notificationTimeout.
+func (s *Server) sendNotification(ctx context.Context, rep repo.Repository, ev *notification.Event) {
+	ctx, cancel := context.WithTimeout(ctxutil.Detach(ctx), notificationTimeout)
+	defer cancel()
+
+	if err := notification.Send(ctx, rep, s.options.ConfigFile, ev); err != nil {
+		log(ctx).Errorf("unable to send notification: %v", err)
+	}
+}
@@ -44,6 +44,11 @@
 func startServer(ctx context.Context, t *testing.T) *repo.APIServerInfo {
 	_, env := repotesting.NewEnvironment(t)
 
+	return startServerForEnvironment(ctx, t, env)
+}
+
+// nolint:thelper
+func startServerForEnvironment(ctx context.Context, t *testing.T, env *repotesting.Environment) *repo.APIServerInfo {
 	s, err := server.New(ctx, server.Options{
 		ConfigFile:      env.ConfigFile(),
 		PasswordPersist: passwordpersist.File,
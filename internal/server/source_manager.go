@@ -3,6 +3,7 @@
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -12,8 +13,10 @@
 	"github.com/kopia/kopia/fs/localfs"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/ctxutil"
+	"github.com/kopia/kopia/internal/notification"
 	"github.com/kopia/kopia/internal/serverapi"
 	"github.com/kopia/kopia/internal/uitask"
+	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 	"github.com/kopia/kopia/snapshot/policy"
@@ -24,6 +27,7 @@
 	statusRefreshInterval       = 15 * time.Second // how frequently to refresh source status
 	failedSnapshotRetryInterval = 5 * time.Minute
 	refreshTimeout              = 30 * time.Second // max amount of time to refresh a single source
+	missedSnapshotDelay         = 1 * time.Minute  // delay before running a missed snapshot after startup or resume
 	oneDay                      = 24 * time.Hour
 )
 
@@ -49,6 +53,10 @@ type sourceManager struct {
 	pol                                policy.SchedulingPolicy
 	state                              string
 	nextSnapshotTime                   *time.Time
+	nextSnapshotTrigger                string
+	lastSnapshotTrigger                string
+	missedSnapshotSlot                 time.Time  // slot for which a missed snapshot has already been scheduled
+	missedSnapshotRunTime              *time.Time // when to run the missed snapshot, nil if not pending
 	lastSnapshot                       *snapshot.Manifest
 	lastCompleteSnapshot               *snapshot.Manifest
 	manifestsSinceLastCompleteSnapshot []*snapshot.Manifest
@@ -62,11 +70,17 @@ func (s *sourceManager) Status() *serverapi.SourceStatus {
 	defer s.mu.RUnlock()
 
 	st := &serverapi.SourceStatus{
-		Source:           s.src,
-		Status:           s.state,
-		NextSnapshotTime: s.nextSnapshotTime,
-		SchedulingPolicy: s.pol,
-		LastSnapshot:     s.lastSnapshot,
+		Source:              s.src,
+		Status:              s.state,
+		NextSnapshotTime:    s.nextSnapshotTime,
+		NextSnapshotTrigger: s.nextSnapshotTrigger,
+		LastSnapshotTrigger: s.lastSnapshotTrigger,
+		SchedulingPolicy:    s.pol,
+		LastSnapshot:        s.lastSnapshot,
+	}
+
+	if st.NextSnapshotTime == nil {
+		st.NextSnapshotTrigger = ""
 	}
 
 	if st.Status == "UPLOADING" {
@@ -139,6 +153,7 @@ func (s *sourceManager) runLocal(ctx context.Context) {
 		case <-s.snapshotRequests:
 			nt := clock.Now()
 			s.nextSnapshotTime = &nt
+			s.nextSnapshotTrigger = serverapi.SnapshotTriggerManual
 
 			continue
 
@@ -146,11 +161,18 @@ func (s *sourceManager) runLocal(ctx context.Context) {
 			s.refreshStatus(ctx)
 
 		case <-time.After(waitTime):
-			log(ctx).Debugf("snapshotting %v", s.src)
+			s.mu.Lock()
+			s.lastSnapshotTrigger = s.nextSnapshotTrigger
+			s.missedSnapshotRunTime = nil
+			s.mu.Unlock()
+
+			log(ctx).Debugf("snapshotting %v (%v)", s.src, s.lastSnapshotTrigger)
 
 			if err := s.snapshot(ctx); err != nil {
 				log(ctx).Errorf("snapshot error: %v", err)
 
+				recordSourceMetrics(ctx, s.src, metricSourceSnapshotFailures.M(1))
+
 				s.backoffBeforeNextSnapshot()
 			} else {
 				s.refreshStatus(ctx)
@@ -166,6 +188,7 @@ func (s *sourceManager) backoffBeforeNextSnapshot() {
 
 	t := clock.Now().Add(failedSnapshotRetryInterval)
 	s.nextSnapshotTime = &t
+	s.nextSnapshotTrigger = serverapi.SnapshotTriggerRetry
 }
 
 func (s *sourceManager) runReadOnly(ctx context.Context) {
@@ -236,7 +259,11 @@ func (s *sourceManager) snapshot(ctx context.Context) error {
 		s.snapshotInternal)
 }
 
-func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Controller) error {
+func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Controller) (err error) {
+	var saved *snapshot.Manifest
+
+	defer func() { s.notifySnapshotResult(ctx, saved, err) }()
+
 	s.setStatus("UPLOADING")
 
 	s.currentTask = ctrl.CurrentTaskID()
@@ -283,8 +310,12 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 		u.Progress = prog
 		onUpload = func(numBytes int64) {
 			u.Progress.UploadedBytes(numBytes)
+
+			recordSourceMetrics(ctx, s.src, metricSourceCurrentSnapshotUploadedBytes.M(s.progress.Snapshot().TotalUploadedBytes))
 		}
 
+		defer recordSourceMetrics(ctx, s.src, metricSourceCurrentSnapshotUploadedBytes.M(0))
+
 		log(ctx).Debugf("starting upload of %v", s.src)
 		s.setUploader(u)
 
@@ -306,35 +337,88 @@ func (s *sourceManager) snapshotInternal(ctx context.Context, ctrl uitask.Contro
 			return errors.Wrap(err, "unable to apply retention policy")
 		}
 
+		saved = manifest
+
 		log(ctx).Debugf("created snapshot %v", snapshotID)
 		return nil
 	})
 }
 
-func (s *sourceManager) findClosestNextSnapshotTime() *time.Time {
-	var nextSnapshotTime *time.Time
+// notifySnapshotResult sends notification about the outcome of a snapshot.
+func (s *sourceManager) notifySnapshotResult(ctx context.Context, man *snapshot.Manifest, snapshotErr error) {
+	var ev *notification.Event
 
-	// compute next snapshot time based on interval
-	if interval := s.pol.IntervalSeconds; interval != 0 {
-		interval := time.Duration(interval) * time.Second
-		nt := s.lastSnapshot.StartTime.Add(interval).Truncate(interval)
-		nextSnapshotTime = &nt
+	switch {
+	case errors.Is(snapshotErr, context.Canceled), man != nil && man.IncompleteReason == snapshotfs.IncompleteReasonCanceled:
+		ev = notification.NewEvent(notification.EventSnapshotCanceled, "Snapshot of %v was canceled", s.src)
+
+	case snapshotErr != nil:
+		ev = notification.NewEvent(notification.EventSnapshotFailed, "Snapshot of %v failed", s.src)
+		ev.Message = snapshotErr.Error()
+
+	case man == nil:
+		// snapshot was not attempted.
+		return
+
+	case man.IncompleteReason != "" || man.Stats.ErrorCount > 0:
+		ev = notification.NewEvent(notification.EventSnapshotPartial, "Snapshot of %v is incomplete", s.src)
+
+	default:
+		ev = notification.NewEvent(notification.EventSnapshotSucceeded, "Snapshot of %v succeeded", s.src)
 	}
 
-	for _, tod := range s.pol.TimesOfDay {
-		nowLocalTime := clock.Now().Local()
-		localSnapshotTime := time.Date(nowLocalTime.Year(), nowLocalTime.Month(), nowLocalTime.Day(), tod.Hour, tod.Minute, 0, 0, time.Local)
+	ev.Details["Source"] = s.src.String()
 
-		if tod.Hour < nowLocalTime.Hour() || (tod.Hour == nowLocalTime.Hour() && tod.Minute < nowLocalTime.Minute()) {
-			localSnapshotTime = localSnapshotTime.Add(oneDay)
-		}
+	if man != nil {
+		ev.Details["Snapshot"] = string(man.ID)
+		ev.Details["Duration"] = man.EndTime.Sub(man.StartTime).String()
+		ev.Details["Total Size"] = units.BytesStringBase10(man.Stats.TotalFileSize)
+		ev.Details["Files"] = strconv.Itoa(int(man.Stats.TotalFileCount))
+		ev.Details["Errors"] = strconv.Itoa(int(man.Stats.ErrorCount))
+		ev.Details["Ignored Errors"] = strconv.Itoa(int(man.Stats.IgnoredErrorCount))
 
-		if nextSnapshotTime == nil || localSnapshotTime.Before(*nextSnapshotTime) {
-			nextSnapshotTime = &localSnapshotTime
+		if man.IncompleteReason != "" {
+			ev.Details["Incomplete Reason"] = man.IncompleteReason
 		}
 	}
 
-	return nextSnapshotTime
+	s.server.sendNotification(ctx, s.server.rep, ev)
+}
+
+func (s *sourceManager) findClosestNextSnapshotTime() *time.Time {
+	nt, ok := s.pol.NextSnapshotTime(s.lastSnapshot.StartTime, clock.Now())
+	if !ok {
+		return nil
+	}
+
+	return &nt
+}
+
+// maybeScheduleMissedSnapshot schedules a snapshot shortly after startup or resume if a time-of-day or
+// cron slot has passed since the last snapshot and the policy asks for missed snapshots to be run.
+// Each missed slot is caught up at most once, so that failing snapshots are not retried indefinitely.
+func (s *sourceManager) maybeScheduleMissedSnapshot() {
+	missed, ok := s.pol.MissedSnapshotTime(s.lastSnapshot.StartTime, clock.Now())
+	if !ok || !s.pol.RunMissedOrDefault(false) || s.pol.Manual {
+		s.missedSnapshotRunTime = nil
+		return
+	}
+
+	if !missed.Equal(s.missedSnapshotSlot) {
+		t := clock.Now().Add(missedSnapshotDelay)
+
+		s.missedSnapshotSlot = missed
+		s.missedSnapshotRunTime = &t
+	}
+
+	if s.missedSnapshotRunTime == nil {
+		return
+	}
+
+	if s.nextSnapshotTime == nil || s.missedSnapshotRunTime.Before(*s.nextSnapshotTime) {
+		s.nextSnapshotTime = s.missedSnapshotRunTime
+		s.nextSnapshotTrigger = serverapi.SnapshotTriggerMissed
+	}
 }
 
 func (s *sourceManager) refreshStatus(ctx context.Context) {
@@ -374,10 +458,15 @@ func (s *sourceManager) refreshStatus(ctx context.Context) {
 		}
 
 		s.nextSnapshotTime = s.findClosestNextSnapshotTime()
+		s.nextSnapshotTrigger = serverapi.SnapshotTriggerSchedule
+
+		s.maybeScheduleMissedSnapshot()
 	} else {
 		s.nextSnapshotTime = nil
 		s.lastSnapshot = nil
 	}
+
+	recordLastSnapshotMetrics(ctx, s.src, s.lastSnapshot, s.lastCompleteSnapshot)
 }
 
 type uitaskProgress struct {
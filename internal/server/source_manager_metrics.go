@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+var tagKeySource = tag.MustNewKey("source")
+
+// per-source metrics.
+var (
+	metricSourceLastSuccessfulSnapshotTime = stats.Int64(
+		"kopia/source/last_successful_snapshot_timestamp_seconds",
+		"Time when the most recent complete snapshot of a source finished, in seconds since the epoch",
+		stats.UnitSeconds,
+	)
+
+	metricSourceLastSnapshotDuration = stats.Float64(
+		"kopia/source/last_snapshot_duration_seconds",
+		"Duration of the most recent snapshot of a source",
+		stats.UnitSeconds,
+	)
+
+	metricSourceLastSnapshotSize = stats.Int64(
+		"kopia/source/last_snapshot_size_bytes",
+		"Total size of files in the most recent snapshot of a source",
+		stats.UnitBytes,
+	)
+
+	metricSourceLastSnapshotErrorCount = stats.Int64(
+		"kopia/source/last_snapshot_error_count",
+		"Number of errors encountered by the most recent snapshot of a source",
+		stats.UnitDimensionless,
+	)
+
+	metricSourceSnapshotFailures = stats.Int64(
+		"kopia/source/snapshot_failures",
+		"Number of snapshots of a source that have failed",
+		stats.UnitDimensionless,
+	)
+
+	metricSourceCurrentSnapshotUploadedBytes = stats.Int64(
+		"kopia/source/current_snapshot_uploaded_bytes",
+		"Number of bytes uploaded so far by the snapshot of a source that is currently in progress, zero when idle",
+		stats.UnitBytes,
+	)
+)
+
+func aggregateBySource(m stats.Measure, agg *view.Aggregation) *view.View {
+	return &view.View{
+		Name:        m.Name(),
+		Aggregation: agg,
+		Description: m.Description(),
+		Measure:     m,
+		TagKeys:     []tag.Key{tagKeySource},
+	}
+}
+
+func init() {
+	if err := view.Register(
+		aggregateBySource(metricSourceLastSuccessfulSnapshotTime, view.LastValue()),
+		aggregateBySource(metricSourceLastSnapshotDuration, view.LastValue()),
+		aggregateBySource(metricSourceLastSnapshotSize, view.LastValue()),
+		aggregateBySource(metricSourceLastSnapshotErrorCount, view.LastValue()),
+		aggregateBySource(metricSourceSnapshotFailures, view.Count()),
+		aggregateBySource(metricSourceCurrentSnapshotUploadedBytes, view.LastValue()),
+	); err != nil {
+		panic("unable to register opencensus views: " + err.Error())
+	}
+}
+
+func recordSourceMetrics(ctx context.Context, src snapshot.SourceInfo, ms ...stats.Measurement) {
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(tagKeySource, src.String())}, ms...)
+}
+
+// recordLastSnapshotMetrics records metrics describing the most recent snapshots of a source.
+func recordLastSnapshotMetrics(ctx context.Context, src snapshot.SourceInfo, last, lastComplete *snapshot.Manifest) {
+	if lastComplete != nil {
+		recordSourceMetrics(ctx, src, metricSourceLastSuccessfulSnapshotTime.M(lastComplete.EndTime.Unix()))
+	}
+
+	if last != nil {
+		recordSourceMetrics(ctx, src,
+			metricSourceLastSnapshotDuration.M(last.EndTime.Sub(last.StartTime).Seconds()),
+			metricSourceLastSnapshotSize.M(last.Stats.TotalFileSize),
+			metricSourceLastSnapshotErrorCount.M(int64(last.Stats.ErrorCount)),
+		)
+	}
+}
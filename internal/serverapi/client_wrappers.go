@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"net/url"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -62,6 +63,16 @@ func CancelUpload(ctx context.Context, c *apiclient.KopiaAPIClient, match *snaps
 	return resp, nil
 }
 
+// EditSnapshots edits description and tags of the provided snapshots.
+func EditSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, req *EditSnapshotsRequest) (*SnapshotsResponse, error) {
+	resp := &SnapshotsResponse{}
+	if err := c.Post(ctx, "snapshots/edit", req, resp); err != nil {
+		return nil, errors.Wrap(err, "EditSnapshots")
+	}
+
+	return resp, nil
+}
+
 // CreateRepository invokes the 'repo/create' API.
 func CreateRepository(ctx context.Context, c *apiclient.KopiaAPIClient, req *CreateRepositoryRequest) error {
 	// nolint:wrapcheck
@@ -96,6 +107,26 @@ func Status(ctx context.Context, c *apiclient.KopiaAPIClient) (*StatusResponse,
 	return resp, nil
 }
 
+// GetThrottlingSchedule returns the bandwidth throttling schedule of the repository.
+func GetThrottlingSchedule(ctx context.Context, c *apiclient.KopiaAPIClient) (*ThrottlingScheduleResponse, error) {
+	resp := &ThrottlingScheduleResponse{}
+	if err := c.Get(ctx, "repo/throttle", nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetThrottlingSchedule")
+	}
+
+	return resp, nil
+}
+
+// SetThrottlingSchedule changes the bandwidth throttling schedule of the repository.
+func SetThrottlingSchedule(ctx context.Context, c *apiclient.KopiaAPIClient, req *ThrottlingScheduleRequest) (*ThrottlingScheduleResponse, error) {
+	resp := &ThrottlingScheduleResponse{}
+	if err := c.Put(ctx, "repo/throttle", req, resp); err != nil {
+		return nil, errors.Wrap(err, "SetThrottlingSchedule")
+	}
+
+	return resp, nil
+}
+
 // ListSources lists the snapshot sources managed by the server.
 func ListSources(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*SourcesResponse, error) {
 	resp := &SourcesResponse{}
@@ -116,6 +147,21 @@ func ListSnapshots(ctx context.Context, c *apiclient.KopiaAPIClient, match *snap
 	return resp, nil
 }
 
+// GetHistory returns distinct versions of the file or directory at the provided path across snapshots.
+func GetHistory(ctx context.Context, c *apiclient.KopiaAPIClient, si snapshot.SourceInfo) (*HistoryResponse, error) {
+	q := url.Values{}
+	q.Set("host", si.Host)
+	q.Set("userName", si.UserName)
+	q.Set("path", si.Path)
+
+	resp := &HistoryResponse{}
+	if err := c.Get(ctx, "history?"+q.Encode(), nil, resp); err != nil {
+		return nil, errors.Wrap(err, "GetHistory")
+	}
+
+	return resp, nil
+}
+
 // ListPolicies lists the policies managed by the server for a given target filter.
 func ListPolicies(ctx context.Context, c *apiclient.KopiaAPIClient, match *snapshot.SourceInfo) (*PoliciesResponse, error) {
 	resp := &PoliciesResponse{}
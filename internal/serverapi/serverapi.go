@@ -8,6 +8,7 @@
 	"github.com/kopia/kopia/internal/uitask"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
@@ -42,15 +43,37 @@ type SourcesResponse struct {
 	Sources []*SourceStatus `json:"sources"`
 }
 
+// ThrottlingScheduleRequest contains request to change bandwidth throttling schedule.
+type ThrottlingScheduleRequest struct {
+	// Schedule is the new bandwidth schedule, nil disables throttling.
+	Schedule *throttling.Schedule `json:"schedule"`
+}
+
+// ThrottlingScheduleResponse contains the bandwidth throttling schedule and the limits currently in effect.
+type ThrottlingScheduleResponse struct {
+	Schedule      *throttling.Schedule `json:"schedule"`
+	CurrentLimits throttling.Limits    `json:"currentLimits"`
+}
+
+// Reasons why a snapshot was triggered, reported in SourceStatus.
+const (
+	SnapshotTriggerSchedule = "schedule"        // scheduled by the policy
+	SnapshotTriggerMissed   = "missed-schedule" // catching up with a scheduled snapshot that was missed
+	SnapshotTriggerManual   = "manual"          // requested via the API
+	SnapshotTriggerRetry    = "retry"           // retrying after a failed snapshot
+)
+
 // SourceStatus describes the status of a single source.
 type SourceStatus struct {
-	Source           snapshot.SourceInfo        `json:"source"`
-	Status           string                     `json:"status"`
-	SchedulingPolicy policy.SchedulingPolicy    `json:"schedule"`
-	LastSnapshot     *snapshot.Manifest         `json:"lastSnapshot,omitempty"`
-	NextSnapshotTime *time.Time                 `json:"nextSnapshotTime,omitempty"`
-	UploadCounters   *snapshotfs.UploadCounters `json:"upload,omitempty"`
-	CurrentTask      string                     `json:"currentTask,omitempty"`
+	Source              snapshot.SourceInfo        `json:"source"`
+	Status              string                     `json:"status"`
+	SchedulingPolicy    policy.SchedulingPolicy    `json:"schedule"`
+	LastSnapshot        *snapshot.Manifest         `json:"lastSnapshot,omitempty"`
+	NextSnapshotTime    *time.Time                 `json:"nextSnapshotTime,omitempty"`
+	NextSnapshotTrigger string                     `json:"nextSnapshotTrigger,omitempty"`
+	LastSnapshotTrigger string                     `json:"lastSnapshotTrigger,omitempty"`
+	UploadCounters      *snapshotfs.UploadCounters `json:"upload,omitempty"`
+	CurrentTask         string                     `json:"currentTask,omitempty"`
 }
 
 // PolicyListEntry describes single policy.
@@ -158,6 +181,7 @@ type Snapshot struct {
 	Summary          *fs.DirectorySummary `json:"summary"`
 	RootEntry        string               `json:"rootID"`
 	RetentionReasons []string             `json:"retention"`
+	Pins             []string             `json:"pins,omitempty"`
 }
 
 // SnapshotsResponse contains a list of snapshots.
@@ -165,6 +189,22 @@ type SnapshotsResponse struct {
 	Snapshots []*Snapshot `json:"snapshots"`
 }
 
+// EditSnapshotsRequest contains request to edit description and tags of snapshots.
+// Tag keys are specified without the "tag:" prefix.
+type EditSnapshotsRequest struct {
+	Snapshots      []manifest.ID     `json:"snapshots"`
+	NewDescription *string           `json:"description,omitempty"`
+	AddTags        map[string]string `json:"addTags,omitempty"`
+	RemoveTags     []string          `json:"removeTags,omitempty"`
+}
+
+// HistoryResponse contains distinct versions of a file or directory across snapshots of its source.
+type HistoryResponse struct {
+	Source       snapshot.SourceInfo        `json:"source"`
+	RelativePath string                     `json:"relativePath"`
+	Versions     []*snapshotfs.EntryVersion `json:"versions"`
+}
+
 // MountSnapshotRequest contains request to mount a snapshot.
 type MountSnapshotRequest struct {
 	Root string `json:"root"`
@@ -1,6 +1,9 @@
 package user
 
 import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/manifest"
 )
 
@@ -13,9 +16,33 @@ type Profile struct {
 	PasswordHash        []byte `json:"passwordHash"`
 }
 
+// DefaultPasswordHashVersion is the default password hash version.
+const DefaultPasswordHashVersion = hashVersion1
+
 // SetPassword changes the password for a user profile.
 func (p *Profile) SetPassword(password string) error {
-	return p.setPasswordV1(password)
+	return p.SetPasswordWithHashVersion(password, DefaultPasswordHashVersion)
+}
+
+// SetPasswordWithHashVersion changes the password for a user profile using the provided hash version:
+// 1 uses scrypt, 2 uses Argon2id.
+func (p *Profile) SetPasswordWithHashVersion(password string, hashVersion int) error {
+	switch hashVersion {
+	case hashVersion1:
+		return p.setPasswordV1(password)
+
+	case hashVersion2:
+		return p.setPasswordV2(password, repo.DefaultArgon2Parameters())
+
+	default:
+		return errors.Errorf("unsupported password hash version: %v", hashVersion)
+	}
+}
+
+// SetPasswordWithArgon2Parameters changes the password for a user profile using Argon2id hashing
+// with the provided parameters, which are subject to the same limits as repository passwords.
+func (p *Profile) SetPasswordWithArgon2Parameters(password string, params repo.Argon2Parameters) error {
+	return p.setPasswordV2(password, params)
 }
 
 // IsValidPassword determines whether the password is valid for a given user.
@@ -32,6 +59,9 @@ func (p *Profile) IsValidPassword(password string) bool {
 	case hashVersion1:
 		return isValidPasswordV1(password, p.PasswordHash)
 
+	case hashVersion2:
+		return isValidPasswordV2(password, p.PasswordHash)
+
 	default:
 		return false
 	}
@@ -0,0 +1,74 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
+
+	"github.com/kopia/kopia/repo"
+)
+
+// parameters for v2 hashing, which uses Argon2id with the same parameters and limits as repository passwords.
+const (
+	hashVersion2 = 2
+
+	v2SaltLength = 32
+	v2KeyLength  = 32
+
+	// v2 hash is stored as time (4 bytes), memory (4 bytes), threads (1 byte), salt and key.
+	v2ParamsLength = 9
+)
+
+func (p *Profile) setPasswordV2(password string, params repo.Argon2Parameters) error {
+	if err := params.Validate(); err != nil {
+		return errors.Wrap(err, "invalid Argon2id parameters")
+	}
+
+	salt := make([]byte, v2SaltLength)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return errors.Wrap(err, "error generating salt")
+	}
+
+	p.PasswordHashVersion = hashVersion2
+	p.PasswordHash = computePasswordHashV2(password, params, salt)
+
+	return nil
+}
+
+func computePasswordHashV2(password string, params repo.Argon2Parameters, salt []byte) []byte {
+	key := argon2.IDKey([]byte(password), salt, params.Time, params.Memory, params.Threads, v2KeyLength)
+
+	payload := make([]byte, v2ParamsLength, v2ParamsLength+v2SaltLength+v2KeyLength)
+	binary.BigEndian.PutUint32(payload[0:4], params.Time)
+	binary.BigEndian.PutUint32(payload[4:8], params.Memory)
+	payload[8] = params.Threads
+
+	return append(append(payload, salt...), key...)
+}
+
+func isValidPasswordV2(password string, hashedPassword []byte) bool {
+	if len(hashedPassword) != v2ParamsLength+v2SaltLength+v2KeyLength {
+		return false
+	}
+
+	params := repo.Argon2Parameters{
+		Time:    binary.BigEndian.Uint32(hashedPassword[0:4]),
+		Memory:  binary.BigEndian.Uint32(hashedPassword[4:8]),
+		Threads: hashedPassword[8],
+	}
+
+	if params.Validate() != nil {
+		// reject hashes with parameters that would exhaust CPU or memory of the server.
+		return false
+	}
+
+	salt := hashedPassword[v2ParamsLength : v2ParamsLength+v2SaltLength]
+
+	h := computePasswordHashV2(password, params, salt)
+
+	return subtle.ConstantTimeCompare(h, hashedPassword) != 0
+}
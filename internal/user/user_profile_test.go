@@ -1,9 +1,11 @@
 package user_test
 
 import (
+	"encoding/binary"
 	"testing"
 
 	"github.com/kopia/kopia/internal/user"
+	"github.com/kopia/kopia/repo"
 )
 
 func TestUserProfile(t *testing.T) {
@@ -24,6 +26,72 @@ func TestUserProfile(t *testing.T) {
 	}
 }
 
+func TestUserProfileArgon2id(t *testing.T) {
+	p := &user.Profile{}
+
+	if err := p.SetPasswordWithHashVersion("foo", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.PasswordHashVersion != 2 {
+		t.Fatalf("unexpected hash version: %v", p.PasswordHashVersion)
+	}
+
+	if !p.IsValidPassword("foo") {
+		t.Fatalf("password not valid!")
+	}
+
+	if p.IsValidPassword("bar") {
+		t.Fatalf("password unexpectedly valid!")
+	}
+
+	if err := p.SetPasswordWithHashVersion("foo", 3); err == nil {
+		t.Fatalf("unexpected success with unsupported hash version")
+	}
+}
+
+func TestUserProfileArgon2idParameters(t *testing.T) {
+	p := &user.Profile{}
+
+	if err := p.SetPasswordWithArgon2Parameters("foo", repo.Argon2Parameters{Time: 1, Memory: 1024, Threads: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.IsValidPassword("foo") {
+		t.Fatalf("password not valid!")
+	}
+
+	if err := p.SetPasswordWithArgon2Parameters("foo", repo.Argon2Parameters{Time: repo.MaxArgon2Time + 1, Memory: 1024, Threads: 1}); err == nil {
+		t.Fatalf("unexpected success with too many iterations")
+	}
+
+	if err := p.SetPasswordWithArgon2Parameters("foo", repo.Argon2Parameters{Time: 1, Memory: repo.MaxArgon2Memory + 1, Threads: 1}); err == nil {
+		t.Fatalf("unexpected success with too much memory")
+	}
+
+	// stored hashes with parameters exceeding the limits are rejected without being computed.
+	for _, tc := range []struct {
+		offset int
+		value  uint32
+	}{
+		{0, repo.MaxArgon2Time + 1},
+		{0, 0xffffffff},
+		{4, repo.MaxArgon2Memory + 1},
+		{4, 0xffffffff},
+	} {
+		p2 := &user.Profile{
+			PasswordHashVersion: p.PasswordHashVersion,
+			PasswordHash:        append([]byte(nil), p.PasswordHash...),
+		}
+
+		binary.BigEndian.PutUint32(p2.PasswordHash[tc.offset:], tc.value)
+
+		if p2.IsValidPassword("foo") {
+			t.Fatalf("password unexpectedly valid with parameter %v at %v", tc.value, tc.offset)
+		}
+	}
+}
+
 func TestNilUserProfile(t *testing.T) {
 	var p *user.Profile
 
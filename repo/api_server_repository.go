@@ -293,8 +293,9 @@ func openRestAPIRepository(ctx context.Context, si *APIServerInfo, cliOpts Clien
 // ConnectAPIServer sets up repository connection to a particular API server.
 func ConnectAPIServer(ctx context.Context, configFile string, si *APIServerInfo, password string, opt *ConnectOptions) error {
 	lc := LocalConfig{
-		APIServer:     si,
-		ClientOptions: opt.ClientOptions.ApplyDefaults(ctx, "API Server: "+si.BaseURL),
+		APIServer:        si,
+		ClientOptions:    opt.ClientOptions.ApplyDefaults(ctx, "API Server: "+si.BaseURL),
+		PasswordProvider: opt.PasswordProvider,
 	}
 
 	if err := setupCachingOptionsWithDefaults(ctx, configFile, &lc, &opt.CachingOptions, []byte(si.BaseURL)); err != nil {
@@ -1,5 +1,7 @@
 package azure
 
+import "github.com/kopia/kopia/repo/blob"
+
 // Options defines options for Azure blob storage storage.
 type Options struct {
 	// Container is the name of the azure storage container where data is stored.
@@ -19,4 +21,12 @@ type Options struct {
 
 	MaxUploadSpeedBytesPerSecond   int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// StorageClasses assigns access tiers to blobs based on blob ID prefix.
+	StorageClasses blob.StorageClassMap `json:"storageClasses,omitempty"`
+}
+
+// BlobStorageClasses implements blob.StorageClassConfig.
+func (o *Options) BlobStorageClasses() blob.StorageClassMap {
+	return o.StorageClasses
 }
@@ -21,6 +21,7 @@
 	"github.com/kopia/kopia/internal/iocopy"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
+	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
 const (
@@ -57,7 +58,7 @@ func (az *azStorage) GetBlob(ctx context.Context, b blob.ID, offset, length int6
 		}
 
 		// nolint:wrapcheck
-		return ioutil.ReadAll(throttled)
+		return ioutil.ReadAll(throttling.DownloadReader(ctx, throttled))
 	}
 
 	fetched, err := attempt()
@@ -117,7 +118,18 @@ func (az *azStorage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) er
 	}
 
 	// create azure Bucket writer
-	writer, err := az.bucket.NewWriter(ctx, az.getObjectNameString(b), &gblob.WriterOptions{ContentType: "application/x-kopia"})
+	writer, err := az.bucket.NewWriter(ctx, az.getObjectNameString(b), &gblob.WriterOptions{
+		ContentType: "application/x-kopia",
+		BeforeWrite: func(asFunc func(interface{}) bool) error {
+			var opts *azblob.UploadStreamToBlockBlobOptions
+
+			if tier := az.StorageClasses.ForBlob(b); tier != "" && asFunc(&opts) {
+				opts.BlobAccessTier = azblob.AccessTierType(tier)
+			}
+
+			return nil
+		},
+	})
 	if err != nil {
 		// nolint:wrapcheck
 		return err
@@ -15,6 +15,7 @@
 
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
+	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
 const (
@@ -57,7 +58,7 @@ func (s *b2Storage) GetBlob(ctx context.Context, id blob.ID, offset, length int6
 			return nil, errors.Wrap(err, "DownloadFileRangeByName")
 		}
 
-		v, err := ioutil.ReadAll(throttled)
+		v, err := ioutil.ReadAll(throttling.DownloadReader(ctx, throttled))
 		if err != nil {
 			return nil, errors.Wrap(err, "ReadAll")
 		}
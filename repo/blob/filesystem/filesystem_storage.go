@@ -18,6 +18,7 @@
 	"github.com/kopia/kopia/internal/retry"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/sharded"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/logging"
 )
 
@@ -86,7 +87,7 @@ func (fs *fsImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, off
 
 		if length < 0 {
 			// nolint:wrapcheck
-			return ioutil.ReadAll(f)
+			return ioutil.ReadAll(throttling.DownloadReader(ctx, f))
 		}
 
 		if _, err = f.Seek(offset, io.SeekStart); err != nil {
@@ -94,7 +95,7 @@ func (fs *fsImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, off
 			return nil, errors.Errorf("seek error: %v", err)
 		}
 
-		b, err := ioutil.ReadAll(io.LimitReader(f, length))
+		b, err := ioutil.ReadAll(throttling.DownloadReader(ctx, io.LimitReader(f, length)))
 		if err != nil {
 			//nolint:wrapcheck
 			return nil, err
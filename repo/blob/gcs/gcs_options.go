@@ -1,6 +1,10 @@
 package gcs
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/kopia/kopia/repo/blob"
+)
 
 // Options defines options Google Cloud Storage-backed storage.
 type Options struct {
@@ -22,4 +26,12 @@ type Options struct {
 	MaxUploadSpeedBytesPerSecond int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// StorageClasses assigns storage classes to blobs based on blob ID prefix.
+	StorageClasses blob.StorageClassMap `json:"storageClasses,omitempty"`
+}
+
+// BlobStorageClasses implements blob.StorageClassConfig.
+func (o *Options) BlobStorageClasses() blob.StorageClassMap {
+	return o.StorageClasses
 }
@@ -23,6 +23,7 @@
 	"github.com/kopia/kopia/internal/throttle"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
+	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
 const (
@@ -54,7 +55,7 @@ func (gcs *gcsStorage) GetBlob(ctx context.Context, b blob.ID, offset, length in
 		defer reader.Close() //nolint:errcheck
 
 		// nolint:wrapcheck
-		return ioutil.ReadAll(reader)
+		return ioutil.ReadAll(throttling.DownloadReader(ctx, reader))
 	}
 
 	fetched, err := attempt()
@@ -105,6 +106,7 @@ func (gcs *gcsStorage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes)
 	writer := obj.NewWriter(ctx)
 	writer.ChunkSize = writerChunkSize
 	writer.ContentType = "application/x-kopia"
+	writer.StorageClass = gcs.StorageClasses.ForBlob(b)
 
 	_, err := iocopy.Copy(writer, data.Reader())
 	if err != nil {
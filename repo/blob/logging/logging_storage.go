@@ -73,6 +73,30 @@ func (s *loggingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return err
 }
 
+func (s *loggingStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	t0 := clock.Now()
+	extended, err := blob.ExtendRetention(ctx, s.base, id, minRetainUntil)
+	dt := clock.Since(t0)
+	s.printf(s.prefix+"ExtendBlobRetention(%q,%v)=(%v,%#v) took %v", id, minRetainUntil, extended, err, dt)
+
+	// nolint:wrapcheck
+	return extended, err
+}
+
+func (s *loggingStorage) BlobRetentionPeriod() time.Duration {
+	return blob.RetentionPeriod(s.base)
+}
+
+func (s *loggingStorage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	t0 := clock.Now()
+	until, err := blob.RetainedUntil(ctx, s.base, id)
+	dt := clock.Since(t0)
+	s.printf(s.prefix+"BlobRetainedUntil(%q)=(%v,%#v) took %v", id, until, err, dt)
+
+	// nolint:wrapcheck
+	return until, err
+}
+
 func (s *loggingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
 	t0 := clock.Now()
 	cnt := 0
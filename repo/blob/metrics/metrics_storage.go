@@ -0,0 +1,201 @@
+// Package metrics implements wrapper around Storage that records latency of all operations.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// results of blob operations.
+const (
+	resultSuccess  = "success"
+	resultNotFound = "not_found"
+	resultError    = "error"
+)
+
+var (
+	tagKeyProvider = tag.MustNewKey("provider")
+	tagKeyMethod   = tag.MustNewKey("method")
+	tagKeyResult   = tag.MustNewKey("result")
+)
+
+// blob storage metrics.
+var (
+	metricBlobOperationLatency = stats.Float64(
+		"kopia/blob/operation_latency",
+		"Latency of blob storage operations",
+		stats.UnitMilliseconds,
+	)
+
+	metricBlobOperationBytes = stats.Int64(
+		"kopia/blob/operation_bytes",
+		"Number of bytes transferred by blob storage operations",
+		stats.UnitBytes,
+	)
+)
+
+// latencyBucketsMillis are upper bounds of latency histogram buckets.
+// nolint:gochecknoglobals
+var latencyBucketsMillis = []float64{
+	1, 2, 5, 10, 20, 50, 100, 200, 500, 1000, 2000, 5000, 10000, 20000, 60000,
+}
+
+func init() {
+	tagKeys := []tag.Key{tagKeyProvider, tagKeyMethod, tagKeyResult}
+
+	if err := view.Register(
+		&view.View{
+			Name:        metricBlobOperationLatency.Name(),
+			Description: metricBlobOperationLatency.Description(),
+			Measure:     metricBlobOperationLatency,
+			Aggregation: view.Distribution(latencyBucketsMillis...),
+			TagKeys:     tagKeys,
+		},
+		&view.View{
+			Name:        metricBlobOperationBytes.Name(),
+			Description: metricBlobOperationBytes.Description(),
+			Measure:     metricBlobOperationBytes,
+			Aggregation: view.Sum(),
+			TagKeys:     tagKeys,
+		},
+	); err != nil {
+		panic("unable to register opencensus views: " + err.Error())
+	}
+}
+
+type metricsStorage struct {
+	base     blob.Storage
+	provider string
+}
+
+func (s *metricsStorage) record(ctx context.Context, method string, t0 time.Time, numBytes int64, err error) {
+	result := resultSuccess
+
+	switch {
+	case errors.Is(err, blob.ErrBlobNotFound):
+		result = resultNotFound
+	case err != nil:
+		result = resultError
+	}
+
+	measurements := []stats.Measurement{
+		metricBlobOperationLatency.M(float64(clock.Since(t0)) / float64(time.Millisecond)),
+	}
+
+	if numBytes > 0 {
+		measurements = append(measurements, metricBlobOperationBytes.M(numBytes))
+	}
+
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{
+		tag.Upsert(tagKeyProvider, s.provider),
+		tag.Upsert(tagKeyMethod, method),
+		tag.Upsert(tagKeyResult, result),
+	}, measurements...)
+}
+
+func (s *metricsStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	t0 := clock.Now()
+	result, err := s.base.GetBlob(ctx, id, offset, length)
+	s.record(ctx, "GetBlob", t0, int64(len(result)), err)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+func (s *metricsStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	t0 := clock.Now()
+	result, err := s.base.GetMetadata(ctx, id)
+	s.record(ctx, "GetMetadata", t0, 0, err)
+
+	// nolint:wrapcheck
+	return result, err
+}
+
+func (s *metricsStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	t0 := clock.Now()
+	err := s.base.PutBlob(ctx, id, data)
+	s.record(ctx, "PutBlob", t0, int64(data.Length()), err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *metricsStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	t0 := clock.Now()
+	err := s.base.SetTime(ctx, id, t)
+	s.record(ctx, "SetTime", t0, 0, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *metricsStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	t0 := clock.Now()
+	err := s.base.DeleteBlob(ctx, id)
+	s.record(ctx, "DeleteBlob", t0, 0, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *metricsStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	t0 := clock.Now()
+	extended, err := blob.ExtendRetention(ctx, s.base, id, minRetainUntil)
+	s.record(ctx, "ExtendBlobRetention", t0, 0, err)
+
+	// nolint:wrapcheck
+	return extended, err
+}
+
+func (s *metricsStorage) BlobRetentionPeriod() time.Duration {
+	return blob.RetentionPeriod(s.base)
+}
+
+func (s *metricsStorage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	t0 := clock.Now()
+	until, err := blob.RetainedUntil(ctx, s.base, id)
+	s.record(ctx, "BlobRetainedUntil", t0, 0, err)
+
+	// nolint:wrapcheck
+	return until, err
+}
+
+func (s *metricsStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	t0 := clock.Now()
+	err := s.base.ListBlobs(ctx, prefix, callback)
+	s.record(ctx, "ListBlobs", t0, 0, err)
+
+	// nolint:wrapcheck
+	return err
+}
+
+func (s *metricsStorage) Close(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+func (s *metricsStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *metricsStorage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+func (s *metricsStorage) FlushCaches(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.FlushCaches(ctx)
+}
+
+// NewWrapper returns a Storage wrapper that records latency of storage operations tagged with the provider type.
+func NewWrapper(wrapped blob.Storage) blob.Storage {
+	return &metricsStorage{base: wrapped, provider: wrapped.ConnectionInfo().Type}
+}
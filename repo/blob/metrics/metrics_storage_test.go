@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestMetricsStorage(t *testing.T) {
+	underlying := blobtesting.NewMapStorage(blobtesting.DataMap{}, map[blob.ID]time.Time{}, nil)
+
+	st := NewWrapper(underlying)
+
+	ctx := testlogging.Context(t)
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	require.NoError(t, st.Close(ctx))
+	require.Equal(t, underlying.ConnectionInfo().Type, st.ConnectionInfo().Type)
+
+	rows, err := view.RetrieveData(metricBlobOperationLatency.Name())
+	require.NoError(t, err)
+
+	results := map[string]bool{}
+
+	for _, r := range rows {
+		tags := map[string]string{}
+		for _, tg := range r.Tags {
+			tags[tg.Key.Name()] = tg.Value
+		}
+
+		require.Equal(t, underlying.ConnectionInfo().Type, tags["provider"])
+
+		if tags["method"] == "GetBlob" {
+			results[tags["result"]] = true
+		}
+	}
+
+	require.True(t, results[resultSuccess])
+	require.True(t, results[resultNotFound])
+}
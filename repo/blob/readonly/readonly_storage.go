@@ -40,6 +40,19 @@ func (s readonlyStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return ErrReadonly
 }
 
+func (s readonlyStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	return false, ErrReadonly
+}
+
+func (s readonlyStorage) BlobRetentionPeriod() time.Duration {
+	return blob.RetentionPeriod(s.base)
+}
+
+func (s readonlyStorage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	// nolint:wrapcheck
+	return blob.RetainedUntil(ctx, s.base, id)
+}
+
 func (s readonlyStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
 	// nolint:wrapcheck
 	return s.base.ListBlobs(ctx, prefix, callback)
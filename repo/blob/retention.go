@@ -0,0 +1,65 @@
+package blob
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrRetentionUnsupported is returned by ExtendRetention when the storage does not support or
+// is not configured for blob retention.
+var ErrRetentionUnsupported = errors.Errorf("blob retention is not supported")
+
+// RetentionExtender is implemented by storage providers that protect blobs from deletion
+// for a configured period of time after they are written.
+type RetentionExtender interface {
+	// ExtendBlobRetention extends the retention period of the given blob to the storage's configured
+	// retention period, counted from now, unless the blob is already retained until minRetainUntil
+	// or later. Zero minRetainUntil always extends the retention.
+	// Returns whether the retention has been extended or ErrRetentionUnsupported if retention is not enabled.
+	ExtendBlobRetention(ctx context.Context, blobID ID, minRetainUntil time.Time) (bool, error)
+
+	// BlobRetentionPeriod returns the period for which blobs are retained after they are written or
+	// their retention is extended, or zero if retention is not enabled.
+	BlobRetentionPeriod() time.Duration
+
+	// BlobRetainedUntil returns the time until which the given blob is protected from deletion,
+	// or zero time if it is not protected.
+	BlobRetainedUntil(ctx context.Context, blobID ID) (time.Time, error)
+}
+
+// ExtendRetention extends retention of the provided blob if the storage supports it and returns whether
+// it has been extended, returns ErrRetentionUnsupported if the storage does not support it.
+func ExtendRetention(ctx context.Context, st Storage, blobID ID, minRetainUntil time.Time) (bool, error) {
+	re, ok := st.(RetentionExtender)
+	if !ok {
+		return false, ErrRetentionUnsupported
+	}
+
+	// nolint:wrapcheck
+	return re.ExtendBlobRetention(ctx, blobID, minRetainUntil)
+}
+
+// RetainedUntil returns the time until which the provided blob is protected from deletion or zero time
+// if it is not protected or the storage does not support retention.
+func RetainedUntil(ctx context.Context, st Storage, blobID ID) (time.Time, error) {
+	re, ok := st.(RetentionExtender)
+	if !ok {
+		return time.Time{}, nil
+	}
+
+	// nolint:wrapcheck
+	return re.BlobRetainedUntil(ctx, blobID)
+}
+
+// RetentionPeriod returns the retention period of the provided storage or zero if it does not support
+// or is not configured for blob retention.
+func RetentionPeriod(st Storage) time.Duration {
+	re, ok := st.(RetentionExtender)
+	if !ok {
+		return 0
+	}
+
+	return re.BlobRetentionPeriod()
+}
@@ -67,6 +67,34 @@ func (s retryingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
 	return err // nolint:wrapcheck
 }
 
+func (s retryingStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	v, err := retry.WithExponentialBackoff(ctx, "ExtendBlobRetention("+string(id)+")", func() (interface{}, error) {
+		// nolint:wrapcheck
+		return blob.ExtendRetention(ctx, s.Storage, id, minRetainUntil)
+	}, isRetriable)
+	if err != nil {
+		return false, err // nolint:wrapcheck
+	}
+
+	return v.(bool), nil
+}
+
+func (s retryingStorage) BlobRetentionPeriod() time.Duration {
+	return blob.RetentionPeriod(s.Storage)
+}
+
+func (s retryingStorage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	v, err := retry.WithExponentialBackoff(ctx, "BlobRetainedUntil("+string(id)+")", func() (interface{}, error) {
+		// nolint:wrapcheck
+		return blob.RetainedUntil(ctx, s.Storage, id)
+	}, isRetriable)
+	if err != nil {
+		return time.Time{}, err // nolint:wrapcheck
+	}
+
+	return v.(time.Time), nil
+}
+
 // NewWrapper returns a Storage wrapper that adds retry loop around all operations of the underlying storage.
 func NewWrapper(wrapped blob.Storage) blob.Storage {
 	return &retryingStorage{Storage: wrapped}
@@ -83,6 +111,9 @@ func isRetriable(err error) bool {
 	case errors.Is(err, blob.ErrSetTimeUnsupported):
 		return false
 
+	case errors.Is(err, blob.ErrRetentionUnsupported):
+		return false
+
 	default:
 		return true
 	}
@@ -2,6 +2,7 @@
 
 import (
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
@@ -82,5 +83,9 @@ func TestRetrying(t *testing.T) {
 		t.Fatalf("unexpected error from SetTime: %v", err)
 	}
 
+	// map storage does not support retention, which must not be retried.
+	_, err = blob.ExtendRetention(ctx, rs, blobID2, time.Time{})
+	require.ErrorIs(t, err, blob.ErrRetentionUnsupported)
+
 	fs.VerifyAllFaultsExercised(t)
 }
@@ -0,0 +1,474 @@
+package s3
+
+import (
+	"crypto/md5" // nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+const (
+	fakeS3BucketName = "fake-bucket"
+	fakeS3Region     = "fake-region-1"
+)
+
+// fakeS3Version is a version of an object stored in fakeS3Server.
+type fakeS3Version struct {
+	versionID      string
+	data           []byte
+	lastModified   time.Time
+	isDeleteMarker bool
+	storageClass   string
+
+	retentionMode string
+	retainUntil   time.Time
+}
+
+func (v *fakeS3Version) etag() string {
+	h := md5.Sum(v.data) // nolint:gosec
+	return `"` + hex.EncodeToString(h[:]) + `"`
+}
+
+func (v *fakeS3Version) locked(now time.Time) bool {
+	return v.retentionMode != "" && v.retainUntil.After(now)
+}
+
+// fakeS3Server implements the subset of S3 API used by s3Storage for a single bucket with object locking,
+// which allows the storage to be tested without an S3-compatible server. Like in S3, buckets with object
+// locking keep all versions of objects and locked versions can't be deleted or have their retention shortened.
+type fakeS3Server struct {
+	mu sync.Mutex
+	// versions of objects by key, oldest first.
+	versions    map[string][]*fakeS3Version
+	nextVersion int
+
+	// versioning is the versioning status reported for the bucket.
+	versioning string
+
+	// now returns the time used for object timestamps and retention.
+	now func() time.Time
+}
+
+// newFakeS3Server starts a fake S3 server and returns it along with storage options to connect to it.
+func newFakeS3Server(t *testing.T) (*fakeS3Server, *Options) {
+	t.Helper()
+
+	s := &fakeS3Server{
+		versions:   map[string][]*fakeS3Version{},
+		versioning: versioningEnabled,
+		now:        clock.Now,
+	}
+
+	hs := httptest.NewTLSServer(s)
+	t.Cleanup(hs.Close)
+
+	u, err := url.Parse(hs.URL)
+	if err != nil {
+		t.Fatalf("invalid server URL: %v", err)
+	}
+
+	return s, &Options{
+		Endpoint:        u.Host,
+		AccessKeyID:     "fake-key",
+		SecretAccessKey: "fake-secret",
+		BucketName:      fakeS3BucketName,
+		Region:          fakeS3Region,
+		DoNotVerifyTLS:  true,
+	}
+}
+
+// latest returns the latest version of the object or nil if it does not exist.
+func (s *fakeS3Server) latest(key string) *fakeS3Version {
+	vs := s.versions[key]
+	if len(vs) == 0 {
+		return nil
+	}
+
+	return vs[len(vs)-1]
+}
+
+// version returns the provided version of the object or its latest version if versionID is empty.
+func (s *fakeS3Server) version(key, versionID string) *fakeS3Version {
+	if versionID == "" {
+		return s.latest(key)
+	}
+
+	for _, v := range s.versions[key] {
+		if v.versionID == versionID {
+			return v
+		}
+	}
+
+	return nil
+}
+
+func (s *fakeS3Server) addVersion(key string, v *fakeS3Version) {
+	s.nextVersion++
+	v.versionID = fmt.Sprintf("v%v", s.nextVersion)
+	v.lastModified = s.now().UTC()
+	s.versions[key] = append(s.versions[key], v)
+}
+
+func (s *fakeS3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2) // nolint:gomnd
+	if p[0] != fakeS3BucketName {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	q := r.URL.Query()
+
+	if len(p) == 1 || p[1] == "" {
+		s.serveBucket(w, r, q)
+		return
+	}
+
+	key := p[1]
+
+	retention := hasQueryParam(q, "retention")
+
+	switch {
+	case retention && r.Method == http.MethodGet:
+		s.getRetention(w, key, q.Get("versionId"))
+	case retention && r.Method == http.MethodPut:
+		s.putRetention(w, r, key, q.Get("versionId"))
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		s.getObject(w, r, key, q.Get("versionId"))
+	case r.Method == http.MethodPut:
+		s.putObject(w, r, key)
+	case r.Method == http.MethodDelete:
+		s.deleteObject(w, key, q.Get("versionId"))
+	default:
+		writeFakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeS3Server) serveBucket(w http.ResponseWriter, r *http.Request, q url.Values) {
+	switch {
+	case r.Method == http.MethodHead:
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet && q.Get("list-type") == "2":
+		s.listObjects(w, q.Get("prefix"))
+	case r.Method == http.MethodGet && hasQueryParam(q, "versions"):
+		s.listObjectVersions(w, q.Get("prefix"))
+	case r.Method == http.MethodGet && hasQueryParam(q, "versioning"):
+		writeFakeS3XML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"VersioningConfiguration"`
+			Status  string   `xml:",omitempty"`
+		}{Status: s.versioning})
+	default:
+		writeFakeS3Error(w, http.StatusNotImplemented, "NotImplemented")
+	}
+}
+
+func (s *fakeS3Server) sortedKeys(prefix string) []string {
+	var keys []string
+
+	for k := range s.versions {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+type fakeS3ListedObject struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+func (s *fakeS3Server) listObjects(w http.ResponseWriter, prefix string) {
+	type listBucketResult struct {
+		XMLName     xml.Name `xml:"ListBucketResult"`
+		Name        string
+		Prefix      string
+		IsTruncated bool
+		Contents    []fakeS3ListedObject
+	}
+
+	result := listBucketResult{Name: fakeS3BucketName, Prefix: prefix}
+
+	for _, k := range s.sortedKeys(prefix) {
+		v := s.latest(k)
+		if v.isDeleteMarker {
+			continue
+		}
+
+		result.Contents = append(result.Contents, fakeS3ListedObject{k, v.lastModified.Format(time.RFC3339Nano), v.etag(), len(v.data)})
+	}
+
+	writeFakeS3XML(w, http.StatusOK, result)
+}
+
+type fakeS3ListedVersion struct {
+	XMLName      xml.Name
+	Key          string
+	VersionID    string `xml:"VersionId"`
+	IsLatest     bool
+	LastModified string
+	ETag         string `xml:",omitempty"`
+	Size         int
+}
+
+// listObjectVersions lists all versions of objects, including delete markers, newest first.
+func (s *fakeS3Server) listObjectVersions(w http.ResponseWriter, prefix string) {
+	type listVersionsResult struct {
+		XMLName     xml.Name `xml:"ListVersionsResult"`
+		Name        string
+		Prefix      string
+		IsTruncated bool
+		Versions    []fakeS3ListedVersion
+	}
+
+	result := listVersionsResult{Name: fakeS3BucketName, Prefix: prefix}
+
+	for _, k := range s.sortedKeys(prefix) {
+		vs := s.versions[k]
+
+		for i := len(vs) - 1; i >= 0; i-- {
+			v := vs[i]
+			lv := fakeS3ListedVersion{
+				XMLName:      xml.Name{Local: "Version"},
+				Key:          k,
+				VersionID:    v.versionID,
+				IsLatest:     i == len(vs)-1,
+				LastModified: v.lastModified.Format(time.RFC3339Nano),
+				ETag:         v.etag(),
+				Size:         len(v.data),
+			}
+
+			if v.isDeleteMarker {
+				lv.XMLName.Local = "DeleteMarker"
+				lv.ETag = ""
+			}
+
+			result.Versions = append(result.Versions, lv)
+		}
+	}
+
+	writeFakeS3XML(w, http.StatusOK, result)
+}
+
+func (s *fakeS3Server) getObject(w http.ResponseWriter, r *http.Request, key, versionID string) {
+	v := s.version(key, versionID)
+	if v == nil || v.isDeleteMarker {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	data := v.data
+	status := http.StatusOK
+
+	if rng := r.Header.Get("Range"); rng != "" {
+		var start, end int
+
+		if _, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end); err != nil || start > end {
+			writeFakeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+
+		if start >= len(data) {
+			writeFakeS3Error(w, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+			return
+		}
+
+		if end >= len(data) {
+			end = len(data) - 1
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %v-%v/%v", start, end, len(data)))
+
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Content-Type", "application/x-kopia")
+	w.Header().Set("ETag", v.etag())
+	w.Header().Set("Last-Modified", v.lastModified.Format(http.TimeFormat))
+	w.Header().Set("x-amz-version-id", v.versionID)
+
+	if v.storageClass != "" {
+		w.Header().Set("x-amz-storage-class", v.storageClass)
+	}
+
+	w.WriteHeader(status)
+
+	if r.Method == http.MethodGet {
+		w.Write(data) // nolint:errcheck
+	}
+}
+
+func (s *fakeS3Server) putObject(w http.ResponseWriter, r *http.Request, key string) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeFakeS3Error(w, http.StatusBadRequest, "IncompleteBody")
+		return
+	}
+
+	v := &fakeS3Version{
+		data:          data,
+		storageClass:  r.Header.Get("X-Amz-Storage-Class"),
+		retentionMode: r.Header.Get("X-Amz-Object-Lock-Mode"),
+	}
+
+	if v.retentionMode != "" {
+		// like S3, require Content-MD5 on writes that set object lock retention.
+		h := md5.Sum(data) // nolint:gosec
+		if r.Header.Get("Content-MD5") != base64.StdEncoding.EncodeToString(h[:]) {
+			writeFakeS3ErrorMessage(w, http.StatusBadRequest, "InvalidRequest", "Content-MD5 HTTP header is required for Put Object requests with Object Lock parameters")
+			return
+		}
+
+		if v.retainUntil, err = time.Parse(time.RFC3339, r.Header.Get("X-Amz-Object-Lock-Retain-Until-Date")); err != nil {
+			writeFakeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+	}
+
+	s.addVersion(key, v)
+
+	w.Header().Set("ETag", v.etag())
+	w.Header().Set("x-amz-version-id", v.versionID)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *fakeS3Server) deleteObject(w http.ResponseWriter, key, versionID string) {
+	if versionID == "" {
+		// deleting without a version places a delete marker.
+		if v := s.latest(key); v != nil && !v.isDeleteMarker {
+			s.addVersion(key, &fakeS3Version{isDeleteMarker: true})
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	vs := s.versions[key]
+
+	for i, v := range vs {
+		if v.versionID != versionID {
+			continue
+		}
+
+		if v.locked(s.now()) {
+			writeFakeS3Error(w, http.StatusForbidden, "AccessDenied")
+			return
+		}
+
+		s.versions[key] = append(vs[0:i:i], vs[i+1:]...)
+		if len(s.versions[key]) == 0 {
+			delete(s.versions, key)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type fakeS3Retention struct {
+	XMLName         xml.Name `xml:"Retention"`
+	Mode            string   `xml:"Mode,omitempty"`
+	RetainUntilDate string   `xml:"RetainUntilDate,omitempty"`
+}
+
+func (s *fakeS3Server) getRetention(w http.ResponseWriter, key, versionID string) {
+	v := s.version(key, versionID)
+	if v == nil || v.isDeleteMarker {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	if v.retentionMode == "" {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchObjectLockConfiguration")
+		return
+	}
+
+	writeFakeS3XML(w, http.StatusOK, fakeS3Retention{
+		Mode:            v.retentionMode,
+		RetainUntilDate: v.retainUntil.Format(time.RFC3339),
+	})
+}
+
+func (s *fakeS3Server) putRetention(w http.ResponseWriter, r *http.Request, key, versionID string) {
+	v := s.version(key, versionID)
+	if v == nil || v.isDeleteMarker {
+		writeFakeS3Error(w, http.StatusNotFound, "NoSuchKey")
+		return
+	}
+
+	var ret fakeS3Retention
+
+	if err := xml.NewDecoder(r.Body).Decode(&ret); err != nil {
+		writeFakeS3Error(w, http.StatusBadRequest, "MalformedXML")
+		return
+	}
+
+	until, err := time.Parse(time.RFC3339, ret.RetainUntilDate)
+	if err != nil {
+		writeFakeS3Error(w, http.StatusBadRequest, "InvalidArgument")
+		return
+	}
+
+	// retention of locked versions can only be extended.
+	if v.locked(s.now()) && until.Before(v.retainUntil) {
+		writeFakeS3Error(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	v.retentionMode = ret.Mode
+	v.retainUntil = until
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func hasQueryParam(q url.Values, name string) bool {
+	_, ok := q[name]
+	return ok
+}
+
+func writeFakeS3XML(w http.ResponseWriter, status int, v interface{}) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header)) // nolint:errcheck
+	w.Write(b)                  // nolint:errcheck
+}
+
+func writeFakeS3Error(w http.ResponseWriter, status int, code string) {
+	writeFakeS3ErrorMessage(w, status, code, code)
+}
+
+func writeFakeS3ErrorMessage(w http.ResponseWriter, status int, code, message string) {
+	writeFakeS3XML(w, status, struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: message})
+}
@@ -1,5 +1,11 @@
 package s3
 
+import (
+	"time"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
 // Options defines options for S3-based storage.
 type Options struct {
 	// BucketName is the name of the bucket where data is stored.
@@ -22,4 +28,22 @@ type Options struct {
 	MaxUploadSpeedBytesPerSecond int `json:"maxUploadSpeedBytesPerSecond,omitempty"`
 
 	MaxDownloadSpeedBytesPerSecond int `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+
+	// RetentionMode is an optional object lock retention mode (COMPLIANCE or GOVERNANCE) applied to all
+	// blobs written. Requires a bucket with object locking enabled.
+	RetentionMode string `json:"retentionMode,omitempty"`
+
+	// RetentionPeriod is the period for which newly-written blobs are protected from deletion.
+	RetentionPeriod time.Duration `json:"retentionPeriod,omitempty"`
+
+	// PointInTime, when set, presents a read-only view of a versioned bucket as it existed at the given time.
+	PointInTime *time.Time `json:"pointInTime,omitempty"`
+
+	// StorageClasses assigns storage classes to blobs based on blob ID prefix.
+	StorageClasses blob.StorageClassMap `json:"storageClasses,omitempty"`
+}
+
+// BlobStorageClasses implements blob.StorageClassConfig.
+func (o *Options) BlobStorageClasses() blob.StorageClassMap {
+	return o.StorageClasses
 }
@@ -0,0 +1,198 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/readonly"
+	"github.com/kopia/kopia/repo/blob/retrying"
+)
+
+const versioningEnabled = "Enabled"
+
+// s3PointInTimeStorage presents a view of a versioned bucket as it existed at a point in time,
+// only listing and reading object versions that were current at that time.
+type s3PointInTimeStorage struct {
+	*s3Storage
+
+	pointInTime time.Time
+
+	mu sync.Mutex
+	// versionsByPrefix caches the result of versionsAtPointInTime() by key prefix. Since the point
+	// in time is in the past, the versions current at that time never change.
+	versionsByPrefix map[string]map[string]minio.ObjectInfo
+}
+
+func (s *s3PointInTimeStorage) GetBlob(ctx context.Context, b blob.ID, offset, length int64) ([]byte, error) {
+	v, err := s.versionAt(ctx, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getBlobWithVersion(ctx, b, v.VersionID, offset, length)
+}
+
+func (s *s3PointInTimeStorage) GetMetadata(ctx context.Context, b blob.ID) (blob.Metadata, error) {
+	v, err := s.versionAt(ctx, b)
+	if err != nil {
+		return blob.Metadata{}, err
+	}
+
+	return blob.Metadata{
+		BlobID:    b,
+		Length:    v.Size,
+		Timestamp: v.LastModified,
+	}, nil
+}
+
+func (s *s3PointInTimeStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	keyPrefix := s.getObjectNameString(prefix)
+
+	versions, err := s.cachedVersions(ctx, keyPrefix)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(versions))
+
+	for k, v := range versions {
+		if !v.IsDeleteMarker && strings.HasPrefix(k, keyPrefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := versions[k]
+
+		if err := callback(blob.Metadata{
+			BlobID:    blob.ID(k[len(s.Prefix):]),
+			Length:    v.Size,
+			Timestamp: v.LastModified,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *s3PointInTimeStorage) String() string {
+	return fmt.Sprintf("%v@%v", s.s3Storage.String(), s.pointInTime.Format(time.RFC3339))
+}
+
+func (s *s3PointInTimeStorage) DisplayName() string {
+	return fmt.Sprintf("%v (as of %v)", s.s3Storage.DisplayName(), s.pointInTime.Format(time.RFC3339))
+}
+
+// versionAt returns the version of the blob that was current at the point in time.
+func (s *s3PointInTimeStorage) versionAt(ctx context.Context, b blob.ID) (minio.ObjectInfo, error) {
+	if b == "" {
+		return minio.ObjectInfo{}, blob.ErrBlobNotFound
+	}
+
+	key := s.getObjectNameString(b)
+
+	// blob IDs of the same kind share the first character, cache their versions together.
+	versions, err := s.cachedVersions(ctx, s.getObjectNameString(b[0:1]))
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+
+	v, ok := versions[key]
+	if !ok || v.IsDeleteMarker {
+		return minio.ObjectInfo{}, blob.ErrBlobNotFound
+	}
+
+	return v, nil
+}
+
+// cachedVersions returns the versions current at the point in time of all objects with the provided key prefix,
+// and possibly other objects. Objects are listed only if no cached listing covers the prefix.
+func (s *s3PointInTimeStorage) cachedVersions(ctx context.Context, keyPrefix string) (map[string]minio.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for p, v := range s.versionsByPrefix {
+		if strings.HasPrefix(keyPrefix, p) {
+			return v, nil
+		}
+	}
+
+	versions, err := s.versionsAtPointInTime(ctx, keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	s.versionsByPrefix[keyPrefix] = versions
+
+	return versions, nil
+}
+
+// versionsAtPointInTime returns the newest version (which may be a delete marker) of each object with the
+// provided key prefix that was created at or before the point in time.
+func (s *s3PointInTimeStorage) versionsAtPointInTime(ctx context.Context, keyPrefix string) (map[string]minio.ObjectInfo, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer cancel()
+
+	return newestVersionsAt(s.cli.ListObjects(ctx, s.BucketName, minio.ListObjectsOptions{
+		Prefix:       keyPrefix,
+		WithVersions: true,
+	}), s.pointInTime)
+}
+
+// newestVersionsAt returns the newest version of each object created at or before the point in time.
+func newestVersionsAt(objects <-chan minio.ObjectInfo, pointInTime time.Time) (map[string]minio.ObjectInfo, error) {
+	result := map[string]minio.ObjectInfo{}
+
+	for o := range objects {
+		if err := o.Err; err != nil {
+			return nil, errors.Wrap(translateError(err), "ListObjects")
+		}
+
+		if o.LastModified.After(pointInTime) {
+			continue
+		}
+
+		if prev, ok := result[o.Key]; ok && !o.LastModified.After(prev.LastModified) {
+			continue
+		}
+
+		result[o.Key] = o
+	}
+
+	return result, nil
+}
+
+// newPointInTimeStorage returns a read-only view of the storage as it existed at the provided time.
+func newPointInTimeStorage(ctx context.Context, s *s3Storage, pointInTime time.Time) (blob.Storage, error) {
+	if !pointInTime.Before(clock.Now()) {
+		return nil, errors.Errorf("point in time must be in the past")
+	}
+
+	vc, err := s.cli.GetBucketVersioning(ctx, s.BucketName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to determine versioning status of bucket %q", s.BucketName)
+	}
+
+	if vc.Status != versioningEnabled {
+		return nil, errors.Errorf("point-in-time view requires versioning to be enabled on bucket %q", s.BucketName)
+	}
+
+	return readonly.NewWrapper(retrying.NewWrapper(&s3PointInTimeStorage{
+		s3Storage:        s,
+		pointInTime:      pointInTime,
+		versionsByPrefix: map[string]map[string]minio.ObjectInfo{},
+	})), nil
+}
@@ -0,0 +1,186 @@
+package s3
+
+import (
+	"testing"
+	"time"
+
+	minio "github.com/minio/minio-go/v7"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestNewestVersionsAt(t *testing.T) {
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	objects := []minio.ObjectInfo{
+		{Key: "blob1", VersionID: "b1v2", LastModified: t0.Add(2 * time.Hour)},
+		{Key: "blob1", VersionID: "b1v1", LastModified: t0},
+		{Key: "blob1", VersionID: "b1v3", LastModified: t0.Add(4 * time.Hour)},
+		{Key: "blob2", VersionID: "b2v1", LastModified: t0},
+		{Key: "blob2", VersionID: "b2d", LastModified: t0.Add(2 * time.Hour), IsDeleteMarker: true},
+		{Key: "blob3", VersionID: "b3v1", LastModified: t0.Add(3 * time.Hour)},
+	}
+
+	cases := []struct {
+		pointInTime time.Time
+		want        map[string]string
+	}{
+		{t0.Add(-time.Hour), map[string]string{}},
+		{t0, map[string]string{"blob1": "b1v1", "blob2": "b2v1"}},
+		{t0.Add(time.Hour), map[string]string{"blob1": "b1v1", "blob2": "b2v1"}},
+		{t0.Add(2 * time.Hour), map[string]string{"blob1": "b1v2", "blob2": "b2d"}},
+		{t0.Add(5 * time.Hour), map[string]string{"blob1": "b1v3", "blob2": "b2d", "blob3": "b3v1"}},
+	}
+
+	for _, tc := range cases {
+		ch := make(chan minio.ObjectInfo, len(objects))
+		for _, o := range objects {
+			ch <- o
+		}
+
+		close(ch)
+
+		versions, err := newestVersionsAt(ch, tc.pointInTime)
+		require.NoError(t, err)
+
+		got := map[string]string{}
+		for k, v := range versions {
+			got[k] = v.VersionID
+		}
+
+		require.Equal(t, tc.want, got, "%v", tc.pointInTime)
+	}
+}
+
+func TestPointInTimeStorage(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	srv, options := newFakeS3Server(t)
+
+	// all writes happen in the past, so that point-in-time views can be opened at any time between them.
+	ta := faketime.NewTimeAdvance(clock.Now().Add(-time.Hour), 0)
+	srv.mu.Lock()
+	srv.now = ta.NowFunc()
+	srv.mu.Unlock()
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+
+	beforeWrites := ta.Advance(time.Minute)
+
+	ta.Advance(time.Minute)
+	require.NoError(t, st.PutBlob(ctx, "blob1", gather.FromSlice([]byte("v1-blob1"))))
+	require.NoError(t, st.PutBlob(ctx, "blob2", gather.FromSlice([]byte("v1-blob2"))))
+	require.NoError(t, st.PutBlob(ctx, "xblob4", gather.FromSlice([]byte("v1-xblob4"))))
+
+	pit := ta.Advance(time.Minute)
+
+	ta.Advance(time.Minute)
+	require.NoError(t, st.PutBlob(ctx, "blob1", gather.FromSlice([]byte("v2"))))
+	require.NoError(t, st.DeleteBlob(ctx, "blob2"))
+	require.NoError(t, st.PutBlob(ctx, "blob3", gather.FromSlice([]byte("v1"))))
+
+	afterWrites := ta.Advance(time.Minute)
+
+	openAt := func(pointInTime time.Time) blob.Storage {
+		t.Helper()
+
+		options.PointInTime = &pointInTime
+
+		pst, err := New(ctx, options)
+		require.NoError(t, err)
+
+		return pst
+	}
+
+	// nothing existed before the writes.
+	pst := openAt(beforeWrites)
+
+	_, err = pst.GetBlob(ctx, "blob1", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	all, err := blob.ListAllBlobs(ctx, pst, "")
+	require.NoError(t, err)
+	require.Empty(t, all)
+
+	// latest versions after all writes.
+	pst = openAt(afterWrites)
+
+	v, err := pst.GetBlob(ctx, "blob1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), v)
+
+	_, err = pst.GetBlob(ctx, "blob2", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	all, err = blob.ListAllBlobs(ctx, pst, "")
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{"blob1", "blob3", "xblob4"}, blob.IDsFromMetadata(all))
+
+	// versions current between the writes.
+	pst = openAt(pit)
+
+	v, err = pst.GetBlob(ctx, "blob1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1-blob1"), v)
+
+	// ranges are read from the same version.
+	v, err = pst.GetBlob(ctx, "blob1", 3, 5)
+	require.NoError(t, err)
+	require.Equal(t, []byte("blob1"), v)
+
+	_, err = pst.GetBlob(ctx, "blob1", 3, 10)
+	require.ErrorIs(t, err, blob.ErrInvalidRange)
+
+	v, err = pst.GetBlob(ctx, "blob2", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1-blob2"), v)
+
+	bm, err := pst.GetMetadata(ctx, "blob2")
+	require.NoError(t, err)
+	require.Equal(t, int64(len("v1-blob2")), bm.Length)
+	require.True(t, bm.Timestamp.Before(pit))
+
+	_, err = pst.GetBlob(ctx, "blob3", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	_, err = pst.GetMetadata(ctx, "blob3")
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	all, err = blob.ListAllBlobs(ctx, pst, "")
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{"blob1", "blob2", "xblob4"}, blob.IDsFromMetadata(all))
+
+	all, err = blob.ListAllBlobs(ctx, pst, "x")
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{"xblob4"}, blob.IDsFromMetadata(all))
+
+	// point-in-time view is read-only.
+	require.Error(t, pst.PutBlob(ctx, "blob4", gather.FromSlice([]byte("v1"))))
+	require.Error(t, pst.DeleteBlob(ctx, "blob1"))
+	_, err = blob.ExtendRetention(ctx, pst, "blob1", time.Time{})
+	require.Error(t, err)
+
+	// point in time must be in the past.
+	future := clock.Now().Add(time.Hour)
+	options.PointInTime = &future
+
+	_, err = New(ctx, options)
+	require.Error(t, err)
+
+	// versioning is required.
+	srv.mu.Lock()
+	srv.versioning = ""
+	srv.mu.Unlock()
+
+	options.PointInTime = &pit
+
+	_, err = New(ctx, options)
+	require.Error(t, err)
+}
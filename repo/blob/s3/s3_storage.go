@@ -18,8 +18,10 @@
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
+	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
 const (
@@ -37,8 +39,14 @@ type s3Storage struct {
 }
 
 func (s *s3Storage) GetBlob(ctx context.Context, b blob.ID, offset, length int64) ([]byte, error) {
+	return s.getBlobWithVersion(ctx, b, "", offset, length)
+}
+
+// getBlobWithVersion returns full or partial contents of the provided version of a blob.
+// An empty version ID means the latest version.
+func (s *s3Storage) getBlobWithVersion(ctx context.Context, b blob.ID, version string, offset, length int64) ([]byte, error) {
 	attempt := func() ([]byte, error) {
-		var opt minio.GetObjectOptions
+		opt := minio.GetObjectOptions{VersionID: version}
 
 		if length > 0 {
 			if err := opt.SetRange(offset, offset+length-1); err != nil {
@@ -58,7 +66,7 @@ func (s *s3Storage) GetBlob(ctx context.Context, b blob.ID, offset, length int64
 			return nil, errors.Wrap(err, "AddReader")
 		}
 
-		v, err := ioutil.ReadAll(throttled)
+		v, err := ioutil.ReadAll(throttling.DownloadReader(ctx, throttled))
 		if err != nil {
 			return nil, errors.Wrap(err, "ReadAll")
 		}
@@ -117,10 +125,7 @@ func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) err
 		return errors.Wrap(err, "AddReader")
 	}
 
-	uploadInfo, err := s.cli.PutObject(ctx, s.BucketName, s.getObjectNameString(b), throttled, int64(data.Length()), minio.PutObjectOptions{
-		ContentType:    "application/x-kopia",
-		SendContentMd5: atomic.LoadInt32(&s.sendMD5) > 0,
-	})
+	uploadInfo, err := s.cli.PutObject(ctx, s.BucketName, s.getObjectNameString(b), throttled, int64(data.Length()), s.putObjectOptions(b))
 
 	var er minio.ErrorResponse
 
@@ -132,19 +137,103 @@ func (s *s3Storage) PutBlob(ctx context.Context, b blob.ID, data blob.Bytes) err
 
 	if errors.Is(err, io.EOF) && uploadInfo.Size == 0 {
 		// special case empty stream
-		_, err = s.cli.PutObject(ctx, s.BucketName, s.getObjectNameString(b), bytes.NewBuffer(nil), 0, minio.PutObjectOptions{
-			ContentType: "application/x-kopia",
-		})
+		_, err = s.cli.PutObject(ctx, s.BucketName, s.getObjectNameString(b), bytes.NewBuffer(nil), 0, s.putObjectOptions(b))
 	}
 
 	// nolint:wrapcheck
 	return err
 }
 
+func (s *s3Storage) putObjectOptions(b blob.ID) minio.PutObjectOptions {
+	opt := minio.PutObjectOptions{
+		ContentType:    "application/x-kopia",
+		SendContentMd5: atomic.LoadInt32(&s.sendMD5) > 0,
+		StorageClass:   s.StorageClasses.ForBlob(b),
+	}
+
+	if s.retentionEnabled() {
+		// S3 requires Content-MD5 on all writes that set object lock retention.
+		opt.SendContentMd5 = true
+		opt.Mode = minio.RetentionMode(s.RetentionMode)
+		opt.RetainUntilDate = s.retainUntil()
+	}
+
+	return opt
+}
+
+func (s *s3Storage) retentionEnabled() bool {
+	return s.RetentionMode != ""
+}
+
+func (s *s3Storage) retainUntil() time.Time {
+	return clock.Now().Add(s.RetentionPeriod).UTC()
+}
+
+// ExtendBlobRetention extends object lock retention of the current version of the blob to
+// RetentionPeriod from now, unless it is already retained until minRetainUntil.
+func (s *s3Storage) ExtendBlobRetention(ctx context.Context, b blob.ID, minRetainUntil time.Time) (bool, error) {
+	if !s.retentionEnabled() {
+		return false, blob.ErrRetentionUnsupported
+	}
+
+	if !minRetainUntil.IsZero() {
+		_, current, err := s.cli.GetObjectRetention(ctx, s.BucketName, s.getObjectNameString(b), "")
+		if err != nil {
+			return false, errors.Wrap(translateError(err), "GetObjectRetention")
+		}
+
+		if current != nil && !current.Before(minRetainUntil) {
+			return false, nil
+		}
+	}
+
+	mode := minio.RetentionMode(s.RetentionMode)
+	until := s.retainUntil()
+
+	if err := translateError(s.cli.PutObjectRetention(ctx, s.BucketName, s.getObjectNameString(b), minio.PutObjectRetentionOptions{
+		Mode:            &mode,
+		RetainUntilDate: &until,
+	})); err != nil {
+		return false, errors.Wrap(err, "PutObjectRetention")
+	}
+
+	return true, nil
+}
+
+func (s *s3Storage) BlobRetentionPeriod() time.Duration {
+	if !s.retentionEnabled() {
+		return 0
+	}
+
+	return s.RetentionPeriod
+}
+
+// BlobRetainedUntil returns the object lock retention date of the current version of the blob.
+func (s *s3Storage) BlobRetainedUntil(ctx context.Context, b blob.ID) (time.Time, error) {
+	_, until, err := s.cli.GetObjectRetention(ctx, s.BucketName, s.getObjectNameString(b), "")
+	if err != nil {
+		var er minio.ErrorResponse
+
+		if errors.As(err, &er) && er.Code == "NoSuchObjectLockConfiguration" {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, errors.Wrap(translateError(err), "GetObjectRetention")
+	}
+
+	if until == nil {
+		return time.Time{}, nil
+	}
+
+	return *until, nil
+}
+
 func (s *s3Storage) SetTime(ctx context.Context, b blob.ID, t time.Time) error {
 	return blob.ErrSetTimeUnsupported
 }
 
+// DeleteBlob removes the blob from the bucket. In buckets with object locking enabled (which are always
+// versioned) this only places a delete marker and the locked data remains until its retention expires.
 func (s *s3Storage) DeleteBlob(ctx context.Context, b blob.ID) error {
 	err := translateError(s.cli.RemoveObject(ctx, s.BucketName, s.getObjectNameString(b), minio.RemoveObjectOptions{}))
 	if errors.Is(err, blob.ErrBlobNotFound) {
@@ -230,6 +319,10 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		return nil, errors.New("bucket name must be specified")
 	}
 
+	if err := validateRetention(opt); err != nil {
+		return nil, err
+	}
+
 	minioOpts := &minio.Options{
 		Creds:  credentials.NewStaticV4(opt.AccessKeyID, opt.SecretAccessKey, opt.SessionToken),
 		Secure: !opt.DoNotUseTLS,
@@ -257,13 +350,35 @@ func New(ctx context.Context, opt *Options) (blob.Storage, error) {
 		return nil, errors.Errorf("bucket %q does not exist", opt.BucketName)
 	}
 
-	return retrying.NewWrapper(&s3Storage{
+	s := &s3Storage{
 		Options:           *opt,
 		cli:               cli,
 		sendMD5:           0,
 		downloadThrottler: downloadThrottler,
 		uploadThrottler:   uploadThrottler,
-	}), nil
+	}
+
+	if opt.PointInTime != nil {
+		return newPointInTimeStorage(ctx, s, *opt.PointInTime)
+	}
+
+	return retrying.NewWrapper(s), nil
+}
+
+func validateRetention(opt *Options) error {
+	if opt.RetentionMode == "" {
+		return nil
+	}
+
+	if !minio.RetentionMode(opt.RetentionMode).IsValid() {
+		return errors.Errorf("invalid retention mode %q, must be %v or %v", opt.RetentionMode, minio.Governance, minio.Compliance)
+	}
+
+	if opt.RetentionPeriod <= 0 {
+		return errors.New("retention period must be specified when retention mode is set")
+	}
+
+	return nil
 }
 
 func init() {
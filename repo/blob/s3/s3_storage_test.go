@@ -7,6 +7,7 @@
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -22,11 +23,14 @@
 
 	"github.com/kopia/kopia/internal/blobtesting"
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/faketime"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/providervalidation"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/maintenance"
 )
 
 const (
@@ -196,6 +200,463 @@ func TestS3StorageMinio(t *testing.T) {
 	testStorage(t, options)
 }
 
+func TestS3StorageMinioObjectLock(t *testing.T) {
+	t.Parallel()
+	testutil.ProviderTest(t)
+
+	minioEndpoint := startDockerMinioOrSkip(t)
+
+	ctx := testlogging.Context(t)
+
+	options := &Options{
+		Endpoint:        minioEndpoint,
+		AccessKeyID:     minioRootAccessKeyID,
+		SecretAccessKey: minioRootSecretAccessKey,
+		BucketName:      minioBucketName,
+		Region:          minioRegion,
+		DoNotUseTLS:     true,
+	}
+
+	cli := createClient(t, options)
+	makeBucket(t, cli, options, true)
+
+	// storage without retention does not support extending it.
+	st0, err := New(ctx, options)
+	require.NoError(t, err)
+	_, err = blob.ExtendRetention(ctx, st0, "some-blob", time.Time{})
+	require.ErrorIs(t, err, blob.ErrRetentionUnsupported)
+
+	_, err = New(ctx, &Options{BucketName: minioBucketName, RetentionMode: "NONE", RetentionPeriod: time.Hour})
+	require.Error(t, err)
+
+	_, err = New(ctx, &Options{BucketName: minioBucketName, RetentionMode: string(minio.Governance)})
+	require.Error(t, err)
+
+	options.RetentionMode = string(minio.Governance)
+	options.RetentionPeriod = time.Hour
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+
+	const blobID blob.ID = "test-locked-blob"
+
+	require.NoError(t, st.PutBlob(ctx, blobID, gather.FromSlice([]byte{1, 2, 3})))
+
+	verifyRetainUntil := func(min time.Time) {
+		t.Helper()
+
+		mode, until, err := cli.GetObjectRetention(ctx, minioBucketName, string(blobID), "")
+		require.NoError(t, err)
+		require.Equal(t, minio.Governance, *mode)
+		require.True(t, until.After(min), "retain until %v, want after %v", until, min)
+	}
+
+	verifyRetainUntil(clock.Now().Add(50 * time.Minute))
+
+	// extending using longer retention period moves the date forward.
+	options.RetentionPeriod = 3 * time.Hour
+
+	st2, err := New(ctx, options)
+	require.NoError(t, err)
+	extended, err := blob.ExtendRetention(ctx, st2, blobID, time.Time{})
+	require.NoError(t, err)
+	require.True(t, extended)
+
+	verifyRetainUntil(clock.Now().Add(150 * time.Minute))
+
+	// blobs retained until the requested time are not extended.
+	options.RetentionPeriod = 5 * time.Hour
+
+	st3, err := New(ctx, options)
+	require.NoError(t, err)
+	extended, err = blob.ExtendRetention(ctx, st3, blobID, clock.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, extended)
+
+	verifyRetainUntil(clock.Now().Add(150 * time.Minute))
+
+	_, until, err := cli.GetObjectRetention(ctx, minioBucketName, string(blobID), "")
+	require.NoError(t, err)
+	require.True(t, until.Before(clock.Now().Add(4*time.Hour)))
+
+	extended, err = blob.ExtendRetention(ctx, st3, blobID, clock.Now().Add(4*time.Hour))
+	require.NoError(t, err)
+	require.True(t, extended)
+
+	verifyRetainUntil(clock.Now().Add(4 * time.Hour))
+
+	// deleting only places a delete marker, the locked version remains.
+	require.NoError(t, st2.DeleteBlob(ctx, blobID))
+
+	_, err = st2.GetBlob(ctx, blobID, 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	var versionID string
+
+	for o := range cli.ListObjects(ctx, minioBucketName, minio.ListObjectsOptions{WithVersions: true}) {
+		require.NoError(t, o.Err)
+
+		if o.Key == string(blobID) && !o.IsDeleteMarker {
+			versionID = o.VersionID
+		}
+	}
+
+	require.NotEmpty(t, versionID)
+
+	err = cli.RemoveObject(ctx, minioBucketName, string(blobID), minio.RemoveObjectOptions{VersionID: versionID})
+	require.Error(t, err, "locked version must not be deletable")
+}
+
+func TestS3StorageRetention(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	srv, options := newFakeS3Server(t)
+
+	// storage without retention does not support extending it.
+	st0, err := New(ctx, options)
+	require.NoError(t, err)
+	_, err = blob.ExtendRetention(ctx, st0, "some-blob", time.Time{})
+	require.ErrorIs(t, err, blob.ErrRetentionUnsupported)
+	require.Zero(t, blob.RetentionPeriod(st0))
+
+	options.RetentionMode = string(minio.Compliance)
+	options.RetentionPeriod = time.Hour
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+	require.Equal(t, time.Hour, blob.RetentionPeriod(st))
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	const blobID blob.ID = "test-locked-blob"
+
+	// the fake server rejects writes that set retention without Content-MD5, like S3 does.
+	require.NoError(t, st.PutBlob(ctx, blobID, gather.FromSlice([]byte{1, 2, 3})))
+
+	verifyRetainUntil := func(min, max time.Time) {
+		t.Helper()
+
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		v := srv.latest(string(blobID))
+		require.Equal(t, string(minio.Compliance), v.retentionMode)
+		require.True(t, v.retainUntil.After(min), "retain until %v, want after %v", v.retainUntil, min)
+		require.True(t, v.retainUntil.Before(max), "retain until %v, want before %v", v.retainUntil, max)
+	}
+
+	verifyRetainUntil(clock.Now().Add(50*time.Minute), clock.Now().Add(70*time.Minute))
+
+	// extending using longer retention period moves the date forward.
+	options.RetentionPeriod = 3 * time.Hour
+
+	st2, err := New(ctx, options)
+	require.NoError(t, err)
+	extended, err := blob.ExtendRetention(ctx, st2, blobID, time.Time{})
+	require.NoError(t, err)
+	require.True(t, extended)
+
+	verifyRetainUntil(clock.Now().Add(170*time.Minute), clock.Now().Add(190*time.Minute))
+
+	// blobs retained until the requested time are not extended.
+	options.RetentionPeriod = 5 * time.Hour
+
+	st3, err := New(ctx, options)
+	require.NoError(t, err)
+	extended, err = blob.ExtendRetention(ctx, st3, blobID, clock.Now().Add(time.Hour))
+	require.NoError(t, err)
+	require.False(t, extended)
+
+	verifyRetainUntil(clock.Now().Add(170*time.Minute), clock.Now().Add(190*time.Minute))
+
+	extended, err = blob.ExtendRetention(ctx, st3, blobID, clock.Now().Add(4*time.Hour))
+	require.NoError(t, err)
+	require.True(t, extended)
+
+	verifyRetainUntil(clock.Now().Add(290*time.Minute), clock.Now().Add(310*time.Minute))
+
+	_, err = blob.ExtendRetention(ctx, st3, "no-such-blob", clock.Now().Add(time.Hour))
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	// deleting only places a delete marker, the locked version remains.
+	require.NoError(t, st2.DeleteBlob(ctx, blobID))
+
+	_, err = st2.GetBlob(ctx, blobID, 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	srv.mu.Lock()
+	versions := srv.versions[string(blobID)]
+	srv.mu.Unlock()
+
+	require.Len(t, versions, 2)
+	require.True(t, versions[1].isDeleteMarker)
+	require.Equal(t, []byte{1, 2, 3}, versions[0].data)
+}
+
+func TestDeleteUnreferencedBlobsWithRetention(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	srv, options := newFakeS3Server(t)
+	ta := faketime.NewClockTimeWithOffset(0)
+
+	srv.mu.Lock()
+	srv.now = ta.NowFunc()
+	srv.mu.Unlock()
+
+	options.RetentionMode = string(minio.Compliance)
+	options.RetentionPeriod = 2 * time.Hour
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+
+	const password = "some-password"
+
+	configFile := filepath.Join(testutil.TempDirectory(t), "kopia.config")
+
+	require.NoError(t, repo.Initialize(ctx, st, &repo.NewRepositoryOptions{}, password))
+	require.NoError(t, repo.Connect(ctx, configFile, st, password, nil))
+
+	r, err := repo.Open(ctx, configFile, password, &repo.Options{TimeNowFunc: ta.NowFunc()})
+	require.NoError(t, err)
+
+	defer r.Close(ctx)
+
+	_, w, err := r.(repo.DirectRepository).NewDirectWriter(ctx, repo.WriteSessionOptions{Purpose: "test"})
+	require.NoError(t, err)
+
+	defer w.Close(ctx)
+
+	const unreferencedBlobID blob.ID = "pdeadbeef"
+
+	require.NoError(t, w.BlobStorage().PutBlob(ctx, unreferencedBlobID, gather.FromSlice([]byte{1, 2, 3})))
+
+	// locked blobs are neither deleted nor counted as deleted while their retention has not expired.
+	n, err := maintenance.DeleteUnreferencedBlobs(ctx, w, maintenance.DeleteUnreferencedBlobsOptions{}, maintenance.SafetyNone)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	n, err = maintenance.DeleteUnreferencedBlobs(ctx, w, maintenance.DeleteUnreferencedBlobsOptions{DryRun: true}, maintenance.SafetyNone)
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	_, err = st.GetBlob(ctx, unreferencedBlobID, 0, -1)
+	require.NoError(t, err)
+
+	ta.Advance(3 * time.Hour)
+
+	n, err = maintenance.DeleteUnreferencedBlobs(ctx, w, maintenance.DeleteUnreferencedBlobsOptions{}, maintenance.SafetyNone)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+
+	_, err = st.GetBlob(ctx, unreferencedBlobID, 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+}
+
+func TestS3StorageMinioPointInTime(t *testing.T) {
+	t.Parallel()
+	testutil.ProviderTest(t)
+
+	minioEndpoint := startDockerMinioOrSkip(t)
+
+	ctx := testlogging.Context(t)
+
+	options := &Options{
+		Endpoint:        minioEndpoint,
+		AccessKeyID:     minioRootAccessKeyID,
+		SecretAccessKey: minioRootSecretAccessKey,
+		BucketName:      minioBucketName,
+		Region:          minioRegion,
+		DoNotUseTLS:     true,
+	}
+
+	cli := createClient(t, options)
+	makeBucket(t, cli, options, false)
+
+	pit := clock.Now()
+
+	// versioning is required.
+	_, err := New(ctx, &Options{
+		Endpoint:        minioEndpoint,
+		AccessKeyID:     minioRootAccessKeyID,
+		SecretAccessKey: minioRootSecretAccessKey,
+		BucketName:      minioBucketName,
+		Region:          minioRegion,
+		DoNotUseTLS:     true,
+		PointInTime:     &pit,
+	})
+	require.Error(t, err)
+
+	require.NoError(t, cli.EnableVersioning(ctx, minioBucketName))
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+
+	beforeWrites := clock.Now()
+
+	// S3 timestamps have a resolution of one second.
+	time.Sleep(2 * time.Second)
+
+	require.NoError(t, st.PutBlob(ctx, "blob1", gather.FromSlice([]byte("v1"))))
+	require.NoError(t, st.PutBlob(ctx, "blob2", gather.FromSlice([]byte("v1"))))
+
+	time.Sleep(2 * time.Second)
+
+	pit = clock.Now()
+
+	time.Sleep(2 * time.Second)
+
+	require.NoError(t, st.PutBlob(ctx, "blob1", gather.FromSlice([]byte("v2"))))
+	require.NoError(t, st.DeleteBlob(ctx, "blob2"))
+	require.NoError(t, st.PutBlob(ctx, "blob3", gather.FromSlice([]byte("v1"))))
+
+	time.Sleep(2 * time.Second)
+
+	afterWrites := clock.Now()
+
+	// point in time must be in the past.
+	future := clock.Now().Add(time.Hour)
+	options.PointInTime = &future
+
+	_, err = New(ctx, options)
+	require.Error(t, err)
+
+	// nothing existed before the writes.
+	options.PointInTime = &beforeWrites
+
+	pst, err := New(ctx, options)
+	require.NoError(t, err)
+
+	_, err = pst.GetBlob(ctx, "blob1", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	all, err := blob.ListAllBlobs(ctx, pst, "")
+	require.NoError(t, err)
+	require.Empty(t, all)
+
+	// latest versions after all writes.
+	options.PointInTime = &afterWrites
+
+	pst, err = New(ctx, options)
+	require.NoError(t, err)
+
+	v, err := pst.GetBlob(ctx, "blob1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v2"), v)
+
+	_, err = pst.GetBlob(ctx, "blob2", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	options.PointInTime = &pit
+
+	pst, err = New(ctx, options)
+	require.NoError(t, err)
+
+	v, err = pst.GetBlob(ctx, "blob1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	v, err = pst.GetBlob(ctx, "blob2", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), v)
+
+	_, err = pst.GetBlob(ctx, "blob3", 0, -1)
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	_, err = pst.GetMetadata(ctx, "blob3")
+	require.ErrorIs(t, err, blob.ErrBlobNotFound)
+
+	all, err = blob.ListAllBlobs(ctx, pst, "")
+	require.NoError(t, err)
+	require.Equal(t, []blob.ID{"blob1", "blob2"}, blob.IDsFromMetadata(all))
+
+	// point-in-time view is read-only.
+	require.Error(t, pst.PutBlob(ctx, "blob4", gather.FromSlice([]byte("v1"))))
+	require.Error(t, pst.DeleteBlob(ctx, "blob1"))
+}
+
+func TestS3StorageMinioStorageClasses(t *testing.T) {
+	t.Parallel()
+	testutil.ProviderTest(t)
+
+	minioEndpoint := startDockerMinioOrSkip(t)
+
+	ctx := testlogging.Context(t)
+
+	options := &Options{
+		Endpoint:        minioEndpoint,
+		AccessKeyID:     minioRootAccessKeyID,
+		SecretAccessKey: minioRootSecretAccessKey,
+		BucketName:      minioBucketName,
+		Region:          minioRegion,
+		DoNotUseTLS:     true,
+		StorageClasses: blob.StorageClassMap{
+			"p": "REDUCED_REDUNDANCY",
+		},
+	}
+
+	cli := createClient(t, options)
+	makeBucket(t, cli, options, false)
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+
+	require.NoError(t, st.PutBlob(ctx, "p1234", gather.FromSlice([]byte{1, 2, 3})))
+	require.NoError(t, st.PutBlob(ctx, "q1234", gather.FromSlice([]byte{1, 2, 3})))
+
+	oi, err := cli.StatObject(ctx, minioBucketName, "p1234", minio.StatObjectOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "REDUCED_REDUNDANCY", oi.StorageClass)
+
+	oi, err = cli.StatObject(ctx, minioBucketName, "q1234", minio.StatObjectOptions{})
+	require.NoError(t, err)
+	require.NotEqual(t, "REDUCED_REDUNDANCY", oi.StorageClass)
+}
+
+func TestS3StorageClasses(t *testing.T) {
+	t.Parallel()
+
+	ctx := testlogging.Context(t)
+	srv, options := newFakeS3Server(t)
+
+	options.StorageClasses = blob.StorageClassMap{
+		"p":  "STANDARD_IA",
+		"xn": "REDUCED_REDUNDANCY",
+	}
+
+	st, err := New(ctx, options)
+	require.NoError(t, err)
+
+	storageClassOf := func(id blob.ID) string {
+		srv.mu.Lock()
+		defer srv.mu.Unlock()
+
+		return srv.latest(string(id)).storageClass
+	}
+
+	for id, want := range map[blob.ID]string{
+		"p1234":     "STANDARD_IA",
+		"xn1234":    "REDUCED_REDUNDANCY",
+		"x1234":     "",
+		"q1234":     "",
+		"pq1234":    "STANDARD_IA",
+		"kopia.foo": "",
+	} {
+		require.NoError(t, st.PutBlob(ctx, id, gather.FromSlice([]byte{1, 2, 3})))
+		require.Equal(t, want, storageClassOf(id), id)
+	}
+
+	// storage class is also set on objects written with retention.
+	options.RetentionMode = string(minio.Governance)
+	options.RetentionPeriod = time.Hour
+
+	st, err = New(ctx, options)
+	require.NoError(t, err)
+	require.NoError(t, st.PutBlob(ctx, "p5678", gather.FromSlice([]byte{1, 2, 3})))
+	require.Equal(t, "STANDARD_IA", storageClassOf("p5678"))
+}
+
 func TestInvalidCredsFailsFast(t *testing.T) {
 	t.Parallel()
 	testutil.ProviderTest(t)
@@ -24,6 +24,7 @@
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
 	"github.com/kopia/kopia/repo/blob/sharded"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/logging"
 )
 
@@ -217,7 +218,7 @@ func (s *sftpImpl) GetBlobFromPath(ctx context.Context, dirPath, fullPath string
 		if length < 0 {
 			// read entire blob
 			// nolint:wrapcheck
-			return ioutil.ReadAll(r)
+			return ioutil.ReadAll(throttling.DownloadReader(ctx, r))
 		}
 
 		// parial read, seek to the provided offset and read given number of bytes.
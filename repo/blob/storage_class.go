@@ -0,0 +1,37 @@
+package blob
+
+import (
+	"strings"
+)
+
+// StorageClassMap maps blob ID prefixes to provider-specific storage classes (or tiers).
+// The class for the longest matching prefix is used and the empty prefix provides the default.
+type StorageClassMap map[ID]string
+
+// ForBlob returns the storage class for the provided blob ID or an empty string
+// if the provider default should be used.
+func (m StorageClassMap) ForBlob(id ID) string {
+	var (
+		longest ID
+		class   string
+		found   bool
+	)
+
+	for prefix, c := range m {
+		if !strings.HasPrefix(string(id), string(prefix)) {
+			continue
+		}
+
+		if !found || len(prefix) > len(longest) {
+			longest, class, found = prefix, c, true
+		}
+	}
+
+	return class
+}
+
+// StorageClassConfig is implemented by storage options of providers that support
+// assigning storage classes to blobs.
+type StorageClassConfig interface {
+	BlobStorageClasses() StorageClassMap
+}
@@ -0,0 +1,37 @@
+package blob_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+func TestStorageClassMap(t *testing.T) {
+	var empty blob.StorageClassMap
+
+	require.Equal(t, "", empty.ForBlob("p1234"))
+
+	m := blob.StorageClassMap{
+		"":   "STANDARD",
+		"p":  "STANDARD_IA",
+		"xn": "REDUCED_REDUNDANCY",
+	}
+
+	cases := map[blob.ID]string{
+		"p1234":            "STANDARD_IA",
+		"q1234":            "STANDARD",
+		"xn0_abcd":         "REDUCED_REDUNDANCY",
+		"xe0":              "STANDARD",
+		"kopia.repository": "STANDARD",
+	}
+
+	for id, want := range cases {
+		require.Equal(t, want, m.ForBlob(id), id)
+	}
+
+	// without default, unmatched blobs use the provider default.
+	delete(m, "")
+	require.Equal(t, "", m.ForBlob("q1234"))
+}
@@ -0,0 +1,199 @@
+package throttling
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const minutesPerDay = 24 * 60
+
+// nolint:gochecknoglobals
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Limits describes bandwidth limits, zero means unlimited.
+type Limits struct {
+	UploadBytesPerSecond   int64 `json:"maxUploadSpeedBytesPerSecond,omitempty"`
+	DownloadBytesPerSecond int64 `json:"maxDownloadSpeedBytesPerSecond,omitempty"`
+}
+
+// Window specifies limits in effect between two times of day, optionally only on selected days of week.
+type Window struct {
+	// Start and End are local times of day in HH:MM format. Start is inclusive, End is exclusive.
+	// When End is not after Start the window spans midnight.
+	Start string `json:"start"`
+	End   string `json:"end"`
+
+	// Days are three-letter names of the days of week (sun,mon,...) on which the window starts, all days if empty.
+	Days []string `json:"days,omitempty"`
+
+	Limits
+
+	// start and end in minutes since midnight, parsed by Validate().
+	start, end int
+}
+
+// Schedule describes bandwidth limits that change depending on the time of day.
+// The schedule applies to all blob storage traffic of a repository connection, regardless
+// of the snapshot source being uploaded, limits for individual sources are not supported.
+// Bandwidth limits specified in the storage options are enforced in addition to the schedule.
+type Schedule struct {
+	// Default limits apply outside of any window.
+	Default Limits   `json:"default"`
+	Windows []Window `json:"windows,omitempty"`
+}
+
+// LimitsAt returns the limits in effect at the provided time, which are the limits of the first matching window
+// or the default limits. The schedule must have been validated.
+func (s *Schedule) LimitsAt(t time.Time) Limits {
+	if s == nil {
+		return Limits{}
+	}
+
+	for _, w := range s.Windows {
+		if w.contains(t) {
+			return w.Limits
+		}
+	}
+
+	return s.Default
+}
+
+// Validate checks the schedule for errors and prepares it for use.
+func (s *Schedule) Validate() error {
+	if s == nil {
+		return nil
+	}
+
+	if err := s.Default.validate(); err != nil {
+		return errors.Wrap(err, "invalid default limits")
+	}
+
+	for i := range s.Windows {
+		if err := s.Windows[i].validate(); err != nil {
+			return errors.Wrapf(err, "invalid window #%v", i+1)
+		}
+	}
+
+	return nil
+}
+
+// String returns human-readable description of the schedule.
+func (s *Schedule) String() string {
+	if s == nil {
+		return "unlimited"
+	}
+
+	parts := []string{"default " + s.Default.String()}
+
+	for _, w := range s.Windows {
+		days := ""
+		if len(w.Days) > 0 {
+			days = " on " + strings.Join(w.Days, ",")
+		}
+
+		parts = append(parts, fmt.Sprintf("%v-%v%v %v", w.Start, w.End, days, w.Limits.String()))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+func (l Limits) validate() error {
+	if l.UploadBytesPerSecond < 0 || l.DownloadBytesPerSecond < 0 {
+		return errors.Errorf("limits must not be negative")
+	}
+
+	return nil
+}
+
+// String returns human-readable description of the limits.
+func (l Limits) String() string {
+	return fmt.Sprintf("upload:%v download:%v", limitString(l.UploadBytesPerSecond), limitString(l.DownloadBytesPerSecond))
+}
+
+func limitString(v int64) string {
+	if v == 0 {
+		return "unlimited"
+	}
+
+	return fmt.Sprintf("%v B/s", v)
+}
+
+func (w *Window) validate() error {
+	var err error
+
+	if w.start, err = parseTimeOfDay(w.Start); err != nil {
+		return err
+	}
+
+	if w.end, err = parseTimeOfDay(w.End); err != nil {
+		return err
+	}
+
+	for _, d := range w.Days {
+		if dayIndex(d) < 0 {
+			return errors.Errorf("invalid day of week %q", d)
+		}
+	}
+
+	return w.Limits.validate()
+}
+
+func (w Window) contains(t time.Time) bool {
+	start, end := w.start, w.end
+
+	now := t.Hour()*60 + t.Minute() // nolint:gomnd
+	day := t.Weekday()
+
+	if end <= start {
+		// window spans midnight, times before the end belong to the window that started the previous day.
+		if now < end {
+			return w.startsOn((day + 6) % 7) // nolint:gomnd
+		}
+
+		end = minutesPerDay
+	}
+
+	return now >= start && now < end && w.startsOn(day)
+}
+
+func (w Window) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+
+	for _, d := range w.Days {
+		if dayIndex(d) == int(day) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func dayIndex(name string) int {
+	for i, n := range dayNames {
+		if strings.EqualFold(n, name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// parseTimeOfDay parses HH:MM and returns the number of minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	var h, m int
+
+	if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil {
+		return 0, errors.Errorf("invalid time of day %q, must be HH:MM", s)
+	}
+
+	if h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, errors.Errorf("invalid time of day %q, must be HH:MM", s)
+	}
+
+	return h*60 + m, nil // nolint:gomnd
+}
@@ -0,0 +1,73 @@
+package throttling
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleLimitsAt(t *testing.T) {
+	night := Limits{UploadBytesPerSecond: 0}
+	business := Limits{UploadBytesPerSecond: 2 << 20, DownloadBytesPerSecond: 4 << 20}
+	weekend := Limits{UploadBytesPerSecond: 10 << 20}
+
+	s := &Schedule{
+		Default: night,
+		Windows: []Window{
+			{Start: "09:00", End: "17:30", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Limits: business},
+			{Start: "22:00", End: "06:00", Days: []string{"sat"}, Limits: weekend},
+		},
+	}
+
+	if err := s.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		t    time.Time
+		want Limits
+	}{
+		// Friday
+		{time.Date(2021, time.October, 15, 8, 59, 0, 0, time.Local), night},
+		{time.Date(2021, time.October, 15, 9, 0, 0, 0, time.Local), business},
+		{time.Date(2021, time.October, 15, 17, 29, 59, 0, time.Local), business},
+		{time.Date(2021, time.October, 15, 17, 30, 0, 0, time.Local), night},
+		// Saturday
+		{time.Date(2021, time.October, 16, 10, 0, 0, 0, time.Local), night},
+		{time.Date(2021, time.October, 16, 23, 0, 0, 0, time.Local), weekend},
+		// Sunday morning belongs to the window that started on Saturday.
+		{time.Date(2021, time.October, 17, 5, 59, 0, 0, time.Local), weekend},
+		{time.Date(2021, time.October, 17, 6, 0, 0, 0, time.Local), night},
+		{time.Date(2021, time.October, 17, 23, 0, 0, 0, time.Local), night},
+	}
+
+	for _, tc := range cases {
+		if got := s.LimitsAt(tc.t); got != tc.want {
+			t.Errorf("invalid limits at %v: %v, want %v", tc.t, got, tc.want)
+		}
+	}
+
+	var nilSchedule *Schedule
+
+	if got := nilSchedule.LimitsAt(time.Now()); got != (Limits{}) {
+		t.Errorf("unexpected limits for nil schedule: %v", got)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	for _, tc := range []struct {
+		s       *Schedule
+		wantErr bool
+	}{
+		{nil, false},
+		{&Schedule{}, false},
+		{&Schedule{Windows: []Window{{Start: "09:00", End: "17:00"}}}, false},
+		{&Schedule{Windows: []Window{{Start: "9", End: "17:00"}}}, true},
+		{&Schedule{Windows: []Window{{Start: "09:00", End: "24:00"}}}, true},
+		{&Schedule{Windows: []Window{{Start: "09:00", End: "17:00", Days: []string{"funday"}}}}, true},
+		{&Schedule{Default: Limits{UploadBytesPerSecond: -1}}, true},
+	} {
+		if err := tc.s.Validate(); (err != nil) != tc.wantErr {
+			t.Errorf("unexpected error for %v: %v", tc.s, err)
+		}
+	}
+}
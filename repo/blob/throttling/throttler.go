@@ -0,0 +1,232 @@
+// Package throttling implements bandwidth throttling of blob storage according to a time-based schedule.
+package throttling
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/clock"
+)
+
+// maxChunkSize is the maximum number of bytes transferred between checks of the bandwidth limit,
+// which bounds the bursts above the limit.
+const maxChunkSize = 16 << 10
+
+// tokenBucket limits the rate of bytes, allowing bursts of up to one second worth of data.
+// Callers may go into debt, in which case they must wait until it is repaid.
+type tokenBucket struct {
+	rate       float64 // bytes per second, zero means unlimited
+	available  float64
+	lastRefill time.Time
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if !b.lastRefill.IsZero() {
+		b.available += now.Sub(b.lastRefill).Seconds() * b.rate
+	}
+
+	if b.available > b.rate {
+		b.available = b.rate
+	}
+
+	b.lastRefill = now
+}
+
+func (b *tokenBucket) setRate(now time.Time, rate int64) {
+	if b.rate == float64(rate) {
+		return
+	}
+
+	wasUnlimited := b.rate <= 0
+
+	b.refill(now)
+	b.rate = float64(rate)
+
+	if wasUnlimited || b.available > b.rate {
+		// start with a full bucket when switching from unlimited bandwidth.
+		b.available = b.rate
+	}
+}
+
+// take removes n bytes from the bucket and returns the duration the caller must wait for.
+func (b *tokenBucket) take(now time.Time, n int64) time.Duration {
+	if b.rate <= 0 {
+		b.available = 0
+		b.lastRefill = now
+
+		return 0
+	}
+
+	b.refill(now)
+	b.available -= float64(n)
+
+	if b.available >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.available / b.rate * float64(time.Second))
+}
+
+// Throttler limits upload and download bandwidth according to the schedule, which can be changed at any time.
+// All uploads and all downloads share a single token bucket each, which is charged as the data is streamed.
+// Bandwidth limits in options of storage providers are enforced by the providers independently.
+type Throttler struct {
+	timeNow func() time.Time
+	sleep   func(ctx context.Context, d time.Duration) error
+
+	mu       sync.Mutex
+	schedule *Schedule
+	upload   tokenBucket
+	download tokenBucket
+}
+
+// Schedule returns a copy of the current schedule or nil if throttling is disabled.
+func (t *Throttler) Schedule() *Schedule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.schedule == nil {
+		return nil
+	}
+
+	s := *t.schedule
+	s.Windows = append([]Window(nil), s.Windows...)
+
+	return &s
+}
+
+// SetSchedule replaces the schedule, nil disables throttling.
+func (t *Throttler) SetSchedule(s *Schedule) error {
+	if err := s.Validate(); err != nil {
+		return err
+	}
+
+	if s != nil {
+		s2 := *s
+		s2.Windows = append([]Window(nil), s.Windows...)
+		s = &s2
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.schedule = s
+
+	return nil
+}
+
+// CurrentLimits returns the limits currently in effect.
+func (t *Throttler) CurrentLimits() Limits {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.schedule.LimitsAt(t.timeNow())
+}
+
+// waitUpload waits until the upload of n bytes is allowed by the current upload limit.
+func (t *Throttler) waitUpload(ctx context.Context, n int64) error {
+	return t.wait(ctx, n, &t.upload, func(l Limits) int64 { return l.UploadBytesPerSecond })
+}
+
+// waitDownload waits until the download of n bytes is allowed by the current download limit.
+func (t *Throttler) waitDownload(ctx context.Context, n int64) error {
+	return t.wait(ctx, n, &t.download, func(l Limits) int64 { return l.DownloadBytesPerSecond })
+}
+
+func (t *Throttler) wait(ctx context.Context, n int64, b *tokenBucket, rate func(l Limits) int64) error {
+	t.mu.Lock()
+	now := t.timeNow()
+	b.setRate(now, rate(t.schedule.LimitsAt(now)))
+	d := b.take(now, n)
+	t.mu.Unlock()
+
+	return t.sleep(ctx, d)
+}
+
+// throttledReader limits the rate of reading from the underlying reader by reading at most maxChunkSize
+// bytes at a time and waiting for the bandwidth limit after each read. For uploads this happens before
+// the data is sent, for downloads it stops receiving more data until the limit allows it.
+type throttledReader struct {
+	ctx  context.Context
+	r    io.Reader
+	wait func(ctx context.Context, n int64) error
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > maxChunkSize {
+		p = p[0:maxChunkSize]
+	}
+
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.wait(r.ctx, int64(n)); werr != nil {
+			return n, werr
+		}
+	}
+
+	// nolint:wrapcheck
+	return n, err
+}
+
+type contextKey struct{}
+
+// downloadThrottle is attached to the context of downloads by the storage wrapper.
+type downloadThrottle struct {
+	throttler *Throttler
+	streamed  int32 // set to 1 when the download was throttled as it was streamed
+}
+
+func withDownloadThrottle(ctx context.Context, t *Throttler) (context.Context, *downloadThrottle) {
+	dt := &downloadThrottle{throttler: t}
+
+	return context.WithValue(ctx, contextKey{}, dt), dt
+}
+
+// DownloadReader returns a reader that limits the rate of reading from r according to the download limits
+// of the Throttler wrapping the storage, or r if the download is not throttled. Storage providers should use it
+// for the data received by GetBlob(), downloads of other providers are throttled only after they complete.
+func DownloadReader(ctx context.Context, r io.Reader) io.Reader {
+	dt, ok := ctx.Value(contextKey{}).(*downloadThrottle)
+	if !ok {
+		return r
+	}
+
+	atomic.StoreInt32(&dt.streamed, 1)
+
+	return &throttledReader{ctx, r, dt.throttler.waitDownload}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "throttling interrupted")
+	case <-timer.C:
+		return nil
+	}
+}
+
+// NewThrottler returns new Throttler with the provided schedule, nil schedule means unlimited bandwidth.
+func NewThrottler(s *Schedule) (*Throttler, error) {
+	t := &Throttler{
+		timeNow: clock.Now,
+		sleep:   sleepContext,
+	}
+
+	if err := t.SetSchedule(s); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
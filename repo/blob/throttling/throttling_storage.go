@@ -0,0 +1,114 @@
+package throttling
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// throttlingStorage limits bandwidth of the underlying storage.
+type throttlingStorage struct {
+	base      blob.Storage
+	throttler *Throttler
+}
+
+// throttledBytes limits the rate at which the data is read when it is uploaded.
+type throttledBytes struct {
+	blob.Bytes
+
+	ctx       context.Context
+	throttler *Throttler
+}
+
+func (b throttledBytes) Reader() io.Reader {
+	return &throttledReader{b.ctx, b.Bytes.Reader(), b.throttler.waitUpload}
+}
+
+func (b throttledBytes) WriteTo(w io.Writer) (int64, error) {
+	// nolint:wrapcheck
+	return io.Copy(w, b.Reader())
+}
+
+func (s *throttlingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	ctx, dt := withDownloadThrottle(ctx, s.throttler)
+
+	data, err := s.base.GetBlob(ctx, id, offset, length)
+	if err != nil {
+		// nolint:wrapcheck
+		return nil, err
+	}
+
+	if atomic.LoadInt32(&dt.streamed) == 0 {
+		// the storage does not stream downloads through the throttler, charge the data after it was received.
+		if err := s.throttler.waitDownload(ctx, int64(len(data))); err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+func (s *throttlingStorage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	// nolint:wrapcheck
+	return s.base.GetMetadata(ctx, id)
+}
+
+func (s *throttlingStorage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	// nolint:wrapcheck
+	return s.base.SetTime(ctx, id, t)
+}
+
+func (s *throttlingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	// nolint:wrapcheck
+	return s.base.PutBlob(ctx, id, throttledBytes{data, ctx, s.throttler})
+}
+
+func (s *throttlingStorage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	// nolint:wrapcheck
+	return s.base.DeleteBlob(ctx, id)
+}
+
+func (s *throttlingStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	// nolint:wrapcheck
+	return blob.ExtendRetention(ctx, s.base, id, minRetainUntil)
+}
+
+func (s *throttlingStorage) BlobRetentionPeriod() time.Duration {
+	return blob.RetentionPeriod(s.base)
+}
+
+func (s *throttlingStorage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	// nolint:wrapcheck
+	return blob.RetainedUntil(ctx, s.base, id)
+}
+
+func (s *throttlingStorage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+func (s *throttlingStorage) Close(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+func (s *throttlingStorage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+func (s *throttlingStorage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+func (s *throttlingStorage) FlushCaches(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.FlushCaches(ctx)
+}
+
+// NewWrapper returns a Storage wrapper that limits upload and download bandwidth using the provided throttler.
+func NewWrapper(wrapped blob.Storage, throttler *Throttler) blob.Storage {
+	return &throttlingStorage{base: wrapped, throttler: throttler}
+}
@@ -0,0 +1,246 @@
+package throttling
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// Friday.
+var testStartTime = time.Date(2021, time.October, 15, 8, 0, 0, 0, time.Local)
+
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.slept += d
+	c.now = c.now.Add(d)
+
+	return nil
+}
+
+func newTestThrottler(t *testing.T, s *Schedule) (*Throttler, *fakeClock) {
+	t.Helper()
+
+	thr, err := NewThrottler(s)
+	if err != nil {
+		t.Fatalf("unable to create throttler: %v", err)
+	}
+
+	fc := &fakeClock{now: testStartTime}
+	thr.timeNow = fc.Now
+	thr.sleep = fc.Sleep
+
+	return thr, fc
+}
+
+func TestThrottlingStorage(t *testing.T) {
+	thr, _ := newTestThrottler(t, nil)
+
+	data := blobtesting.DataMap{}
+	underlying := blobtesting.NewMapStorage(data, nil, nil)
+
+	st := NewWrapper(underlying, thr)
+
+	ctx := testlogging.Context(t)
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	if got, want := st.ConnectionInfo().Type, underlying.ConnectionInfo().Type; got != want {
+		t.Errorf("unexpected connection info %v, want %v", got, want)
+	}
+}
+
+func TestThrottlingStorageLimits(t *testing.T) {
+	thr, fc := newTestThrottler(t, &Schedule{
+		Windows: []Window{
+			{Start: "09:00", End: "17:00", Limits: Limits{UploadBytesPerSecond: 1000, DownloadBytesPerSecond: 500}},
+		},
+	})
+
+	ctx := testlogging.Context(t)
+	st := NewWrapper(blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), thr)
+	payload := make([]byte, 3000)
+
+	// outside of business hours there are no limits.
+	if err := st.PutBlob(ctx, "blob1", gather.FromSlice(payload)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if fc.slept != 0 {
+		t.Fatalf("unexpected throttling outside of the window: %v", fc.slept)
+	}
+
+	fc.now = time.Date(2021, time.October, 15, 10, 0, 0, 0, time.Local)
+
+	// the initial burst is free, subsequent bytes are limited to 1000 B/s.
+	if err := st.PutBlob(ctx, "blob2", gather.FromSlice(payload)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if err := st.PutBlob(ctx, "blob3", gather.FromSlice(payload)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if got, want := fc.slept, 5*time.Second; got != want {
+		t.Errorf("unexpected upload throttling %v, want %v", got, want)
+	}
+
+	fc.slept = 0
+
+	if _, err := st.GetBlob(ctx, "blob1", 0, -1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if got, want := fc.slept, 5*time.Second; got != want {
+		t.Errorf("unexpected download throttling %v, want %v", got, want)
+	}
+
+	// changing the schedule at runtime takes effect immediately.
+	if err := thr.SetSchedule(nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	fc.slept = 0
+
+	if _, err := st.GetBlob(ctx, blob.ID("blob2"), 0, -1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if fc.slept != 0 {
+		t.Errorf("unexpected throttling after disabling it: %v", fc.slept)
+	}
+}
+
+func TestThrottlerContextCanceled(t *testing.T) {
+	thr, err := NewThrottler(&Schedule{Default: Limits{UploadBytesPerSecond: 1}})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(testlogging.Context(t))
+	cancel()
+
+	if err := thr.waitUpload(ctx, 1000); err == nil {
+		t.Errorf("expected error when context is canceled")
+	}
+}
+
+// streamingStorage reads uploaded data and streams downloads the way storage providers do,
+// recording the number of bytes transferred at each point in time.
+type streamingStorage struct {
+	blob.Storage
+
+	fc        *fakeClock
+	start     time.Time
+	transfers []transfer
+}
+
+type transfer struct {
+	elapsed time.Duration
+	total   int
+}
+
+func (s *streamingStorage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	var b bytes.Buffer
+
+	if _, err := io.Copy(&b, &timingReader{data.Reader(), s}); err != nil {
+		return err
+	}
+
+	return s.Storage.PutBlob(ctx, id, gather.FromSlice(b.Bytes()))
+}
+
+func (s *streamingStorage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	data, err := s.Storage.GetBlob(ctx, id, offset, length)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(&timingReader{DownloadReader(ctx, bytes.NewReader(data)), s})
+}
+
+type timingReader struct {
+	r  io.Reader
+	st *streamingStorage
+}
+
+func (r *timingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+
+	total := n
+	if len(r.st.transfers) > 0 {
+		total += r.st.transfers[len(r.st.transfers)-1].total
+	}
+
+	r.st.transfers = append(r.st.transfers, transfer{r.st.fc.now.Sub(r.st.start), total})
+
+	// nolint:wrapcheck
+	return n, err
+}
+
+func TestThrottlingStorageStreams(t *testing.T) {
+	thr, fc := newTestThrottler(t, &Schedule{
+		Default: Limits{UploadBytesPerSecond: maxChunkSize, DownloadBytesPerSecond: maxChunkSize},
+	})
+
+	ctx := testlogging.Context(t)
+	underlying := &streamingStorage{Storage: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil), fc: fc, start: fc.now}
+	st := NewWrapper(underlying, thr)
+	payload := make([]byte, 4*maxChunkSize)
+
+	if err := st.PutBlob(ctx, "blob1", gather.FromSlice(payload)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	verifyTransfers(t, underlying.transfers, fc.slept)
+
+	fc.now = fc.now.Add(time.Hour)
+	fc.slept = 0
+	underlying.start = fc.now
+	underlying.transfers = nil
+
+	if _, err := st.GetBlob(ctx, "blob1", 0, -1); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// streamed downloads are not charged again after they complete.
+	verifyTransfers(t, underlying.transfers, fc.slept)
+}
+
+// verifyTransfers ensures that the limit of maxChunkSize bytes per second with the burst of one second
+// was enforced during the transfer and not only before or after it.
+func verifyTransfers(t *testing.T, transfers []transfer, slept time.Duration) {
+	t.Helper()
+
+	if got, want := slept, 3*time.Second; got != want {
+		t.Errorf("unexpected throttling %v, want %v", got, want)
+	}
+
+	if len(transfers) == 0 || transfers[len(transfers)-1].total != 4*maxChunkSize {
+		t.Fatalf("unexpected transfers: %v", transfers)
+	}
+
+	if transfers[0].elapsed != 0 {
+		t.Errorf("transfer started after %v, want immediately", transfers[0].elapsed)
+	}
+
+	for _, tr := range transfers {
+		if allowed := int(tr.elapsed.Seconds()*maxChunkSize) + maxChunkSize; tr.total > allowed {
+			t.Errorf("transferred %v bytes after %v, allowed %v", tr.total, tr.elapsed, allowed)
+		}
+	}
+}
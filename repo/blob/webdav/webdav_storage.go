@@ -5,6 +5,7 @@
 	"bytes"
 	"context"
 	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net/http"
 	"os"
@@ -20,6 +21,7 @@
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/retrying"
 	"github.com/kopia/kopia/repo/blob/sharded"
+	"github.com/kopia/kopia/repo/blob/throttling"
 )
 
 const (
@@ -47,7 +49,14 @@ type davStorageImpl struct {
 }
 
 func (d *davStorageImpl) GetBlobFromPath(ctx context.Context, dirPath, path string, offset, length int64) ([]byte, error) {
-	data, err := d.cli.Read(path)
+	r, err := d.cli.ReadStream(path)
+	if err != nil {
+		return nil, d.translateError(err)
+	}
+
+	defer r.Close() //nolint:errcheck
+
+	data, err := ioutil.ReadAll(throttling.DownloadReader(ctx, r))
 	if err != nil {
 		return nil, d.translateError(err)
 	}
@@ -2,17 +2,21 @@
 
 import (
 	"context"
-	"os"
-	"path/filepath"
 
 	"github.com/pkg/errors"
 )
 
 // ChangePassword changes the repository password and rewrites `kopia.repository`.
 func (r *directRepository) ChangePassword(ctx context.Context, newPassword string) error {
-	f := r.formatBlob
+	return r.ChangePasswordWithKeyDerivation(ctx, newPassword, nil)
+}
+
+// ChangePasswordWithKeyDerivation changes the repository password and optionally the password-based
+// key derivation algorithm and rewrites `kopia.repository`.
+func (r *directRepository) ChangePasswordWithKeyDerivation(ctx context.Context, newPassword string, kd *KeyDerivationOptions) error {
+	f := r.formatBlob.clone()
 
-	repoConfig, err := f.decryptFormatBytes(r.formatEncryptionKey)
+	repoConfig, err := f.decryptFormatBytesForKeySlot(r.formatEncryptionKey, r.keySlotID)
 	if err != nil {
 		return errors.Wrap(err, "unable to decrypt repository config")
 	}
@@ -21,27 +25,31 @@ func (r *directRepository) ChangePassword(ctx context.Context, newPassword strin
 		return errors.Errorf("password changes are not supported for repositories created using Kopia v0.8 or older")
 	}
 
+	if len(f.KeySlots) > 0 {
+		return r.changeKeySlotPassword(ctx, newPassword, kd)
+	}
+
+	if kd != nil {
+		if err := f.applyKeyDerivationOptions(*kd); err != nil {
+			return errors.Wrap(err, "invalid key derivation options")
+		}
+	}
+
 	newFormatEncryptionKey, err := f.deriveFormatEncryptionKeyFromPassword(newPassword)
 	if err != nil {
 		return errors.Wrap(err, "unable to derive master key")
 	}
 
-	r.formatEncryptionKey = newFormatEncryptionKey
-
 	if err := encryptFormatBytes(f, repoConfig, newFormatEncryptionKey, f.UniqueID); err != nil {
 		return errors.Wrap(err, "unable to encrypt format bytes")
 	}
 
-	if err := writeFormatBlob(ctx, r.blobs, f); err != nil {
-		return errors.Wrap(err, "unable to write format blob")
+	if err := r.writeFormatBlobAndInvalidateCache(ctx, f); err != nil {
+		return err
 	}
 
-	// remove cached kopia.repository blob.
-	if cd := r.cachingOptions.CacheDirectory; cd != "" {
-		if err := os.Remove(filepath.Join(r.cachingOptions.CacheDirectory, "kopia.repository")); err != nil {
-			log(ctx).Errorf("unable to remove kopia.repository: %v", err)
-		}
-	}
+	r.formatEncryptionKey = newFormatEncryptionKey
+	r.passwordKey = newFormatEncryptionKey
 
 	return nil
 }
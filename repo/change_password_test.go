@@ -0,0 +1,55 @@
+package repo
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob/throttling"
+	"github.com/kopia/kopia/repo/content"
+)
+
+func TestChangePasswordWriteFailure(t *testing.T) {
+	ctx := testlogging.Context(t)
+	st := &blobtesting.FaultyStorage{
+		Base: blobtesting.NewMapStorage(blobtesting.DataMap{}, nil, nil),
+	}
+
+	require.NoError(t, Initialize(ctx, st, &NewRepositoryOptions{
+		BlockFormat: content.FormattingOptions{EnablePasswordChange: true},
+	}, "password"))
+
+	throttler, err := throttling.NewThrottler(nil)
+	require.NoError(t, err)
+
+	r, err := openWithConfig(ctx, st, throttler, &LocalConfig{}, "password", &Options{}, nil, "")
+	require.NoError(t, err)
+
+	defer r.Close(ctx)
+
+	dr := r.(*directRepository)
+
+	formatBlob := dr.formatBlob.clone()
+	formatEncryptionKey := dr.formatEncryptionKey
+	passwordKey := dr.passwordKey
+
+	st.Faults = map[string][]*blobtesting.Fault{
+		"PutBlob": {{Err: errors.New("some error")}},
+	}
+
+	// failure to write the format blob leaves the repository using the old password.
+	require.Error(t, dr.ChangePasswordWithKeyDerivation(ctx, "new-password", &KeyDerivationOptions{Algorithm: KeyDerivationScrypt}))
+	require.Equal(t, formatBlob, dr.formatBlob)
+	require.Equal(t, formatEncryptionKey, dr.formatEncryptionKey)
+	require.Equal(t, passwordKey, dr.passwordKey)
+
+	require.NoError(t, dr.ChangePassword(ctx, "new-password"))
+	require.NotEqual(t, formatEncryptionKey, dr.formatEncryptionKey)
+
+	r2, err := openWithConfig(ctx, st, throttler, &LocalConfig{}, "new-password", &Options{}, nil, "")
+	require.NoError(t, err)
+	r2.Close(ctx)
+}
@@ -8,6 +8,7 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 )
 
@@ -16,6 +17,9 @@ type ConnectOptions struct {
 	ClientOptions
 
 	content.CachingOptions
+
+	// PasswordProvider is the external source of the password to be used by future connections.
+	PasswordProvider *PasswordProviderConfig
 }
 
 // ErrRepositoryNotInitialized is returned when attempting to connect to repository that has not
@@ -47,6 +51,7 @@ func Connect(ctx context.Context, configFile string, st blob.Storage, password s
 	ci := st.ConnectionInfo()
 	lc.Storage = &ci
 	lc.ClientOptions = opt.ClientOptions.ApplyDefaults(ctx, "Repository in "+st.DisplayName())
+	lc.PasswordProvider = opt.PasswordProvider
 
 	if err = setupCachingOptionsWithDefaults(ctx, configFile, &lc, &opt.CachingOptions, f.UniqueID); err != nil {
 		return errors.Wrap(err, "unable to set up caching")
@@ -112,3 +117,69 @@ func SetClientOptions(ctx context.Context, configFile string, cliOpt ClientOptio
 
 	return lc.writeToFile(configFile)
 }
+
+// SetNotificationPassword stores the password of the notification profile in the provided configuration file,
+// empty password removes it.
+func SetNotificationPassword(ctx context.Context, configFile, profileName, password string) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	if password == "" {
+		if _, ok := lc.NotificationPasswords[profileName]; !ok {
+			return nil
+		}
+
+		delete(lc.NotificationPasswords, profileName)
+	} else {
+		if lc.NotificationPasswords == nil {
+			lc.NotificationPasswords = map[string]string{}
+		}
+
+		lc.NotificationPasswords[profileName] = password
+	}
+
+	return lc.writeToFile(configFile)
+}
+
+// SetNotificationHeaders stores HTTP headers of the webhook notification profile in the provided configuration file,
+// empty headers remove them.
+func SetNotificationHeaders(ctx context.Context, configFile, profileName string, headers map[string]string) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	if len(headers) == 0 {
+		if _, ok := lc.NotificationHeaders[profileName]; !ok {
+			return nil
+		}
+
+		delete(lc.NotificationHeaders, profileName)
+	} else {
+		if lc.NotificationHeaders == nil {
+			lc.NotificationHeaders = map[string]map[string]string{}
+		}
+
+		lc.NotificationHeaders[profileName] = headers
+	}
+
+	return lc.writeToFile(configFile)
+}
+
+// SetThrottlingSchedule updates the bandwidth schedule stored in the provided configuration file.
+func SetThrottlingSchedule(ctx context.Context, configFile string, s *throttling.Schedule) error {
+	if err := s.Validate(); err != nil {
+		return errors.Wrap(err, "invalid throttling schedule")
+	}
+
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	lc.Throttling = s
+
+	return lc.writeToFile(configFile)
+}
@@ -4,9 +4,13 @@
 	"crypto/aes"
 	"encoding/hex"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/hashing"
@@ -23,9 +27,60 @@
 //          for the encryption. In case of longer hash functions, we use last 16 bytes of
 //          their outputs.
 //   'optionalSuffix' can be any string
+//
+// When PublicKeyEncryptor is set, contents identified by PublicKeyEncryptionKeyID are encrypted
+// using it, while BLOBs are always encrypted using the Encryptor.
+//
+// After master key rotation, the Encryptor uses the current master key identified by KeyGeneration,
+// while PreviousEncryptors are only used to decrypt contents and BLOBs written using previous master keys.
+// Master keys rotated by other clients after the Crypter was created are learned by reloading
+// the repository format when decryption fails or the repository is refreshed, after which new
+// contents and BLOBs are encrypted using the rotated master key.
 type Crypter struct {
-	HashFunction hashing.HashFunc
-	Encryptor    encryption.Encryptor
+	HashFunction       hashing.HashFunc
+	Encryptor          encryption.Encryptor
+	PublicKeyEncryptor encryption.Encryptor
+
+	KeyGeneration      byte
+	PreviousEncryptors map[byte]encryption.Encryptor
+
+	hasPrivateKey bool
+
+	// reloadFormat returns the current formatting options of the repository.
+	reloadFormat func() (*FormattingOptions, error)
+
+	reloadMutex        sync.Mutex
+	lastReloadTime     time.Time                     // guarded by reloadMutex
+	reloadedEncryptors map[byte]encryption.Encryptor // guarded by reloadMutex
+
+	// writeKey holds the *masterKey used to encrypt new contents and BLOBs once a master key rotated
+	// after the Crypter was created has been learned.
+	writeKey atomic.Value
+}
+
+// masterKey is a generation of the master key along with its encryptor.
+type masterKey struct {
+	generation byte
+	encryptor  encryption.Encryptor
+}
+
+// keyReloadInterval is the minimum interval between reloads of the repository format
+// caused by decryption failures.
+const keyReloadInterval = time.Minute
+
+// currentMasterKey returns the most recent master key known to the Crypter, which is used to encrypt new data.
+func (c *Crypter) currentMasterKey() *masterKey {
+	if k, ok := c.writeKey.Load().(*masterKey); ok {
+		return k
+	}
+
+	return &masterKey{c.KeyGeneration, c.Encryptor}
+}
+
+// CanDecryptContents returns false when contents encrypted using the public key can't be decrypted
+// because the repository is connected without the private key.
+func (c *Crypter) CanDecryptContents() bool {
+	return c.PublicKeyEncryptor == nil || c.hasPrivateKey
 }
 
 // getIndexBlobIV gets the initialization vector from the provided blob ID by taking
@@ -64,7 +119,7 @@ func (c *Crypter) EncryptBLOB(data []byte, prefix blob.ID, sessionID SessionID)
 		return "", nil, err
 	}
 
-	data2, err := c.Encryptor.Encrypt(nil, data, iv)
+	data2, err := c.encrypt(nil, data, iv)
 	if err != nil {
 		return "", nil, errors.Wrapf(err, "error encrypting BLOB %v", blobID)
 	}
@@ -79,11 +134,237 @@ func (c *Crypter) DecryptBLOB(payload []byte, blobID blob.ID) ([]byte, error) {
 		return nil, errors.Wrap(err, "unable to get index blob IV")
 	}
 
+	decrypted, err := c.decryptWithAnyKey(payload, iv)
+
+	return decrypted, errors.Wrapf(err, "error decrypting BLOB %v", blobID)
+}
+
+// decryptWithAnyKey decrypts data that does not carry the encryption key ID, such as BLOBs,
+// trying all master keys, including the ones rotated after the Crypter was created.
+func (c *Crypter) decryptWithAnyKey(payload, iv []byte) ([]byte, error) {
+	decrypted, err := c.decryptBLOBWithKnownKeys(payload, iv)
+	if err == nil {
+		return decrypted, nil
+	}
+
+	// the data may have been written using a master key rotated after the Crypter was created.
+	encryptors, rerr := c.reloadedEncryptorList()
+	if rerr != nil {
+		return nil, rerr
+	}
+
+	for _, e := range encryptors {
+		if decrypted, rerr := e.Decrypt(nil, payload, iv); rerr == nil {
+			return decrypted, nil
+		}
+	}
+
+	return nil, err
+}
+
+// decryptBLOBWithKnownKeys decrypts the BLOB using the current or one of the previous master keys.
+func (c *Crypter) decryptBLOBWithKnownKeys(payload, iv []byte) ([]byte, error) {
 	// Decrypt will verify the payload.
-	payload, err = c.Encryptor.Decrypt(nil, payload, iv)
+	decrypted, err := c.decrypt(nil, payload, iv)
+	if err == nil {
+		return decrypted, nil
+	}
+
+	if c.currentMasterKey().encryptor != c.Encryptor {
+		if decrypted, perr := c.Encryptor.Decrypt(nil, payload, iv); perr == nil {
+			return decrypted, nil
+		}
+	}
+
+	// BLOBs don't carry the encryption key ID, try master keys replaced by key rotation.
+	for _, e := range c.PreviousEncryptors {
+		if decrypted, perr := e.Decrypt(nil, payload, iv); perr == nil {
+			return decrypted, nil
+		}
+	}
+
+	return nil, err
+}
+
+// reloadedEncryptor returns the encryptor for the master key that was not known when the Crypter was created
+// or nil if the key can't be found after reloading the repository format.
+func (c *Crypter) reloadedEncryptor(keyID byte) (encryption.Encryptor, error) {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	if e := c.reloadedEncryptors[keyID]; e != nil {
+		return e, nil
+	}
+
+	if err := c.maybeReloadEncryptorsLocked(); err != nil {
+		return nil, err
+	}
+
+	return c.reloadedEncryptors[keyID], nil
+}
+
+// reloadedEncryptorList returns encryptors for all master keys that were not known when the Crypter was created.
+func (c *Crypter) reloadedEncryptorList() ([]encryption.Encryptor, error) {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	if err := c.maybeReloadEncryptorsLocked(); err != nil {
+		return nil, err
+	}
+
+	var result []encryption.Encryptor
+	for _, e := range c.reloadedEncryptors {
+		result = append(result, e)
+	}
+
+	return result, nil
+}
+
+// reloadMasterKeys reloads the repository format at most once per keyReloadInterval and switches
+// encryption of new data to the current master key if it has been rotated by another client.
+func (c *Crypter) reloadMasterKeys() error {
+	c.reloadMutex.Lock()
+	defer c.reloadMutex.Unlock()
+
+	return c.maybeReloadEncryptorsLocked()
+}
+
+// maybeReloadEncryptorsLocked reloads the repository format at most once per keyReloadInterval
+// and creates encryptors for master keys that are not known yet.
+func (c *Crypter) maybeReloadEncryptorsLocked() error {
+	now := clock.Now()
+	if c.reloadFormat == nil || now.Sub(c.lastReloadTime) < keyReloadInterval {
+		return nil
+	}
+
+	c.lastReloadTime = now
+
+	f, err := c.reloadFormat()
+	if err != nil {
+		return errors.Wrap(err, "unable to reload repository format")
+	}
+
+	keys := map[byte]*FormattingOptions{byte(f.KeyGeneration): f}
+	for _, g := range f.PreviousMasterKeys {
+		keys[byte(g.Generation)] = f.previousMasterKeyParameters(g)
+	}
+
+	for keyID, p := range keys {
+		if keyID == c.KeyGeneration || c.PreviousEncryptors[keyID] != nil || c.reloadedEncryptors[keyID] != nil {
+			continue
+		}
+
+		e, err := encryption.CreateEncryptor(p)
+		if err != nil {
+			return errors.Wrapf(err, "unable to create encryptor for master key generation %v", keyID)
+		}
+
+		if c.reloadedEncryptors == nil {
+			c.reloadedEncryptors = map[byte]encryption.Encryptor{}
+		}
+
+		c.reloadedEncryptors[keyID] = e
+	}
+
+	// generations only increase, switch to the rotated master key for all new data.
+	if g := byte(f.KeyGeneration); g > c.currentMasterKey().generation {
+		c.writeKey.Store(&masterKey{g, c.reloadedEncryptors[g]})
+	}
+
+	return nil
+}
+
+// reencryptBLOB re-encrypts the BLOB encrypted using one of the previous master keys with the current one.
+// Returns nil if the BLOB is already encrypted using the current master key.
+func (c *Crypter) reencryptBLOB(payload []byte, blobID blob.ID) ([]byte, error) {
+	iv, err := c.getIndexBlobIV(blobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get BLOB IV")
+	}
+
+	if _, err = c.decrypt(nil, payload, iv); err == nil {
+		return nil, nil
+	}
+
+	// BLOBs written using master keys unknown to this Crypter must not be re-encrypted using an older key.
+	decrypted, err := c.decryptBLOBWithKnownKeys(payload, iv)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error decrypting BLOB %v", blobID)
 	}
 
-	return payload, nil
+	return c.encrypt(nil, decrypted, iv)
+}
+
+// encryptorForKeyID returns the encryptor for contents with the provided encryption key ID.
+func (c *Crypter) encryptorForKeyID(keyID byte) (encryption.Encryptor, error) {
+	if k := c.currentMasterKey(); keyID == k.generation {
+		return k.encryptor, nil
+	}
+
+	switch {
+	case keyID == c.KeyGeneration:
+		return c.Encryptor, nil
+
+	case keyID == PublicKeyEncryptionKeyID && c.PublicKeyEncryptor != nil:
+		return c.PublicKeyEncryptor, nil
+
+	case c.PreviousEncryptors[keyID] != nil:
+		return c.PreviousEncryptors[keyID], nil
+
+	default:
+		// the content may have been written using a master key rotated after the Crypter was created.
+		e, err := c.reloadedEncryptor(keyID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unsupported encryption key ID: %v", keyID)
+		}
+
+		if e != nil {
+			return e, nil
+		}
+
+		return nil, errors.Errorf("unsupported encryption key ID: %v", keyID)
+	}
+}
+
+// maxOverhead returns the maximum encryption overhead of any of the encryptors.
+func (c *Crypter) maxOverhead() int {
+	if c.PublicKeyEncryptor != nil && c.PublicKeyEncryptor.Overhead() > c.Encryptor.Overhead() {
+		return c.PublicKeyEncryptor.Overhead()
+	}
+
+	return c.Encryptor.Overhead()
+}
+
+// encrypt appends the data encrypted using the current master key to a given slice.
+func (c *Crypter) encrypt(output, data, iv []byte) ([]byte, error) {
+	// nolint:wrapcheck
+	return c.currentMasterKey().encryptor.Encrypt(output, data, iv)
+}
+
+// encryptContent is like encrypt, but uses the encryptor for the provided encryption key ID.
+func (c *Crypter) encryptContent(output, data, iv []byte, keyID byte) ([]byte, error) {
+	e, err := c.encryptorForKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint:wrapcheck
+	return e.Encrypt(output, data, iv)
+}
+
+// decrypt appends the data decrypted using the current master key to a given slice.
+func (c *Crypter) decrypt(output, payload, iv []byte) ([]byte, error) {
+	// nolint:wrapcheck
+	return c.currentMasterKey().encryptor.Decrypt(output, payload, iv)
+}
+
+// decryptContent is like decrypt, but uses the encryptor for the provided encryption key ID.
+func (c *Crypter) decryptContent(output, payload, iv []byte, keyID byte) ([]byte, error) {
+	e, err := c.encryptorForKeyID(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// nolint:wrapcheck
+	return e.Decrypt(output, payload, iv)
 }
@@ -20,6 +20,7 @@
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/filesystem"
 	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/hashing"
 	"github.com/kopia/kopia/repo/logging"
 )
@@ -221,13 +222,12 @@ func (sm *SharedManager) decryptContentAndVerify(payload []byte, bi Info) ([]byt
 		return nil, err
 	}
 
-	// reserved for future use
-	if k := bi.GetEncryptionKeyID(); k != 0 {
-		return nil, errors.Errorf("unsupported encryption key ID: %v", k)
-	}
-
-	decrypted, err := sm.decryptAndVerify(payload, iv)
+	decrypted, err := sm.decryptContentWithKeyAndVerify(payload, iv, bi.GetEncryptionKeyID())
 	if err != nil {
+		if errors.Is(err, encryption.ErrPrivateKeyUnavailable) {
+			return nil, errors.Wrapf(err, "unable to decrypt %v", bi.GetContentID())
+		}
+
 		return nil, errors.Wrapf(err, "invalid checksum at %v offset %v length %v", bi.GetPackBlobID(), bi.GetPackOffset(), len(payload))
 	}
 
@@ -249,8 +249,27 @@ func (sm *SharedManager) decryptContentAndVerify(payload []byte, bi Info) ([]byt
 	return decrypted, nil
 }
 
+// decryptAndVerify decrypts data that does not carry the encryption key ID, such as local pack indexes,
+// using any of the master keys.
 func (sm *SharedManager) decryptAndVerify(encrypted, iv []byte) ([]byte, error) {
-	decrypted, err := sm.crypter.Encryptor.Decrypt(nil, encrypted, iv)
+	decrypted, err := sm.crypter.decryptWithAnyKey(encrypted, iv)
+	if err != nil {
+		sm.Stats.foundInvalidContent()
+		return nil, errors.Wrap(err, "decrypt")
+	}
+
+	sm.Stats.foundValidContent()
+	sm.Stats.decrypted(len(decrypted))
+
+	return decrypted, nil
+}
+
+func (sm *SharedManager) decryptContentWithKeyAndVerify(encrypted, iv []byte, keyID byte) ([]byte, error) {
+	decrypted, err := sm.crypter.decryptContent(nil, encrypted, iv, keyID)
+	if errors.Is(err, encryption.ErrPrivateKeyUnavailable) {
+		return nil, err
+	}
+
 	if err != nil {
 		sm.Stats.foundInvalidContent()
 		return nil, errors.Wrap(err, "decrypt")
@@ -483,11 +502,13 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		return nil, errors.Errorf("can't handle repositories created using version %v (min supported %v, max supported %v)", f.Version, minSupportedWriteVersion, maxSupportedWriteVersion)
 	}
 
-	crypter, err := CreateCrypter(f)
+	crypter, err := createCrypter(f, opts.PrivateKey)
 	if err != nil {
 		return nil, err
 	}
 
+	crypter.reloadFormat = opts.ReloadFormattingOptions
+
 	actualIndexVersion := f.IndexVersion
 	if actualIndexVersion == 0 {
 		actualIndexVersion = DefaultIndexVersion
@@ -497,6 +518,22 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		return nil, errors.Errorf("index version %v is not supported", actualIndexVersion)
 	}
 
+	if f.ECC != "" && f.Version < FormatVersion2 {
+		return nil, errors.Errorf("error correction requires format version %v or newer", FormatVersion2)
+	}
+
+	if f.PublicKeyEncryption != "" && actualIndexVersion < MinPublicKeyEncryptionIndexVersion {
+		return nil, errors.Errorf("public-key encryption requires index version %v or newer", MinPublicKeyEncryptionIndexVersion)
+	}
+
+	if f.PublicKeyEncryption != "" && f.Version < FormatVersion2 {
+		return nil, errors.Errorf("public-key encryption requires format version %v or newer", FormatVersion2)
+	}
+
+	if f.KeyGeneration != 0 && actualIndexVersion < MinKeyRotationIndexVersion {
+		return nil, errors.Errorf("master key rotation requires index version %v or newer", MinKeyRotationIndexVersion)
+	}
+
 	// create internal logger that will be writing logs as encrypted repository blobs.
 	ilm := newInternalLogManager(ctx, st, crypter)
 
@@ -525,7 +562,7 @@ func NewSharedManager(ctx context.Context, st blob.Storage, f *FormattingOptions
 		repositoryFormatBytes:   opts.RepositoryFormatBytes,
 		checkInvariantsOnUnlock: os.Getenv("KOPIA_VERIFY_INVARIANTS") != "",
 		writeFormatVersion:      int32(f.Version),
-		encryptionBufferPool:    buf.NewPool(ctx, defaultEncryptionBufferPoolSegmentSize+crypter.Encryptor.Overhead()+maxCompressionOverheadPerContent, "content-manager-encryption"),
+		encryptionBufferPool:    buf.NewPool(ctx, defaultEncryptionBufferPoolSegmentSize+crypter.maxOverhead()+maxCompressionOverheadPerContent, "content-manager-encryption"),
 		indexVersion:            actualIndexVersion,
 		indexShardSize:          defaultIndexShardSize,
 		internalLogManager:      ilm,
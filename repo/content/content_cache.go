@@ -8,6 +8,12 @@
 
 type cacheKey string
 
+// blobCacheReplacer is implemented by caches that store entire BLOBs keyed by BLOB ID, whose cached copies
+// must be replaced when a BLOB is rewritten in place.
+type blobCacheReplacer interface {
+	replaceBlob(ctx context.Context, blobID blob.ID, data []byte)
+}
+
 type contentCache interface {
 	close(ctx context.Context)
 	getContent(ctx context.Context, cacheKey cacheKey, blobID blob.ID, offset, length int64) ([]byte, error)
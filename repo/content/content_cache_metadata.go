@@ -106,6 +106,17 @@ func (c *contentCacheForMetadata) getContent(ctx context.Context, cacheKey cache
 	return blobData[offset : offset+length], nil
 }
 
+// replaceBlob replaces the cached copy of the blob, if any.
+func (c *contentCacheForMetadata) replaceBlob(ctx context.Context, blobID blob.ID, data []byte) {
+	m := c.mutexForBlob(blobID)
+	m.Lock()
+	defer m.Unlock()
+
+	if c.pc.Get(ctx, string(blobID), 0, -1) != nil {
+		c.pc.Put(ctx, string(blobID), data)
+	}
+}
+
 func (c *contentCacheForMetadata) close(ctx context.Context) {
 	c.pc.Close(ctx)
 }
@@ -1,10 +1,16 @@
 package content
 
 import (
+	"crypto/rand"
+	"io"
+	"time"
+
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/epoch"
 	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/encryption"
 )
 
 const (
@@ -14,16 +20,36 @@
 
 // FormattingOptions describes the rules for formatting contents in repository.
 type FormattingOptions struct {
-	Version    int    `json:"version,omitempty"`    // version number, must be "1"
+	Version    int    `json:"version,omitempty"`    // version number, must be "1" or "2"
 	Hash       string `json:"hash,omitempty"`       // identifier of the hash algorithm used
 	Encryption string `json:"encryption,omitempty"` // identifier of the encryption algorithm used
 	HMACSecret []byte `json:"secret,omitempty"`     // HMAC secret used to generate encryption keys
 	MasterKey  []byte `json:"masterKey,omitempty"`  // master encryption key (SIV-mode encryption only)
+
+	KeyGeneration      int                   `json:"keyGeneration,omitempty"`      // generation of the master key, incremented on each key rotation
+	PreviousMasterKeys []MasterKeyGeneration `json:"previousMasterKeys,omitempty"` // master keys replaced by key rotation that have not been retired yet
+
+	ECC                string `json:"ecc,omitempty"`                // identifier of the error correction algorithm used
+	ECCOverheadPercent int    `json:"eccOverheadPercent,omitempty"` // space overhead of error correction codes
+
+	PublicKeyEncryption string `json:"publicKeyEncryption,omitempty"` // identifier of the public-key encryption algorithm used for data contents
+	PublicKey           []byte `json:"publicKey,omitempty"`           // public key used to encrypt data contents
+	MetadataKey         []byte `json:"metadataKey,omitempty"`         // key used instead of the master key to encrypt indexes, manifests and other metadata when using public-key encryption
+
 	MutableParameters
 
 	EnablePasswordChange bool `json:"enablePasswordChange"` // disables replication of kopia.repository blob in packs
 }
 
+// MasterKeyGeneration is a master key replaced by key rotation, which is still used to decrypt
+// contents and BLOBs written before the rotation until they are re-encrypted using the current key.
+type MasterKeyGeneration struct {
+	Generation   int       `json:"generation"`
+	MasterKey    []byte    `json:"masterKey"`
+	MetadataKey  []byte    `json:"metadataKey,omitempty"`
+	ReplacedTime time.Time `json:"replaced"`
+}
+
 // MutableParameters represents parameters of the content manager that can be mutated after the repository
 // is created.
 type MutableParameters struct {
@@ -53,16 +79,188 @@ func (v *MutableParameters) Validate() error {
 	return nil
 }
 
+// Validate validates the formatting options.
+func (f *FormattingOptions) Validate() error {
+	if err := f.MutableParameters.Validate(); err != nil {
+		return err
+	}
+
+	if f.ECC != "" {
+		// clients that don't support error correction would fail to read blobs with parity shards.
+		if f.Version < FormatVersion2 {
+			return errors.Errorf("error correction requires format version %v or newer", FormatVersion2)
+		}
+
+		if _, err := ecc.CreateEncoder(f); err != nil {
+			return errors.Wrap(err, "invalid error correction parameters")
+		}
+	}
+
+	if f.PublicKeyEncryption != "" {
+		// public-key encrypted contents are identified by encryption key ID, which can't be represented in v1 index.
+		if f.IndexVersion < MinPublicKeyEncryptionIndexVersion {
+			return errors.Errorf("public-key encryption requires index version %v or newer", MinPublicKeyEncryptionIndexVersion)
+		}
+
+		// clients that don't support public-key encryption would write contents using the master key.
+		if f.Version < FormatVersion2 {
+			return errors.Errorf("public-key encryption requires format version %v or newer", FormatVersion2)
+		}
+
+		if _, err := encryption.CreatePublicKeyEncryptor(f, nil); err != nil {
+			return errors.Wrap(err, "invalid public-key encryption parameters")
+		}
+	}
+
+	if f.KeyGeneration != 0 || len(f.PreviousMasterKeys) > 0 {
+		if err := f.validateKeyGenerations(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *FormattingOptions) validateKeyGenerations() error {
+	// key generations are stored in index entries as encryption key IDs, which can't be represented in v1 index.
+	if f.IndexVersion < MinKeyRotationIndexVersion {
+		return errors.Errorf("master key rotation requires index version %v or newer", MinKeyRotationIndexVersion)
+	}
+
+	if f.Version < FormatVersion2 {
+		return errors.Errorf("master key rotation requires format version %v or newer", FormatVersion2)
+	}
+
+	if f.KeyGeneration < 0 || f.KeyGeneration > maxKeyGeneration {
+		return errors.Errorf("invalid master key generation %v", f.KeyGeneration)
+	}
+
+	for _, g := range f.PreviousMasterKeys {
+		if g.Generation < 0 || g.Generation >= f.KeyGeneration {
+			return errors.Errorf("invalid previous master key generation %v", g.Generation)
+		}
+
+		if len(g.MasterKey) != len(f.MasterKey) {
+			return errors.Errorf("invalid previous master key length for generation %v", g.Generation)
+		}
+
+		if len(g.MetadataKey) != len(f.MetadataKey) {
+			return errors.Errorf("invalid previous metadata key length for generation %v", g.Generation)
+		}
+	}
+
+	return nil
+}
+
+// RotateMasterKey replaces the master key with a newly generated one, retaining the replaced key
+// for decryption until it is retired using RetirePreviousMasterKeys.
+func (f *FormattingOptions) RotateMasterKey(now time.Time) error {
+	if f.IndexVersion < MinKeyRotationIndexVersion {
+		return errors.Errorf("master key rotation requires index version %v or newer", MinKeyRotationIndexVersion)
+	}
+
+	if f.KeyGeneration >= maxKeyGeneration {
+		return errors.Errorf("maximum number of master key generations reached")
+	}
+
+	if len(f.MasterKey) == 0 {
+		return errors.Errorf("master key is not available")
+	}
+
+	newKey := make([]byte, len(f.MasterKey))
+	if _, err := io.ReadFull(rand.Reader, newKey); err != nil {
+		return errors.Wrap(err, "error generating master key")
+	}
+
+	newMetadataKey := make([]byte, len(f.MetadataKey))
+	if _, err := io.ReadFull(rand.Reader, newMetadataKey); err != nil {
+		return errors.Wrap(err, "error generating metadata key")
+	}
+
+	f.PreviousMasterKeys = append(f.PreviousMasterKeys, MasterKeyGeneration{
+		Generation:   f.KeyGeneration,
+		MasterKey:    f.MasterKey,
+		MetadataKey:  f.MetadataKey,
+		ReplacedTime: now,
+	})
+
+	f.MasterKey = newKey
+
+	if len(f.MetadataKey) > 0 {
+		f.MetadataKey = newMetadataKey
+	}
+	f.KeyGeneration++
+
+	// clients supporting only FormatVersion1 would ignore previous master keys and drop them
+	// when rewriting the repository format, so they must refuse to open the repository.
+	if f.Version < FormatVersion2 {
+		f.Version = FormatVersion2
+	}
+
+	return nil
+}
+
+// RetirePreviousMasterKeys removes all master keys replaced by key rotation, after which
+// contents and BLOBs encrypted using them can no longer be decrypted.
+func (f *FormattingOptions) RetirePreviousMasterKeys() {
+	f.PreviousMasterKeys = nil
+}
+
+// previousMasterKeyParameters returns encryption parameters using the provided previous master key.
+func (f *FormattingOptions) previousMasterKeyParameters(g MasterKeyGeneration) *FormattingOptions {
+	f2 := *f
+	f2.MasterKey = g.MasterKey
+	f2.MetadataKey = g.MetadataKey
+	f2.PreviousMasterKeys = nil
+
+	return &f2
+}
+
 // GetEncryptionAlgorithm implements encryption.Parameters.
 func (f *FormattingOptions) GetEncryptionAlgorithm() string {
 	return f.Encryption
 }
 
 // GetMasterKey implements encryption.Parameters.
+//
+// Repositories using public-key encryption encrypt indexes, manifests and other metadata using
+// the metadata key, which allows write-only clients to add data without having the master key.
 func (f *FormattingOptions) GetMasterKey() []byte {
+	if f.PublicKeyEncryption != "" && len(f.MetadataKey) > 0 {
+		return f.MetadataKey
+	}
+
 	return f.MasterKey
 }
 
+// WriteOnly returns a copy of the formatting options without the master keys, which is given to clients
+// that can only add data to repositories using public-key encryption.
+func (f *FormattingOptions) WriteOnly() *FormattingOptions {
+	f2 := *f
+	f2.MasterKey = nil
+	f2.PreviousMasterKeys = nil
+
+	for _, g := range f.PreviousMasterKeys {
+		f2.PreviousMasterKeys = append(f2.PreviousMasterKeys, MasterKeyGeneration{
+			Generation:   g.Generation,
+			MetadataKey:  g.MetadataKey,
+			ReplacedTime: g.ReplacedTime,
+		})
+	}
+
+	return &f2
+}
+
+// GetPublicKeyEncryptionAlgorithm implements encryption.PublicKeyParameters.
+func (f *FormattingOptions) GetPublicKeyEncryptionAlgorithm() string {
+	return f.PublicKeyEncryption
+}
+
+// GetPublicKey implements encryption.PublicKeyParameters.
+func (f *FormattingOptions) GetPublicKey() []byte {
+	return f.PublicKey
+}
+
 // GetHashFunction implements hashing.Parameters.
 func (f *FormattingOptions) GetHashFunction() string {
 	return f.Hash
@@ -72,3 +270,13 @@ func (f *FormattingOptions) GetHashFunction() string {
 func (f *FormattingOptions) GetHmacSecret() []byte {
 	return f.HMACSecret
 }
+
+// GetECCAlgorithm implements ecc.Parameters.
+func (f *FormattingOptions) GetECCAlgorithm() string {
+	return f.ECC
+}
+
+// GetECCOverheadPercent implements ecc.Parameters.
+func (f *FormattingOptions) GetECCOverheadPercent() int {
+	return f.ECCOverheadPercent
+}
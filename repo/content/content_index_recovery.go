@@ -181,7 +181,7 @@ func (sm *SharedManager) writePackFileIndexRecoveryData(buf *gather.WriteBuffer,
 
 	localIndexIV := sm.hashData(nil, localIndex)
 
-	encryptedLocalIndex, err := sm.crypter.Encryptor.Encrypt(nil, localIndex, localIndexIV)
+	encryptedLocalIndex, err := sm.crypter.encrypt(nil, localIndex, localIndexIV)
 	if err != nil {
 		return errors.Wrap(err, "encryption error")
 	}
@@ -38,6 +38,23 @@
 	defaultIndexShardSize = 16e6 // slightly less than 2^24, which lets index use 24-bit/3-byte indexes
 
 	DefaultIndexVersion = 1
+
+	// MinPublicKeyEncryptionIndexVersion is the minimum index version that supports public-key encryption.
+	MinPublicKeyEncryptionIndexVersion = v2IndexVersion
+
+	// PublicKeyEncryptionKeyID is the encryption key ID of contents encrypted using the repository public key.
+	PublicKeyEncryptionKeyID byte = 0x80
+
+	// MinKeyRotationIndexVersion is the minimum index version that supports master key rotation.
+	MinKeyRotationIndexVersion = v2IndexVersion
+
+	// contents encrypted using the master key use its generation as encryption key ID,
+	// which must not collide with PublicKeyEncryptionKeyID.
+	maxKeyGeneration = int(PublicKeyEncryptionKeyID) - 1
+
+	// manifestContentPrefix is the prefix of manifest contents, which are always encrypted using the master key,
+	// since clients need to read policies and snapshot manifests even without the private key.
+	manifestContentPrefix ID = "m"
 )
 
 // PackBlobIDPrefixes contains all possible prefixes for pack blobs.
@@ -56,17 +73,27 @@
 	defaultMaxPreambleLength = 32
 	defaultPaddingUnit       = 4096
 
-	currentWriteVersion = 1
+	currentWriteVersion = FormatVersion2
 
-	minSupportedWriteVersion = 1
+	minSupportedWriteVersion = FormatVersion1
 	maxSupportedWriteVersion = currentWriteVersion
 
-	minSupportedReadVersion = 1
+	minSupportedReadVersion = FormatVersion1
 	maxSupportedReadVersion = currentWriteVersion
 
 	indexLoadAttempts = 10
 )
 
+const (
+	// FormatVersion1 is the original format version of repositories.
+	FormatVersion1 = 1
+
+	// FormatVersion2 is the format version of repositories using features that would be silently
+	// mishandled by clients supporting only FormatVersion1, such as error correction,
+	// rotated master keys or objects with holes.
+	FormatVersion2 = 2
+)
+
 // ErrContentNotFound is returned when content is not found.
 var ErrContentNotFound = errors.New("content not found")
 
@@ -262,9 +289,10 @@ func (bm *WriteManager) addToPackUnlocked(ctx context.Context, contentID ID, dat
 		TimestampSeconds: bm.timeNow().Unix(),
 		FormatVersion:    byte(bm.writeFormatVersion),
 		OriginalLength:   uint32(len(data)),
+		EncryptionKeyID:  bm.encryptionKeyIDForContent(contentID),
 	}
 
-	actualComp, err := bm.maybeCompressAndEncryptDataForPacking(pp.currentPackData, data, contentID, comp)
+	actualComp, err := bm.maybeCompressAndEncryptDataForPacking(pp.currentPackData, data, contentID, comp, info.EncryptionKeyID)
 	if err != nil {
 		return errors.Wrapf(err, "unable to encrypt %q", contentID)
 	}
@@ -767,6 +795,11 @@ type ManagerOptions struct {
 	RepositoryFormatBytes []byte
 	TimeNow               func() time.Time // Time provider
 	DisableInternalLog    bool
+	PrivateKey            []byte // private key used to decrypt contents encrypted using the public key
+
+	// ReloadFormattingOptions returns the current formatting options of the repository, which is used
+	// to learn about master keys rotated by other clients after the repository was opened.
+	ReloadFormattingOptions func() (*FormattingOptions, error)
 }
 
 // CloneOrDefault returns a clone of provided ManagerOptions or default empty struct if nil.
@@ -39,6 +39,11 @@ func (sm *SharedManager) Refresh(ctx context.Context) error {
 
 	t0 := clock.Now()
 
+	// pick up master keys rotated by other clients, so that new data is encrypted using the current one.
+	if err := sm.crypter.reloadMasterKeys(); err != nil {
+		sm.log.Errorf("unable to reload master keys: %v", err)
+	}
+
 	err := sm.loadPackIndexesUnlocked(ctx)
 	sm.log.Debugf("Refresh completed in %v", clock.Since(t0))
 
@@ -0,0 +1,91 @@
+package content
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/epoch"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+)
+
+// reencryptedBlobPrefixes are prefixes of BLOBs encrypted as a whole using the master key,
+// as opposed to pack blobs where each content is encrypted individually.
+// Epoch markers are not encrypted and are not included.
+// nolint:gochecknoglobals
+var reencryptedBlobPrefixes = []blob.ID{
+	IndexBlobPrefix,
+	compactionLogBlobPrefix,
+	cleanupBlobPrefix,
+
+	epoch.UncompactedIndexBlobPrefix,
+	epoch.SingleEpochCompactionBlobPrefix,
+	epoch.RangeCheckpointIndexBlobPrefix,
+
+	BlobIDPrefixSession,
+	internalLogBlobPrefix,
+}
+
+// ReencryptBlobs re-encrypts index, session and log BLOBs written using master keys replaced by key rotation
+// with the current master key and returns the number of BLOBs that were re-encrypted.
+// Since BLOB IDs don't depend on the master key, each BLOB is overwritten in place and its cached copy is replaced.
+// Other clients replace their cached copies when those fail to decrypt.
+func (sm *SharedManager) ReencryptBlobs(ctx context.Context) (int, error) {
+	if len(sm.crypter.PreviousEncryptors) == 0 {
+		return 0, nil
+	}
+
+	count := 0
+
+	for _, prefix := range reencryptedBlobPrefixes {
+		blobs, err := blob.ListAllBlobs(ctx, sm.st, prefix)
+		if err != nil {
+			return count, errors.Wrapf(err, "error listing blobs with prefix %v", prefix)
+		}
+
+		for _, bm := range blobs {
+			reencrypted, err := sm.reencryptBlob(ctx, bm.BlobID)
+			if err != nil {
+				return count, err
+			}
+
+			if reencrypted {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func (sm *SharedManager) reencryptBlob(ctx context.Context, blobID blob.ID) (bool, error) {
+	payload, err := sm.st.GetBlob(ctx, blobID, 0, -1)
+	if errors.Is(err, blob.ErrBlobNotFound) {
+		// blob deleted concurrently.
+		return false, nil
+	}
+
+	if err != nil {
+		return false, errors.Wrapf(err, "error reading blob %v", blobID)
+	}
+
+	data, err := sm.crypter.reencryptBLOB(payload, blobID)
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to re-encrypt blob %v", blobID)
+	}
+
+	if data == nil {
+		return false, nil
+	}
+
+	if err := sm.st.PutBlob(ctx, blobID, gather.FromSlice(data)); err != nil {
+		return false, errors.Wrapf(err, "error writing re-encrypted blob %v", blobID)
+	}
+
+	sm.enc.replaceCachedBlob(ctx, blobID, data)
+
+	sm.log.Debugf("re-encrypted blob %v", blobID)
+
+	return true, nil
+}
@@ -24,7 +24,22 @@
 
 const indexBlobCompactionWarningThreshold = 1000
 
-func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(output *gather.WriteBuffer, data []byte, contentID ID, comp compression.HeaderID) (compression.HeaderID, error) {
+// encryptionKeyIDForContent returns the ID of the key used to encrypt the provided content.
+// When public-key encryption is enabled, all contents except manifests are encrypted using the public key,
+// otherwise contents are encrypted using the most recent generation of the master key known to the client.
+//
+// Public-key encryption only protects contents, clients without the private key still hold the master key
+// and can read manifests (including snapshot manifests and policies), index blobs and internal logs,
+// and can check whether particular data is present in the repository using content IDs.
+func (sm *SharedManager) encryptionKeyIDForContent(contentID ID) byte {
+	if sm.crypter.PublicKeyEncryptor == nil || contentID.Prefix() == manifestContentPrefix {
+		return sm.crypter.currentMasterKey().generation
+	}
+
+	return PublicKeyEncryptionKeyID
+}
+
+func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(output *gather.WriteBuffer, data []byte, contentID ID, comp compression.HeaderID, keyID byte) (compression.HeaderID, error) {
 	var hashOutput [hashing.MaxHashSize]byte
 
 	iv, err := getPackedContentIV(hashOutput[:], contentID)
@@ -60,10 +75,10 @@ func (sm *SharedManager) maybeCompressAndEncryptDataForPacking(output *gather.Wr
 		}
 	}
 
-	b := sm.encryptionBufferPool.Allocate(len(data) + sm.crypter.Encryptor.Overhead())
+	b := sm.encryptionBufferPool.Allocate(len(data) + sm.crypter.maxOverhead())
 	defer b.Release()
 
-	cipherText, err := sm.crypter.Encryptor.Encrypt(b.Data[:0], data, iv)
+	cipherText, err := sm.crypter.encryptContent(b.Data[:0], data, iv, keyID)
 	if err != nil {
 		return NoCompression, errors.Wrap(err, "unable to encrypt")
 	}
@@ -102,21 +117,26 @@ func ValidatePrefix(prefix ID) error {
 }
 
 func (bm *WriteManager) getContentDataUnlocked(ctx context.Context, pp *pendingPackInfo, bi Info) ([]byte, error) {
-	var payload []byte
+	payload, err := bm.getContentPayloadUnlocked(ctx, pp, bi)
+	if err != nil {
+		return nil, err
+	}
 
+	return bm.decryptContentAndVerify(payload, bi)
+}
+
+func (bm *WriteManager) getContentPayloadUnlocked(ctx context.Context, pp *pendingPackInfo, bi Info) ([]byte, error) {
 	if pp != nil && pp.packBlobID == bi.GetPackBlobID() {
 		// we need to use a lock here in case somebody else writes to the pack at the same time.
-		payload = pp.currentPackData.AppendSectionTo(nil, int(bi.GetPackOffset()), int(bi.GetPackedLength()))
-	} else {
-		var err error
+		return pp.currentPackData.AppendSectionTo(nil, int(bi.GetPackOffset()), int(bi.GetPackedLength())), nil
+	}
 
-		payload, err = bm.getCacheForContentID(bi.GetContentID()).getContent(ctx, cacheKey(bi.GetContentID()), bi.GetPackBlobID(), int64(bi.GetPackOffset()), int64(bi.GetPackedLength()))
-		if err != nil {
-			return nil, errors.Wrap(err, "getCacheForContentID")
-		}
+	payload, err := bm.getCacheForContentID(bi.GetContentID()).getContent(ctx, cacheKey(bi.GetContentID()), bi.GetPackBlobID(), int64(bi.GetPackOffset()), int64(bi.GetPackedLength()))
+	if err != nil {
+		return nil, errors.Wrap(err, "getCacheForContentID")
 	}
 
-	return bm.decryptContentAndVerify(payload, bi)
+	return payload, nil
 }
 
 func (bm *WriteManager) preparePackDataContent(pp *pendingPackInfo) (packIndexBuilder, error) {
@@ -187,7 +207,12 @@ func (sm *SharedManager) hashData(output, data []byte) []byte {
 }
 
 // CreateCrypter returns a Crypter based on the specified formatting options.
+// Contents encrypted using the public key can't be decrypted by the returned Crypter.
 func CreateCrypter(f *FormattingOptions) (*Crypter, error) {
+	return createCrypter(f, nil)
+}
+
+func createCrypter(f *FormattingOptions, privateKey []byte) (*Crypter, error) {
 	h, err := hashing.CreateHashFunc(f)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create hash")
@@ -205,5 +230,29 @@ func CreateCrypter(f *FormattingOptions) (*Crypter, error) {
 		return nil, errors.Wrap(err, "invalid encryptor")
 	}
 
-	return &Crypter{h, e}, nil
+	c := &Crypter{HashFunction: h, Encryptor: e, KeyGeneration: byte(f.KeyGeneration)}
+
+	for _, g := range f.PreviousMasterKeys {
+		pe, err := encryption.CreateEncryptor(f.previousMasterKeyParameters(g))
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to create encryptor for master key generation %v", g.Generation)
+		}
+
+		if c.PreviousEncryptors == nil {
+			c.PreviousEncryptors = map[byte]encryption.Encryptor{}
+		}
+
+		c.PreviousEncryptors[byte(g.Generation)] = pe
+	}
+
+	if f.PublicKeyEncryption != "" {
+		c.PublicKeyEncryptor, err = encryption.CreatePublicKeyEncryptor(f, privateKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create public-key encryptor")
+		}
+
+		c.hasPrivateKey = len(privateKey) > 0
+	}
+
+	return c, nil
 }
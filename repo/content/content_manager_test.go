@@ -23,12 +23,15 @@
 	"github.com/kopia/kopia/internal/blobtesting"
 	"github.com/kopia/kopia/internal/epoch"
 	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/ownwrites"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/blob/logging"
 	"github.com/kopia/kopia/repo/compression"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/encryption"
 )
 
 const (
@@ -2077,6 +2080,276 @@ func (s *contentManagerSuite) TestCompression_NonCompressibleData(t *testing.T)
 	verifyContent(ctx, t, bm2, cid, nonCompressibleData)
 }
 
+func (s *contentManagerSuite) TestErrorCorrectionRequiresFormatVersion2(t *testing.T) {
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	ctx := testlogging.Context(t)
+
+	fo := &FormattingOptions{
+		Hash:               "HMAC-SHA256",
+		Encryption:         "AES256-GCM-HMAC-SHA256",
+		HMACSecret:         hmacSecret,
+		ECC:                ecc.DefaultAlgorithm,
+		ECCOverheadPercent: 10,
+		MutableParameters:  s.mutableParameters,
+		Version:            FormatVersion2,
+	}
+
+	bm, err := NewManagerForTesting(ctx, st, fo, nil, nil)
+	require.NoError(t, err)
+	require.NoError(t, bm.Close(ctx))
+
+	// error correction is applied to blobs by the repository, but clients that don't support it
+	// must refuse to open the repository, which requires format version 2.
+	fo.Version = FormatVersion1
+	_, err = NewManagerForTesting(ctx, st, fo, nil, nil)
+	require.Error(t, err)
+}
+
+func (s *contentManagerSuite) TestPublicKeyEncryption(t *testing.T) {
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	ctx := testlogging.Context(t)
+
+	mp := s.mutableParameters
+	mp.IndexVersion = v2IndexVersion
+
+	publicKey, privateKey, err := encryption.GenerateKeyPair(encryption.DefaultPublicKeyAlgorithm)
+	require.NoError(t, err)
+
+	fo := &FormattingOptions{
+		Hash:                "HMAC-SHA256",
+		Encryption:          "AES256-GCM-HMAC-SHA256",
+		HMACSecret:          hmacSecret,
+		PublicKeyEncryption: encryption.DefaultPublicKeyAlgorithm,
+		PublicKey:           publicKey,
+		MutableParameters:   mp,
+		Version:             FormatVersion2,
+	}
+
+	// write-only manager.
+	bm, err := NewManagerForTesting(ctx, st, fo, nil, nil)
+	require.NoError(t, err)
+
+	defer bm.Close(ctx)
+
+	contentData := seededRandomData(10, 1000)
+	manifestData := seededRandomData(11, 1000)
+
+	cid, err := bm.WriteContent(ctx, contentData, "", NoCompression)
+	require.NoError(t, err)
+
+	mid, err := bm.WriteContent(ctx, manifestData, manifestContentPrefix, NoCompression)
+	require.NoError(t, err)
+
+	require.NoError(t, bm.Flush(ctx))
+
+	ci, err := bm.ContentInfo(ctx, cid)
+	require.NoError(t, err)
+	require.Equal(t, PublicKeyEncryptionKeyID, ci.GetEncryptionKeyID())
+
+	mi, err := bm.ContentInfo(ctx, mid)
+	require.NoError(t, err)
+	require.Equal(t, byte(0), mi.GetEncryptionKeyID())
+
+	// manifests are readable without the private key, other contents are not.
+	verifyContent(ctx, t, bm, mid, manifestData)
+
+	_, err = bm.GetContent(ctx, cid)
+	require.ErrorIs(t, err, encryption.ErrPrivateKeyUnavailable)
+
+	// writing the same content again is deduplicated.
+	cid2, err := bm.WriteContent(ctx, contentData, "", NoCompression)
+	require.NoError(t, err)
+	require.Equal(t, cid, cid2)
+
+	bm2, err := NewManagerForTesting(ctx, st, fo, nil, &ManagerOptions{PrivateKey: privateKey})
+	require.NoError(t, err)
+
+	defer bm2.Close(ctx)
+
+	verifyContent(ctx, t, bm2, cid, contentData)
+	verifyContent(ctx, t, bm2, mid, manifestData)
+
+	// private key must match the public key.
+	_, otherPrivateKey, err := encryption.GenerateKeyPair(encryption.DefaultPublicKeyAlgorithm)
+	require.NoError(t, err)
+
+	_, err = NewManagerForTesting(ctx, st, fo, nil, &ManagerOptions{PrivateKey: otherPrivateKey})
+	require.Error(t, err)
+
+	// public-key encryption requires format version 2, which clients that don't support it refuse to open.
+	v1 := *fo
+	v1.Version = FormatVersion1
+	_, err = NewManagerForTesting(ctx, st, &v1, nil, nil)
+	require.Error(t, err)
+
+	// public-key encryption requires v2 index.
+	fo.IndexVersion = v1IndexVersion
+	_, err = NewManagerForTesting(ctx, st, fo, nil, nil)
+	require.Error(t, err)
+}
+
+func (s *contentManagerSuite) TestMasterKeyRotation(t *testing.T) {
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	ctx := testlogging.Context(t)
+
+	mp := s.mutableParameters
+	mp.IndexVersion = v2IndexVersion
+
+	fo := &FormattingOptions{
+		Hash:              "HMAC-SHA256",
+		Encryption:        "AES256-GCM-HMAC-SHA256",
+		HMACSecret:        hmacSecret,
+		MasterKey:         make([]byte, 32),
+		MutableParameters: mp,
+		Version:           1,
+	}
+
+	// each operation advances the time, so that rewritten contents are newer than the originals.
+	opts := &ManagerOptions{TimeNow: faketime.AutoAdvance(fakeTime, time.Second)}
+
+	bm, err := NewManagerForTesting(ctx, st, fo, nil, opts)
+	require.NoError(t, err)
+
+	defer bm.Close(ctx)
+
+	oldData := seededRandomData(10, 1000)
+
+	oldID, err := bm.WriteContent(ctx, oldData, "", NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, bm.Flush(ctx))
+
+	require.NoError(t, fo.RotateMasterKey(fakeTime))
+	require.NoError(t, fo.validateKeyGenerations())
+	require.Equal(t, 1, fo.KeyGeneration)
+	require.Equal(t, FormatVersion2, fo.Version)
+
+	bm2, err := NewManagerForTesting(ctx, st, fo, nil, opts)
+	require.NoError(t, err)
+
+	defer bm2.Close(ctx)
+
+	newData := seededRandomData(11, 1000)
+
+	newID, err := bm2.WriteContent(ctx, newData, "", NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, bm2.Flush(ctx))
+
+	// index blobs and contents written using both keys are readable.
+	verifyContent(ctx, t, bm2, oldID, oldData)
+	verifyContent(ctx, t, bm2, newID, newData)
+
+	ci, err := bm2.ContentInfo(ctx, newID)
+	require.NoError(t, err)
+	require.Equal(t, byte(1), ci.GetEncryptionKeyID())
+
+	require.NoError(t, bm2.RewriteContent(ctx, oldID))
+	require.NoError(t, bm2.Flush(ctx))
+
+	n, err := bm2.ReencryptBlobs(ctx)
+	require.NoError(t, err)
+	require.NotZero(t, n)
+
+	// nothing left to re-encrypt.
+	n, err = bm2.ReencryptBlobs(ctx)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	fo.RetirePreviousMasterKeys()
+
+	bm3, err := NewManagerForTesting(ctx, st, fo, nil, opts)
+	require.NoError(t, err)
+
+	defer bm3.Close(ctx)
+
+	verifyContent(ctx, t, bm3, oldID, oldData)
+	verifyContent(ctx, t, bm3, newID, newData)
+
+	// rotated keys require format version 2, which clients that don't support key rotation refuse to open.
+	v1 := *fo
+	v1.Version = FormatVersion1
+	require.Error(t, v1.validateKeyGenerations())
+
+	// key rotation requires v2 index.
+	fo.IndexVersion = v1IndexVersion
+	require.Error(t, fo.validateKeyGenerations())
+	require.Error(t, fo.RotateMasterKey(fakeTime))
+}
+
+func (s *contentManagerSuite) TestMasterKeyRotationByAnotherClient(t *testing.T) {
+	data := blobtesting.DataMap{}
+	st := blobtesting.NewMapStorage(data, nil, nil)
+
+	ctx := testlogging.Context(t)
+
+	mp := s.mutableParameters
+	mp.IndexVersion = v2IndexVersion
+
+	fo := &FormattingOptions{
+		Hash:              "HMAC-SHA256",
+		Encryption:        "AES256-GCM-HMAC-SHA256",
+		HMACSecret:        hmacSecret,
+		MasterKey:         make([]byte, 32),
+		MutableParameters: mp,
+		Version:           1,
+	}
+
+	rotated := *fo
+	require.NoError(t, rotated.RotateMasterKey(fakeTime))
+
+	reloads := 0
+
+	// the client opened before the key rotation reloads the format when it encounters the new key.
+	bm, err := NewManagerForTesting(ctx, st, fo, nil, &ManagerOptions{
+		ReloadFormattingOptions: func() (*FormattingOptions, error) {
+			reloads++
+			return &rotated, nil
+		},
+	})
+	require.NoError(t, err)
+
+	defer bm.Close(ctx)
+
+	bm2, err := NewManagerForTesting(ctx, st, &rotated, nil, nil)
+	require.NoError(t, err)
+
+	defer bm2.Close(ctx)
+
+	newData := seededRandomData(11, 1000)
+
+	newID, err := bm2.WriteContent(ctx, newData, "", NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, bm2.Flush(ctx))
+
+	require.Zero(t, reloads)
+	require.NoError(t, bm.Refresh(ctx))
+	verifyContent(ctx, t, bm, newID, newData)
+	require.Equal(t, 1, reloads)
+
+	// after refreshing, the client writes new data using the rotated master key.
+	laterID, err := bm.WriteContent(ctx, seededRandomData(12, 1000), "", NoCompression)
+	require.NoError(t, err)
+	require.NoError(t, bm.Flush(ctx))
+
+	ci, err := bm.ContentInfo(ctx, laterID)
+	require.NoError(t, err)
+	require.Equal(t, byte(1), ci.GetEncryptionKeyID())
+
+	require.NoError(t, bm2.Refresh(ctx))
+	verifyContent(ctx, t, bm2, laterID, seededRandomData(12, 1000))
+
+	// BLOBs that can't be decrypted using any of the keys are not re-encrypted.
+	require.NoError(t, st.PutBlob(ctx, "n00000000000000000000000000000000", gather.FromSlice([]byte("garbage"))))
+
+	_, err = bm2.ReencryptBlobs(ctx)
+	require.Error(t, err)
+}
+
 func (s *contentManagerSuite) newTestContentManager(t *testing.T, st blob.Storage) *WriteManager {
 	t.Helper()
 
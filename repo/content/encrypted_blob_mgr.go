@@ -1,6 +1,7 @@
 package content
 
 import (
+	"bytes"
 	"context"
 
 	"github.com/pkg/errors"
@@ -23,7 +24,34 @@ func (m *encryptedBlobMgr) getEncryptedBlob(ctx context.Context, blobID blob.ID)
 		return nil, errors.Wrap(err, "getContent")
 	}
 
-	return m.crypter.DecryptBLOB(payload, blobID)
+	data, err := m.crypter.DecryptBLOB(payload, blobID)
+	if err == nil {
+		return data, nil
+	}
+
+	// the BLOB may have been re-encrypted in place after master key rotation, in which case
+	// the cached copy can't be decrypted once the previous master key is retired.
+	current, gerr := m.st.GetBlob(ctx, blobID, 0, -1)
+	if gerr != nil || bytes.Equal(current, payload) {
+		return nil, err
+	}
+
+	data, err = m.crypter.DecryptBLOB(current, blobID)
+	if err != nil {
+		return nil, err
+	}
+
+	m.log.Debugf("replacing stale cached blob %v", blobID)
+	m.replaceCachedBlob(ctx, blobID, current)
+
+	return data, nil
+}
+
+// replaceCachedBlob replaces the cached copy of the BLOB that has been rewritten in place.
+func (m *encryptedBlobMgr) replaceCachedBlob(ctx context.Context, blobID blob.ID, data []byte) {
+	if c, ok := m.indexBlobCache.(blobCacheReplacer); ok {
+		c.replaceBlob(ctx, blobID, data)
+	}
 }
 
 func (m *encryptedBlobMgr) encryptAndWriteBlob(ctx context.Context, data []byte, prefix blob.ID, sessionID SessionID) (blob.Metadata, error) {
@@ -54,7 +54,7 @@ func (e indexEntryInfoV1) GetTimestampSeconds() int64 {
 	return decodeBigEndianUint48(e.data)
 }
 
-// entry byte 6: format version (currently always == 1).
+// entry byte 6: format version (1 or 2).
 func (e indexEntryInfoV1) GetFormatVersion() byte {
 	return e.data[6]
 }
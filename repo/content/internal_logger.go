@@ -52,6 +52,9 @@ func (m *internalLogManager) encryptAndWriteLogBlob(prefix blob.ID, data []byte)
 	}()
 }
 
+// internalLogBlobPrefix is the prefix of BLOBs containing internal logs.
+const internalLogBlobPrefix blob.ID = "_log"
+
 // NewLogger creates new logger.
 func (m *internalLogManager) NewLogger() *internalLogger {
 	var rnd [2]byte
@@ -60,7 +63,7 @@ func (m *internalLogManager) NewLogger() *internalLogger {
 
 	return &internalLogger{
 		m:      m,
-		prefix: blob.ID(fmt.Sprintf("_log_%v_%x", clock.Now().Local().Format("20060102150405"), rnd)),
+		prefix: blob.ID(fmt.Sprintf("%v_%v_%x", internalLogBlobPrefix, clock.Now().Local().Format("20060102150405"), rnd)),
 	}
 }
 
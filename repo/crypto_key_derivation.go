@@ -4,6 +4,8 @@
 	"crypto/sha256"
 	"io"
 
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/hkdf"
 )
 
@@ -18,3 +20,115 @@ func deriveKeyFromMasterKey(masterKey, uniqueID, purpose []byte, length int) []b
 
 	return key
 }
+
+// Supported password-based key derivation algorithms.
+const (
+	KeyDerivationScrypt   = "scrypt-65536-8-1"
+	KeyDerivationArgon2id = "argon2id"
+)
+
+// Default Argon2id parameters.
+const (
+	DefaultArgon2Time    = 3
+	DefaultArgon2Memory  = 64 * 1024 // KiB
+	DefaultArgon2Threads = 4
+
+	minArgon2MemoryPerThread = 8 // KiB, as required by Argon2
+)
+
+// Maximum Argon2id parameters, which prevent format blobs from requiring excessive resources to open.
+const (
+	MaxArgon2Time   = DefaultArgon2Time * 16
+	MaxArgon2Memory = DefaultArgon2Memory * 16 // KiB
+)
+
+// Argon2Parameters specifies tunable parameters of the Argon2id key derivation.
+type Argon2Parameters struct {
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // in KiB
+	Threads uint8  `json:"threads"`
+}
+
+// DefaultArgon2Parameters returns default parameters of the Argon2id key derivation.
+func DefaultArgon2Parameters() Argon2Parameters {
+	return Argon2Parameters{
+		Time:    DefaultArgon2Time,
+		Memory:  DefaultArgon2Memory,
+		Threads: DefaultArgon2Threads,
+	}
+}
+
+// Validate validates Argon2id parameters.
+func (p *Argon2Parameters) Validate() error {
+	if p.Time < 1 || p.Time > MaxArgon2Time {
+		return errors.Errorf("argon2 time must be between 1 and %v", MaxArgon2Time)
+	}
+
+	if p.Threads < 1 {
+		return errors.Errorf("argon2 threads must be at least 1")
+	}
+
+	if p.Memory < minArgon2MemoryPerThread*uint32(p.Threads) {
+		return errors.Errorf("argon2 memory must be at least %v KiB per thread", minArgon2MemoryPerThread)
+	}
+
+	if p.Memory > MaxArgon2Memory {
+		return errors.Errorf("argon2 memory must not exceed %v KiB", MaxArgon2Memory)
+	}
+
+	return nil
+}
+
+// KeyDerivationOptions specifies how the repository format encryption key is derived from the password.
+type KeyDerivationOptions struct {
+	Algorithm string            `json:"algorithm,omitempty"`
+	Argon2    *Argon2Parameters `json:"argon2,omitempty"`
+}
+
+// SupportedKeyDerivationAlgorithms returns the names of supported password-based key derivation algorithms.
+func SupportedKeyDerivationAlgorithms() []string {
+	return []string{KeyDerivationScrypt, KeyDerivationArgon2id}
+}
+
+// applyKeyDerivationOptions sets the key derivation algorithm and parameters of the format blob.
+func (f *formatBlob) applyKeyDerivationOptions(kd KeyDerivationOptions) error {
+	switch kd.Algorithm {
+	case "":
+		f.KeyDerivationAlgorithm = defaultKeyDerivationAlgorithm
+		f.Argon2 = nil
+
+	case KeyDerivationArgon2id:
+		p := DefaultArgon2Parameters()
+		if kd.Argon2 != nil {
+			p = *kd.Argon2
+		}
+
+		if err := p.Validate(); err != nil {
+			return err
+		}
+
+		f.KeyDerivationAlgorithm = KeyDerivationArgon2id
+		f.Argon2 = &p
+
+	case KeyDerivationScrypt:
+		f.KeyDerivationAlgorithm = KeyDerivationScrypt
+		f.Argon2 = nil
+
+	default:
+		return errors.Errorf("unsupported key derivation algorithm: %v", kd.Algorithm)
+	}
+
+	return nil
+}
+
+func (f *formatBlob) deriveArgon2idKeyFromPassword(password string, keySize uint32) ([]byte, error) {
+	if f.Argon2 == nil {
+		return nil, errors.Errorf("missing argon2 parameters")
+	}
+
+	if err := f.Argon2.Validate(); err != nil {
+		return nil, err
+	}
+
+	return argon2.IDKey([]byte(password), f.UniqueID, f.Argon2.Time, f.Argon2.Memory, f.Argon2.Threads, keySize), nil
+}
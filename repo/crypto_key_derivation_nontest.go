@@ -8,16 +8,19 @@
 )
 
 // defaultKeyDerivationAlgorithm is the key derivation algorithm for new configurations.
-const defaultKeyDerivationAlgorithm = "scrypt-65536-8-1"
+const defaultKeyDerivationAlgorithm = KeyDerivationScrypt
 
 func (f *formatBlob) deriveFormatEncryptionKeyFromPassword(password string) ([]byte, error) {
 	const masterKeySize = 32
 
 	switch f.KeyDerivationAlgorithm {
-	case "scrypt-65536-8-1":
+	case KeyDerivationScrypt:
 		// nolint:wrapcheck,gomnd
 		return scrypt.Key([]byte(password), f.UniqueID, 65536, 8, 1, masterKeySize)
 
+	case KeyDerivationArgon2id:
+		return f.deriveArgon2idKeyFromPassword(password, masterKeySize)
+
 	default:
 		return nil, errors.Errorf("unsupported key algorithm: %v", f.KeyDerivationAlgorithm)
 	}
@@ -23,6 +23,9 @@ func (f *formatBlob) deriveFormatEncryptionKeyFromPassword(password string) ([]b
 
 		return h.Sum(nil), nil
 
+	case KeyDerivationArgon2id:
+		return f.deriveArgon2idKeyFromPassword(password, masterKeySize)
+
 	default:
 		return nil, errors.Errorf("unsupported key algorithm: %v", f.KeyDerivationAlgorithm)
 	}
@@ -0,0 +1,84 @@
+// Package ecc manages error correction codes applied to entire blobs when they are written to storage.
+package ecc
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ErrUncorrectable is returned when the data is damaged beyond what error correction codes can repair.
+var ErrUncorrectable = errors.New("data is damaged beyond repair")
+
+// ErrDamagedRange is returned when the range of data can't be decoded without repairing it.
+var ErrDamagedRange = errors.New("range of data is damaged")
+
+// Encoder adds error correction codes to blobs and uses them to repair damaged blobs.
+type Encoder interface {
+	// Encode appends the input protected with error correction codes to a given slice.
+	// Must not clobber the input slice.
+	Encode(output, input []byte) ([]byte, error)
+
+	// Decode appends the original data to a given slice, repairing it if necessary.
+	// Returns the number of damaged fragments that had to be repaired.
+	// Must not clobber the input slice.
+	Decode(output, input []byte) ([]byte, int, error)
+
+	// EncodedRange returns the range of the encoded data that holds the given range of the original data.
+	EncodedRange(offset, length int64) (encodedOffset, encodedLength int64)
+
+	// DecodeRange appends the given range of the original data to a given slice, given the encoded
+	// range returned by EncodedRange. Returns ErrDamagedRange if the range needs to be repaired,
+	// which requires decoding all encoded data.
+	DecodeRange(output, encoded []byte, offset, length int64) ([]byte, error)
+}
+
+// Parameters encapsulates all error correction parameters.
+type Parameters interface {
+	GetECCAlgorithm() string
+	GetECCOverheadPercent() int
+}
+
+// EncoderFactory creates new Encoder for given parameters.
+type EncoderFactory func(p Parameters) (Encoder, error)
+
+// DefaultAlgorithm is the name of the default error correction algorithm.
+const DefaultAlgorithm = "REED-SOLOMON-CRC32"
+
+// CreateEncoder creates an Encoder for given parameters.
+func CreateEncoder(p Parameters) (Encoder, error) {
+	e := encoders[p.GetECCAlgorithm()]
+	if e == nil {
+		return nil, errors.Errorf("unknown error correction algorithm: %v", p.GetECCAlgorithm())
+	}
+
+	return e.newEncoder(p)
+}
+
+// SupportedAlgorithms returns the names of the supported error correction algorithms.
+func SupportedAlgorithms() []string {
+	var result []string
+
+	for k := range encoders {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// Register registers new error correction algorithm.
+func Register(name, description string, newEncoder EncoderFactory) {
+	encoders[name] = &encoderInfo{
+		description,
+		newEncoder,
+	}
+}
+
+type encoderInfo struct {
+	description string
+	newEncoder  EncoderFactory
+}
+
+var encoders = map[string]*encoderInfo{}
@@ -0,0 +1,382 @@
+package ecc
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"sync"
+
+	"github.com/klauspost/reedsolomon"
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+)
+
+const (
+	// minimum and maximum supported overhead, the upper bound comes from the limit of 256 shards
+	// in GF(2^8) Reed-Solomon codes given the maximum number of data shards below.
+	minOverheadPercent = 1
+	maxOverheadPercent = 100
+
+	// each stripe holds up to maxDataShards shards of shardSize bytes of the original data.
+	maxDataShards = 128
+	shardSize     = 1024
+
+	// each shard is prefixed with the number of bytes of the original data it holds (zero for parity shards)
+	// and CRC32 of the above and the shard itself.
+	usedSize        = 2
+	crcSize         = 4
+	shardPrefixSize = usedSize + crcSize
+
+	// header contains number of data shards per stripe, overhead percentage, shard size,
+	// length of the original data and CRC32 of the above.
+	headerSize = 1 + 1 + 4 + 8 + crcSize
+
+	// header is small but critical for decoding so we store several copies of it.
+	headerCopies = 3
+)
+
+// reedSolomonCRC32 splits the input into stripes of equally-sized data shards and adds parity shards
+// computed using Reed-Solomon codes to each stripe. Each shard is stored with its CRC32 checksum,
+// which allows damaged shards to be identified and reconstructed from the remaining ones.
+//
+// The output is formatted as:
+//
+//	<header> x 3
+//	<stripe> x N
+//
+// Where each stripe is:
+//
+//	<used><crc32><shard> x (dataShards + parityShards)
+//
+// All stripes except the last one have the maximum number of data shards, so the location of any
+// byte of the original data can be computed without reading the header, which allows ranges of the
+// original data to be read and verified without reading the entire blob. The last stripe has only as
+// many data shards as needed, the last of which is zero-padded, and proportionally fewer parity shards.
+type reedSolomonCRC32 struct {
+	layout   layout
+	encoders sync.Map // map[shardCounts]reedsolomon.Encoder
+}
+
+type shardCounts struct {
+	data   int
+	parity int
+}
+
+// layout describes the sizes of stripes and shards.
+type layout struct {
+	dataShards      int
+	overheadPercent int
+	shardSize       int
+}
+
+// counts returns the number of data and parity shards of a stripe holding the given number of bytes.
+func (l layout) counts(length int) shardCounts {
+	data := (length + l.shardSize - 1) / l.shardSize
+	if data > l.dataShards {
+		data = l.dataShards
+	}
+
+	// nolint:gomnd
+	parity := (data*l.overheadPercent + 99) / 100
+	if parity < 1 {
+		parity = 1
+	}
+
+	return shardCounts{data, parity}
+}
+
+func (l layout) stripeDataSize() int64 {
+	return int64(l.dataShards * l.shardSize)
+}
+
+func (l layout) shardStride() int64 {
+	return int64(shardPrefixSize + l.shardSize)
+}
+
+// shardOffset returns the offset of the encoded shard holding the given offset of the original data.
+func (l layout) shardOffset(offset int64) int64 {
+	full := l.counts(l.dataShards * l.shardSize)
+	stripe := offset / l.stripeDataSize()
+	shard := offset % l.stripeDataSize() / int64(l.shardSize)
+
+	return headerCopies*headerSize + stripe*int64(full.data+full.parity)*l.shardStride() + shard*l.shardStride()
+}
+
+func (e *reedSolomonCRC32) encoderFor(counts shardCounts) (reedsolomon.Encoder, error) {
+	if v, ok := e.encoders.Load(counts); ok {
+		return v.(reedsolomon.Encoder), nil // nolint:forcetypeassert
+	}
+
+	enc, err := reedsolomon.New(counts.data, counts.parity)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create Reed-Solomon encoder")
+	}
+
+	v, _ := e.encoders.LoadOrStore(counts, enc)
+
+	return v.(reedsolomon.Encoder), nil // nolint:forcetypeassert
+}
+
+func (e *reedSolomonCRC32) Encode(output, input []byte) ([]byte, error) {
+	l := e.layout
+
+	var header [headerSize]byte
+
+	header[0] = byte(l.dataShards)
+	header[1] = byte(l.overheadPercent)
+	binary.BigEndian.PutUint32(header[2:6], uint32(l.shardSize))
+	binary.BigEndian.PutUint64(header[6:14], uint64(len(input)))
+	binary.BigEndian.PutUint32(header[14:18], crc32.ChecksumIEEE(header[0:14]))
+
+	for i := 0; i < headerCopies; i++ {
+		output = append(output, header[:]...)
+	}
+
+	for len(input) > 0 {
+		chunk := input
+		if int64(len(chunk)) > l.stripeDataSize() {
+			chunk = chunk[0:l.stripeDataSize()]
+		}
+
+		input = input[len(chunk):]
+
+		var err error
+
+		if output, err = e.encodeStripe(output, chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	return output, nil
+}
+
+func (e *reedSolomonCRC32) encodeStripe(output, chunk []byte) ([]byte, error) {
+	ss := e.layout.shardSize
+	counts := e.layout.counts(len(chunk))
+
+	enc, err := e.encoderFor(counts)
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, (counts.data+counts.parity)*ss)
+	copy(data, chunk)
+
+	shards := make([][]byte, counts.data+counts.parity)
+	for i := range shards {
+		shards[i] = data[i*ss : (i+1)*ss]
+	}
+
+	if err := enc.Encode(shards); err != nil {
+		return nil, errors.Wrap(err, "unable to compute parity shards")
+	}
+
+	var prefix [shardPrefixSize]byte
+
+	for i, s := range shards {
+		used := 0
+
+		if i < counts.data {
+			used = len(chunk) - i*ss
+			if used > ss {
+				used = ss
+			}
+		}
+
+		binary.BigEndian.PutUint16(prefix[0:usedSize], uint16(used))
+		binary.BigEndian.PutUint32(prefix[usedSize:], shardChecksum(prefix[0:usedSize], s))
+
+		output = append(output, prefix[:]...)
+		output = append(output, s...)
+	}
+
+	return output, nil
+}
+
+func shardChecksum(used, shard []byte) uint32 {
+	return crc32.Update(crc32.ChecksumIEEE(used), crc32.IEEETable, shard)
+}
+
+// verifyShard returns the shard data and the number of bytes of the original data it holds
+// or false if the shard is damaged.
+func verifyShard(chunk []byte) (shard []byte, used int, ok bool) {
+	prefix, shard := chunk[0:shardPrefixSize], chunk[shardPrefixSize:]
+
+	if shardChecksum(prefix[0:usedSize], shard) != binary.BigEndian.Uint32(prefix[usedSize:]) {
+		return nil, 0, false
+	}
+
+	return shard, int(binary.BigEndian.Uint16(prefix[0:usedSize])), true
+}
+
+// parseHeader returns the layout and length of the original data from the first undamaged copy of the header
+// along with the number of damaged copies.
+func parseHeader(input []byte) (l layout, length int64, damaged int, err error) {
+	if len(input) < headerCopies*headerSize {
+		return l, 0, 0, errors.Wrap(ErrUncorrectable, "data too short")
+	}
+
+	var valid []byte
+
+	for i := 0; i < headerCopies; i++ {
+		h := input[i*headerSize : (i+1)*headerSize]
+		if crc32.ChecksumIEEE(h[0:14]) != binary.BigEndian.Uint32(h[14:18]) {
+			damaged++
+			continue
+		}
+
+		if valid == nil {
+			valid = h
+		}
+	}
+
+	if valid == nil {
+		return l, 0, damaged, errors.Wrap(ErrUncorrectable, "all copies of error correction header are damaged")
+	}
+
+	l = layout{
+		dataShards:      int(valid[0]),
+		overheadPercent: int(valid[1]),
+		shardSize:       int(binary.BigEndian.Uint32(valid[2:6])),
+	}
+	length = int64(binary.BigEndian.Uint64(valid[6:14]))
+
+	if l.dataShards == 0 || l.overheadPercent < minOverheadPercent || l.overheadPercent > maxOverheadPercent || l.shardSize == 0 || length < 0 {
+		return l, 0, damaged, errors.Wrap(ErrUncorrectable, "invalid error correction header")
+	}
+
+	return l, length, damaged, nil
+}
+
+func (e *reedSolomonCRC32) Decode(output, input []byte) ([]byte, int, error) {
+	l, remaining, damaged, err := parseHeader(input)
+	if err != nil {
+		return nil, damaged, err
+	}
+
+	body := input[headerCopies*headerSize:]
+	stride := int(l.shardStride())
+
+	for remaining > 0 {
+		stripeLen := remaining
+		if stripeLen > l.stripeDataSize() {
+			stripeLen = l.stripeDataSize()
+		}
+
+		counts := l.counts(int(stripeLen))
+
+		stripeSize := (counts.data + counts.parity) * stride
+		if len(body) < stripeSize {
+			return nil, damaged, errors.Wrapf(ErrUncorrectable, "data truncated, expected %v more bytes, got %v", stripeSize, len(body))
+		}
+
+		shards := make([][]byte, counts.data+counts.parity)
+		stripeDamaged := 0
+
+		for i := range shards {
+			shard, _, ok := verifyShard(body[i*stride : (i+1)*stride : (i+1)*stride])
+			if !ok {
+				stripeDamaged++
+				continue
+			}
+
+			shards[i] = shard
+		}
+
+		damaged += stripeDamaged
+
+		if stripeDamaged > counts.parity {
+			return nil, damaged, errors.Wrapf(ErrUncorrectable, "%v shards of a stripe damaged, can repair at most %v", stripeDamaged, counts.parity)
+		}
+
+		if stripeDamaged > 0 {
+			enc, err := e.encoderFor(counts)
+			if err != nil {
+				return nil, damaged, err
+			}
+
+			if err := enc.ReconstructData(shards); err != nil {
+				return nil, damaged, errors.Wrap(err, "unable to reconstruct damaged shards")
+			}
+		}
+
+		for _, s := range shards[0:counts.data] {
+			n := int64(len(s))
+			if n > stripeLen {
+				n = stripeLen
+			}
+
+			output = append(output, s[0:n]...)
+			stripeLen -= n
+			remaining -= n
+		}
+
+		body = body[stripeSize:]
+	}
+
+	if len(body) != 0 {
+		return nil, damaged, errors.Wrapf(ErrUncorrectable, "unexpected %v bytes after the last stripe", len(body))
+	}
+
+	return output, damaged, nil
+}
+
+func (e *reedSolomonCRC32) EncodedRange(offset, length int64) (encodedOffset, encodedLength int64) {
+	start := e.layout.shardOffset(offset)
+	end := e.layout.shardOffset(offset+length-1) + e.layout.shardStride()
+
+	return start, end - start
+}
+
+func (e *reedSolomonCRC32) DecodeRange(output, encoded []byte, offset, length int64) ([]byte, error) {
+	l := e.layout
+	start := l.shardOffset(offset)
+
+	for length > 0 {
+		p := l.shardOffset(offset) - start
+		if p+l.shardStride() > int64(len(encoded)) {
+			return nil, errors.Errorf("encoded range too short")
+		}
+
+		shard, used, ok := verifyShard(encoded[p : p+l.shardStride()])
+		if !ok {
+			return nil, ErrDamagedRange
+		}
+
+		within := offset % l.stripeDataSize() % int64(l.shardSize)
+
+		n := int64(l.shardSize) - within
+		if n > length {
+			n = length
+		}
+
+		if within+n > int64(used) {
+			return nil, errors.Wrap(blob.ErrInvalidRange, "range extends past the end of data")
+		}
+
+		output = append(output, shard[within:within+n]...)
+		offset += n
+		length -= n
+	}
+
+	return output, nil
+}
+
+func newReedSolomonCRC32(p Parameters) (Encoder, error) {
+	pct := p.GetECCOverheadPercent()
+	if pct < minOverheadPercent || pct > maxOverheadPercent {
+		return nil, errors.Errorf("invalid error correction overhead %v%%, must be between %v%% and %v%%", pct, minOverheadPercent, maxOverheadPercent)
+	}
+
+	return &reedSolomonCRC32{
+		layout: layout{
+			dataShards:      maxDataShards,
+			overheadPercent: pct,
+			shardSize:       shardSize,
+		},
+	}, nil
+}
+
+func init() {
+	Register(DefaultAlgorithm, "Reed-Solomon codes with CRC32-protected shards", newReedSolomonCRC32)
+}
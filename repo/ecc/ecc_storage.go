@@ -0,0 +1,224 @@
+package ecc
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/logging"
+)
+
+var log = logging.GetContextLoggerFunc("ecc")
+
+// Storage protects blobs written to the underlying storage with error correction codes and repairs
+// damaged blobs when they are read. Blobs with IDs starting with the unprotected prefix are passed through
+// unchanged, which is needed for blobs that must be readable before the error correction parameters are known.
+//
+// Ranges of blobs are read without reading entire blobs, unless they are damaged.
+// Repaired blobs are not rewritten, which is left to the user once they are reported.
+type Storage struct {
+	base              blob.Storage
+	enc               Encoder
+	unprotectedPrefix blob.ID
+
+	mu       sync.Mutex
+	repaired map[blob.ID]bool // guarded by mu
+}
+
+func (s *Storage) isProtected(id blob.ID) bool {
+	return s.unprotectedPrefix == "" || !strings.HasPrefix(string(id), string(s.unprotectedPrefix))
+}
+
+// GetBlob implements blob.Storage.
+func (s *Storage) GetBlob(ctx context.Context, id blob.ID, offset, length int64) ([]byte, error) {
+	if !s.isProtected(id) {
+		// nolint:wrapcheck
+		return s.base.GetBlob(ctx, id, offset, length)
+	}
+
+	if offset < 0 {
+		return nil, errors.Wrapf(blob.ErrInvalidRange, "invalid offset: %v", offset)
+	}
+
+	if length < 0 {
+		return s.getAndRepair(ctx, id)
+	}
+
+	if length == 0 {
+		if _, err := s.base.GetMetadata(ctx, id); err != nil {
+			// nolint:wrapcheck
+			return nil, err
+		}
+
+		return []byte{}, nil
+	}
+
+	encodedOffset, encodedLength := s.enc.EncodedRange(offset, length)
+
+	encoded, err := s.base.GetBlob(ctx, id, encodedOffset, encodedLength)
+	if err != nil {
+		// nolint:wrapcheck
+		return nil, err
+	}
+
+	result, err := s.enc.DecodeRange(nil, encoded, offset, length)
+	if !errors.Is(err, ErrDamagedRange) {
+		return result, errors.Wrapf(err, "error decoding blob %v", id)
+	}
+
+	// repairing the range requires the entire blob.
+	data, err := s.getAndRepair(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset+length > int64(len(data)) {
+		return nil, errors.Wrapf(blob.ErrInvalidRange, "invalid range [%v,%v) of blob of length %v", offset, offset+length, len(data))
+	}
+
+	return data[offset : offset+length], nil
+}
+
+func (s *Storage) getAndRepair(ctx context.Context, id blob.ID) ([]byte, error) {
+	encoded, err := s.base.GetBlob(ctx, id, 0, -1)
+	if err != nil {
+		// nolint:wrapcheck
+		return nil, err
+	}
+
+	result, damaged, err := s.enc.Decode(nil, encoded)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error correction failed for blob %v", id)
+	}
+
+	if damaged > 0 {
+		log(ctx).Infof("repaired %v damaged fragments of blob %v using error correction codes", damaged, id)
+
+		s.mu.Lock()
+		s.repaired[id] = true
+		s.mu.Unlock()
+	}
+
+	return result, nil
+}
+
+// RepairedBlobs returns the sorted IDs of blobs that have been repaired since the storage was created.
+func (s *Storage) RepairedBlobs() []blob.ID {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var result []blob.ID
+
+	for id := range s.repaired {
+		result = append(result, id)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+
+	return result
+}
+
+// PutBlob implements blob.Storage.
+func (s *Storage) PutBlob(ctx context.Context, id blob.ID, data blob.Bytes) error {
+	if !s.isProtected(id) {
+		// nolint:wrapcheck
+		return s.base.PutBlob(ctx, id, data)
+	}
+
+	var b bytes.Buffer
+
+	if _, err := data.WriteTo(&b); err != nil {
+		return errors.Wrap(err, "error reading blob data")
+	}
+
+	encoded, err := s.enc.Encode(nil, b.Bytes())
+	if err != nil {
+		return errors.Wrapf(err, "error encoding blob %v", id)
+	}
+
+	// nolint:wrapcheck
+	return s.base.PutBlob(ctx, id, gather.FromSlice(encoded))
+}
+
+// GetMetadata implements blob.Storage.
+func (s *Storage) GetMetadata(ctx context.Context, id blob.ID) (blob.Metadata, error) {
+	// nolint:wrapcheck
+	return s.base.GetMetadata(ctx, id)
+}
+
+// SetTime implements blob.Storage.
+func (s *Storage) SetTime(ctx context.Context, id blob.ID, t time.Time) error {
+	// nolint:wrapcheck
+	return s.base.SetTime(ctx, id, t)
+}
+
+// DeleteBlob implements blob.Storage.
+func (s *Storage) DeleteBlob(ctx context.Context, id blob.ID) error {
+	// nolint:wrapcheck
+	return s.base.DeleteBlob(ctx, id)
+}
+
+// ExtendBlobRetention implements blob.RetentionExtender.
+func (s *Storage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	// nolint:wrapcheck
+	return blob.ExtendRetention(ctx, s.base, id, minRetainUntil)
+}
+
+// BlobRetentionPeriod implements blob.RetentionExtender.
+func (s *Storage) BlobRetentionPeriod() time.Duration {
+	return blob.RetentionPeriod(s.base)
+}
+
+// BlobRetainedUntil implements blob.RetentionExtender.
+func (s *Storage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	// nolint:wrapcheck
+	return blob.RetainedUntil(ctx, s.base, id)
+}
+
+// ListBlobs implements blob.Storage. Reported lengths include error correction codes.
+func (s *Storage) ListBlobs(ctx context.Context, prefix blob.ID, callback func(blob.Metadata) error) error {
+	// nolint:wrapcheck
+	return s.base.ListBlobs(ctx, prefix, callback)
+}
+
+// Close implements blob.Storage.
+func (s *Storage) Close(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.Close(ctx)
+}
+
+// ConnectionInfo implements blob.Storage.
+func (s *Storage) ConnectionInfo() blob.ConnectionInfo {
+	return s.base.ConnectionInfo()
+}
+
+// DisplayName implements blob.Storage.
+func (s *Storage) DisplayName() string {
+	return s.base.DisplayName()
+}
+
+// FlushCaches implements blob.Storage.
+func (s *Storage) FlushCaches(ctx context.Context) error {
+	// nolint:wrapcheck
+	return s.base.FlushCaches(ctx)
+}
+
+// NewWrapper returns a Storage wrapper that protects blobs written to the underlying storage using
+// the provided Encoder, except for blobs with IDs starting with the unprotected prefix.
+func NewWrapper(wrapped blob.Storage, enc Encoder, unprotectedPrefix blob.ID) *Storage {
+	return &Storage{
+		base:              wrapped,
+		enc:               enc,
+		unprotectedPrefix: unprotectedPrefix,
+		repaired:          map[blob.ID]bool{},
+	}
+}
@@ -0,0 +1,85 @@
+package ecc_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/gather"
+	"github.com/kopia/kopia/internal/testlogging"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/ecc"
+)
+
+func newTestStorage(t *testing.T, data blobtesting.DataMap) (*ecc.Storage, ecc.Encoder) {
+	t.Helper()
+
+	e, err := ecc.CreateEncoder(parameters{ecc.DefaultAlgorithm, 10})
+	require.NoError(t, err)
+
+	return ecc.NewWrapper(blobtesting.NewMapStorage(data, map[blob.ID]time.Time{}, nil), e, "kopia."), e
+}
+
+func TestStorage(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st, _ := newTestStorage(t, data)
+
+	blobtesting.VerifyStorage(ctx, t, st)
+
+	// blobs are stored with error correction codes, except for unprotected ones.
+	require.Greater(t, len(data["abgc3dca496d510f492c858a2df1eb824e62"]), 10000)
+	require.Equal(t, bytes.Repeat([]byte{2}, 100), data["kopia.repository"])
+	require.Empty(t, st.RepairedBlobs())
+}
+
+func TestStorageRepair(t *testing.T) {
+	ctx := testlogging.Context(t)
+	data := blobtesting.DataMap{}
+	st, e := newTestStorage(t, data)
+
+	payload := make([]byte, 1<<20)
+	rand.Read(payload)
+
+	require.NoError(t, st.PutBlob(ctx, "pack1", gather.FromSlice(payload)))
+	require.NoError(t, st.PutBlob(ctx, "pack2", gather.FromSlice(payload)))
+
+	// damage a byte of the shard holding the middle of pack1.
+	o, _ := e.EncodedRange(1<<19, 1)
+	data["pack1"][o+100] ^= 0xff
+
+	// ranges not affected by the damage are read without repairing the blob.
+	b, err := st.GetBlob(ctx, "pack1", 1000, 5000)
+	require.NoError(t, err)
+	require.Equal(t, payload[1000:6000], b)
+	require.Empty(t, st.RepairedBlobs())
+
+	// the damaged range is repaired.
+	b, err = st.GetBlob(ctx, "pack1", 1<<19-10000, 20000)
+	require.NoError(t, err)
+	require.Equal(t, payload[1<<19-10000:1<<19+10000], b)
+	require.Equal(t, []blob.ID{"pack1"}, st.RepairedBlobs())
+
+	b, err = st.GetBlob(ctx, "pack1", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, payload, b)
+
+	// damage beyond the capacity of error correction codes.
+	for i := range data["pack2"] {
+		if i%100 == 0 {
+			data["pack2"][i] ^= 0xff
+		}
+	}
+
+	_, err = st.GetBlob(ctx, "pack2", 0, -1)
+	require.ErrorIs(t, err, ecc.ErrUncorrectable)
+
+	_, err = st.GetBlob(ctx, "pack2", 1000, 100)
+	require.ErrorIs(t, err, ecc.ErrUncorrectable)
+
+	require.Equal(t, []blob.ID{"pack1"}, st.RepairedBlobs())
+}
@@ -0,0 +1,208 @@
+package ecc_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/ecc"
+)
+
+type parameters struct {
+	algorithm       string
+	overheadPercent int
+}
+
+func (p parameters) GetECCAlgorithm() string    { return p.algorithm }
+func (p parameters) GetECCOverheadPercent() int { return p.overheadPercent }
+
+func TestRoundTrip(t *testing.T) {
+	for _, algo := range ecc.SupportedAlgorithms() {
+		for _, pct := range []int{1, 10, 50, 100} {
+			for _, size := range []int{0, 1, 1000, 1024, 1025, 128 << 10, 128<<10 + 1, 4<<20 + 17} {
+				algo, pct, size := algo, pct, size
+
+				t.Run(fmt.Sprintf("%v-%v-%v", algo, pct, size), func(t *testing.T) {
+					e, err := ecc.CreateEncoder(parameters{algo, pct})
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					data := make([]byte, size)
+					rand.Read(data)
+
+					encoded, err := e.Encode([]byte("prefix"), data)
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if !bytes.HasPrefix(encoded, []byte("prefix")) {
+						t.Fatalf("Encode() did not preserve output prefix")
+					}
+
+					decoded, repaired, err := e.Decode([]byte("prefix"), encoded[6:])
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					if repaired != 0 {
+						t.Errorf("unexpected repairs: %v", repaired)
+					}
+
+					if !bytes.Equal(decoded[6:], data) || !bytes.HasPrefix(decoded, []byte("prefix")) {
+						t.Errorf("invalid decoded data")
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestOverhead(t *testing.T) {
+	cases := []struct {
+		pct         int
+		size        int
+		maxOverhead float64
+	}{
+		// short inputs need at least one data and one parity shard.
+		{10, 1000, 1.2},
+		{10, 64 << 10, 0.2},
+		{10, 1 << 20, 0.12},
+		{50, 64 << 10, 0.6},
+		{1, 1 << 20, 0.03},
+	}
+
+	for _, tc := range cases {
+		e, err := ecc.CreateEncoder(parameters{ecc.DefaultAlgorithm, tc.pct})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		encoded, err := e.Encode(nil, make([]byte, tc.size))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := float64(len(encoded)-tc.size) / float64(tc.size); got > tc.maxOverhead {
+			t.Errorf("overhead of %v bytes at %v%% is %.3f, want at most %v", tc.size, tc.pct, got, tc.maxOverhead)
+		}
+	}
+}
+
+func TestRepair(t *testing.T) {
+	e, err := ecc.CreateEncoder(parameters{ecc.DefaultAlgorithm, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+
+	encoded, err := e.Encode(nil, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flip one bit in the first header copy and a byte in the middle of the data.
+	damaged := append([]byte(nil), encoded...)
+	damaged[0] ^= 1
+	damaged[len(damaged)/2] ^= 0xff
+
+	decoded, repaired, err := e.Decode(nil, damaged)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the damaged header copy and the damaged shard.
+	if repaired != 2 {
+		t.Errorf("unexpected number of repaired fragments: %v", repaired)
+	}
+
+	if !bytes.Equal(decoded, data) {
+		t.Errorf("invalid repaired data")
+	}
+
+	// damage every byte in the second half, which exceeds the number of parity shards.
+	for i := len(damaged) / 2; i < len(damaged); i++ {
+		damaged[i] ^= 0xff
+	}
+
+	if _, _, err := e.Decode(nil, damaged); !errors.Is(err, ecc.ErrUncorrectable) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDecodeRange(t *testing.T) {
+	e, err := ecc.CreateEncoder(parameters{ecc.DefaultAlgorithm, 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := make([]byte, 300<<10+17)
+	rand.Read(data)
+
+	encoded, err := e.Encode(nil, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decodeRange := func(encoded []byte, offset, length int64) ([]byte, error) {
+		encodedOffset, encodedLength := e.EncodedRange(offset, length)
+		if encodedOffset+encodedLength > int64(len(encoded)) {
+			return nil, blob.ErrInvalidRange
+		}
+
+		return e.DecodeRange(nil, encoded[encodedOffset:encodedOffset+encodedLength], offset, length)
+	}
+
+	n := int64(len(data))
+
+	for _, r := range [][2]int64{
+		{0, 1}, {0, n}, {1023, 2}, {1024, 1024}, {128<<10 - 1, 2}, {128 << 10, 1}, {200 << 10, 100 << 10}, {n - 1, 1}, {n - 17, 17},
+	} {
+		got, err := decodeRange(encoded, r[0], r[1])
+		if err != nil {
+			t.Fatalf("DecodeRange(%v,%v): %v", r[0], r[1], err)
+		}
+
+		if !bytes.Equal(got, data[r[0]:r[0]+r[1]]) {
+			t.Errorf("invalid data returned by DecodeRange(%v,%v)", r[0], r[1])
+		}
+	}
+
+	// ranges extending past the end of data are invalid, even if they are within the padding of the last shard.
+	for _, r := range [][2]int64{{n - 1, 2}, {n, 1}, {n + 1, 3}} {
+		if _, err := decodeRange(encoded, r[0], r[1]); !errors.Is(err, blob.ErrInvalidRange) {
+			t.Errorf("unexpected error from DecodeRange(%v,%v): %v", r[0], r[1], err)
+		}
+	}
+
+	// damage a byte in the second shard.
+	damaged := append([]byte(nil), encoded...)
+	o, _ := e.EncodedRange(1024, 1)
+	damaged[o+100] ^= 0xff
+
+	if _, err := decodeRange(damaged, 0, 2048); !errors.Is(err, ecc.ErrDamagedRange) {
+		t.Errorf("unexpected error from DecodeRange() of damaged range: %v", err)
+	}
+
+	// other ranges are unaffected.
+	if _, err := decodeRange(damaged, 2048, 1000); err != nil {
+		t.Errorf("unexpected error from DecodeRange() of undamaged range: %v", err)
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	if _, err := ecc.CreateEncoder(parameters{"no-such-algorithm", 10}); err == nil {
+		t.Errorf("expected error")
+	}
+
+	for _, pct := range []int{-1, 0, 101} {
+		if _, err := ecc.CreateEncoder(parameters{ecc.DefaultAlgorithm, pct}); err == nil {
+			t.Errorf("expected error for %v%%", pct)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package encryption
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultPublicKeyAlgorithm is the name of the default public-key encryption algorithm.
+const DefaultPublicKeyAlgorithm = "X25519-AES256-GCM"
+
+// ErrPrivateKeyUnavailable is returned when decrypting data encrypted with a public key without the private key.
+var ErrPrivateKeyUnavailable = errors.New("private key is required to decrypt data encrypted with public key")
+
+// PublicKeyParameters encapsulates public-key encryption parameters.
+type PublicKeyParameters interface {
+	GetPublicKeyEncryptionAlgorithm() string
+	GetPublicKey() []byte
+}
+
+// PublicKeyEncryptorFactory creates new Encryptor for given public key and optional private key.
+// Encryptors created without the private key can only encrypt.
+type PublicKeyEncryptorFactory func(publicKey, privateKey []byte) (Encryptor, error)
+
+// KeyPairGenerator generates new public and private key pair.
+type KeyPairGenerator func() (publicKey, privateKey []byte, err error)
+
+// PublicKeyDeriver computes the public key corresponding to the provided private key.
+type PublicKeyDeriver func(privateKey []byte) (publicKey []byte, err error)
+
+// CreatePublicKeyEncryptor creates an Encryptor for given public-key parameters and optional private key.
+func CreatePublicKeyEncryptor(p PublicKeyParameters, privateKey []byte) (Encryptor, error) {
+	e := publicKeyEncryptors[p.GetPublicKeyEncryptionAlgorithm()]
+	if e == nil {
+		return nil, errors.Errorf("unknown public-key encryption algorithm: %v", p.GetPublicKeyEncryptionAlgorithm())
+	}
+
+	return e.newEncryptor(p.GetPublicKey(), privateKey)
+}
+
+// GenerateKeyPair generates new public and private key pair for the provided algorithm.
+func GenerateKeyPair(algorithm string) (publicKey, privateKey []byte, err error) {
+	e := publicKeyEncryptors[algorithm]
+	if e == nil {
+		return nil, nil, errors.Errorf("unknown public-key encryption algorithm: %v", algorithm)
+	}
+
+	return e.generateKeyPair()
+}
+
+// DerivePublicKey returns the public key corresponding to the provided private key, which allows
+// key pairs to be derived deterministically from secret key material.
+func DerivePublicKey(algorithm string, privateKey []byte) ([]byte, error) {
+	e := publicKeyEncryptors[algorithm]
+	if e == nil {
+		return nil, errors.Errorf("unknown public-key encryption algorithm: %v", algorithm)
+	}
+
+	return e.derivePublicKey(privateKey)
+}
+
+// SupportedPublicKeyAlgorithms returns the names of the supported public-key encryption methods.
+func SupportedPublicKeyAlgorithms() []string {
+	var result []string
+
+	for k := range publicKeyEncryptors {
+		result = append(result, k)
+	}
+
+	sort.Strings(result)
+
+	return result
+}
+
+// RegisterPublicKey registers new public-key encryption algorithm.
+func RegisterPublicKey(name, description string, newEncryptor PublicKeyEncryptorFactory, generateKeyPair KeyPairGenerator, derivePublicKey PublicKeyDeriver) {
+	publicKeyEncryptors[name] = &publicKeyEncryptorInfo{
+		description,
+		newEncryptor,
+		generateKeyPair,
+		derivePublicKey,
+	}
+}
+
+type publicKeyEncryptorInfo struct {
+	description     string
+	newEncryptor    PublicKeyEncryptorFactory
+	generateKeyPair KeyPairGenerator
+	derivePublicKey PublicKeyDeriver
+}
+
+var publicKeyEncryptors = map[string]*publicKeyEncryptorInfo{}
@@ -0,0 +1,92 @@
+package encryption_test
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/repo/encryption"
+)
+
+type publicKeyParameters struct {
+	algorithm string
+	publicKey []byte
+}
+
+func (p publicKeyParameters) GetPublicKeyEncryptionAlgorithm() string { return p.algorithm }
+func (p publicKeyParameters) GetPublicKey() []byte                    { return p.publicKey }
+
+func TestPublicKeyRoundTrip(t *testing.T) {
+	data := make([]byte, 100)
+	rand.Read(data)
+
+	contentID := make([]byte, 16)
+	rand.Read(contentID)
+
+	for _, algo := range encryption.SupportedPublicKeyAlgorithms() {
+		algo := algo
+		t.Run(algo, func(t *testing.T) {
+			publicKey, privateKey, err := encryption.GenerateKeyPair(algo)
+			require.NoError(t, err)
+
+			derivedPublicKey, err := encryption.DerivePublicKey(algo, privateKey)
+			require.NoError(t, err)
+			require.Equal(t, publicKey, derivedPublicKey)
+
+			p := publicKeyParameters{algo, publicKey}
+
+			writeOnly, err := encryption.CreatePublicKeyEncryptor(p, nil)
+			require.NoError(t, err)
+
+			full, err := encryption.CreatePublicKeyEncryptor(p, privateKey)
+			require.NoError(t, err)
+
+			cipherText, err := writeOnly.Encrypt(nil, data, contentID)
+			require.NoError(t, err)
+			require.Len(t, cipherText, len(data)+writeOnly.Overhead())
+
+			cipherText2, err := writeOnly.Encrypt(nil, data, contentID)
+			require.NoError(t, err)
+			require.NotEqual(t, cipherText, cipherText2)
+
+			_, err = writeOnly.Decrypt(nil, cipherText, contentID)
+			require.ErrorIs(t, err, encryption.ErrPrivateKeyUnavailable)
+
+			plainText, err := full.Decrypt(nil, cipherText, contentID)
+			require.NoError(t, err)
+			require.Equal(t, data, plainText)
+
+			// output is appended to the provided slice.
+			prefix := []byte("prefix")
+
+			prefixed, err := writeOnly.Encrypt(append([]byte(nil), prefix...), data, contentID)
+			require.NoError(t, err)
+			require.Equal(t, prefix, prefixed[:len(prefix)])
+
+			plainText, err = full.Decrypt(append([]byte(nil), prefix...), prefixed[len(prefix):], contentID)
+			require.NoError(t, err)
+			require.Equal(t, append(append([]byte(nil), prefix...), data...), plainText)
+
+			// content ID is authenticated.
+			_, err = full.Decrypt(nil, cipherText, make([]byte, 16))
+			require.Error(t, err)
+
+			// private key must match.
+			_, otherPrivateKey, err := encryption.GenerateKeyPair(algo)
+			require.NoError(t, err)
+
+			_, err = encryption.CreatePublicKeyEncryptor(p, otherPrivateKey)
+			require.Error(t, err)
+		})
+	}
+
+	_, _, err := encryption.GenerateKeyPair("no-such-algorithm")
+	require.Error(t, err)
+
+	_, err = encryption.CreatePublicKeyEncryptor(publicKeyParameters{"no-such-algorithm", nil}, nil)
+	require.Error(t, err)
+
+	_, err = encryption.DerivePublicKey("no-such-algorithm", nil)
+	require.Error(t, err)
+}
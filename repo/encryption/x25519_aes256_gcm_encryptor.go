@@ -0,0 +1,161 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	x25519KeySize = curve25519.ScalarSize
+
+	// ephemeral public key followed by GCM tag, the nonce is not stored since each key is only used once.
+	x25519AES256GCMOverhead = x25519KeySize + 16
+
+	purposeX25519EncryptionKey = "x25519-aes256-gcm"
+)
+
+// x25519AES256GCM encrypts each content using a key agreed between a random ephemeral key
+// and the recipient public key, so that only the holder of the private key can decrypt it.
+type x25519AES256GCM struct {
+	publicKey  []byte
+	privateKey []byte
+}
+
+// aeadForKey returns cipher.AEAD using the key derived from the shared secret of the key agreement.
+func (e x25519AES256GCM) aeadForKey(sharedSecret, ephemeralPublicKey []byte) (cipher.AEAD, error) {
+	salt := append(append([]byte(nil), ephemeralPublicKey...), e.publicKey...)
+
+	key := make([]byte, aes256KeyDerivationSecretSize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, sharedSecret, salt, []byte(purposeX25519EncryptionKey)), key); err != nil {
+		return nil, errors.Wrap(err, "unable to derive encryption key")
+	}
+
+	c, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create AES-256 cipher")
+	}
+
+	// nolint:wrapcheck
+	return cipher.NewGCM(c)
+}
+
+func (e x25519AES256GCM) Encrypt(output, input, contentID []byte) ([]byte, error) {
+	ephemeralPrivateKey := make([]byte, x25519KeySize)
+	if _, err := io.ReadFull(rand.Reader, ephemeralPrivateKey); err != nil {
+		return nil, errors.Wrap(err, "unable to generate ephemeral key")
+	}
+
+	ephemeralPublicKey, err := curve25519.X25519(ephemeralPrivateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compute ephemeral public key")
+	}
+
+	sharedSecret, err := curve25519.X25519(ephemeralPrivateKey, e.publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "key agreement failed")
+	}
+
+	a, err := e.aeadForKey(sharedSecret, ephemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	output = append(output, ephemeralPublicKey...)
+
+	return a.Seal(output, make([]byte, a.NonceSize()), input, contentID), nil
+}
+
+func (e x25519AES256GCM) Decrypt(output, input, contentID []byte) ([]byte, error) {
+	if e.privateKey == nil {
+		return nil, ErrPrivateKeyUnavailable
+	}
+
+	if len(input) < x25519AES256GCMOverhead {
+		return nil, errors.Errorf("ciphertext too short")
+	}
+
+	ephemeralPublicKey := input[0:x25519KeySize]
+
+	sharedSecret, err := curve25519.X25519(e.privateKey, ephemeralPublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "key agreement failed")
+	}
+
+	a, err := e.aeadForKey(sharedSecret, ephemeralPublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	v, err := a.Open(output, make([]byte, a.NonceSize()), input[x25519KeySize:], contentID)
+	if err != nil {
+		return nil, errors.Errorf("unable to decrypt content")
+	}
+
+	return v, nil
+}
+
+func (e x25519AES256GCM) Overhead() int {
+	return x25519AES256GCMOverhead
+}
+
+func newX25519AES256GCM(publicKey, privateKey []byte) (Encryptor, error) {
+	if len(publicKey) != x25519KeySize {
+		return nil, errors.Errorf("invalid public key length: %v, expected %v", len(publicKey), x25519KeySize)
+	}
+
+	if privateKey != nil {
+		if len(privateKey) != x25519KeySize {
+			return nil, errors.Errorf("invalid private key length: %v, expected %v", len(privateKey), x25519KeySize)
+		}
+
+		pub, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid private key")
+		}
+
+		if !bytes.Equal(pub, publicKey) {
+			return nil, errors.Errorf("private key does not match the public key")
+		}
+	}
+
+	return x25519AES256GCM{publicKey, privateKey}, nil
+}
+
+func generateX25519KeyPair() (publicKey, privateKey []byte, err error) {
+	privateKey = make([]byte, x25519KeySize)
+	if _, err = io.ReadFull(rand.Reader, privateKey); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to generate private key")
+	}
+
+	publicKey, err = deriveX25519PublicKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return publicKey, privateKey, nil
+}
+
+func deriveX25519PublicKey(privateKey []byte) ([]byte, error) {
+	if len(privateKey) != x25519KeySize {
+		return nil, errors.Errorf("invalid private key length: %v, expected %v", len(privateKey), x25519KeySize)
+	}
+
+	publicKey, err := curve25519.X25519(privateKey, curve25519.Basepoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to compute public key")
+	}
+
+	return publicKey, nil
+}
+
+func init() {
+	RegisterPublicKey(DefaultPublicKeyAlgorithm, "X25519 key agreement with ephemeral keys and AES-256-GCM", newX25519AES256GCM, generateX25519KeyPair, deriveX25519PublicKey)
+}
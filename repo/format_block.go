@@ -9,6 +9,7 @@
 	"crypto/sha256"
 	"encoding/json"
 	"io"
+	"strconv"
 
 	"github.com/pkg/errors"
 
@@ -33,6 +34,19 @@
 // FormatBlobID is the identifier of a BLOB that describes repository format.
 const FormatBlobID = "kopia.repository"
 
+// unprotectedBlobIDPrefix is the prefix of repository-level BLOBs, such as the format BLOB, which
+// are not protected with error correction codes.
+const unprotectedBlobIDPrefix = "kopia."
+
+const (
+	// formatBlobVersionDefault is the version of format blobs with format encryption key derived from the password.
+	formatBlobVersionDefault = "1"
+
+	// formatBlobVersionKeySlots is the version of format blobs with format encryption key stored in key slots.
+	// Clients released before key slots don't check the version and are stopped by keySlotsKeyDerivationAlgorithm.
+	formatBlobVersionKeySlots = "2"
+)
+
 var (
 	purposeAESKey   = []byte("AES")
 	purposeAuthData = []byte("CHECKSUM")
@@ -45,13 +59,19 @@ type formatBlob struct {
 	BuildVersion string `json:"buildVersion"`
 	BuildInfo    string `json:"buildInfo"`
 
-	UniqueID               []byte `json:"uniqueID"`
-	KeyDerivationAlgorithm string `json:"keyAlgo"`
+	UniqueID               []byte            `json:"uniqueID"`
+	KeyDerivationAlgorithm string            `json:"keyAlgo"`
+	Argon2                 *Argon2Parameters `json:"argon2,omitempty"`
 
 	Version              string                  `json:"version"`
 	EncryptionAlgorithm  string                  `json:"encryption"`
 	EncryptedFormatBytes []byte                  `json:"encryptedBlockFormat,omitempty"`
 	UnencryptedFormat    *repositoryObjectFormat `json:"blockFormat,omitempty"`
+
+	// EncryptedWriteOnlyFormatBytes is the repository config without the master keys, which is unlocked by write-only key slots.
+	EncryptedWriteOnlyFormatBytes []byte `json:"encryptedWriteOnlyFormat,omitempty"`
+
+	KeySlots []*keySlot `json:"keySlots,omitempty"`
 }
 
 // encryptedRepositoryConfig contains the configuration of repository that's persisted in encrypted format.
@@ -66,7 +86,30 @@ func parseFormatBlob(b []byte) (*formatBlob, error) {
 		return nil, errors.Wrap(err, "invalid format blob")
 	}
 
-	return f, nil
+	switch f.Version {
+	case formatBlobVersionDefault, formatBlobVersionKeySlots:
+		return f, nil
+
+	default:
+		return nil, errors.Errorf("unsupported repository format version %q, upgrade to a newer version of Kopia", f.Version)
+	}
+}
+
+// clone returns a copy of the format blob that can be modified without affecting the original.
+func (f *formatBlob) clone() *formatBlob {
+	c := *f
+	c.KeySlots = append([]*keySlot(nil), f.KeySlots...)
+
+	return &c
+}
+
+// upgradeVersion sets the format version to the provided one, unless the format blob already has a newer version.
+func (f *formatBlob) upgradeVersion(v string) {
+	current, _ := strconv.Atoi(f.Version)
+
+	if nv, _ := strconv.Atoi(v); nv > current {
+		f.Version = v
+	}
 }
 
 // RecoverFormatBlob attempts to recover format blob replica from the specified file.
@@ -178,34 +221,52 @@ func (f *formatBlob) decryptFormatBytes(masterKey []byte) (*repositoryObjectForm
 		return f.UnencryptedFormat, nil
 
 	case "AES256_GCM":
-		aead, authData, err := initCrypto(masterKey, f.UniqueID)
-		if err != nil {
-			return nil, errors.Wrap(err, "cannot initialize cipher")
-		}
+		return openFormatBytes(f.EncryptedFormatBytes, masterKey, f.UniqueID)
 
-		content := append([]byte(nil), f.EncryptedFormatBytes...)
-		if len(content) < aead.NonceSize() {
-			return nil, errors.Errorf("invalid encrypted payload, too short")
-		}
+	default:
+		return nil, errors.Errorf("unknown encryption algorithm: '%v'", f.EncryptionAlgorithm)
+	}
+}
 
-		nonce := content[0:aead.NonceSize()]
-		payload := content[aead.NonceSize():]
+// decryptFormatBytesForKeySlot decrypts the repository config available to the provided key slot.
+// Write-only key slots only unlock the copy of the config without the master keys.
+func (f *formatBlob) decryptFormatBytesForKeySlot(formatKey []byte, keySlotID string) (*repositoryObjectFormat, error) {
+	if !f.isWriteOnlyKeySlot(keySlotID) {
+		return f.decryptFormatBytes(formatKey)
+	}
 
-		plainText, err := aead.Open(payload[:0], nonce, payload, authData)
-		if err != nil {
-			return nil, errors.Errorf("unable to decrypt repository format, invalid credentials?")
-		}
+	if len(f.EncryptedWriteOnlyFormatBytes) == 0 {
+		return nil, errors.Errorf("repository format does not have write-only configuration")
+	}
 
-		var erc encryptedRepositoryConfig
-		if err := json.Unmarshal(plainText, &erc); err != nil {
-			return nil, errors.Wrap(err, "invalid repository format")
-		}
+	return openFormatBytes(f.EncryptedWriteOnlyFormatBytes, formatKey, f.UniqueID)
+}
 
-		return &erc.Format, nil
+func openFormatBytes(encrypted, masterKey, repositoryID []byte) (*repositoryObjectFormat, error) {
+	aead, authData, err := initCrypto(masterKey, repositoryID)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot initialize cipher")
+	}
 
-	default:
-		return nil, errors.Errorf("unknown encryption algorithm: '%v'", f.EncryptionAlgorithm)
+	content := append([]byte(nil), encrypted...)
+	if len(content) < aead.NonceSize() {
+		return nil, errors.Errorf("invalid encrypted payload, too short")
 	}
+
+	nonce := content[0:aead.NonceSize()]
+	payload := content[aead.NonceSize():]
+
+	plainText, err := aead.Open(payload[:0], nonce, payload, authData)
+	if err != nil {
+		return nil, errors.Errorf("unable to decrypt repository format, invalid credentials?")
+	}
+
+	var erc encryptedRepositoryConfig
+	if err := json.Unmarshal(plainText, &erc); err != nil {
+		return nil, errors.Wrap(err, "invalid repository format")
+	}
+
+	return &erc.Format, nil
 }
 
 func initCrypto(masterKey, repositoryID []byte) (cipher.AEAD, []byte, error) {
@@ -225,6 +286,8 @@ func initCrypto(masterKey, repositoryID []byte) (cipher.AEAD, []byte, error) {
 	return aead, authData, nil
 }
 
+// encryptFormatBytes encrypts the repository config using the format encryption key. When the config has
+// a write-only format key, the copy of the config for write-only key slots is encrypted using it as well.
 func encryptFormatBytes(f *formatBlob, format *repositoryObjectFormat, masterKey, repositoryID []byte) error {
 	switch f.EncryptionAlgorithm {
 	case "NONE":
@@ -232,30 +295,25 @@ func encryptFormatBytes(f *formatBlob, format *repositoryObjectFormat, masterKey
 		return nil
 
 	case "AES256_GCM":
-		content, err := json.Marshal(&encryptedRepositoryConfig{Format: *format})
+		content, err := sealFormatBytes(format, masterKey, repositoryID)
 		if err != nil {
-			return errors.Wrap(err, "can't marshal format to JSON")
+			return err
 		}
 
-		aead, authData, err := initCrypto(masterKey, repositoryID)
-		if err != nil {
-			return errors.Wrap(err, "unable to initialize crypto")
-		}
+		f.EncryptedFormatBytes = content
+		f.EncryptedWriteOnlyFormatBytes = nil
 
-		nonceLength := aead.NonceSize()
-		noncePlusContentLength := nonceLength + len(content)
-		cipherText := make([]byte, noncePlusContentLength+aead.Overhead())
+		if format.WriteOnlyFormatKey != nil {
+			wo := &repositoryObjectFormat{
+				FormattingOptions: *format.FormattingOptions.WriteOnly(),
+				Format:            format.Format,
+			}
 
-		// Store nonce at the beginning of ciphertext.
-		nonce := cipherText[0:nonceLength]
-		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-			return errors.Wrap(err, "error reading random bytes for nonce")
+			if f.EncryptedWriteOnlyFormatBytes, err = sealFormatBytes(wo, format.WriteOnlyFormatKey, repositoryID); err != nil {
+				return errors.Wrap(err, "unable to encrypt write-only format bytes")
+			}
 		}
 
-		b := aead.Seal(cipherText[nonceLength:nonceLength], nonce, content, authData)
-		content = nonce[0 : nonceLength+len(b)]
-		f.EncryptedFormatBytes = content
-
 		return nil
 
 	default:
@@ -263,6 +321,32 @@ func encryptFormatBytes(f *formatBlob, format *repositoryObjectFormat, masterKey
 	}
 }
 
+func sealFormatBytes(format *repositoryObjectFormat, masterKey, repositoryID []byte) ([]byte, error) {
+	content, err := json.Marshal(&encryptedRepositoryConfig{Format: *format})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't marshal format to JSON")
+	}
+
+	aead, authData, err := initCrypto(masterKey, repositoryID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to initialize crypto")
+	}
+
+	nonceLength := aead.NonceSize()
+	noncePlusContentLength := nonceLength + len(content)
+	cipherText := make([]byte, noncePlusContentLength+aead.Overhead())
+
+	// Store nonce at the beginning of ciphertext.
+	nonce := cipherText[0:nonceLength]
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.Wrap(err, "error reading random bytes for nonce")
+	}
+
+	b := aead.Seal(cipherText[nonceLength:nonceLength], nonce, content, authData)
+
+	return nonce[0 : nonceLength+len(b)], nil
+}
+
 func addFormatBlobChecksumAndLength(fb []byte) ([]byte, error) {
 	h := hmac.New(sha256.New, formatBlobChecksumSecret)
 	h.Write(fb)
@@ -8,11 +8,115 @@
 	"github.com/pkg/errors"
 
 	"github.com/kopia/kopia/internal/blobtesting"
+	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/testlogging"
 	"github.com/kopia/kopia/repo/blob"
 )
 
+func TestParseFormatBlobVersion(t *testing.T) {
+	for _, v := range []string{formatBlobVersionDefault, formatBlobVersionKeySlots} {
+		if _, err := parseFormatBlob([]byte(`{"version":"` + v + `"}`)); err != nil {
+			t.Errorf("unexpected error for version %v: %v", v, err)
+		}
+	}
+
+	if _, err := parseFormatBlob([]byte(`{"version":"99"}`)); err == nil {
+		t.Errorf("expected error for unsupported version")
+	}
+}
+
+func TestFormatBlobUpgradeVersion(t *testing.T) {
+	f := &formatBlob{Version: formatBlobVersionDefault}
+
+	f.upgradeVersion(formatBlobVersionKeySlots)
+
+	if got, want := f.Version, formatBlobVersionKeySlots; got != want {
+		t.Errorf("unexpected version %v, want %v", got, want)
+	}
+
+	// version is never downgraded.
+	f.upgradeVersion(formatBlobVersionDefault)
+
+	if got, want := f.Version, formatBlobVersionKeySlots; got != want {
+		t.Errorf("unexpected version %v, want %v", got, want)
+	}
+}
+
+func TestUnlockKeySlotsHint(t *testing.T) {
+	f := &formatBlob{UniqueID: []byte("unique-id")}
+	formatKey := []byte("0123456789abcdef0123456789abcdef")
+	kd := KeyDerivationOptions{Algorithm: KeyDerivationScrypt}
+
+	for _, id := range []string{"a", "b", "c"} {
+		s, _, err := newKeySlot(id, "", "password-"+id, kd, formatKey, f.UniqueID, clock.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		f.KeySlots = append(f.KeySlots, s)
+	}
+
+	for _, hint := range []string{"", "a", "b", "c", "no-such-slot"} {
+		for _, id := range []string{"a", "b", "c"} {
+			pk, slotID, err := f.unlockKeySlots("password-"+id, hint)
+			if err != nil {
+				t.Fatalf("unable to unlock %v with hint %q: %v", id, hint, err)
+			}
+
+			k, err := f.formatEncryptionKeyForSlot(pk, slotID)
+			if err != nil {
+				t.Fatalf("unable to unwrap format key of %v: %v", id, err)
+			}
+
+			if slotID != id || !reflect.DeepEqual(k, formatKey) {
+				t.Errorf("unexpected result for %v with hint %q: %v", id, hint, slotID)
+			}
+		}
+	}
+
+	if _, _, err := f.unlockKeySlots("wrong-password", "b"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("unexpected error for wrong password: %v", err)
+	}
+
+	if got := len(f.KeySlots); got != 3 || f.KeySlots[0].ID != "a" {
+		t.Errorf("key slots were modified")
+	}
+}
+
+func TestKeySlotRewrapWithoutPassword(t *testing.T) {
+	repositoryID := []byte("unique-id")
+	formatKey := []byte("0123456789abcdef0123456789abcdef")
+	newFormatKey := []byte("fedcba9876543210fedcba9876543210")
+
+	s, pk, err := newKeySlot("a", "", "password-a", KeyDerivationOptions{Algorithm: KeyDerivationScrypt}, formatKey, repositoryID, clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key can be wrapped again using only the public key of the slot.
+	rewrapped := *s
+	if err = rewrapped.wrapKey(newFormatKey, repositoryID); err != nil {
+		t.Fatal(err)
+	}
+
+	if k, err := rewrapped.unwrapKey(pk, repositoryID); err != nil || !reflect.DeepEqual(k, newFormatKey) {
+		t.Errorf("unexpected key after rewrapping: %x %v", k, err)
+	}
+
+	if k, err := s.unwrapKey(pk, repositoryID); err != nil || !reflect.DeepEqual(k, formatKey) {
+		t.Errorf("unexpected key of the original slot: %x %v", k, err)
+	}
+
+	if _, err := rewrapped.unwrapKey(make([]byte, len(pk)), repositoryID); err == nil {
+		t.Errorf("unexpected success with wrong password key")
+	}
+
+	if _, err := rewrapped.unwrapKey(pk, []byte("other-id")); err == nil {
+		t.Errorf("unexpected success with wrong repository ID")
+	}
+}
+
 func TestFormatBlobRecovery(t *testing.T) {
 	data := blobtesting.DataMap{}
 	st := blobtesting.NewMapStorage(data, nil, nil)
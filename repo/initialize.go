@@ -35,6 +35,8 @@ type NewRepositoryOptions struct {
 	BlockFormat  content.FormattingOptions `json:"blockFormat"`
 	DisableHMAC  bool                      `json:"disableHMAC"`
 	ObjectFormat object.Format             `json:"objectFormat"` // object format
+
+	KeyDerivation KeyDerivationOptions `json:"keyDerivation"` // password-based key derivation
 }
 
 // ErrAlreadyInitialized indicates that repository has already been initialized.
@@ -57,6 +59,9 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 	}
 
 	format := formatBlobFromOptions(opt)
+	if err := format.applyKeyDerivationOptions(opt.KeyDerivation); err != nil {
+		return errors.Wrap(err, "invalid key derivation options")
+	}
 
 	formatEncryptionKey, err := format.deriveFormatEncryptionKeyFromPassword(password)
 	if err != nil {
@@ -64,7 +69,7 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 	}
 
 	f := repositoryObjectFormatFromOptions(opt)
-	if err := f.MutableParameters.Validate(); err != nil {
+	if err := f.FormattingOptions.Validate(); err != nil {
 		return errors.Wrap(err, "invalid parameters")
 	}
 
@@ -80,34 +85,43 @@ func Initialize(ctx context.Context, st blob.Storage, opt *NewRepositoryOptions,
 }
 
 func formatBlobFromOptions(opt *NewRepositoryOptions) *formatBlob {
-	return &formatBlob{
-		Tool:                   "https://github.com/kopia/kopia",
-		BuildInfo:              BuildInfo,
-		BuildVersion:           BuildVersion,
-		KeyDerivationAlgorithm: defaultKeyDerivationAlgorithm,
-		UniqueID:               applyDefaultRandomBytes(opt.UniqueID, uniqueIDLength),
-		Version:                "1",
-		EncryptionAlgorithm:    defaultFormatEncryption,
+	f := &formatBlob{
+		Tool:                "https://github.com/kopia/kopia",
+		BuildInfo:           BuildInfo,
+		BuildVersion:        BuildVersion,
+		UniqueID:            applyDefaultRandomBytes(opt.UniqueID, uniqueIDLength),
+		Version:             formatBlobVersionDefault,
+		EncryptionAlgorithm: defaultFormatEncryption,
 	}
+
+	return f
 }
 
 func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) *repositoryObjectFormat {
 	f := &repositoryObjectFormat{
 		FormattingOptions: content.FormattingOptions{
-			Version:    1,
+			Version:    formatVersion(opt),
 			Hash:       applyDefaultString(opt.BlockFormat.Hash, hashing.DefaultAlgorithm),
 			Encryption: applyDefaultString(opt.BlockFormat.Encryption, encryption.DefaultAlgorithm),
 			HMACSecret: applyDefaultRandomBytes(opt.BlockFormat.HMACSecret, hmacSecretLength),
 			MasterKey:  applyDefaultRandomBytes(opt.BlockFormat.MasterKey, masterKeyLength),
+
+			ECC:                opt.BlockFormat.ECC,
+			ECCOverheadPercent: opt.BlockFormat.ECCOverheadPercent,
+
+			PublicKeyEncryption: opt.BlockFormat.PublicKeyEncryption,
+			PublicKey:           opt.BlockFormat.PublicKey,
+
 			MutableParameters: content.MutableParameters{
 				MaxPackSize:     applyDefaultInt(opt.BlockFormat.MaxPackSize, 20<<20), //nolint:gomnd
-				IndexVersion:    applyDefaultInt(opt.BlockFormat.IndexVersion, content.DefaultIndexVersion),
+				IndexVersion:    applyDefaultInt(opt.BlockFormat.IndexVersion, defaultIndexVersion(opt)),
 				EpochParameters: opt.BlockFormat.EpochParameters,
 			},
 			EnablePasswordChange: opt.BlockFormat.EnablePasswordChange,
 		},
 		Format: object.Format{
-			Splitter: applyDefaultString(opt.ObjectFormat.Splitter, splitter.DefaultAlgorithm),
+			Splitter:      applyDefaultString(opt.ObjectFormat.Splitter, splitter.DefaultAlgorithm),
+			SparseObjects: opt.ObjectFormat.SparseObjects,
 		},
 	}
 
@@ -115,9 +129,47 @@ func repositoryObjectFormatFromOptions(opt *NewRepositoryOptions) *repositoryObj
 		f.HMACSecret = nil
 	}
 
+	if f.PublicKeyEncryption != "" {
+		// indexes and manifests are encrypted using a separate key, so that write-only clients don't get the master key.
+		f.MetadataKey = applyDefaultRandomBytes(opt.BlockFormat.MetadataKey, masterKeyLength)
+	}
+
 	return f
 }
 
+// formatVersion returns the format version of the new repository, which is only raised when the repository
+// uses features that clients supporting older versions would mishandle.
+func formatVersion(opt *NewRepositoryOptions) int {
+	if opt.BlockFormat.ECC != "" {
+		// clients that don't support error correction would fail to read blobs with parity shards.
+		return content.FormatVersion2
+	}
+
+	if opt.BlockFormat.PublicKeyEncryption != "" {
+		// clients that don't support public-key encryption would write contents using the master key
+		// and would drop the public key when rewriting the repository format.
+		return content.FormatVersion2
+	}
+
+	if opt.ObjectFormat.SparseObjects {
+		// clients that don't support sparse objects would fail to read objects with holes
+		// and would drop the setting when rewriting the repository format.
+		return content.FormatVersion2
+	}
+
+	return content.FormatVersion1
+}
+
+// defaultIndexVersion returns the index version to use when not explicitly specified.
+func defaultIndexVersion(opt *NewRepositoryOptions) int {
+	if opt.BlockFormat.PublicKeyEncryption != "" {
+		// public-key encryption requires index format that stores encryption key IDs.
+		return content.MinPublicKeyEncryptionIndexVersion
+	}
+
+	return content.DefaultIndexVersion
+}
+
 func randomBytes(n int) []byte {
 	b := make([]byte, n)
 	io.ReadFull(rand.Reader, b) //nolint:errcheck
@@ -0,0 +1,129 @@
+package repo
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// RotateMasterKey replaces the master key used to encrypt new contents and BLOBs with a newly generated one
+// and returns its generation. Data encrypted using previous master keys remains readable until it is
+// re-encrypted by maintenance, which then retires the previous keys.
+//
+// Clients, including this one, start encrypting new data using the new master key when they refresh
+// the repository, which happens periodically, or reopen it.
+func (r *directRepository) RotateMasterKey(ctx context.Context) (int, error) {
+	if err := r.requireFullAccess(); err != nil {
+		return 0, err
+	}
+
+	f := r.formatBlob.clone()
+
+	repoConfig, err := f.decryptFormatBytes(r.formatEncryptionKey)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	if !repoConfig.EnablePasswordChange {
+		return 0, errors.Errorf("master key rotation is not supported for repositories created using Kopia v0.8 or older")
+	}
+
+	if err := repoConfig.FormattingOptions.RotateMasterKey(r.Time()); err != nil {
+		return 0, errors.Wrap(err, "unable to rotate master key")
+	}
+
+	if err := repoConfig.FormattingOptions.Validate(); err != nil {
+		return 0, errors.Wrap(err, "invalid parameters")
+	}
+
+	if err := encryptFormatBytes(f, repoConfig, r.formatEncryptionKey, f.UniqueID); err != nil {
+		return 0, errors.Wrap(err, "unable to encrypt format bytes")
+	}
+
+	if err := r.writeFormatBlobAndInvalidateCache(ctx, f); err != nil {
+		return 0, err
+	}
+
+	return repoConfig.KeyGeneration, nil
+}
+
+// RetirePreviousMasterKeys removes master keys replaced by key rotation from the repository, after which
+// data encrypted using them can no longer be decrypted. The caller must ensure that all such data has been
+// re-encrypted using the current master key.
+func (r *directRepository) RetirePreviousMasterKeys(ctx context.Context) error {
+	if err := r.requireFullAccess(); err != nil {
+		return err
+	}
+
+	// re-read the format blob to avoid overwriting changes made since the repository was opened.
+	f, formatKey, repoConfig, err := readFormatBlobAndConfig(ctx, r.blobs, r.passwordKey, r.keySlotID)
+	if err != nil {
+		return err
+	}
+
+	if repoConfig.KeyGeneration != r.cmgr.ContentFormat().KeyGeneration {
+		return errors.Errorf("master key has been rotated since the repository was opened")
+	}
+
+	if len(repoConfig.PreviousMasterKeys) == 0 {
+		return nil
+	}
+
+	repoConfig.RetirePreviousMasterKeys()
+
+	if err := encryptFormatBytes(f, repoConfig, formatKey, f.UniqueID); err != nil {
+		return errors.Wrap(err, "unable to encrypt format bytes")
+	}
+
+	if err := r.writeFormatBlobAndInvalidateCache(ctx, f); err != nil {
+		return err
+	}
+
+	r.formatEncryptionKey = formatKey
+
+	return nil
+}
+
+// readFormatBlobAndConfig reads and decrypts the format blob directly from the storage, bypassing the cache,
+// and returns it along with the format encryption key unlocked using the provided key slot.
+func readFormatBlobAndConfig(ctx context.Context, st blob.Storage, passwordKey []byte, keySlotID string) (*formatBlob, []byte, *repositoryObjectFormat, error) {
+	b, err := st.GetBlob(ctx, FormatBlobID, 0, -1)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to read format blob")
+	}
+
+	f, err := parseFormatBlob(b)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "can't parse format blob")
+	}
+
+	formatKey, err := f.formatEncryptionKeyForSlot(passwordKey, keySlotID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to unlock format encryption key")
+	}
+
+	repoConfig, err := f.decryptFormatBytesForKeySlot(formatKey, keySlotID)
+	if err != nil {
+		return nil, nil, nil, errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	return f, formatKey, repoConfig, nil
+}
+
+// formattingOptionsReloader returns a function that reloads the formatting options of the repository,
+// which allows the content manager to learn about master keys rotated by other clients.
+func formattingOptionsReloader(ctx context.Context, st blob.Storage, passwordKey []byte, keySlotID string) func() (*content.FormattingOptions, error) {
+	return func() (*content.FormattingOptions, error) {
+		log(ctx).Debugf("reloading repository format to look for rotated master keys")
+
+		_, _, repoConfig, err := readFormatBlobAndConfig(ctx, st, passwordKey, keySlotID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &repoConfig.FormattingOptions, nil
+	}
+}
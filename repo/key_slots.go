@@ -0,0 +1,569 @@
+package repo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo/encryption"
+)
+
+const (
+	// DefaultKeySlotID is the identifier of the key slot holding the original repository password
+	// after the repository has been converted to use key slots.
+	DefaultKeySlotID = "password"
+
+	keySlotSaltLength       = 32
+	keySlotIDLength         = 4
+	keySlotPrivateKeyLength = 32
+	formatKeyLength         = 32
+	keySlotEncryption       = "AES256_GCM"
+	keySlotIDGenPrefix      = "key-"
+
+	// keySlotWrapAlgorithm is the public-key encryption algorithm used to wrap the format encryption key.
+	keySlotWrapAlgorithm = encryption.DefaultPublicKeyAlgorithm
+
+	// keySlotsKeyDerivationAlgorithm replaces the key derivation algorithm of format blobs converted to key slots,
+	// whose slots have their own algorithms. Kopia versions that don't support key slots don't check
+	// the format blob version and would report an invalid password, but they reject unknown algorithms.
+	keySlotsKeyDerivationAlgorithm = "key-slots"
+)
+
+// ErrWriteOnlyAccess is returned when an operation requires the master keys, which are not available
+// to clients that have opened the repository using a write-only key slot.
+var ErrWriteOnlyAccess = errors.New("operation requires full access to the repository, which write-only key slots do not provide")
+
+var purposeKeySlotPrivateKey = []byte("KEY-SLOT")
+
+// keySlot stores the repository format encryption key wrapped using a key derived from one of the
+// repository passwords.
+//
+// Repositories without key slots derive the format encryption key directly from the only password.
+// After the first key slot is added, the format is encrypted with a random key which is stored
+// in each slot, so that every password can be individually added and revoked.
+//
+// The key is encrypted using a public key, whose private key is derived from the password. This allows
+// the format encryption key to be rotated and wrapped again for all remaining slots when a slot is removed,
+// without knowing their passwords.
+//
+// Write-only key slots store a different key, which only decrypts the copy of the format without
+// the master keys, so their passwords allow adding data to repositories using public-key encryption
+// without being able to decrypt it.
+type keySlot struct {
+	ID          string    `json:"id"`
+	Description string    `json:"description,omitempty"`
+	CreatedTime time.Time `json:"created"`
+	WriteOnly   bool      `json:"writeOnly,omitempty"`
+
+	KeyDerivationAlgorithm string            `json:"keyAlgo"`
+	Argon2                 *Argon2Parameters `json:"argon2,omitempty"`
+	Salt                   []byte            `json:"salt"`
+
+	WrapAlgorithm string `json:"wrapAlgo"`
+	PublicKey     []byte `json:"publicKey"`
+	EncryptedKey  []byte `json:"encryptedKey"`
+}
+
+// KeySlotInfo describes a key slot that can be used to unlock the repository.
+type KeySlotInfo struct {
+	ID                     string    `json:"id"`
+	Description            string    `json:"description,omitempty"`
+	CreatedTime            time.Time `json:"created"`
+	KeyDerivationAlgorithm string    `json:"keyAlgo"`
+	WriteOnly              bool      `json:"writeOnly,omitempty"`
+	Current                bool      `json:"current"`
+}
+
+// passwordKey derives the key wrapping the format encryption key from the provided password.
+func (s *keySlot) passwordKey(password string) ([]byte, error) {
+	tmp := &formatBlob{
+		UniqueID:               s.Salt,
+		KeyDerivationAlgorithm: s.KeyDerivationAlgorithm,
+		Argon2:                 s.Argon2,
+	}
+
+	return tmp.deriveFormatEncryptionKeyFromPassword(password)
+}
+
+// GetPublicKeyEncryptionAlgorithm implements encryption.PublicKeyParameters.
+func (s *keySlot) GetPublicKeyEncryptionAlgorithm() string {
+	return s.WrapAlgorithm
+}
+
+// GetPublicKey implements encryption.PublicKeyParameters.
+func (s *keySlot) GetPublicKey() []byte {
+	return s.PublicKey
+}
+
+// privateKey derives the private key of the slot from the key derived from the password.
+func (s *keySlot) privateKey(passwordKey, repositoryID []byte) []byte {
+	return deriveKeyFromMasterKey(passwordKey, repositoryID, purposeKeySlotPrivateKey, keySlotPrivateKeyLength)
+}
+
+// setPasswordKey sets the public key of the slot, which corresponds to the key derived from the password.
+func (s *keySlot) setPasswordKey(passwordKey, repositoryID []byte) error {
+	pub, err := encryption.DerivePublicKey(keySlotWrapAlgorithm, s.privateKey(passwordKey, repositoryID))
+	if err != nil {
+		return errors.Wrap(err, "unable to derive key slot public key")
+	}
+
+	s.WrapAlgorithm = keySlotWrapAlgorithm
+	s.PublicKey = pub
+
+	return nil
+}
+
+// wrapKey encrypts the format encryption key using the public key of the slot.
+func (s *keySlot) wrapKey(formatKey, repositoryID []byte) error {
+	e, err := encryption.CreatePublicKeyEncryptor(s, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to initialize crypto")
+	}
+
+	s.EncryptedKey, err = e.Encrypt(nil, formatKey, repositoryID)
+
+	return errors.Wrap(err, "unable to encrypt key")
+}
+
+// unwrapKey decrypts the format encryption key using the key derived from the password.
+func (s *keySlot) unwrapKey(passwordKey, repositoryID []byte) ([]byte, error) {
+	e, err := encryption.CreatePublicKeyEncryptor(s, s.privateKey(passwordKey, repositoryID))
+	if err != nil {
+		// the private key derived from a wrong password does not match the public key.
+		return nil, errors.Errorf("unable to decrypt key slot %v, invalid password?", s.ID)
+	}
+
+	k, err := e.Decrypt(nil, s.EncryptedKey, repositoryID)
+	if err != nil {
+		return nil, errors.Errorf("unable to decrypt key slot %v, invalid password?", s.ID)
+	}
+
+	return k, nil
+}
+
+func (s *keySlot) info(currentSlotID string) KeySlotInfo {
+	return KeySlotInfo{
+		ID:                     s.ID,
+		Description:            s.Description,
+		CreatedTime:            s.CreatedTime,
+		KeyDerivationAlgorithm: s.KeyDerivationAlgorithm,
+		WriteOnly:              s.WriteOnly,
+		Current:                s.ID == currentSlotID,
+	}
+}
+
+// newKeySlot creates a key slot wrapping the format encryption key using the provided password
+// and returns it along with the key derived from the password.
+func newKeySlot(id, description, password string, kd KeyDerivationOptions, formatKey, repositoryID []byte, now time.Time) (*keySlot, []byte, error) {
+	tmp := &formatBlob{}
+	if err := tmp.applyKeyDerivationOptions(kd); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid key derivation options")
+	}
+
+	s := &keySlot{
+		ID:                     id,
+		Description:            description,
+		CreatedTime:            now,
+		KeyDerivationAlgorithm: tmp.KeyDerivationAlgorithm,
+		Argon2:                 tmp.Argon2,
+		Salt:                   make([]byte, keySlotSaltLength),
+	}
+
+	if _, err := io.ReadFull(rand.Reader, s.Salt); err != nil {
+		return nil, nil, errors.Wrap(err, "error generating salt")
+	}
+
+	pk, err := s.passwordKey(password)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to derive key")
+	}
+
+	if err := s.setPasswordKey(pk, repositoryID); err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.wrapKey(formatKey, repositoryID); err != nil {
+		return nil, nil, err
+	}
+
+	return s, pk, nil
+}
+
+// passwordKeyFromPassword returns the key derived from the provided password, which unlocks the format
+// encryption key either directly or using a key slot, along with the identifier of that key slot, if any.
+// The key slot with the hinted identifier is tried first.
+func (f *formatBlob) passwordKeyFromPassword(password, keySlotHint string) ([]byte, string, error) {
+	if len(f.KeySlots) > 0 {
+		return f.unlockKeySlots(password, keySlotHint)
+	}
+
+	k, err := f.deriveFormatEncryptionKeyFromPassword(password)
+
+	return k, "", err
+}
+
+// unlockKeySlots finds the key slot which can be unlocked using the provided password
+// and returns the key derived from the password along with the identifier of the slot.
+// Since each attempt derives a key from the password, the hinted slot is tried first.
+func (f *formatBlob) unlockKeySlots(password, keySlotHint string) ([]byte, string, error) {
+	slots := f.KeySlots
+
+	if i, hinted := f.findKeySlot(keySlotHint); hinted != nil {
+		slots = append([]*keySlot{hinted}, f.KeySlots[0:i:i]...)
+		slots = append(slots, f.KeySlots[i+1:]...)
+	}
+
+	for _, s := range slots {
+		pk, err := s.passwordKey(password)
+		if err != nil {
+			continue
+		}
+
+		if _, err := s.unwrapKey(pk, f.UniqueID); err != nil {
+			continue
+		}
+
+		return pk, s.ID, nil
+	}
+
+	return nil, "", ErrInvalidPassword
+}
+
+// formatEncryptionKeyForSlot returns the format encryption key unlocked using the key derived from
+// the password of a given key slot, which remains valid when the format encryption key is rotated.
+func (f *formatBlob) formatEncryptionKeyForSlot(passwordKey []byte, keySlotID string) ([]byte, error) {
+	if len(f.KeySlots) == 0 {
+		return passwordKey, nil
+	}
+
+	if keySlotID == "" {
+		// the repository has been converted to key slots, which preserves the original password.
+		keySlotID = DefaultKeySlotID
+	}
+
+	_, s := f.findKeySlot(keySlotID)
+	if s == nil {
+		return nil, errors.Errorf("key slot %q not found", keySlotID)
+	}
+
+	return s.unwrapKey(passwordKey, f.UniqueID)
+}
+
+func (f *formatBlob) findKeySlot(id string) (int, *keySlot) {
+	for i, s := range f.KeySlots {
+		if s.ID == id {
+			return i, s
+		}
+	}
+
+	return -1, nil
+}
+
+// isWriteOnlyKeySlot returns true if the key slot with a given identifier is a write-only key slot.
+func (f *formatBlob) isWriteOnlyKeySlot(id string) bool {
+	_, s := f.findKeySlot(id)
+
+	return s != nil && s.WriteOnly
+}
+
+// requireFullAccess returns an error when the repository has been opened using a write-only key slot.
+func (r *directRepository) requireFullAccess() error {
+	if r.formatBlob.isWriteOnlyKeySlot(r.keySlotID) {
+		return ErrWriteOnlyAccess
+	}
+
+	return nil
+}
+
+// KeySlots returns the list of key slots that can be used to unlock the repository.
+func (r *directRepository) KeySlots() []KeySlotInfo {
+	var result []KeySlotInfo
+
+	for _, s := range r.formatBlob.KeySlots {
+		result = append(result, s.info(r.keySlotID))
+	}
+
+	return result
+}
+
+// AddKeySlot adds a key slot allowing the repository to be opened using the provided password.
+// When the repository does not use key slots yet, it is converted first, such that
+// the current password is preserved in a slot named DefaultKeySlotID. Converted repositories
+// can't be opened by Kopia versions that don't support key slots, which report an unsupported key algorithm.
+func (r *directRepository) AddKeySlot(ctx context.Context, id, description, password string, kd *KeyDerivationOptions) error {
+	return r.addKeySlot(ctx, id, description, password, kd, false)
+}
+
+// AddWriteOnlyKeySlot adds a key slot allowing the repository using public-key encryption to be opened
+// using the provided password without access to the master keys. Such clients can add data to the repository,
+// but can't decrypt data contents or manage the repository.
+func (r *directRepository) AddWriteOnlyKeySlot(ctx context.Context, id, description, password string, kd *KeyDerivationOptions) error {
+	return r.addKeySlot(ctx, id, description, password, kd, true)
+}
+
+func (r *directRepository) addKeySlot(ctx context.Context, id, description, password string, kd *KeyDerivationOptions, writeOnly bool) error {
+	if err := r.requireFullAccess(); err != nil {
+		return err
+	}
+
+	f := r.formatBlob.clone()
+
+	repoConfig, err := f.decryptFormatBytes(r.formatEncryptionKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	if !repoConfig.EnablePasswordChange {
+		return errors.Errorf("key slots are not supported for repositories created using Kopia v0.8 or older")
+	}
+
+	if f.EncryptionAlgorithm != keySlotEncryption {
+		return errors.Errorf("key slots are not supported for repositories using %v format encryption", f.EncryptionAlgorithm)
+	}
+
+	if writeOnly {
+		if repoConfig.PublicKeyEncryption == "" || len(repoConfig.MetadataKey) == 0 {
+			return errors.Errorf("write-only key slots are only supported for repositories created with public-key encryption")
+		}
+
+		if repoConfig.WriteOnlyFormatKey == nil {
+			if repoConfig.WriteOnlyFormatKey, err = randomFormatKey(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if id == "" {
+		id, err = generateKeySlotID()
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, existing := f.findKeySlot(id); existing != nil || (len(f.KeySlots) == 0 && id == DefaultKeySlotID) {
+		return errors.Errorf("key slot %q already exists", id)
+	}
+
+	// unless specified, the new slot uses key derivation of the password used to open the repository.
+	slotKD := KeyDerivationOptions{Algorithm: f.KeyDerivationAlgorithm, Argon2: f.Argon2}
+	if _, current := f.findKeySlot(r.keySlotID); current != nil {
+		slotKD = KeyDerivationOptions{Algorithm: current.KeyDerivationAlgorithm, Argon2: current.Argon2}
+	}
+
+	if kd != nil {
+		slotKD = *kd
+	}
+
+	formatKey := r.formatEncryptionKey
+	currentSlotID := r.keySlotID
+
+	var newSlots []*keySlot
+
+	if len(f.KeySlots) == 0 {
+		// convert the repository to use a random format encryption key and wrap it using
+		// the key derived from the current password, which keeps the password valid.
+		if formatKey, err = randomFormatKey(); err != nil {
+			return err
+		}
+
+		legacy := &keySlot{
+			ID:                     DefaultKeySlotID,
+			Description:            "Original repository password",
+			CreatedTime:            r.Time(),
+			KeyDerivationAlgorithm: f.KeyDerivationAlgorithm,
+			Argon2:                 f.Argon2,
+			Salt:                   f.UniqueID,
+		}
+
+		if err = legacy.setPasswordKey(r.passwordKey, f.UniqueID); err != nil {
+			return err
+		}
+
+		if err = legacy.wrapKey(formatKey, f.UniqueID); err != nil {
+			return err
+		}
+
+		newSlots = append(newSlots, legacy)
+		currentSlotID = DefaultKeySlotID
+
+		f.KeyDerivationAlgorithm = keySlotsKeyDerivationAlgorithm
+		f.Argon2 = nil
+	}
+
+	slotKey := formatKey
+	if writeOnly {
+		slotKey = repoConfig.WriteOnlyFormatKey
+	}
+
+	s, _, err := newKeySlot(id, description, password, slotKD, slotKey, f.UniqueID, r.Time())
+	if err != nil {
+		return err
+	}
+
+	s.WriteOnly = writeOnly
+
+	f.KeySlots = append(append(f.KeySlots, newSlots...), s)
+	f.upgradeVersion(formatBlobVersionKeySlots)
+
+	if err := encryptFormatBytes(f, repoConfig, formatKey, f.UniqueID); err != nil {
+		return errors.Wrap(err, "unable to encrypt format bytes")
+	}
+
+	if err := r.writeFormatBlobAndInvalidateCache(ctx, f); err != nil {
+		return err
+	}
+
+	r.formatEncryptionKey = formatKey
+	r.keySlotID = currentSlotID
+
+	return nil
+}
+
+// RemoveKeySlot removes the key slot with a given identifier, which revokes the corresponding password.
+// The key slot used to open the repository and the last remaining key slot can't be removed.
+//
+// The format encryption keys are rotated and wrapped again for the remaining slots, so that the revoked
+// password no longer unlocks the repository format, even using a copy of the removed slot. The master key
+// that its holder could have obtained before the removal remains valid; rotate the master key to prevent
+// access to data written afterwards.
+func (r *directRepository) RemoveKeySlot(ctx context.Context, id string) error {
+	if err := r.requireFullAccess(); err != nil {
+		return err
+	}
+
+	f := r.formatBlob.clone()
+
+	if _, s := f.findKeySlot(id); s == nil {
+		return errors.Errorf("key slot %q not found", id)
+	}
+
+	if id == r.keySlotID {
+		return errors.Errorf("key slot %q is used by the current connection and can't be removed", id)
+	}
+
+	if len(f.KeySlots) == 1 {
+		return errors.Errorf("can't remove the last key slot")
+	}
+
+	repoConfig, err := f.decryptFormatBytes(r.formatEncryptionKey)
+	if err != nil {
+		return errors.Wrap(err, "unable to decrypt repository config")
+	}
+
+	formatKey, err := randomFormatKey()
+	if err != nil {
+		return err
+	}
+
+	if repoConfig.WriteOnlyFormatKey != nil {
+		if repoConfig.WriteOnlyFormatKey, err = randomFormatKey(); err != nil {
+			return err
+		}
+	}
+
+	f.KeySlots = nil
+
+	for _, ks := range r.formatBlob.KeySlots {
+		if ks.ID == id {
+			continue
+		}
+
+		rewrapped := *ks
+
+		slotKey := formatKey
+		if rewrapped.WriteOnly {
+			slotKey = repoConfig.WriteOnlyFormatKey
+		}
+
+		if err := rewrapped.wrapKey(slotKey, f.UniqueID); err != nil {
+			return errors.Wrapf(err, "unable to wrap key for key slot %v", ks.ID)
+		}
+
+		f.KeySlots = append(f.KeySlots, &rewrapped)
+	}
+
+	if err := encryptFormatBytes(f, repoConfig, formatKey, f.UniqueID); err != nil {
+		return errors.Wrap(err, "unable to encrypt format bytes")
+	}
+
+	if err := r.writeFormatBlobAndInvalidateCache(ctx, f); err != nil {
+		return err
+	}
+
+	r.formatEncryptionKey = formatKey
+
+	return nil
+}
+
+// changeKeySlotPassword re-wraps the format encryption key of the current key slot using the new password.
+func (r *directRepository) changeKeySlotPassword(ctx context.Context, newPassword string, kd *KeyDerivationOptions) error {
+	f := r.formatBlob.clone()
+
+	i, s := f.findKeySlot(r.keySlotID)
+	if s == nil {
+		return errors.Errorf("key slot %q not found", r.keySlotID)
+	}
+
+	slotKD := KeyDerivationOptions{Algorithm: s.KeyDerivationAlgorithm, Argon2: s.Argon2}
+	if kd != nil {
+		slotKD = *kd
+	}
+
+	ns, pk, err := newKeySlot(s.ID, s.Description, newPassword, slotKD, r.formatEncryptionKey, f.UniqueID, s.CreatedTime)
+	if err != nil {
+		return err
+	}
+
+	ns.WriteOnly = s.WriteOnly
+	f.KeySlots[i] = ns
+
+	if err := r.writeFormatBlobAndInvalidateCache(ctx, f); err != nil {
+		return err
+	}
+
+	r.passwordKey = pk
+
+	return nil
+}
+
+// writeFormatBlobAndInvalidateCache writes the provided format blob and makes it current after it has been written.
+func (r *directRepository) writeFormatBlobAndInvalidateCache(ctx context.Context, f *formatBlob) error {
+	if err := writeFormatBlob(ctx, r.blobs, f); err != nil {
+		return errors.Wrap(err, "unable to write format blob")
+	}
+
+	r.formatBlob = f
+
+	// remove cached kopia.repository blob.
+	if cd := r.cachingOptions.CacheDirectory; cd != "" {
+		if err := os.Remove(filepath.Join(cd, "kopia.repository")); err != nil && !os.IsNotExist(err) {
+			log(ctx).Errorf("unable to remove kopia.repository: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func randomFormatKey() ([]byte, error) {
+	k := make([]byte, formatKeyLength)
+	if _, err := io.ReadFull(rand.Reader, k); err != nil {
+		return nil, errors.Wrap(err, "error generating format encryption key")
+	}
+
+	return k, nil
+}
+
+func generateKeySlotID() (string, error) {
+	b := make([]byte, keySlotIDLength)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", errors.Wrap(err, "error generating key slot ID")
+	}
+
+	return keySlotIDGenPrefix + hex.EncodeToString(b), nil
+}
@@ -12,6 +12,7 @@
 
 	"github.com/kopia/kopia/internal/atomicfile"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/object"
 )
@@ -31,6 +32,10 @@ type ClientOptions struct {
 	EnableActions bool `json:"enableActions"`
 
 	FormatBlobCacheDuration time.Duration `json:"formatBlobCacheDuration,omitempty"`
+
+	// PrivateKeyFile is the path to the private key used to decrypt contents of repositories
+	// using public-key encryption, without it contents can't be read, but manifests and indexes can.
+	PrivateKeyFile string `json:"privateKeyFile,omitempty"`
 }
 
 // ApplyDefaults returns a copy of ClientOptions with defaults filled out.
@@ -72,6 +77,10 @@ func (o ClientOptions) Override(other ClientOptions) ClientOptions {
 		o.ReadOnly = other.ReadOnly
 	}
 
+	if other.PrivateKeyFile != "" {
+		o.PrivateKeyFile = other.PrivateKeyFile
+	}
+
 	return o
 }
 
@@ -80,6 +89,20 @@ func (o ClientOptions) UsernameAtHost() string {
 	return o.Username + "@" + o.Hostname
 }
 
+// PasswordProviderConfig describes the external source of the repository password used by the connection
+// instead of a persisted password. It's kept outside of ClientOptions, because it may carry credentials.
+// URLHeaders are stored as 'Name: $VAR' references to environment variables holding the values.
+type PasswordProviderConfig struct {
+	Command        string        `json:"command,omitempty"`
+	CommandTimeout time.Duration `json:"commandTimeout,omitempty"`
+	URL            string        `json:"url,omitempty"`
+	URLHeaders     []string      `json:"urlHeaders,omitempty"`
+	URLJSONField   string        `json:"urlJSONField,omitempty"`
+	URLTimeout     time.Duration `json:"urlTimeout,omitempty"`
+	CacheMode      string        `json:"cacheMode,omitempty"`
+	CacheTTL       time.Duration `json:"cacheTTL,omitempty"`
+}
+
 // LocalConfig is a configuration of Kopia stored in a configuration file.
 type LocalConfig struct {
 	// APIServer is only provided for remote repository.
@@ -90,6 +113,23 @@ type LocalConfig struct {
 
 	Caching *content.CachingOptions `json:"caching,omitempty"`
 
+	// Throttling is the bandwidth schedule applied to direct repository access.
+	Throttling *throttling.Schedule `json:"throttling,omitempty"`
+
+	// NotificationPasswords are passwords of notification profiles by profile name, which are kept
+	// locally because the repository is readable by all its users.
+	NotificationPasswords map[string]string `json:"notificationPasswords,omitempty"`
+
+	// NotificationHeaders are HTTP headers of webhook notification profiles by profile name, which are kept
+	// locally because they usually carry credentials.
+	NotificationHeaders map[string]map[string]string `json:"notificationHeaders,omitempty"`
+
+	// KeySlotID is the identifier of the key slot last used to unlock the repository, which is tried first.
+	KeySlotID string `json:"keySlotID,omitempty"`
+
+	// PasswordProvider is the external source of the repository password specified when connecting.
+	PasswordProvider *PasswordProviderConfig `json:"passwordProvider,omitempty"`
+
 	ClientOptions
 }
 
@@ -97,6 +137,9 @@ type LocalConfig struct {
 type repositoryObjectFormat struct {
 	content.FormattingOptions
 	object.Format
+
+	// WriteOnlyFormatKey encrypts the copy of the config unlocked by write-only key slots.
+	WriteOnlyFormatKey []byte `json:"writeOnlyFormatKey,omitempty"`
 }
 
 // writeToFile writes the config to a given file.
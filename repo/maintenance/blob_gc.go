@@ -21,7 +21,9 @@ type DeleteUnreferencedBlobsOptions struct {
 }
 
 // DeleteUnreferencedBlobs deletes old blobs that are no longer referenced by index entries.
-// nolint:gocyclo
+// Blobs whose retention has not expired yet are preserved, since deleting them would not free any space,
+// they are deleted by subsequent runs after their retention expires.
+// nolint:gocyclo,funlen
 func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWriter, opt DeleteUnreferencedBlobsOptions, safety SafetyParameters) (int, error) {
 	if opt.Parallel == 0 {
 		opt.Parallel = 16
@@ -29,7 +31,7 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 
 	const deleteQueueSize = 100
 
-	var unreferenced, deleted stats.CountSum
+	var unreferenced, retained, deleted stats.CountSum
 
 	var eg errgroup.Group
 
@@ -69,6 +71,8 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 		return 0, errors.Wrap(err, "unable to load active sessions")
 	}
 
+	hasRetention := blob.RetentionPeriod(rep.BlobStorage()) > 0
+
 	// iterate all pack blobs + session blobs and keep ones that are too young or
 	// belong to alive sessions.
 	if err := rep.ContentManager().IterateUnreferencedBlobs(ctx, prefixes, opt.Parallel, func(bm blob.Metadata) error {
@@ -85,6 +89,20 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 			}
 		}
 
+		if hasRetention {
+			retainUntil, err := blob.RetainedUntil(ctx, rep.BlobStorage(), bm.BlobID)
+			if err != nil {
+				return errors.Wrapf(err, "unable to get retention of blob %q", bm.BlobID)
+			}
+
+			if retainUntil.After(rep.Time()) {
+				log(ctx).Debugf("  preserving %v because its retention has not expired (until %v)", bm.BlobID, retainUntil)
+				retained.Add(bm.Length)
+
+				return nil
+			}
+		}
+
 		unreferenced.Add(bm.Length)
 
 		if !opt.DryRun {
@@ -101,6 +119,10 @@ func DeleteUnreferencedBlobs(ctx context.Context, rep repo.DirectRepositoryWrite
 	unreferencedCount, unreferencedSize := unreferenced.Approximate()
 	log(ctx).Debugf("Found %v blobs to delete (%v)", unreferencedCount, units.BytesStringBase10(unreferencedSize))
 
+	if retainedCount, retainedSize := retained.Approximate(); retainedCount > 0 {
+		log(ctx).Infof("Preserved %v unreferenced blobs (%v) whose retention has not expired", retainedCount, units.BytesStringBase10(retainedSize))
+	}
+
 	// wait for all delete workers to finish.
 	if err := eg.Wait(); err != nil {
 		return 0, errors.Wrap(err, "worker error")
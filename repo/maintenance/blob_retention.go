@@ -0,0 +1,163 @@
+package maintenance
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/stats"
+	"github.com/kopia/kopia/internal/units"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ExtendBlobRetentionOptions provides options for extending retention of blobs.
+type ExtendBlobRetentionOptions struct {
+	Parallel int
+
+	// ExtendWithin, when set, limits the extension to blobs whose retention ends within that time from now.
+	ExtendWithin time.Duration
+
+	// MaintenanceInterval, when set, is the interval between extensions, which the retention period
+	// of the storage must exceed for blobs to remain protected until the next extension.
+	MaintenanceInterval time.Duration
+}
+
+// ExtendBlobRetention extends retention of all blobs still in use by the repository, which excludes
+// pack and session blobs that are no longer referenced by index entries.
+// Returns the number of blobs whose retention was extended or 0 if the storage does not support retention.
+func ExtendBlobRetention(ctx context.Context, rep repo.DirectRepositoryWriter, opt ExtendBlobRetentionOptions) (int, error) {
+	return extendBlobRetention(ctx, rep, rep.BlobStorage(), opt)
+}
+
+func extendBlobRetention(ctx context.Context, rep repo.DirectRepositoryWriter, st blob.Storage, opt ExtendBlobRetentionOptions) (int, error) {
+	period := blob.RetentionPeriod(st)
+	if period == 0 {
+		log(ctx).Debugf("storage does not have blob retention enabled")
+		return 0, nil
+	}
+
+	if opt.MaintenanceInterval > 0 && period <= opt.MaintenanceInterval {
+		return 0, errors.Errorf("retention period of the storage (%v) must be longer than the full maintenance interval (%v), otherwise blobs lose protection before their retention is extended", period, opt.MaintenanceInterval)
+	}
+
+	// the format blob always exists and must always be protected.
+	formatExtended, err := blob.ExtendRetention(ctx, st, repo.FormatBlobID, opt.minRetainUntil())
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to extend retention of format blob")
+	}
+
+	n, err := extendBlobRetentionInUse(ctx, rep, st, opt)
+
+	if formatExtended {
+		n++
+	}
+
+	return n, err
+}
+
+func (o ExtendBlobRetentionOptions) minRetainUntil() time.Time {
+	if o.ExtendWithin == 0 {
+		return time.Time{}
+	}
+
+	return clock.Now().Add(o.ExtendWithin)
+}
+
+// extendBlobRetentionInUse extends retention of blobs other than the format blob. Failures to extend individual
+// blobs are logged and don't stop the extension of remaining ones, since they will be retried by the next run.
+func extendBlobRetentionInUse(ctx context.Context, rep repo.DirectRepositoryWriter, st blob.Storage, opt ExtendBlobRetentionOptions) (int, error) {
+	if opt.Parallel == 0 {
+		opt.Parallel = 16
+	}
+
+	const extendQueueSize = 100
+
+	log(ctx).Infof("Extending retention of blobs in use...")
+
+	var (
+		mu           sync.Mutex
+		unreferenced = map[blob.ID]bool{}
+	)
+
+	if err := rep.ContentManager().IterateUnreferencedBlobs(ctx, []blob.ID{
+		content.PackBlobIDPrefixRegular, content.PackBlobIDPrefixSpecial, content.BlobIDPrefixSession,
+	}, opt.Parallel, func(bm blob.Metadata) error {
+		mu.Lock()
+		unreferenced[bm.BlobID] = true
+		mu.Unlock()
+
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "error looking for unreferenced blobs")
+	}
+
+	var extended, retained, failed stats.CountSum
+
+	minRetainUntil := opt.minRetainUntil()
+
+	eg, ctx := errgroup.WithContext(ctx)
+	toExtend := make(chan blob.Metadata, extendQueueSize)
+
+	for i := 0; i < opt.Parallel; i++ {
+		eg.Go(func() error {
+			for bm := range toExtend {
+				ok, err := blob.ExtendRetention(ctx, st, bm.BlobID, minRetainUntil)
+
+				switch {
+				case errors.Is(err, blob.ErrBlobNotFound):
+				case err != nil:
+					log(ctx).Errorf("unable to extend retention of blob %q: %v", bm.BlobID, err)
+					failed.Add(bm.Length)
+				case ok:
+					extended.Add(bm.Length)
+				default:
+					retained.Add(bm.Length)
+				}
+			}
+
+			return nil
+		})
+	}
+
+	eg.Go(func() error {
+		defer close(toExtend)
+
+		// nolint:wrapcheck
+		return st.ListBlobs(ctx, "", func(bm blob.Metadata) error {
+			if unreferenced[bm.BlobID] || bm.BlobID == repo.FormatBlobID {
+				return nil
+			}
+
+			select {
+			case toExtend <- bm:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	})
+
+	if err := eg.Wait(); err != nil {
+		return 0, errors.Wrap(err, "error extending blob retention")
+	}
+
+	cnt, size := extended.Approximate()
+
+	log(ctx).Infof("Extended retention of %v blobs (%v)", cnt, units.BytesStringBase10(size))
+
+	if retainedCount, retainedSize := retained.Approximate(); retainedCount > 0 {
+		log(ctx).Infof("Retention of %v blobs (%v) did not need to be extended", retainedCount, units.BytesStringBase10(retainedSize))
+	}
+
+	if failedCount, failedSize := failed.Approximate(); failedCount > 0 {
+		log(ctx).Errorf("Failed to extend retention of %v blobs (%v)", failedCount, units.BytesStringBase10(failedSize))
+	}
+
+	return int(cnt), nil
+}
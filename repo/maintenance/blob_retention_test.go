@@ -0,0 +1,157 @@
+package maintenance
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/object"
+)
+
+// retentionRecordingStorage simulates blob retention and records blobs whose retention has been extended.
+type retentionRecordingStorage struct {
+	blob.Storage
+
+	period time.Duration
+	failID blob.ID
+
+	mu          sync.Mutex
+	retainUntil map[blob.ID]time.Time
+	extended    map[blob.ID]bool
+}
+
+func (s *retentionRecordingStorage) ExtendBlobRetention(ctx context.Context, id blob.ID, minRetainUntil time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id == s.failID {
+		return false, errors.Errorf("some error")
+	}
+
+	if !minRetainUntil.IsZero() && !s.retainUntil[id].Before(minRetainUntil) {
+		return false, nil
+	}
+
+	s.retainUntil[id] = clock.Now().Add(s.period)
+	s.extended[id] = true
+
+	return true, nil
+}
+
+func (s *retentionRecordingStorage) BlobRetentionPeriod() time.Duration {
+	return s.period
+}
+
+func (s *retentionRecordingStorage) BlobRetainedUntil(ctx context.Context, id blob.ID) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.retainUntil[id], nil
+}
+
+func TestExtendBlobRetention(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	w := env.RepositoryWriter.NewObjectWriter(ctx, object.WriterOptions{})
+	_, err := io.WriteString(w, "hello world!")
+	require.NoError(t, err)
+	_, err = w.Result()
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	// storage without retention is a no-op.
+	n, err := ExtendBlobRetention(ctx, env.RepositoryWriter, ExtendBlobRetentionOptions{})
+	require.NoError(t, err)
+	require.Equal(t, 0, n)
+
+	const (
+		unreferencedBlobID blob.ID = "pdeadbeef1"
+		failingBlobID      blob.ID = "xdeadbeef2"
+	)
+
+	mustPutDummyBlob(t, env.RepositoryWriter.BlobStorage(), unreferencedBlobID)
+	mustPutDummyBlob(t, env.RepositoryWriter.BlobStorage(), failingBlobID)
+
+	st := &retentionRecordingStorage{
+		Storage:     env.RepositoryWriter.BlobStorage(),
+		period:      10 * time.Hour,
+		failID:      failingBlobID,
+		retainUntil: map[blob.ID]time.Time{},
+		extended:    map[blob.ID]bool{},
+	}
+
+	extend := func(opt ExtendBlobRetentionOptions) int {
+		t.Helper()
+
+		n, err := extendBlobRetention(ctx, env.RepositoryWriter, st, opt)
+		require.NoError(t, err)
+
+		return n
+	}
+
+	// failure to extend one blob does not prevent extending the others.
+	n = extend(ExtendBlobRetentionOptions{})
+
+	allBlobs, err := blob.ListAllBlobs(ctx, st, "")
+	require.NoError(t, err)
+
+	// all blobs except the unreferenced and failing ones had their retention extended.
+	require.Equal(t, len(allBlobs)-2, n)
+	require.Len(t, st.extended, n)
+	require.True(t, st.extended[repo.FormatBlobID])
+	require.False(t, st.extended[unreferencedBlobID])
+	require.False(t, st.extended[failingBlobID])
+
+	for _, bm := range allBlobs {
+		if bm.BlobID != unreferencedBlobID && bm.BlobID != failingBlobID {
+			require.True(t, st.extended[bm.BlobID], bm.BlobID)
+		}
+	}
+
+	// blobs retained beyond the requested time are not extended again.
+	st.extended = map[blob.ID]bool{}
+
+	// blobs that are already retained long enough are not counted as extended.
+	require.Equal(t, 0, extend(ExtendBlobRetentionOptions{ExtendWithin: 5 * time.Hour}))
+	require.Empty(t, st.extended)
+
+	require.Equal(t, len(allBlobs)-2, extend(ExtendBlobRetentionOptions{ExtendWithin: 20 * time.Hour}))
+	require.Len(t, st.extended, len(allBlobs)-2)
+
+	// retention period must be longer than the maintenance interval, which is verified
+	// before the retention of any blob, including the format blob, is extended.
+	st.extended = map[blob.ID]bool{}
+
+	_, err = extendBlobRetention(ctx, env.RepositoryWriter, st, ExtendBlobRetentionOptions{MaintenanceInterval: 10 * time.Hour})
+	require.Error(t, err)
+	require.Empty(t, st.extended)
+
+	n, err = extendBlobRetention(ctx, env.RepositoryWriter, st, ExtendBlobRetentionOptions{MaintenanceInterval: 9 * time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, len(allBlobs)-2, n)
+}
+
+func TestExtendBlobRetentionTaskSkippedWithoutRetention(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	s := &Schedule{}
+
+	require.NoError(t, runTaskExtendBlobRetention(ctx, RunParameters{
+		rep:    env.RepositoryWriter,
+		Mode:   ModeFull,
+		Params: &Params{FullCycle: CycleParams{Enabled: true, Interval: time.Hour}},
+	}, s))
+
+	require.Empty(t, s.Runs[TaskExtendBlobRetention])
+}
@@ -0,0 +1,136 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+)
+
+// ReencryptContents re-encrypts contents and BLOBs written using master keys replaced by key rotation
+// with the current master key. Each run picks up the data that has not been re-encrypted yet,
+// so it can be safely interrupted and resumed.
+func ReencryptContents(ctx context.Context, rep repo.DirectRepositoryWriter, safety SafetyParameters) error {
+	log(ctx).Infof("Re-encrypting contents using the current master key...")
+
+	if err := RewriteContents(ctx, rep, &RewriteContentsOptions{
+		ContentIDRange:     content.AllIDs,
+		PreviousMasterKeys: true,
+	}, safety); err != nil {
+		return err
+	}
+
+	n, err := rep.ContentManager().ReencryptBlobs(ctx)
+	if err != nil {
+		return errors.Wrap(err, "error re-encrypting blobs")
+	}
+
+	log(ctx).Infof("Re-encrypted %v blobs.", n)
+
+	return nil
+}
+
+// RetireMasterKeys removes master keys replaced by key rotation from the repository after re-encrypting
+// any remaining BLOBs and verifying that no contents encrypted using them remain.
+//
+// Clients pick up rotated master keys when they refresh the repository, but sessions started before
+// that may still be writing contents encrypted using previous master keys, so retirement is refused
+// while any of them is active. Remaining contents are counted after refreshing the indexes,
+// immediately before the keys are retired.
+func RetireMasterKeys(ctx context.Context, rep repo.DirectRepositoryWriter, safety SafetyParameters) error {
+	if _, err := rep.ContentManager().ReencryptBlobs(ctx); err != nil {
+		return errors.Wrap(err, "error re-encrypting blobs")
+	}
+
+	if err := verifyNoSessionsUsingPreviousMasterKeys(ctx, rep, safety); err != nil {
+		return err
+	}
+
+	if err := rep.Refresh(ctx); err != nil {
+		return errors.Wrap(err, "error refreshing indexes")
+	}
+
+	remaining, err := countContentsWithPreviousMasterKeys(ctx, rep)
+	if err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		return errors.Errorf("unable to retire previous master keys, %v contents are still encrypted using them", remaining)
+	}
+
+	log(ctx).Infof("Retiring previous master keys...")
+
+	// nolint:wrapcheck
+	return rep.RetirePreviousMasterKeys(ctx)
+}
+
+// verifyNoSessionsUsingPreviousMasterKeys returns an error if there are active sessions started before all clients
+// were expected to pick up the current master key, which may not have committed contents encrypted using previous ones.
+func verifyNoSessionsUsingPreviousMasterKeys(ctx context.Context, rep repo.DirectRepositoryWriter, safety SafetyParameters) error {
+	cutoff := masterKeyPickupTime(rep.ContentReader().ContentFormat(), safety)
+
+	activeSessions, err := rep.ContentManager().ListActiveSessions(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to load active sessions")
+	}
+
+	for sid, s := range activeSessions {
+		if age := rep.Time().Sub(s.CheckpointTime); age >= safety.SessionExpirationAge {
+			continue
+		}
+
+		if s.StartTime.Before(cutoff) {
+			return errors.Errorf("unable to retire previous master keys, session %v started at %v by %v@%v may be using them", sid, s.StartTime, s.User, s.Host)
+		}
+	}
+
+	return nil
+}
+
+// masterKeyPickupTime returns the time by which all clients are expected to have picked up the current master key.
+func masterKeyPickupTime(f content.FormattingOptions, safety SafetyParameters) time.Time {
+	var result time.Time
+
+	for _, g := range f.PreviousMasterKeys {
+		if t := g.ReplacedTime.Add(safety.MarginBetweenSnapshotGC); t.After(result) {
+			result = t
+		}
+	}
+
+	return result
+}
+
+// previousMasterKeyIDs returns the set of encryption key IDs of contents encrypted using previous master keys.
+func previousMasterKeyIDs(rep repo.DirectRepository) map[byte]bool {
+	result := map[byte]bool{}
+
+	for _, g := range rep.ContentReader().ContentFormat().PreviousMasterKeys {
+		result[byte(g.Generation)] = true
+	}
+
+	return result
+}
+
+func countContentsWithPreviousMasterKeys(ctx context.Context, rep repo.DirectRepository) (int, error) {
+	previous := previousMasterKeyIDs(rep)
+	count := 0
+
+	err := rep.ContentReader().IterateContents(
+		ctx,
+		content.IterateOptions{
+			Range:          content.AllIDs,
+			IncludeDeleted: true,
+		},
+		func(b content.Info) error {
+			if previous[b.GetEncryptionKeyID()] {
+				count++
+			}
+			return nil
+		})
+
+	return count, errors.Wrap(err, "error iterating contents")
+}
@@ -0,0 +1,181 @@
+package maintenance_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/faketime"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/maintenance"
+	"github.com/kopia/kopia/repo/object"
+)
+
+func TestReencryptContents(t *testing.T) {
+	ta := faketime.NewClockTimeWithOffset(0)
+	openOpt := func(o *repo.Options) {
+		o.TimeNowFunc = ta.NowFunc()
+	}
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		OpenOptions: openOpt,
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.BlockFormat.IndexVersion = content.MinKeyRotationIndexVersion
+		},
+	})
+
+	writeObject := func() (object.ID, string) {
+		data := uuid.NewString()
+
+		var oid object.ID
+
+		require.NoError(t, repo.WriteSession(ctx, env.RepositoryWriter, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.RepositoryWriter) error {
+			ow := w.NewObjectWriter(ctx, object.WriterOptions{})
+			fmt.Fprintf(ow, "%v", data)
+
+			var err error
+
+			oid, err = ow.Result()
+
+			return err
+		}))
+
+		return oid, data
+	}
+
+	verifyObject := func(oid object.ID, want string) {
+		r, err := env.RepositoryWriter.OpenObject(ctx, oid)
+		require.NoError(t, err)
+
+		defer r.Close()
+
+		got := make([]byte, len(want))
+		_, err = r.Read(got)
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	}
+
+	contentKeyIDs := func() map[byte]int {
+		result := map[byte]int{}
+
+		require.NoError(t, env.RepositoryWriter.ContentReader().IterateContents(ctx, content.IterateOptions{IncludeDeleted: true}, func(ci content.Info) error {
+			result[ci.GetEncryptionKeyID()]++
+			return nil
+		}))
+
+		return result
+	}
+
+	oid1, data1 := writeObject()
+
+	gen, err := env.RepositoryWriter.RotateMasterKey(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, gen)
+
+	env.MustReopen(t, openOpt)
+
+	f := env.RepositoryWriter.ContentReader().ContentFormat()
+	require.Equal(t, 1, f.KeyGeneration)
+	require.Equal(t, content.FormatVersion2, f.Version)
+	require.Len(t, f.PreviousMasterKeys, 1)
+
+	// contents written before the rotation remain readable, new contents use the new key.
+	verifyObject(oid1, data1)
+
+	oid2, data2 := writeObject()
+	verifyObject(oid2, data2)
+
+	before := contentKeyIDs()
+	require.NotZero(t, before[0])
+	require.NotZero(t, before[1])
+
+	// retirement is refused while contents encrypted using the previous key remain.
+	require.Error(t, maintenance.RetireMasterKeys(ctx, env.RepositoryWriter, maintenance.SafetyNone))
+
+	// move the clock forward so that re-encrypted index entries are newer than the original ones.
+	ta.Advance(time.Minute)
+
+	require.NoError(t, repo.DirectWriteSession(ctx, env.RepositoryWriter, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		return maintenance.ReencryptContents(ctx, w, maintenance.SafetyNone)
+	}))
+
+	env.MustReopen(t, openOpt)
+
+	after := contentKeyIDs()
+	require.Zero(t, after[0])
+	require.NotZero(t, after[1])
+
+	require.NoError(t, repo.DirectWriteSession(ctx, env.RepositoryWriter, repo.WriteSessionOptions{}, func(ctx context.Context, w repo.DirectRepositoryWriter) error {
+		return maintenance.RetireMasterKeys(ctx, w, maintenance.SafetyNone)
+	}))
+
+	// after retirement the repository opens and reads all data without the previous key.
+	env.MustReopen(t, openOpt)
+
+	f = env.RepositoryWriter.ContentReader().ContentFormat()
+	require.Equal(t, 1, f.KeyGeneration)
+	require.Empty(t, f.PreviousMasterKeys)
+
+	verifyObject(oid1, data1)
+	verifyObject(oid2, data2)
+}
+
+func TestRetireMasterKeysWithActiveSession(t *testing.T) {
+	ta := faketime.NewClockTimeWithOffset(0)
+	openOpt := func(o *repo.Options) {
+		o.TimeNowFunc = ta.NowFunc()
+	}
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		OpenOptions: openOpt,
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.BlockFormat.IndexVersion = content.MinKeyRotationIndexVersion
+		},
+	})
+
+	// session started before the rotation writes contents using the previous master key without committing them.
+	_, w, err := env.RepositoryWriter.NewWriter(ctx, repo.WriteSessionOptions{})
+	require.NoError(t, err)
+
+	ow := w.NewObjectWriter(ctx, object.WriterOptions{})
+	fmt.Fprintf(ow, "%v", uuid.NewString())
+
+	_, err = ow.Result()
+	require.NoError(t, err)
+
+	_, err = env.RepositoryWriter.RotateMasterKey(ctx)
+	require.NoError(t, err)
+
+	rep2, err := repo.Open(ctx, env.ConfigFile(), env.Password, &repo.Options{TimeNowFunc: ta.NowFunc()})
+	require.NoError(t, err)
+
+	defer rep2.Close(ctx)
+
+	_, w2, err := rep2.(repo.DirectRepository).NewDirectWriter(ctx, repo.WriteSessionOptions{})
+	require.NoError(t, err)
+
+	safety := maintenance.SafetyFull
+
+	ta.Advance(safety.MarginBetweenSnapshotGC + time.Hour)
+
+	// retirement is refused while the session is active.
+	err = maintenance.RetireMasterKeys(ctx, w2, safety)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "session")
+
+	// once the session is committed, its contents are found by the check done at retirement.
+	require.NoError(t, w.Flush(ctx))
+
+	err = maintenance.RetireMasterKeys(ctx, w2, safety)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "contents are still encrypted")
+
+	f := w2.ContentReader().ContentFormat()
+	require.Len(t, f.PreviousMasterKeys, 1)
+}
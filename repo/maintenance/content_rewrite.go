@@ -25,6 +25,9 @@ type RewriteContentsOptions struct {
 	ShortPacks     bool
 	FormatVersion  int
 	DryRun         bool
+
+	// PreviousMasterKeys causes rewriting of contents encrypted using master keys replaced by key rotation.
+	PreviousMasterKeys bool
 }
 
 const shortPackThresholdPercent = 60 // blocks below 60% of max block size are considered to be 'short
@@ -137,6 +140,11 @@ func getContentToRewrite(ctx context.Context, rep repo.DirectRepository, opt *Re
 		if opt.FormatVersion != 0 {
 			findContentWithFormatVersion(ctx, rep, ch, opt)
 		}
+
+		// add all contents encrypted using previous master keys
+		if opt.PreviousMasterKeys {
+			findContentWithPreviousMasterKeys(ctx, rep, ch, opt)
+		}
 	}()
 
 	return ch
@@ -168,6 +176,23 @@ func(b content.Info) error {
 		})
 }
 
+func findContentWithPreviousMasterKeys(ctx context.Context, rep repo.DirectRepository, ch chan contentInfoOrError, opt *RewriteContentsOptions) {
+	previous := previousMasterKeyIDs(rep)
+
+	_ = rep.ContentReader().IterateContents(
+		ctx,
+		content.IterateOptions{
+			Range:          opt.ContentIDRange,
+			IncludeDeleted: true,
+		},
+		func(b content.Info) error {
+			if previous[b.GetEncryptionKeyID()] && strings.HasPrefix(string(b.GetPackBlobID()), string(opt.PackPrefix)) {
+				ch <- contentInfoOrError{Info: b}
+			}
+			return nil
+		})
+}
+
 func findContentInShortPacks(ctx context.Context, rep repo.DirectRepository, ch chan contentInfoOrError, threshold int64, opt *RewriteContentsOptions) {
 	var prefixes []blob.ID
 
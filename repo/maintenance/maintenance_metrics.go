@@ -0,0 +1,98 @@
+package maintenance
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var tagKeyTask = tag.MustNewKey("task")
+
+// maintenance metrics.
+var (
+	metricTaskLastRunTime = stats.Int64(
+		"kopia/maintenance/last_run_timestamp_seconds",
+		"Time when the most recent run of a maintenance task finished, in seconds since the epoch",
+		stats.UnitSeconds,
+	)
+
+	metricTaskLastRunDuration = stats.Float64(
+		"kopia/maintenance/last_run_duration_seconds",
+		"Duration of the most recent run of a maintenance task",
+		stats.UnitSeconds,
+	)
+
+	metricTaskLastRunSuccess = stats.Int64(
+		"kopia/maintenance/last_run_success",
+		"Whether the most recent run of a maintenance task succeeded (1) or failed (0)",
+		stats.UnitDimensionless,
+	)
+
+	metricNextFullMaintenanceTime = stats.Int64(
+		"kopia/maintenance/next_full_maintenance_timestamp_seconds",
+		"Time when full maintenance is scheduled to run next, in seconds since the epoch",
+		stats.UnitSeconds,
+	)
+
+	metricNextQuickMaintenanceTime = stats.Int64(
+		"kopia/maintenance/next_quick_maintenance_timestamp_seconds",
+		"Time when quick maintenance is scheduled to run next, in seconds since the epoch",
+		stats.UnitSeconds,
+	)
+)
+
+func lastValueView(m stats.Measure, tagKeys ...tag.Key) *view.View {
+	return &view.View{
+		Name:        m.Name(),
+		Aggregation: view.LastValue(),
+		Description: m.Description(),
+		Measure:     m,
+		TagKeys:     tagKeys,
+	}
+}
+
+func init() {
+	if err := view.Register(
+		lastValueView(metricTaskLastRunTime, tagKeyTask),
+		lastValueView(metricTaskLastRunDuration, tagKeyTask),
+		lastValueView(metricTaskLastRunSuccess, tagKeyTask),
+		lastValueView(metricNextFullMaintenanceTime),
+		lastValueView(metricNextQuickMaintenanceTime),
+	); err != nil {
+		panic("unable to register opencensus views: " + err.Error())
+	}
+}
+
+// RecordScheduleMetrics records the outcome of the most recent run of each maintenance task
+// and the next scheduled maintenance times.
+func RecordScheduleMetrics(ctx context.Context, s *Schedule) {
+	if !s.NextFullMaintenanceTime.IsZero() {
+		stats.Record(ctx, metricNextFullMaintenanceTime.M(s.NextFullMaintenanceTime.Unix()))
+	}
+
+	if !s.NextQuickMaintenanceTime.IsZero() {
+		stats.Record(ctx, metricNextQuickMaintenanceTime.M(s.NextQuickMaintenanceTime.Unix()))
+	}
+
+	for taskType, runs := range s.Runs {
+		if len(runs) == 0 {
+			continue
+		}
+
+		// runs are stored newest first.
+		last := runs[0]
+
+		var success int64
+		if last.Success {
+			success = 1
+		}
+
+		_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(tagKeyTask, string(taskType))},
+			metricTaskLastRunTime.M(last.End.Unix()),
+			metricTaskLastRunDuration.M(last.End.Sub(last.Start).Seconds()),
+			metricTaskLastRunSuccess.M(success),
+		)
+	}
+}
@@ -0,0 +1,44 @@
+package maintenance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opencensus.io/stats/view"
+)
+
+func TestRecordScheduleMetrics(t *testing.T) {
+	t0 := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	s := &Schedule{}
+	s.ReportRun(TaskSnapshotGarbageCollection, RunInfo{Start: t0, End: t0.Add(time.Minute), Success: true})
+	s.ReportRun(TaskSnapshotGarbageCollection, RunInfo{Start: t0.Add(time.Hour), End: t0.Add(time.Hour + 2*time.Minute), Error: "some error"})
+
+	RecordScheduleMetrics(context.Background(), s)
+
+	require.Equal(t, 0.0, lastValueForTask(t, metricTaskLastRunSuccess.Name(), TaskSnapshotGarbageCollection))
+	require.Equal(t, 120.0, lastValueForTask(t, metricTaskLastRunDuration.Name(), TaskSnapshotGarbageCollection))
+	require.Equal(t, float64(t0.Add(time.Hour+2*time.Minute).Unix()), lastValueForTask(t, metricTaskLastRunTime.Name(), TaskSnapshotGarbageCollection))
+}
+
+func lastValueForTask(t *testing.T, viewName string, taskType TaskType) float64 {
+	t.Helper()
+
+	rows, err := view.RetrieveData(viewName)
+	require.NoError(t, err)
+
+	for _, r := range rows {
+		for _, tg := range r.Tags {
+			if tg.Key == tagKeyTask && tg.Value == string(taskType) {
+				// nolint:forcetypeassert
+				return r.Data.(*view.LastValueData).Value
+			}
+		}
+	}
+
+	t.Fatalf("no data for %v", taskType)
+
+	return 0
+}
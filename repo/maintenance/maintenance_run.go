@@ -11,6 +11,7 @@
 
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
 	"github.com/kopia/kopia/repo/logging"
 )
@@ -41,6 +42,9 @@
 	TaskDropDeletedContentsFull   = "full-drop-deleted-content"
 	TaskIndexCompaction           = "index-compaction"
 	TaskCleanupLogs               = "cleanup-logs"
+	TaskExtendBlobRetention       = "extend-blob-retention"
+	TaskReencryptContents         = "reencrypt-contents"
+	TaskRetireMasterKeys          = "retire-master-keys"
 )
 
 // shouldRun returns Mode if repository is due for periodic maintenance.
@@ -263,6 +267,12 @@ func notDeletingOrphanedBlobs(ctx context.Context, s *Schedule, safety SafetyPar
 	log(ctx).Infof("Skipping blob deletion because not enough time has passed yet (%v left).", left)
 }
 
+func notRetiringMasterKeys(ctx context.Context, f content.FormattingOptions, s *Schedule, safety SafetyParameters) {
+	left := clock.Until(nextMasterKeyRetirementTime(f, s, safety)).Truncate(time.Second)
+
+	log(ctx).Infof("Skipping retirement of previous master keys because not enough time has passed yet (%v left).", left)
+}
+
 func runTaskIndexCompaction(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
 	return ReportRun(ctx, runParams.rep, TaskIndexCompaction, s, func() error {
 		return IndexCompaction(ctx, runParams.rep, safety)
@@ -319,6 +329,34 @@ func runTaskRewriteContentsFull(ctx context.Context, runParams RunParameters, s
 	})
 }
 
+func runTaskReencryptContents(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
+	if len(runParams.rep.ContentReader().ContentFormat().PreviousMasterKeys) == 0 {
+		return nil
+	}
+
+	remaining, err := countContentsWithPreviousMasterKeys(ctx, runParams.rep)
+	if err != nil {
+		return err
+	}
+
+	if remaining > 0 {
+		log(ctx).Infof("Found %v contents encrypted using previous master keys.", remaining)
+
+		return ReportRun(ctx, runParams.rep, TaskReencryptContents, s, func() error {
+			return ReencryptContents(ctx, runParams.rep, safety)
+		})
+	}
+
+	if !shouldRetireMasterKeys(runParams.rep.Time(), runParams.rep.ContentReader().ContentFormat(), s, safety) {
+		notRetiringMasterKeys(ctx, runParams.rep.ContentReader().ContentFormat(), s, safety)
+		return nil
+	}
+
+	return ReportRun(ctx, runParams.rep, TaskRetireMasterKeys, s, func() error {
+		return RetireMasterKeys(ctx, runParams.rep, safety)
+	})
+}
+
 func runTaskDeleteOrphanedBlobsFull(ctx context.Context, runParams RunParameters, s *Schedule, safety SafetyParameters) error {
 	return ReportRun(ctx, runParams.rep, TaskDeleteOrphanedBlobsFull, s, func() error {
 		_, err := DeleteUnreferencedBlobs(ctx, runParams.rep, DeleteUnreferencedBlobsOptions{}, safety)
@@ -335,6 +373,25 @@ func runTaskDeleteOrphanedBlobsQuick(ctx context.Context, runParams RunParameter
 	})
 }
 
+func runTaskExtendBlobRetention(ctx context.Context, runParams RunParameters, s *Schedule) error {
+	if blob.RetentionPeriod(runParams.rep.BlobStorage()) == 0 {
+		// don't report the task for storage without retention.
+		return nil
+	}
+
+	// only extend blobs that would otherwise expire before the next full maintenance,
+	// allowing for the next run to be late.
+	opt := ExtendBlobRetentionOptions{
+		ExtendWithin:        2 * runParams.Params.FullCycle.Interval, // nolint:gomnd
+		MaintenanceInterval: runParams.Params.FullCycle.Interval,
+	}
+
+	return ReportRun(ctx, runParams.rep, TaskExtendBlobRetention, s, func() error {
+		_, err := ExtendBlobRetention(ctx, runParams.rep, opt)
+		return err
+	})
+}
+
 func runFullMaintenance(ctx context.Context, runParams RunParameters, safety SafetyParameters) error {
 	s, err := GetSchedule(ctx, runParams.rep)
 	if err != nil {
@@ -357,6 +414,12 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		notRewritingContents(ctx)
 	}
 
+	// re-encrypt contents written using master keys replaced by key rotation,
+	// orphaning old packs in the process, and retire the keys when done.
+	if err := runTaskReencryptContents(ctx, runParams, s, safety); err != nil {
+		return errors.Wrap(err, "error re-encrypting contents")
+	}
+
 	if shouldDeleteOrphanedPacks(runParams.rep.Time(), s, safety) {
 		// delete orphaned packs after some time.
 		if err := runTaskDeleteOrphanedBlobsFull(ctx, runParams, s, safety); err != nil {
@@ -366,6 +429,12 @@ func runFullMaintenance(ctx context.Context, runParams RunParameters, safety Saf
 		notDeletingOrphanedBlobs(ctx, s, safety)
 	}
 
+	// extend retention of blobs that are still in use after all deletions have been performed,
+	// so that unreferenced blobs can expire.
+	if err := runTaskExtendBlobRetention(ctx, runParams, s); err != nil {
+		return errors.Wrap(err, "error extending blob retention")
+	}
+
 	return nil
 }
 
@@ -411,7 +480,7 @@ func shouldDeleteOrphanedPacks(now time.Time, s *Schedule, safety SafetyParamete
 }
 
 func nextBlobDeleteTime(s *Schedule, safety SafetyParameters) time.Time {
-	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRewriteContentsQuick])
+	latestContentRewriteEndTime := maxEndTime(s.Runs[TaskRewriteContentsFull], s.Runs[TaskRewriteContentsQuick], s.Runs[TaskReencryptContents])
 	if latestContentRewriteEndTime.IsZero() {
 		return time.Time{}
 	}
@@ -419,6 +488,26 @@ func nextBlobDeleteTime(s *Schedule, safety SafetyParameters) time.Time {
 	return latestContentRewriteEndTime.Add(safety.MinRewriteToOrphanDeletionDelay)
 }
 
+// shouldRetireMasterKeys returns true if it's ok to retire previous master keys.
+// clients pick up the rotated master key when they periodically refresh the repository and clients
+// who have old indexes cached may be trying to read contents from packs orphaned by re-encryption,
+// so we wait for both to settle. RetireMasterKeys additionally verifies that no sessions started before
+// that are still active.
+func shouldRetireMasterKeys(now time.Time, f content.FormattingOptions, s *Schedule, safety SafetyParameters) bool {
+	return now.After(nextMasterKeyRetirementTime(f, s, safety))
+}
+
+func nextMasterKeyRetirementTime(f content.FormattingOptions, s *Schedule, safety SafetyParameters) time.Time {
+	result := masterKeyPickupTime(f, safety)
+
+	// contents rewritten for any reason orphan packs encrypted using previous master keys.
+	if t := nextBlobDeleteTime(s, safety); t.After(result) {
+		result = t
+	}
+
+	return result
+}
+
 func hadRecentFullRewrite(s *Schedule) bool {
 	return maxEndTime(s.Runs[TaskRewriteContentsFull]).After(maxEndTime(s.Runs[TaskRewriteContentsQuick]))
 }
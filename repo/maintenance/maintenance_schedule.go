@@ -164,5 +164,7 @@ func ReportRun(ctx context.Context, rep repo.DirectRepositoryWriter, taskType Ta
 		log(ctx).Errorf("unable to report run: %v", err)
 	}
 
+	RecordScheduleMetrics(ctx, s)
+
 	return runErr
 }
@@ -1,6 +1,7 @@
 package object
 
 // indirectObjectEntry represents an entry in indirect object stream.
+// Entries without an object represent holes, which read as zero bytes.
 type indirectObjectEntry struct {
 	Start  int64 `json:"s,omitempty"`
 	Length int64 `json:"l,omitempty"`
@@ -11,6 +12,10 @@ func (i *indirectObjectEntry) endOffset() int64 {
 	return i.Start + i.Length
 }
 
+func (i *indirectObjectEntry) isHole() bool {
+	return i.Object == ""
+}
+
 /*
 
 {"stream":"kopia:indirect","entries":[
@@ -19,6 +24,8 @@ func (i *indirectObjectEntry) endOffset() int64 {
 {"s":3000180,"l":4352499,"o":"D6b6eb48ca5361d06d72fe193813e42e1"},
 {"s":7352679,"l":1170821,"o":"Dd14653f76b63802ed48be64a0e67fea9"},
 
-{"s":91094118,"l":1645153,"o":"Daa55df764d881a1daadb5ea9de17abbb"}
+{"s":91094118,"l":1645153,"o":"Daa55df764d881a1daadb5ea9de17abbb"},
+{"s":92739271,"l":1048576},
+{"s":93787847,"l":4096,"o":"D0b4fc2d6e7c8c4b5b2ff0e86e6ba1e2d"}
 ]}
 */
@@ -27,6 +27,19 @@ type Reader interface {
 	Length() int64
 }
 
+// Region describes a range of bytes within an object.
+type Region struct {
+	Offset int64
+	Length int64
+}
+
+// ReaderWithDataRegions is optionally implemented by Reader of objects that may contain holes.
+type ReaderWithDataRegions interface {
+	// DataRegions returns regions of the object that are backed by data or nil if the object has no holes.
+	// Bytes outside of the returned regions read as zeros.
+	DataRegions() []Region
+}
+
 type contentReader interface {
 	ContentInfo(ctx context.Context, contentID content.ID) (content.Info, error)
 	GetContent(ctx context.Context, contentID content.ID) ([]byte, error)
@@ -40,7 +53,8 @@ type contentManager interface {
 
 // Format describes the format of objects in a repository.
 type Format struct {
-	Splitter string `json:"splitter,omitempty"` // splitter used to break objects into pieces of content
+	Splitter      string `json:"splitter,omitempty"`      // splitter used to break objects into pieces of content
+	SparseObjects bool   `json:"sparseObjects,omitempty"` // allows objects with holes, which older clients can't read
 }
 
 // Manager implements a content-addressable storage on top of blob storage.
@@ -85,15 +85,21 @@ func (f *fakeContentManager) Flush(ctx context.Context) error {
 func setupTest(t *testing.T, compressionHeaderID map[content.ID]compression.HeaderID) (map[content.ID][]byte, *Manager) {
 	t.Helper()
 
+	return setupTestWithFormat(t, compressionHeaderID, Format{
+		Splitter: "FIXED-1M",
+	})
+}
+
+func setupTestWithFormat(t *testing.T, compressionHeaderID map[content.ID]compression.HeaderID, f Format) (map[content.ID][]byte, *Manager) {
+	t.Helper()
+
 	data := map[content.ID][]byte{}
 
 	r, err := NewObjectManager(testlogging.Context(t), &fakeContentManager{
 		data:                       data,
 		supportsContentCompression: compressionHeaderID != nil,
 		compresionIDs:              compressionHeaderID,
-	}, Format{
-		Splitter: "FIXED-1M",
-	})
+	}, f)
 	if err != nil {
 		t.Fatalf("can't create object manager: %v", err)
 	}
@@ -458,6 +464,129 @@ func TestHMAC(t *testing.T) {
 	}
 }
 
+func TestWriteHole(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, om := setupTestWithFormat(t, nil, Format{Splitter: "FIXED-1M", SparseObjects: true})
+
+	data1 := bytes.Repeat([]byte{1}, 3000)
+	data2 := bytes.Repeat([]byte{2}, 500)
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	defer w.Close()
+
+	_, err := w.Write(data1)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteHole(10000))
+	require.NoError(t, w.WriteHole(5000))
+	_, err = w.Write(data2)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteHole(1000))
+
+	oid, err := w.Result()
+	require.NoError(t, err)
+
+	var expected []byte
+
+	expected = append(expected, data1...)
+	expected = append(expected, make([]byte, 15000)...)
+	expected = append(expected, data2...)
+	expected = append(expected, make([]byte, 1000)...)
+
+	r, err := Open(ctx, om.contentMgr, oid)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	require.Equal(t, int64(len(expected)), r.Length())
+	require.Equal(t, []Region{{0, 3000}, {18000, 500}}, r.(ReaderWithDataRegions).DataRegions())
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, expected, got)
+
+	// seek into the middle of the hole and read across data boundary
+	_, err = r.Seek(17990, io.SeekStart)
+	require.NoError(t, err)
+
+	got = make([]byte, 20)
+	_, err = io.ReadFull(r, got)
+	require.NoError(t, err)
+	require.Equal(t, expected[17990:18010], got)
+
+	// holes do not reference any contents.
+	contentIDs, err := VerifyObject(ctx, om.contentMgr, oid)
+	require.NoError(t, err)
+	require.Len(t, contentIDs, 3)
+}
+
+func TestWriteHoleOnly(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, om := setupTestWithFormat(t, nil, Format{Splitter: "FIXED-1M", SparseObjects: true})
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	defer w.Close()
+
+	require.NoError(t, w.WriteHole(1<<30))
+
+	oid, err := w.Result()
+	require.NoError(t, err)
+
+	_, isIndirect := oid.IndexObjectID()
+	require.True(t, isIndirect)
+
+	r, err := Open(ctx, om.contentMgr, oid)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	require.Equal(t, int64(1<<30), r.Length())
+	require.Equal(t, []Region{}, r.(ReaderWithDataRegions).DataRegions())
+
+	_, err = r.Seek(-10, io.SeekEnd)
+	require.NoError(t, err)
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, 10), got)
+}
+
+func TestWriteHoleWithoutSparseObjects(t *testing.T) {
+	ctx := testlogging.Context(t)
+
+	_, om := setupTestWithFormat(t, nil, Format{Splitter: "FIXED-1M"})
+
+	data1 := bytes.Repeat([]byte{1}, 3000)
+
+	w := om.NewWriter(ctx, WriterOptions{})
+	defer w.Close()
+
+	_, err := w.Write(data1)
+	require.NoError(t, err)
+	require.NoError(t, w.WriteHole(200000))
+
+	oid, err := w.Result()
+	require.NoError(t, err)
+
+	r, err := Open(ctx, om.contentMgr, oid)
+	require.NoError(t, err)
+
+	defer r.Close()
+
+	// holes are stored as data, which older clients can read.
+	if dr, ok := r.(ReaderWithDataRegions); ok {
+		require.Nil(t, dr.DataRegions())
+	}
+
+	_, isIndex := oid.IndexObjectID()
+	require.False(t, isIndex)
+
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, append(data1, make([]byte, 200000)...), got)
+}
+
 // nolint:gocyclo
 func TestConcatenate(t *testing.T) {
 	ctx := testlogging.Context(t)
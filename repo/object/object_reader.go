@@ -39,8 +39,8 @@ type objectReader struct {
 	totalLength     int64 // Overall length
 
 	currentChunkIndex    int    // Index of current chunk in the seek table
-	currentChunkData     []byte // Current chunk data
-	currentChunkPosition int    // Read position in the current chunk
+	currentChunkData     []byte // Current chunk data, always nil for holes
+	currentChunkPosition int64  // Read position in the current chunk
 }
 
 func (r *objectReader) Read(buffer []byte) (int, error) {
@@ -52,12 +52,37 @@ func (r *objectReader) Read(buffer []byte) (int, error) {
 	}
 
 	for remaining > 0 {
+		if r.currentChunkIndex < len(r.seekTable) && r.seekTable[r.currentChunkIndex].isHole() {
+			toZero := r.seekTable[r.currentChunkIndex].Length - r.currentChunkPosition
+			if toZero == 0 {
+				// EOF on current hole
+				r.currentChunkIndex++
+				r.currentChunkPosition = 0
+
+				continue
+			}
+
+			if toZero > int64(remaining) {
+				toZero = int64(remaining)
+			}
+
+			zeroBytes(buffer[readBytes : readBytes+int(toZero)])
+
+			r.currentChunkPosition += toZero
+			r.currentPosition += toZero
+			readBytes += int(toZero)
+			remaining -= int(toZero)
+
+			continue
+		}
+
 		if r.currentChunkData != nil {
-			toCopy := len(r.currentChunkData) - r.currentChunkPosition
+			toCopy := len(r.currentChunkData) - int(r.currentChunkPosition)
 			if toCopy == 0 {
 				// EOF on current chunk
 				r.closeCurrentChunk()
 				r.currentChunkIndex++
+				r.currentChunkPosition = 0
 
 				continue
 			}
@@ -67,9 +92,9 @@ func (r *objectReader) Read(buffer []byte) (int, error) {
 			}
 
 			copy(buffer[readBytes:],
-				r.currentChunkData[r.currentChunkPosition:r.currentChunkPosition+toCopy])
+				r.currentChunkData[r.currentChunkPosition:r.currentChunkPosition+int64(toCopy)])
 
-			r.currentChunkPosition += toCopy
+			r.currentChunkPosition += int64(toCopy)
 			r.currentPosition += int64(toCopy)
 			readBytes += toCopy
 			remaining -= toCopy
@@ -94,6 +119,12 @@ func (r *objectReader) Read(buffer []byte) (int, error) {
 	return readBytes, nil
 }
 
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
 func (r *objectReader) openCurrentChunk() error {
 	st := r.seekTable[r.currentChunkIndex]
 
@@ -171,13 +202,13 @@ func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
 		r.currentChunkIndex = index
 	}
 
-	if r.currentChunkData == nil {
+	if r.currentChunkData == nil && !r.seekTable[index].isHole() {
 		if err := r.openCurrentChunk(); err != nil {
 			return 0, err
 		}
 	}
 
-	r.currentChunkPosition = int(offset - chunkStartOffset)
+	r.currentChunkPosition = offset - chunkStartOffset
 	r.currentPosition = offset
 
 	return r.currentPosition, nil
@@ -187,6 +218,34 @@ func (r *objectReader) Close() error {
 	return nil
 }
 
+var _ ReaderWithDataRegions = (*objectReader)(nil)
+
+// DataRegions returns regions of the object that are backed by data or nil if the object has no holes.
+func (r *objectReader) DataRegions() []Region {
+	hasHoles := false
+	result := []Region{}
+
+	for _, st := range r.seekTable {
+		if st.isHole() {
+			hasHoles = true
+			continue
+		}
+
+		if n := len(result); n > 0 && result[n-1].Offset+result[n-1].Length == st.Start {
+			result[n-1].Length += st.Length
+			continue
+		}
+
+		result = append(result, Region{Offset: st.Start, Length: st.Length})
+	}
+
+	if !hasHoles {
+		return nil
+	}
+
+	return result
+}
+
 func (r *objectReader) Length() int64 {
 	return r.totalLength
 }
@@ -223,6 +282,10 @@ func verifyIndirectObjectInternal(ctx context.Context, cr contentReader, indexOb
 	}
 
 	for _, m := range seekTable {
+		if m.isHole() {
+			continue
+		}
+
 		err := verifyObjectInternal(ctx, cr, m.Object, tracker)
 		if err != nil {
 			return err
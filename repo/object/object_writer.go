@@ -20,6 +20,9 @@
 
 const indirectContentPrefix = "x"
 
+// zeroBufferSize is the size of the buffer used to write holes as data.
+const zeroBufferSize = 64 << 10
+
 // Writer allows writing content to the storage and supports automatic deduplication and encryption
 // of written data.
 type Writer interface {
@@ -32,6 +35,10 @@ type Writer interface {
 
 	// Result returns object ID representing all bytes written to the writer.
 	Result() (ID, error)
+
+	// WriteHole appends the specified number of zero bytes to the object without storing them.
+	// In repositories that don't support sparse objects, the zero bytes are written as data.
+	WriteHole(length int64) error
 }
 
 type contentIDTracker struct {
@@ -78,6 +85,7 @@ type objectWriter struct {
 	indirectIndexGrowMutex sync.Mutex
 	indirectIndex          []indirectObjectEntry
 	indirectIndexBuf       [4]indirectObjectEntry // small buffer so that we avoid allocations most of the time
+	lastEntryIsHole        bool                   // whether the last entry in indirectIndex is a hole
 
 	description string
 
@@ -115,6 +123,10 @@ func (w *objectWriter) Write(data []byte) (n int, err error) {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
+	return w.writeLocked(data)
+}
+
+func (w *objectWriter) writeLocked(data []byte) (n int, err error) {
 	dataLen := len(data)
 	w.totalLength += int64(dataLen)
 
@@ -149,6 +161,7 @@ func (w *objectWriter) flushBuffer() error {
 	w.indirectIndex[chunkID].Start = w.currentPosition
 	w.indirectIndex[chunkID].Length = int64(length)
 	w.currentPosition += int64(length)
+	w.lastEntryIsHole = false
 	w.indirectIndexGrowMutex.Unlock()
 
 	defer w.buffer.Reset()
@@ -184,6 +197,66 @@ func (w *objectWriter) flushBuffer() error {
 	return nil
 }
 
+// WriteHole appends the specified number of zero bytes to the object. Holes are recorded in the
+// index of the object and do not consume any storage, which allows sparse files to be represented cheaply.
+func (w *objectWriter) WriteHole(length int64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if length <= 0 {
+		return nil
+	}
+
+	if !w.om.Format.SparseObjects {
+		return w.writeZerosLocked(length)
+	}
+
+	// holes always start a new entry, so flush any pending data first.
+	if w.buffer.Len() > 0 {
+		if err := w.flushBuffer(); err != nil {
+			return err
+		}
+	}
+
+	w.indirectIndexGrowMutex.Lock()
+	if n := len(w.indirectIndex); n > 0 && w.lastEntryIsHole {
+		w.indirectIndex[n-1].Length += length
+	} else {
+		w.indirectIndex = append(w.indirectIndex, indirectObjectEntry{
+			Start:  w.currentPosition,
+			Length: length,
+		})
+	}
+	w.currentPosition += length
+	w.lastEntryIsHole = true
+	w.indirectIndexGrowMutex.Unlock()
+
+	w.totalLength += length
+
+	return nil
+}
+
+// writeZerosLocked writes the specified number of zero bytes as data, which is how holes are stored
+// in repositories that don't support sparse objects.
+func (w *objectWriter) writeZerosLocked(length int64) error {
+	var zeros [zeroBufferSize]byte
+
+	for length > 0 {
+		n := int64(len(zeros))
+		if n > length {
+			n = length
+		}
+
+		if _, err := w.writeLocked(zeros[0:n]); err != nil {
+			return err
+		}
+
+		length -= n
+	}
+
+	return nil
+}
+
 func (w *objectWriter) prepareAndWriteContentChunk(chunkID int, data []byte) error {
 	// allocate buffer to hold either compressed bytes or the uncompressed
 	b := w.om.bufferPool.Allocate(len(data) + maxCompressionOverheadPerSegment)
@@ -288,7 +361,7 @@ func (w *objectWriter) checkpointLocked() (ID, error) {
 		return "", nil
 	}
 
-	if len(w.indirectIndex) == 1 {
+	if len(w.indirectIndex) == 1 && !w.indirectIndex[0].isHole() {
 		return w.indirectIndex[0].Object, nil
 	}
 
@@ -14,10 +14,14 @@
 	"github.com/kopia/kopia/internal/atomicfile"
 	"github.com/kopia/kopia/internal/cache"
 	"github.com/kopia/kopia/internal/clock"
+	"github.com/kopia/kopia/internal/ctxutil"
 	"github.com/kopia/kopia/repo/blob"
 	loggingwrapper "github.com/kopia/kopia/repo/blob/logging"
+	metricswrapper "github.com/kopia/kopia/repo/blob/metrics"
 	"github.com/kopia/kopia/repo/blob/readonly"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
@@ -145,11 +149,18 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 		return nil, errors.Errorf("storage not set in the configuration file")
 	}
 
+	throttler, err := throttling.NewThrottler(lc.Throttling)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid throttling schedule")
+	}
+
 	st, err := blob.NewStorage(ctx, *lc.Storage)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot open storage")
 	}
 
+	st = metricswrapper.NewWrapper(st)
+
 	if options.TraceStorage != nil {
 		st = loggingwrapper.NewWrapper(st, options.TraceStorage, "[STORAGE] ")
 	}
@@ -158,19 +169,39 @@ func openDirect(ctx context.Context, configFile string, lc *LocalConfig, passwor
 		st = readonly.NewWrapper(st)
 	}
 
-	r, err := openWithConfig(ctx, st, lc, password, options, lc.Caching, configFile)
+	r, err := openWithConfig(ctx, st, throttler, lc, password, options, lc.Caching, configFile)
 	if err != nil {
 		st.Close(ctx) //nolint:errcheck
 		return nil, err
 	}
 
+	if dr, ok := r.(*directRepository); ok && dr.keySlotID != lc.KeySlotID {
+		if err := setKeySlotHint(configFile, dr.keySlotID); err != nil {
+			log(ctx).Errorf("unable to remember key slot: %v", err)
+		}
+	}
+
 	return r, nil
 }
 
+// setKeySlotHint updates the identifier of the key slot tried first when opening the repository.
+func setKeySlotHint(configFile, keySlotID string) error {
+	lc, err := LoadConfigFromFile(configFile)
+	if err != nil {
+		return err
+	}
+
+	lc.KeySlotID = keySlotID
+
+	return lc.writeToFile(configFile)
+}
+
 // openWithConfig opens the repository with a given configuration, avoiding the need for a config file.
-func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, password string, options *Options, caching *content.CachingOptions, configFile string) (DirectRepository, error) {
+func openWithConfig(ctx context.Context, st blob.Storage, throttler *throttling.Throttler, lc *LocalConfig, password string, options *Options, caching *content.CachingOptions, configFile string) (DirectRepository, error) {
 	caching = caching.CloneOrDefault()
 
+	st = throttling.NewWrapper(st, throttler)
+
 	// Read format blob, potentially from cache.
 	fb, err := readAndCacheFormatBlobBytes(ctx, st, caching.CacheDirectory, lc.FormatBlobCacheDuration)
 	if err != nil {
@@ -191,12 +222,17 @@ func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		return nil, errors.Errorf("unable to add checksum")
 	}
 
-	formatEncryptionKey, err := f.deriveFormatEncryptionKeyFromPassword(password)
+	passwordKey, keySlotID, err := f.passwordKeyFromPassword(password, lc.KeySlotID)
 	if err != nil {
 		return nil, err
 	}
 
-	repoConfig, err := f.decryptFormatBytes(formatEncryptionKey)
+	formatEncryptionKey, err := f.formatEncryptionKeyForSlot(passwordKey, keySlotID)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+
+	repoConfig, err := f.decryptFormatBytesForKeySlot(formatEncryptionKey, keySlotID)
 	if err != nil {
 		return nil, ErrInvalidPassword
 	}
@@ -221,11 +257,43 @@ func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 		DisableInternalLog:    options.DisableInternalLog,
 	}
 
+	if fo.PublicKeyEncryption != "" && lc.PrivateKeyFile != "" {
+		algorithm, pk, err := ReadPrivateKeyFile(lc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if algorithm != fo.PublicKeyEncryption {
+			return nil, errors.Errorf("private key algorithm %q does not match repository public-key encryption %q", algorithm, fo.PublicKeyEncryption)
+		}
+
+		cmOpts.PrivateKey = pk
+	}
+
+	if fo.EnablePasswordChange {
+		cmOpts.ReloadFormattingOptions = formattingOptionsReloader(ctxutil.Detach(ctx), st, passwordKey, keySlotID)
+	}
+
 	// do not embed repository format info in pack blobs when password change is enabled.
 	if fo.EnablePasswordChange {
 		cmOpts.RepositoryFormatBytes = nil
 	}
 
+	encodedBlobs := st
+
+	var eccStorage *ecc.Storage
+
+	if fo.ECC != "" {
+		enc, err := ecc.CreateEncoder(fo)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create error correction encoder")
+		}
+
+		// the format blob and other repository-level blobs are read before error correction parameters are known.
+		eccStorage = ecc.NewWrapper(st, enc, unprotectedBlobIDPrefix)
+		st = eccStorage
+	}
+
 	scm, err := content.NewSharedManager(ctx, st, fo, caching, cmOpts)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create shared content manager")
@@ -257,10 +325,15 @@ func openWithConfig(ctx context.Context, st blob.Storage, lc *LocalConfig, passw
 			cachingOptions:      *caching,
 			formatBlob:          f,
 			formatEncryptionKey: formatEncryptionKey,
+			passwordKey:         passwordKey,
+			keySlotID:           keySlotID,
 			timeNow:             cmOpts.TimeNow,
 			cliOpts:             lc.ClientOptions.ApplyDefaults(ctx, "Repository in "+st.DisplayName()),
 			configFile:          configFile,
 			nextWriterID:        new(int32),
+			throttler:           throttler,
+			eccStorage:          eccStorage,
+			encodedBlobs:        encodedBlobs,
 		},
 		closed: make(chan struct{}),
 	}
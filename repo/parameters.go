@@ -12,6 +12,10 @@
 
 // SetParameters changes mutable repository parameters.
 func (r *directRepository) SetParameters(ctx context.Context, m content.MutableParameters) error {
+	if err := r.requireFullAccess(); err != nil {
+		return err
+	}
+
 	f := r.formatBlob
 
 	repoConfig, err := f.decryptFormatBytes(r.formatEncryptionKey)
@@ -19,12 +23,12 @@ func (r *directRepository) SetParameters(ctx context.Context, m content.MutableP
 		return errors.Wrap(err, "unable to decrypt repository config")
 	}
 
-	if err := m.Validate(); err != nil {
+	repoConfig.FormattingOptions.MutableParameters = m
+
+	if err := repoConfig.FormattingOptions.Validate(); err != nil {
 		return errors.Wrap(err, "invalid parameters")
 	}
 
-	repoConfig.FormattingOptions.MutableParameters = m
-
 	if err := encryptFormatBytes(f, repoConfig, r.formatEncryptionKey, f.UniqueID); err != nil {
 		return errors.Errorf("unable to encrypt format bytes")
 	}
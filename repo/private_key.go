@@ -0,0 +1,53 @@
+package repo
+
+import (
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	privateKeyPEMType         = "KOPIA PRIVATE KEY"
+	privateKeyAlgorithmHeader = "Algorithm"
+	privateKeyFileMode        = 0o600
+)
+
+// WritePrivateKeyFile writes the private key used for public-key encryption of repository contents
+// to the provided file, which must not exist.
+func WritePrivateKeyFile(fname, algorithm string, privateKey []byte) error {
+	b := pem.EncodeToMemory(&pem.Block{
+		Type:    privateKeyPEMType,
+		Headers: map[string]string{privateKeyAlgorithmHeader: algorithm},
+		Bytes:   privateKey,
+	})
+
+	f, err := os.OpenFile(fname, os.O_CREATE|os.O_EXCL|os.O_WRONLY, privateKeyFileMode) //nolint:gosec
+	if err != nil {
+		return errors.Wrap(err, "unable to create private key file")
+	}
+
+	if _, err := f.Write(b); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		return errors.Wrap(err, "unable to write private key file")
+	}
+
+	return errors.Wrap(f.Close(), "unable to close private key file")
+}
+
+// ReadPrivateKeyFile reads the private key used for public-key encryption of repository contents
+// from the provided file.
+func ReadPrivateKeyFile(fname string) (algorithm string, privateKey []byte, err error) {
+	b, err := ioutil.ReadFile(fname) //nolint:gosec
+	if err != nil {
+		return "", nil, errors.Wrap(err, "unable to read private key file")
+	}
+
+	blk, _ := pem.Decode(b)
+	if blk == nil || blk.Type != privateKeyPEMType {
+		return "", nil, errors.Errorf("invalid private key file: %v", fname)
+	}
+
+	return blk.Headers[privateKeyAlgorithmHeader], blk.Bytes, nil
+}
@@ -10,7 +10,9 @@
 
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/repo/blob"
+	"github.com/kopia/kopia/repo/blob/throttling"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/ecc"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/manifest"
 	"github.com/kopia/kopia/repo/object"
@@ -63,6 +65,7 @@ type DirectRepository interface {
 	UniqueID() []byte
 	ConfigFilename() string
 	DeriveKey(purpose []byte, keyLength int) []byte
+	KeySlots() []KeySlotInfo
 	Token(password string) (string, error)
 }
 
@@ -75,6 +78,26 @@ type DirectRepositoryWriter interface {
 	ContentManager() *content.WriteManager
 	SetParameters(ctx context.Context, m content.MutableParameters) error
 	ChangePassword(ctx context.Context, newPassword string) error
+	ChangePasswordWithKeyDerivation(ctx context.Context, newPassword string, kd *KeyDerivationOptions) error
+	AddKeySlot(ctx context.Context, id, description, password string, kd *KeyDerivationOptions) error
+	AddWriteOnlyKeySlot(ctx context.Context, id, description, password string, kd *KeyDerivationOptions) error
+	RemoveKeySlot(ctx context.Context, id string) error
+	RotateMasterKey(ctx context.Context) (int, error)
+	RetirePreviousMasterKeys(ctx context.Context) error
+}
+
+// ThrottlingRepository is implemented by repositories whose blob storage bandwidth is limited by a Throttler.
+type ThrottlingRepository interface {
+	Throttler() *throttling.Throttler
+}
+
+// ErrorCorrectingRepository is implemented by repositories which may protect blobs with error correction codes.
+type ErrorCorrectingRepository interface {
+	// RepairedBlobs returns IDs of damaged blobs repaired using error correction codes since the repository was opened.
+	RepairedBlobs() []blob.ID
+
+	// EncodedBlobReader returns the reader of blobs as stored, including error correction codes.
+	EncodedBlobReader() blob.Reader
 }
 
 type directRepositoryParameters struct {
@@ -85,7 +108,12 @@ type directRepositoryParameters struct {
 	timeNow             func() time.Time
 	formatBlob          *formatBlob
 	formatEncryptionKey []byte
+	passwordKey         []byte // unlocks formatEncryptionKey using the key slot, even after it has been rotated
+	keySlotID           string
 	nextWriterID        *int32
+	throttler           *throttling.Throttler
+	eccStorage          *ecc.Storage // nil when error correction is disabled
+	encodedBlobs        blob.Storage // blob storage without decoding error correction codes
 }
 
 // directRepository is an implementation of repository that directly manipulates underlying storage.
@@ -104,7 +132,11 @@ type directRepository struct {
 // DeriveKey derives encryption key of the provided length from the master key.
 func (r *directRepository) DeriveKey(purpose []byte, keyLength int) []byte {
 	if r.cmgr.ContentFormat().EnablePasswordChange {
-		return deriveKeyFromMasterKey(r.cmgr.ContentFormat().MasterKey, r.uniqueID, purpose, keyLength)
+		// repositories using public-key encryption derive keys from the metadata key, which is also
+		// available to write-only clients.
+		cf := r.cmgr.ContentFormat()
+
+		return deriveKeyFromMasterKey(cf.GetMasterKey(), r.uniqueID, purpose, keyLength)
 	}
 
 	// version of kopia <v0.9 had a bug where certain keys were derived directly from
@@ -133,6 +165,25 @@ func (r *directRepository) ConfigFilename() string {
 	return r.configFile
 }
 
+// Throttler returns the throttler that limits the bandwidth of blob storage.
+func (r *directRepository) Throttler() *throttling.Throttler {
+	return r.throttler
+}
+
+// RepairedBlobs returns IDs of damaged blobs repaired using error correction codes since the repository was opened.
+func (r *directRepository) RepairedBlobs() []blob.ID {
+	if r.eccStorage == nil {
+		return nil
+	}
+
+	return r.eccStorage.RepairedBlobs()
+}
+
+// EncodedBlobReader returns the reader of blobs as stored, including error correction codes.
+func (r *directRepository) EncodedBlobReader() blob.Reader {
+	return r.encodedBlobs
+}
+
 // Crypter returns a Crypter object.
 func (r *directRepository) Crypter() *content.Crypter {
 	return r.sm.Crypter()
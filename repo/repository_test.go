@@ -3,6 +3,7 @@
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"math/rand"
 	"runtime/debug"
@@ -11,10 +12,14 @@
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/kopia/kopia/internal/gather"
 	"github.com/kopia/kopia/internal/repotesting"
 	"github.com/kopia/kopia/internal/testutil"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/blob"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/ecc"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/object"
 )
 
@@ -447,6 +452,254 @@ func TestChangePassword(t *testing.T) {
 	r.Close(ctx)
 }
 
+func TestArgon2idKeyDerivation(t *testing.T) {
+	kd := repo.KeyDerivationOptions{
+		Algorithm: repo.KeyDerivationArgon2id,
+		Argon2:    &repo.Argon2Parameters{Time: 1, Memory: 1024, Threads: 1},
+	}
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.KeyDerivation = kd
+		},
+	})
+
+	fb := mustGetFormatBlob(ctx, t, env)
+	require.Equal(t, repo.KeyDerivationArgon2id, fb.KeyAlgo)
+	require.Equal(t, kd.Argon2, fb.Argon2)
+
+	// migrate to a different set of parameters while changing password.
+	kd.Argon2 = &repo.Argon2Parameters{Time: 2, Memory: 2048, Threads: 2}
+
+	require.NoError(t, env.RepositoryWriter.ChangePasswordWithKeyDerivation(ctx, "new-password", &kd))
+	require.Equal(t, kd.Argon2, mustGetFormatBlob(ctx, t, env).Argon2)
+
+	r, err := repo.Open(ctx, env.RepositoryWriter.ConfigFilename(), "new-password", nil)
+	require.NoError(t, err)
+	r.Close(ctx)
+
+	kd.Argon2 = &repo.Argon2Parameters{Time: 1, Memory: 1, Threads: 1}
+	require.Error(t, env.RepositoryWriter.ChangePasswordWithKeyDerivation(ctx, "new-password", &kd))
+
+	kd.Argon2 = &repo.Argon2Parameters{Time: 1, Memory: repo.MaxArgon2Memory + 1, Threads: 1}
+	require.Error(t, env.RepositoryWriter.ChangePasswordWithKeyDerivation(ctx, "new-password", &kd))
+
+	kd.Argon2 = &repo.Argon2Parameters{Time: repo.MaxArgon2Time + 1, Memory: 1024, Threads: 1}
+	require.Error(t, env.RepositoryWriter.ChangePasswordWithKeyDerivation(ctx, "new-password", &kd))
+
+	require.Error(t, env.RepositoryWriter.ChangePasswordWithKeyDerivation(ctx, "new-password", &repo.KeyDerivationOptions{Algorithm: "no-such-algorithm"}))
+}
+
+func TestKeySlots(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	openWithPassword := func(password string) (repo.DirectRepository, error) {
+		r, err := repo.Open(ctx, env.RepositoryWriter.ConfigFilename(), password, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		t.Cleanup(func() { r.Close(ctx) })
+
+		return r.(repo.DirectRepository), nil
+	}
+
+	w := env.RepositoryWriter
+	require.Empty(t, w.KeySlots())
+	require.Error(t, w.RemoveKeySlot(ctx, repo.DefaultKeySlotID))
+	require.Error(t, w.AddKeySlot(ctx, repo.DefaultKeySlotID, "", "other-password", nil))
+	require.Equal(t, "1", mustGetFormatBlob(ctx, t, env).Version)
+
+	require.NoError(t, w.AddKeySlot(ctx, "backup", "backup admin", "backup-password", nil))
+	require.Equal(t, "2", mustGetFormatBlob(ctx, t, env).Version)
+
+	// versions that don't support key slots fail on the key algorithm instead of reporting an invalid password.
+	require.Equal(t, "key-slots", mustGetFormatBlob(ctx, t, env).KeyAlgo)
+	require.Error(t, w.AddKeySlot(ctx, "backup", "", "another-password", nil))
+
+	slots := w.KeySlots()
+	require.Len(t, slots, 2)
+	require.Equal(t, repo.DefaultKeySlotID, slots[0].ID)
+	require.True(t, slots[0].Current)
+	require.Equal(t, "backup", slots[1].ID)
+	require.Equal(t, "backup admin", slots[1].Description)
+	require.False(t, slots[1].Current)
+
+	// both the original and the new password can be used to open the repository.
+	_, err := openWithPassword(env.Password)
+	require.NoError(t, err)
+
+	r2, err := openWithPassword("backup-password")
+	require.NoError(t, err)
+	require.Len(t, r2.KeySlots(), 2)
+	require.True(t, r2.KeySlots()[1].Current)
+
+	// the slot that was used is tried first next time.
+	lc, err := repo.LoadConfigFromFile(env.RepositoryWriter.ConfigFilename())
+	require.NoError(t, err)
+	require.Equal(t, "backup", lc.KeySlotID)
+
+	_, err = openWithPassword("wrong-password")
+	require.ErrorIs(t, err, repo.ErrInvalidPassword)
+
+	// the slot used by the current connection can't be removed.
+	require.Error(t, w.RemoveKeySlot(ctx, repo.DefaultKeySlotID))
+
+	// changing the password only affects the current slot.
+	require.NoError(t, w.ChangePassword(ctx, "changed-password"))
+
+	_, err = openWithPassword(env.Password)
+	require.ErrorIs(t, err, repo.ErrInvalidPassword)
+
+	_, err = openWithPassword("changed-password")
+	require.NoError(t, err)
+
+	_, err = openWithPassword("backup-password")
+	require.NoError(t, err)
+
+	// revoke the backup password, which rotates the format encryption key.
+	encryptedFormatBeforeRemoval := mustGetFormatBlob(ctx, t, env).EncryptedFormat
+
+	require.NoError(t, w.RemoveKeySlot(ctx, "backup"))
+	require.NotEqual(t, encryptedFormatBeforeRemoval, mustGetFormatBlob(ctx, t, env).EncryptedFormat)
+	require.Error(t, w.RemoveKeySlot(ctx, "backup"))
+	require.Len(t, w.KeySlots(), 1)
+
+	_, err = openWithPassword("backup-password")
+	require.ErrorIs(t, err, repo.ErrInvalidPassword)
+
+	_, err = openWithPassword("changed-password")
+	require.NoError(t, err)
+
+	// the connection that removed the slot continues using the new format encryption key.
+	require.NoError(t, w.AddKeySlot(ctx, "another", "", "another-password", nil))
+
+	_, err = openWithPassword("another-password")
+	require.NoError(t, err)
+}
+
+func TestWriteOnlyKeySlots(t *testing.T) {
+	publicKey, _, err := encryption.GenerateKeyPair(encryption.DefaultPublicKeyAlgorithm)
+	require.NoError(t, err)
+
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.BlockFormat.PublicKeyEncryption = encryption.DefaultPublicKeyAlgorithm
+			nro.BlockFormat.PublicKey = publicKey
+		},
+	})
+
+	w := env.RepositoryWriter
+	require.NoError(t, w.AddWriteOnlyKeySlot(ctx, "writer", "backup client", "writer-password", nil))
+
+	slots := w.KeySlots()
+	require.Len(t, slots, 2)
+	require.False(t, slots[0].WriteOnly)
+	require.True(t, slots[1].WriteOnly)
+
+	openWriteOnly := func() repo.DirectRepository {
+		r, err := repo.Open(ctx, env.RepositoryWriter.ConfigFilename(), "writer-password", nil)
+		require.NoError(t, err)
+
+		t.Cleanup(func() { r.Close(ctx) })
+
+		return r.(repo.DirectRepository)
+	}
+
+	r2 := openWriteOnly()
+	require.True(t, r2.KeySlots()[1].Current)
+	require.Empty(t, r2.ContentReader().ContentFormat().MasterKey)
+	require.NotEmpty(t, r2.ContentReader().ContentFormat().MetadataKey)
+
+	// write-only clients can add data and manifests.
+	_, w2, err := r2.NewDirectWriter(ctx, repo.WriteSessionOptions{Purpose: "test"})
+	require.NoError(t, err)
+
+	ow := w2.NewObjectWriter(ctx, object.WriterOptions{})
+	_, err = ow.Write([]byte("some data"))
+	require.NoError(t, err)
+
+	oid, err := ow.Result()
+	require.NoError(t, err)
+
+	mid, err := w2.PutManifest(ctx, map[string]string{"type": "test"}, map[string]string{"foo": "bar"})
+	require.NoError(t, err)
+	require.NoError(t, w2.Flush(ctx))
+
+	// but can't manage the repository.
+	require.ErrorIs(t, w2.AddKeySlot(ctx, "other", "", "other-password", nil), repo.ErrWriteOnlyAccess)
+	require.ErrorIs(t, w2.RemoveKeySlot(ctx, repo.DefaultKeySlotID), repo.ErrWriteOnlyAccess)
+	require.ErrorIs(t, w2.SetParameters(ctx, w2.ContentReader().ContentFormat().MutableParameters), repo.ErrWriteOnlyAccess)
+
+	_, err = w2.RotateMasterKey(ctx)
+	require.ErrorIs(t, err, repo.ErrWriteOnlyAccess)
+	require.NoError(t, w2.Close(ctx))
+
+	// the client with full access reads what has been written.
+	require.NoError(t, w.Refresh(ctx))
+
+	var payload map[string]string
+
+	_, err = w.GetManifest(ctx, mid, &payload)
+	require.NoError(t, err)
+	require.Equal(t, "bar", payload["foo"])
+
+	_, err = w.VerifyObject(ctx, oid)
+	require.NoError(t, err)
+
+	// the write-only configuration is updated along with the repository format.
+	_, err = w.RotateMasterKey(ctx)
+	require.NoError(t, err)
+
+	r3 := openWriteOnly()
+	require.Empty(t, r3.ContentReader().ContentFormat().MasterKey)
+	require.Len(t, r3.ContentReader().ContentFormat().PreviousMasterKeys, 1)
+	require.Empty(t, r3.ContentReader().ContentFormat().PreviousMasterKeys[0].MasterKey)
+
+	_, err = r3.GetManifest(ctx, mid, &payload)
+	require.NoError(t, err)
+
+	// removing a write-only slot rotates the write-only format key for the remaining ones.
+	require.NoError(t, w.AddWriteOnlyKeySlot(ctx, "writer2", "", "writer2-password", nil))
+	require.NoError(t, w.RemoveKeySlot(ctx, "writer"))
+
+	_, err = repo.Open(ctx, env.RepositoryWriter.ConfigFilename(), "writer-password", nil)
+	require.ErrorIs(t, err, repo.ErrInvalidPassword)
+
+	r4, err := repo.Open(ctx, env.RepositoryWriter.ConfigFilename(), "writer2-password", nil)
+	require.NoError(t, err)
+
+	defer r4.Close(ctx)
+
+	require.Empty(t, r4.(repo.DirectRepository).ContentReader().ContentFormat().MasterKey)
+}
+
+func TestWriteOnlyKeySlotsRequirePublicKeyEncryption(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	require.Error(t, env.RepositoryWriter.AddWriteOnlyKeySlot(ctx, "writer", "", "writer-password", nil))
+}
+
+type testFormatBlob struct {
+	Version         string                 `json:"version"`
+	KeyAlgo         string                 `json:"keyAlgo"`
+	Argon2          *repo.Argon2Parameters `json:"argon2"`
+	EncryptedFormat []byte                 `json:"encryptedBlockFormat"`
+}
+
+func mustGetFormatBlob(ctx context.Context, t *testing.T, env *repotesting.Environment) *testFormatBlob {
+	t.Helper()
+
+	b, err := env.RepositoryWriter.BlobStorage().GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	require.NoError(t, err)
+
+	var fb testFormatBlob
+
+	require.NoError(t, json.Unmarshal(b, &fb))
+
+	return &fb
+}
+
 func verifyNotFound(ctx context.Context, t *testing.T, rep repo.Repository, objectID object.ID, testCaseID string) {
 	t.Helper()
 
@@ -456,3 +709,56 @@ func verifyNotFound(ctx context.Context, t *testing.T, rep repo.Repository, obje
 		return
 	}
 }
+
+func TestErrorCorrection(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(nro *repo.NewRepositoryOptions) {
+			nro.BlockFormat.ECC = ecc.DefaultAlgorithm
+			nro.BlockFormat.ECCOverheadPercent = 10
+		},
+	})
+
+	data := make([]byte, 300000)
+	rand.Read(data)
+
+	oid := writeObject(ctx, t, env.RepositoryWriter, data, "ecc")
+	require.NoError(t, env.RepositoryWriter.Flush(ctx))
+
+	ecr, ok := env.RepositoryWriter.(repo.ErrorCorrectingRepository)
+	require.True(t, ok)
+
+	encoded, ok := ecr.EncodedBlobReader().(blob.Storage)
+	require.True(t, ok)
+
+	packs, err := blob.ListAllBlobs(ctx, encoded, content.PackBlobIDPrefixRegular)
+	require.NoError(t, err)
+	require.Len(t, packs, 1)
+
+	// the format blob is not protected, so that it can be read before error correction parameters are known.
+	fb, err := env.RepositoryWriter.BlobReader().GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	require.NoError(t, err)
+	efb, err := encoded.GetBlob(ctx, repo.FormatBlobID, 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, efb, fb)
+
+	// damage a few bytes of the pack blob, within the capacity of error correction codes.
+	packID := packs[0].BlobID
+
+	b, err := encoded.GetBlob(ctx, packID, 0, -1)
+	require.NoError(t, err)
+
+	for i := 1000; i < len(b); i += 50000 {
+		b[i] ^= 0xff
+	}
+
+	require.NoError(t, encoded.PutBlob(ctx, packID, gather.FromSlice(b)))
+
+	r, err := repo.Open(ctx, env.RepositoryWriter.ConfigFilename(), env.Password, nil)
+	require.NoError(t, err)
+
+	defer r.Close(ctx)
+
+	verify(ctx, t, r, oid, data, "ecc")
+
+	require.Equal(t, []blob.ID{packID}, r.(repo.ErrorCorrectingRepository).RepairedBlobs())
+}
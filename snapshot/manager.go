@@ -3,6 +3,7 @@
 
 import (
 	"context"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 
@@ -146,6 +147,32 @@ func SaveSnapshot(ctx context.Context, rep repo.RepositoryWriter, man *Manifest)
 	return id, nil
 }
 
+// UpdateSnapshot replaces the existing snapshot manifest with the provided one and returns the new manifest ID.
+// When the old manifest can't be deleted, the new one is deleted as well, so that the snapshot is not duplicated.
+func UpdateSnapshot(ctx context.Context, rep repo.RepositoryWriter, man *Manifest) (manifest.ID, error) {
+	oldID := man.ID
+
+	newID, err := SaveSnapshot(ctx, rep, man)
+	if err != nil {
+		man.ID = oldID
+		return "", err
+	}
+
+	if oldID != "" && oldID != newID {
+		if err := rep.DeleteManifest(ctx, oldID); err != nil {
+			man.ID = oldID
+
+			if rerr := rep.DeleteManifest(ctx, newID); rerr != nil {
+				return "", errors.Wrapf(err, "error deleting old manifest, unable to delete new manifest %v: %v", newID, rerr)
+			}
+
+			return "", errors.Wrap(err, "error deleting old manifest")
+		}
+	}
+
+	return newID, nil
+}
+
 // LoadSnapshots efficiently loads and parses a given list of snapshot IDs.
 func LoadSnapshots(ctx context.Context, rep repo.Repository, manifestIDs []manifest.ID) ([]*Manifest, error) {
 	result := make([]*Manifest, len(manifestIDs))
@@ -205,6 +232,38 @@ func ListSnapshotManifests(ctx context.Context, rep repo.Repository, src *Source
 	return entryIDs(entries), nil
 }
 
+// ListSnapshotManifestsForPath returns the list of snapshot manifests of the source that contains
+// the provided path, along with the slash-separated path relative to the source root.
+func ListSnapshotManifestsForPath(ctx context.Context, rep repo.Repository, si SourceInfo, tags map[string]string) (manifestIDs []manifest.ID, relPath string, err error) {
+	for len(si.Path) > 0 {
+		list, err := ListSnapshotManifests(ctx, rep, &si, tags)
+		if err != nil {
+			return nil, "", errors.Wrapf(err, "error listing manifests for %v", si)
+		}
+
+		if len(list) > 0 {
+			return list, relPath, nil
+		}
+
+		if len(relPath) > 0 {
+			relPath = filepath.Base(si.Path) + "/" + relPath
+		} else {
+			relPath = filepath.Base(si.Path)
+		}
+
+		log(ctx).Debugf("No snapshots of %v@%v:%v", si.UserName, si.Host, si.Path)
+
+		parentPath := filepath.Dir(si.Path)
+		if parentPath == si.Path {
+			break
+		}
+
+		si.Path = parentPath
+	}
+
+	return nil, "", nil
+}
+
 // FindSnapshotsByRootObjectID returns the list of matching snapshots for a given rootID.
 func FindSnapshotsByRootObjectID(ctx context.Context, rep repo.Repository, rootID object.ID) ([]*Manifest, error) {
 	ids, err := ListSnapshotManifests(ctx, rep, nil, nil)
@@ -5,6 +5,7 @@
 	"encoding/json"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,6 +15,9 @@
 	"github.com/kopia/kopia/repo/object"
 )
 
+// TagKeyPrefix is the prefix for user-defined tag keys.
+const TagKeyPrefix = "tag:"
+
 // Manifest represents information about a single point-in-time filesystem snapshot.
 type Manifest struct {
 	ID     manifest.ID `json:"id"`
@@ -31,6 +35,9 @@ type Manifest struct {
 	RetentionReasons []string `json:"-"`
 
 	Tags map[string]string `json:"tags,omitempty"`
+
+	// Pins contains reasons why the snapshot is pinned, pinned snapshots are never deleted by retention policy.
+	Pins []string `json:"pins,omitempty"`
 }
 
 // EntryType is a type of a filesystem entry.
@@ -42,6 +49,11 @@ type Manifest struct {
 	EntryTypeFile      EntryType = "f" // file
 	EntryTypeDirectory EntryType = "d" // directory
 	EntryTypeSymlink   EntryType = "s" // symbolic link
+
+	EntryTypeBlockDevice EntryType = "b" // block device
+	EntryTypeCharDevice  EntryType = "c" // character device
+	EntryTypeNamedPipe   EntryType = "p" // named pipe (FIFO)
+	EntryTypeSocket      EntryType = "o" // UNIX domain socket
 )
 
 // Permissions encapsulates UNIX permissions for a filesystem entry.
@@ -88,8 +100,16 @@ type DirEntry struct {
 	ModTime     time.Time            `json:"mtime,omitempty"`
 	UserID      uint32               `json:"uid,omitempty"`
 	GroupID     uint32               `json:"gid,omitempty"`
-	ObjectID    object.ID            `json:"obj,omitempty"`
+	ObjectID    object.ID            `json:"obj,omitempty"` // empty for special files, which have no content
 	DirSummary  *fs.DirectorySummary `json:"summ,omitempty"`
+
+	ExtendedAttributes fs.ExtendedAttributes `json:"xattrs,omitempty"`
+
+	// LinkGroup is shared by all hard links to the same file within a snapshot.
+	LinkGroup string `json:"linkGroup,omitempty"`
+
+	// DeviceNumber holds the major and minor number of block and character devices.
+	DeviceNumber uint64 `json:"rdev,omitempty"`
 }
 
 // HasDirEntry is implemented by objects that have a DirEntry associated with them.
@@ -121,6 +141,88 @@ func (m *Manifest) RootObjectID() object.ID {
 	return ""
 }
 
+// UpdatePins adds and removes the provided pins and returns true if the set of pins has changed.
+// Pins are kept sorted and without duplicates.
+func (m *Manifest) UpdatePins(add, remove []string) bool {
+	pins := map[string]bool{}
+	for _, p := range m.Pins {
+		pins[p] = true
+	}
+
+	for _, p := range add {
+		pins[p] = true
+	}
+
+	for _, p := range remove {
+		delete(pins, p)
+	}
+
+	var result []string
+	for p := range pins {
+		result = append(result, p)
+	}
+
+	sort.Strings(result)
+
+	changed := len(result) != len(m.Pins)
+
+	for i := 0; !changed && i < len(result); i++ {
+		changed = result[i] != m.Pins[i]
+	}
+
+	m.Pins = result
+
+	return changed
+}
+
+// ValidateUserTags ensures that keys of user-defined tags, provided without TagKeyPrefix, are not empty
+// and don't contain ':', which separates tag keys from values.
+func ValidateUserTags(tags map[string]string) error {
+	for k := range tags {
+		if k == "" {
+			return errors.New("tag key must not be empty")
+		}
+
+		if strings.Contains(k, ":") {
+			return errors.Errorf("tag key must not contain ':' (%s)", k)
+		}
+	}
+
+	return nil
+}
+
+// UpdateTags adds and removes user-defined tags, returns true if the tags have changed.
+// Tag keys are provided without TagKeyPrefix.
+func (m *Manifest) UpdateTags(add map[string]string, remove []string) bool {
+	changed := false
+
+	for _, k := range remove {
+		if _, ok := m.Tags[TagKeyPrefix+k]; ok {
+			delete(m.Tags, TagKeyPrefix+k)
+
+			changed = true
+		}
+	}
+
+	for k, v := range add {
+		if m.Tags == nil {
+			m.Tags = map[string]string{}
+		}
+
+		if old, ok := m.Tags[TagKeyPrefix+k]; !ok || old != v {
+			m.Tags[TagKeyPrefix+k] = v
+
+			changed = true
+		}
+	}
+
+	if len(m.Tags) == 0 {
+		m.Tags = nil
+	}
+
+	return changed
+}
+
 // GroupBySource returns a slice of slices, such that each result item contains manifests from a single source.
 func GroupBySource(manifests []*Manifest) [][]*Manifest {
 	resultMap := map[SourceInfo][]*Manifest{}
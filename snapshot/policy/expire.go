@@ -61,10 +61,13 @@ func getExpiredSnapshotsForSource(ctx context.Context, rep repo.Repository, snap
 	var toDelete []*snapshot.Manifest
 
 	for _, s := range snapshots {
-		if len(s.RetentionReasons) == 0 {
+		switch {
+		case len(s.Pins) > 0:
+			log(ctx).Debugf("  keeping %v pins: [%v]", s.StartTime, strings.Join(s.Pins, ","))
+		case len(s.RetentionReasons) == 0:
 			log(ctx).Debugf("  deleting %v", s.StartTime)
 			toDelete = append(toDelete, s)
-		} else {
+		default:
 			log(ctx).Debugf("  keeping %v reasons: [%v]", s.StartTime, strings.Join(s.RetentionReasons, ","))
 		}
 	}
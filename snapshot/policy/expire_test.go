@@ -0,0 +1,62 @@
+package policy_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+)
+
+func TestApplyRetentionPolicyKeepsPinnedSnapshots(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	src := snapshot.SourceInfo{Host: "host-1", UserName: "user-1", Path: "/some/path"}
+	keepLatest, keepNone := 1, 0
+
+	require.NoError(t, policy.SetPolicy(ctx, env.RepositoryWriter, src, &policy.Policy{
+		RetentionPolicy: policy.RetentionPolicy{
+			KeepLatest:  &keepLatest,
+			KeepHourly:  &keepNone,
+			KeepDaily:   &keepNone,
+			KeepWeekly:  &keepNone,
+			KeepMonthly: &keepNone,
+			KeepAnnual:  &keepNone,
+		},
+	}))
+
+	t0 := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var manifests []*snapshot.Manifest
+
+	for i := 0; i < 3; i++ {
+		m := &snapshot.Manifest{
+			Source:    src,
+			StartTime: t0.Add(time.Duration(i) * time.Hour),
+			EndTime:   t0.Add(time.Duration(i) * time.Hour),
+		}
+
+		if i == 0 {
+			m.Pins = []string{"legal-hold"}
+		}
+
+		_, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, m)
+		require.NoError(t, err)
+
+		manifests = append(manifests, m)
+	}
+
+	deleted, err := policy.ApplyRetentionPolicy(ctx, env.RepositoryWriter, src, true)
+	require.NoError(t, err)
+
+	// the oldest snapshot is pinned and the newest is retained by policy.
+	require.Len(t, deleted, 1)
+	require.Equal(t, manifests[1].ID, deleted[0].ID)
+
+	remaining, err := snapshot.ListSnapshots(ctx, env.RepositoryWriter, src)
+	require.NoError(t, err)
+	require.Len(t, remaining, 2)
+}
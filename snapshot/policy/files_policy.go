@@ -13,6 +13,10 @@ type FilesPolicy struct {
 	MaxFileSize int64 `json:"maxFileSize,omitempty"`
 
 	OneFileSystem *bool `json:"oneFileSystem,omitempty"`
+
+	ExtendedAttributes *bool `json:"extendedAttributes,omitempty"`
+
+	SpecialFiles *bool `json:"specialFiles,omitempty"`
 }
 
 // Merge applies default values from the provided policy.
@@ -36,6 +40,14 @@ func (p *FilesPolicy) Merge(src FilesPolicy) {
 	if p.OneFileSystem == nil {
 		p.OneFileSystem = src.OneFileSystem
 	}
+
+	if p.ExtendedAttributes == nil {
+		p.ExtendedAttributes = src.ExtendedAttributes
+	}
+
+	if p.SpecialFiles == nil {
+		p.SpecialFiles = src.SpecialFiles
+	}
 }
 
 // IgnoreCacheDirectoriesOrDefault gets the value of IgnoreCacheDirs or the provided default if not set.
@@ -56,6 +68,24 @@ func (p *FilesPolicy) OneFileSystemOrDefault(def bool) bool {
 	return *p.OneFileSystem
 }
 
+// ExtendedAttributesOrDefault gets the value of ExtendedAttributes or the provided default if not set.
+func (p *FilesPolicy) ExtendedAttributesOrDefault(def bool) bool {
+	if p.ExtendedAttributes == nil {
+		return def
+	}
+
+	return *p.ExtendedAttributes
+}
+
+// SpecialFilesOrDefault gets the value of SpecialFiles or the provided default if not set.
+func (p *FilesPolicy) SpecialFilesOrDefault(def bool) bool {
+	if p.SpecialFiles == nil {
+		return def
+	}
+
+	return *p.SpecialFiles
+}
+
 // defaultFilesPolicy is the default file ignore policy.
 var defaultFilesPolicy = FilesPolicy{
 	DotIgnoreFiles: []string{".kopiaignore"},
@@ -91,9 +91,13 @@ func MergePolicies(policies []*Policy) *Policy {
 }
 
 // ValidatePolicy returns error if the given policy is invalid.
-// Currently, only SchedulingPolicy is validated.
+// Currently, only SchedulingPolicy and RetentionPolicy are validated.
 func ValidatePolicy(pol *Policy) error {
-	return ValidateSchedulingPolicy(pol.SchedulingPolicy)
+	if err := ValidateSchedulingPolicy(pol.SchedulingPolicy); err != nil {
+		return err
+	}
+
+	return ValidateRetentionPolicy(pol.RetentionPolicy)
 }
 
 // validatePolicyPath validates that the provided policy path is valid and the path exists.
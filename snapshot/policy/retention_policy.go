@@ -4,6 +4,8 @@
 	"fmt"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/kopia/kopia/snapshot"
 )
 
@@ -23,6 +25,23 @@ type RetentionPolicy struct {
 	KeepWeekly  *int `json:"keepWeekly,omitempty"`
 	KeepMonthly *int `json:"keepMonthly,omitempty"`
 	KeepAnnual  *int `json:"keepAnnual,omitempty"`
+
+	// KeepWithin retains all snapshots taken within the provided duration before the latest complete snapshot.
+	KeepWithin *RetentionDuration `json:"keepWithin,omitempty"`
+
+	// KeepTags retains snapshots with matching tags.
+	KeepTags []TagRetentionRule `json:"keepTags,omitempty"`
+}
+
+// ValidateRetentionPolicy returns an error if any of the tag retention rules is invalid.
+func ValidateRetentionPolicy(r RetentionPolicy) error {
+	for _, tr := range r.KeepTags {
+		if err := tr.validate(); err != nil {
+			return errors.Wrapf(err, "invalid retention policy: tag retention rule %q", tr.Tag)
+		}
+	}
+
+	return nil
 }
 
 // ComputeRetentionReasons computes the reasons why each snapshot is retained, based on
@@ -64,6 +83,7 @@ func (r *RetentionPolicy) ComputeRetentionReasons(manifests []*snapshot.Manifest
 		daily:   cutoffTime(r.KeepDaily, daysAgo),
 		hourly:  cutoffTime(r.KeepHourly, hoursAgo),
 		weekly:  cutoffTime(r.KeepHourly, weeksAgo),
+		latest:  maxCompleteStartTime,
 	}
 
 	ids := make(map[string]bool)
@@ -143,6 +163,22 @@ func (r *RetentionPolicy) getRetentionReasons(i int, s *snapshot.Manifest, cutof
 		}
 	}
 
+	if r.KeepWithin != nil && *r.KeepWithin > 0 && !s.StartTime.Before(cutoff.latest.Add(-time.Duration(*r.KeepWithin))) {
+		keepReasons = append(keepReasons, fmt.Sprintf("within-%v", r.KeepWithin))
+	}
+
+	for _, tr := range r.KeepTags {
+		if !tr.matches(s) {
+			continue
+		}
+
+		if tr.KeepFor != nil && s.StartTime.Before(cutoff.latest.Add(-time.Duration(*tr.KeepFor))) {
+			continue
+		}
+
+		keepReasons = append(keepReasons, fmt.Sprintf("tag-%v", tr.Tag))
+	}
+
 	return keepReasons
 }
 
@@ -152,6 +188,9 @@ type cutoffTimes struct {
 	daily   time.Time
 	hourly  time.Time
 	weekly  time.Time
+
+	// start time of the latest complete snapshot.
+	latest time.Time
 }
 
 func yearsAgo(base time.Time, n int) time.Time {
@@ -217,4 +256,42 @@ func (r *RetentionPolicy) Merge(src RetentionPolicy) {
 	if r.KeepAnnual == nil {
 		r.KeepAnnual = src.KeepAnnual
 	}
+
+	if r.KeepWithin == nil {
+		r.KeepWithin = src.KeepWithin
+	}
+
+	r.KeepTags = mergeTagRetentionRules(src.KeepTags, r.KeepTags)
+}
+
+// mergeTagRetentionRules returns the union of parent and child tag retention rules,
+// where a child rule for the same tag overrides the parent one.
+func mergeTagRetentionRules(parent, child []TagRetentionRule) []TagRetentionRule {
+	var result []TagRetentionRule
+
+	overridden := map[string]bool{}
+
+	for _, r := range child {
+		overridden[r.Tag] = true
+	}
+
+	for _, r := range parent {
+		if !overridden[r.Tag] {
+			result = append(result, r)
+		}
+	}
+
+	seen := map[string]bool{}
+
+	for _, r := range child {
+		if seen[r.Tag] {
+			continue
+		}
+
+		seen[r.Tag] = true
+
+		result = append(result, r)
+	}
+
+	return result
 }
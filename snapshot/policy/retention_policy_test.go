@@ -194,3 +194,94 @@ func TestRetentionPolicyTest(t *testing.T) {
 		})
 	}
 }
+
+func TestRetentionPolicyKeepWithinAndTags(t *testing.T) {
+	keepWithin := RetentionDuration(3 * retentionDay)
+	keepFor := RetentionDuration(30 * retentionDay)
+
+	rp := &RetentionPolicy{
+		KeepLatest: intPtr(1),
+		KeepWithin: &keepWithin,
+		KeepTags: []TagRetentionRule{
+			{Tag: "release:*", KeepFor: &keepFor},
+			{Tag: "keep:forever"},
+		},
+	}
+
+	cases := []struct {
+		startTime string
+		tags      map[string]string
+		want      []string
+	}{
+		{"2019-01-01T12:00:00Z", map[string]string{"tag:keep": "forever"}, []string{"tag-keep:forever"}},
+		{"2020-01-01T12:00:00Z", map[string]string{"tag:release": "v1"}, []string{}}, // too old for the release rule
+		{"2020-03-15T12:00:00Z", map[string]string{"tag:release": "v2"}, []string{"tag-release:*"}},
+		{"2020-03-20T12:00:00Z", nil, []string{}},
+		{"2020-03-29T12:00:00Z", nil, []string{"within-3d"}},
+		{"2020-03-30T12:00:00Z", map[string]string{"tag:release": "v3"}, []string{"within-3d", "tag-release:*"}},
+		{"2020-04-01T12:00:00Z", nil, []string{"latest-1", "within-3d"}},
+	}
+
+	var manifests []*snapshot.Manifest
+
+	for _, tc := range cases {
+		startTime, err := time.Parse(time.RFC3339, tc.startTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		manifests = append(manifests, &snapshot.Manifest{
+			Description: tc.startTime,
+			StartTime:   startTime,
+			Tags:        tc.tags,
+		})
+	}
+
+	rp.ComputeRetentionReasons(manifests)
+
+	for i, tc := range cases {
+		if diff := cmp.Diff(manifests[i].RetentionReasons, tc.want); diff != "" {
+			t.Errorf("unexpected retention reasons for snapshot at %v diff: %v", tc.startTime, diff)
+		}
+	}
+}
+
+func TestRetentionPolicyMergeKeepTags(t *testing.T) {
+	year := RetentionDuration(365 * retentionDay)
+	month := RetentionDuration(30 * retentionDay)
+
+	p := RetentionPolicy{KeepTags: []TagRetentionRule{
+		{Tag: "release:*", KeepFor: &month},
+		{Tag: "audit:*"},
+	}}
+	p.Merge(RetentionPolicy{KeepTags: []TagRetentionRule{
+		{Tag: "keep:forever"},
+		{Tag: "release:*", KeepFor: &year},
+	}})
+
+	want := []TagRetentionRule{
+		{Tag: "keep:forever"},
+		{Tag: "release:*", KeepFor: &month},
+		{Tag: "audit:*"},
+	}
+
+	if diff := cmp.Diff(p.KeepTags, want); diff != "" {
+		t.Errorf("unexpected merged tag retention rules: %v", diff)
+	}
+}
+
+func TestValidateRetentionPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		pol     RetentionPolicy
+		wantErr bool
+	}{
+		{RetentionPolicy{}, false},
+		{RetentionPolicy{KeepTags: []TagRetentionRule{{Tag: "release:v*"}}}, false},
+		{RetentionPolicy{KeepTags: []TagRetentionRule{{Tag: "release:v1["}}}, true},
+		{RetentionPolicy{KeepTags: []TagRetentionRule{{Tag: "release"}}}, true},
+	} {
+		if err := ValidatePolicy(&Policy{RetentionPolicy: tc.pol}); (err != nil) != tc.wantErr {
+			t.Errorf("unexpected error for %+v: %v", tc.pol, err)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+const (
+	retentionDay  = 24 * time.Hour
+	retentionWeek = 7 * retentionDay
+	retentionYear = 365 * retentionDay
+)
+
+// nolint:gochecknoglobals
+var retentionDurationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"y", retentionYear},
+	{"w", retentionWeek},
+	{"d", retentionDay},
+}
+
+// RetentionDuration is a duration used by retention rules, which in addition to Go duration syntax
+// supports days ("14d"), weeks ("4w") and years of 365 days ("2y").
+type RetentionDuration time.Duration
+
+// ParseRetentionDuration parses the provided retention duration string.
+func ParseRetentionDuration(s string) (RetentionDuration, error) {
+	for _, u := range retentionDurationUnits {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+
+		n, err := strconv.ParseInt(strings.TrimSuffix(s, u.suffix), 10, 64)
+		if err != nil || n < 0 {
+			return 0, errors.Errorf("invalid duration: %q", s)
+		}
+
+		if n > math.MaxInt64/int64(u.unit) {
+			return 0, errors.Errorf("duration too long: %q", s)
+		}
+
+		return RetentionDuration(time.Duration(n) * u.unit), nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.Errorf("invalid duration: %q", s)
+	}
+
+	return RetentionDuration(d), nil
+}
+
+func (d RetentionDuration) String() string {
+	if d == 0 {
+		return "0"
+	}
+
+	for _, u := range retentionDurationUnits {
+		if time.Duration(d)%u.unit == 0 {
+			return fmt.Sprintf("%v%v", int64(time.Duration(d)/u.unit), u.suffix)
+		}
+	}
+
+	return time.Duration(d).String()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d RetentionDuration) MarshalJSON() ([]byte, error) {
+	// nolint:wrapcheck
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *RetentionDuration) UnmarshalJSON(b []byte) error {
+	var s string
+
+	if err := json.Unmarshal(b, &s); err != nil {
+		return errors.Wrap(err, "invalid retention duration")
+	}
+
+	v, err := ParseRetentionDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = v
+
+	return nil
+}
+
+// TagRetentionRule retains snapshots having a tag matching the provided pattern.
+type TagRetentionRule struct {
+	// Tag is specified as <key>:<value-pattern>, where value pattern supports '*' and '?' wildcards.
+	Tag string `json:"tag"`
+
+	// KeepFor specifies how long before the latest complete snapshot matching snapshots are kept, nil means forever.
+	KeepFor *RetentionDuration `json:"keepFor,omitempty"`
+}
+
+// ParseTagRetentionRule parses the tag retention rule in the <key>:<value-pattern>[=<duration>] format.
+func ParseTagRetentionRule(s string) (TagRetentionRule, error) {
+	var r TagRetentionRule
+
+	tag := s
+
+	if p := strings.LastIndex(s, "="); p >= 0 {
+		d, err := ParseRetentionDuration(s[p+1:])
+		if err != nil {
+			return r, err
+		}
+
+		tag = s[0:p]
+		r.KeepFor = &d
+	}
+
+	r.Tag = tag
+
+	if err := r.validate(); err != nil {
+		return r, errors.Wrapf(err, "invalid tag retention rule %q", s)
+	}
+
+	return r, nil
+}
+
+// validate checks that the rule has a key and a well-formed value pattern.
+func (r TagRetentionRule) validate() error {
+	key, pattern, ok := splitTagPattern(r.Tag)
+	if !ok || key == "" {
+		return errors.Errorf("tag must be specified as <key>:<value-pattern>")
+	}
+
+	if err := validateTagValuePattern(pattern); err != nil {
+		return err
+	}
+
+	if r.KeepFor != nil && *r.KeepFor < 0 {
+		return errors.Errorf("negative duration")
+	}
+
+	return nil
+}
+
+// validateTagValuePattern returns an error if the pattern is not a valid path.Match pattern.
+// path.Match only reports malformed patterns when it gets to the malformed part while matching,
+// so the entire pattern is checked here.
+func validateTagValuePattern(pattern string) error {
+	bad := errors.Errorf("invalid tag value pattern %q", pattern)
+
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			if i++; i >= len(pattern) {
+				return bad
+			}
+
+		case '[':
+			i++
+			if i < len(pattern) && pattern[i] == '^' {
+				i++
+			}
+
+			// character class must be non-empty and terminated.
+			if i >= len(pattern) || pattern[i] == ']' {
+				return bad
+			}
+
+			for ; i < len(pattern) && pattern[i] != ']'; i++ {
+				if pattern[i] == '\\' {
+					i++
+				}
+			}
+
+			if i >= len(pattern) {
+				return bad
+			}
+		}
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return bad
+	}
+
+	return nil
+}
+
+func (r TagRetentionRule) String() string {
+	if r.KeepFor == nil {
+		return r.Tag
+	}
+
+	return r.Tag + "=" + r.KeepFor.String()
+}
+
+// matches determines whether the snapshot has a tag matching the rule.
+func (r TagRetentionRule) matches(m *snapshot.Manifest) bool {
+	key, pattern, ok := splitTagPattern(r.Tag)
+	if !ok {
+		return false
+	}
+
+	v, ok := m.Tags[snapshot.TagKeyPrefix+key]
+	if !ok {
+		return false
+	}
+
+	matched, err := path.Match(pattern, v)
+
+	// treat malformed patterns as matching to avoid deleting snapshots by mistake.
+	return matched || err != nil
+}
+
+func splitTagPattern(tag string) (key, pattern string, ok bool) {
+	p := strings.Index(tag, ":")
+	if p < 0 {
+		return "", "", false
+	}
+
+	return tag[0:p], tag[p+1:], true
+}
@@ -0,0 +1,84 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+func TestParseRetentionDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"0":     0,
+		"36h":   36 * time.Hour,
+		"90m":   90 * time.Minute,
+		"14d":   14 * 24 * time.Hour,
+		"4w":    28 * 24 * time.Hour,
+		"2y":    2 * 365 * 24 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	}
+
+	for s, want := range cases {
+		d, err := ParseRetentionDuration(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, time.Duration(d), s)
+
+		// round-trip through JSON.
+		b, err := json.Marshal(d)
+		require.NoError(t, err)
+
+		var d2 RetentionDuration
+
+		require.NoError(t, json.Unmarshal(b, &d2))
+		require.Equal(t, d, d2)
+	}
+
+	for _, s := range []string{"", "x", "-1d", "1.5d", "d", "-3h", "300y", "9223372036854775807d"} {
+		_, err := ParseRetentionDuration(s)
+		require.Error(t, err, s)
+	}
+
+	require.Equal(t, "2y", RetentionDuration(2*retentionYear).String())
+	require.Equal(t, "2w", RetentionDuration(14*retentionDay).String())
+	require.Equal(t, "3d", RetentionDuration(3*retentionDay).String())
+	require.Equal(t, "36h0m0s", RetentionDuration(36*time.Hour).String())
+}
+
+func TestParseTagRetentionRule(t *testing.T) {
+	r, err := ParseTagRetentionRule("release:*=2y")
+	require.NoError(t, err)
+	require.Equal(t, "release:*", r.Tag)
+	require.Equal(t, RetentionDuration(2*retentionYear), *r.KeepFor)
+	require.Equal(t, "release:*=2y", r.String())
+
+	r, err = ParseTagRetentionRule("keep:forever")
+	require.NoError(t, err)
+	require.Nil(t, r.KeepFor)
+	require.Equal(t, "keep:forever", r.String())
+
+	for _, s := range []string{"release", ":foo", "release:*=bad", "release:[", "release:*=-1d", "release:v1[", "release:v1\\", "release:[]", "release:[a-]"} {
+		_, err := ParseTagRetentionRule(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestTagRetentionRuleMatches(t *testing.T) {
+	m := &snapshot.Manifest{
+		Tags: map[string]string{"tag:release": "v1.2.3"},
+	}
+
+	cases := map[string]bool{
+		"release:*":      true,
+		"release:v1.*":   true,
+		"release:v2.*":   false,
+		"release:v1.2.3": true,
+		"other:*":        false,
+	}
+
+	for tag, want := range cases {
+		require.Equal(t, want, TagRetentionRule{Tag: tag}.matches(m), tag)
+	}
+}
@@ -9,6 +9,7 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/cron"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/snapshot"
 )
@@ -57,6 +58,8 @@ func SortAndDedupeTimesOfDay(tod []TimeOfDay) []TimeOfDay {
 type SchedulingPolicy struct {
 	IntervalSeconds int64       `json:"intervalSeconds,omitempty"`
 	TimesOfDay      []TimeOfDay `json:"timeOfDay,omitempty"`
+	Cron            []string    `json:"cron,omitempty"`
+	RunMissed       *bool       `json:"runMissed,omitempty"`
 	Manual          bool        `json:"manual,omitempty"`
 }
 
@@ -79,11 +82,95 @@ func (p *SchedulingPolicy) Merge(src SchedulingPolicy) {
 	p.TimesOfDay = SortAndDedupeTimesOfDay(
 		append(append([]TimeOfDay(nil), src.TimesOfDay...), p.TimesOfDay...))
 
+	p.Cron = dedupeStrings(append(append([]string(nil), src.Cron...), p.Cron...))
+
+	if p.RunMissed == nil {
+		p.RunMissed = src.RunMissed
+	}
+
 	if !p.Manual {
 		p.Manual = src.Manual
 	}
 }
 
+// NextSnapshotTime returns the earliest time after now when a snapshot should be taken according to
+// the policy, given the start time of the previous snapshot (zero if not known).
+// Returns false if the policy does not schedule any snapshots.
+func (p *SchedulingPolicy) NextSnapshotTime(previousSnapshotTime, now time.Time) (time.Time, bool) {
+	var (
+		nextSnapshotTime time.Time
+		ok               bool
+	)
+
+	setIfEarlier := func(t time.Time) {
+		if !ok || t.Before(nextSnapshotTime) {
+			nextSnapshotTime = t
+			ok = true
+		}
+	}
+
+	// compute next snapshot time based on interval
+	if interval := p.Interval(); interval != 0 && !previousSnapshotTime.IsZero() {
+		setIfEarlier(previousSnapshotTime.Add(interval).Truncate(interval))
+	}
+
+	for _, tod := range p.TimesOfDay {
+		nowLocalTime := now.Local()
+		localSnapshotTime := time.Date(nowLocalTime.Year(), nowLocalTime.Month(), nowLocalTime.Day(), tod.Hour, tod.Minute, 0, 0, time.Local)
+
+		if tod.Hour < nowLocalTime.Hour() || (tod.Hour == nowLocalTime.Hour() && tod.Minute < nowLocalTime.Minute()) {
+			localSnapshotTime = localSnapshotTime.AddDate(0, 0, 1)
+		}
+
+		setIfEarlier(localSnapshotTime)
+	}
+
+	for _, expr := range p.Cron {
+		s, err := cron.Parse(expr)
+		if err != nil {
+			// invalid expressions are rejected when the policy is saved.
+			continue
+		}
+
+		if t := s.Next(now.Local()); !t.IsZero() {
+			setIfEarlier(t)
+		}
+	}
+
+	return nextSnapshotTime, ok
+}
+
+// MissedSnapshotTime returns the earliest time of day or cron schedule slot after the previous snapshot
+// that has already passed, which indicates that a scheduled snapshot was missed, for example because
+// the machine was turned off or asleep.
+func (p *SchedulingPolicy) MissedSnapshotTime(previousSnapshotTime, now time.Time) (time.Time, bool) {
+	// snapshots based on interval are taken as soon as possible anyway, only consider fixed slots.
+	// Times of day have minute granularity and a snapshot started within the minute of the slot
+	// satisfies it, while cron schedules only consider times after the given one.
+	timesOfDay := SchedulingPolicy{TimesOfDay: p.TimesOfDay}
+	cronSlots := SchedulingPolicy{Cron: p.Cron}
+
+	t, ok := timesOfDay.NextSnapshotTime(time.Time{}, previousSnapshotTime.Add(time.Minute))
+	if t2, ok2 := cronSlots.NextSnapshotTime(time.Time{}, previousSnapshotTime); ok2 && (!ok || t2.Before(t)) {
+		t, ok = t2, true
+	}
+
+	if !ok || !t.Before(now) {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// RunMissedOrDefault returns the run-missed setting if set, or the provided default.
+func (p *SchedulingPolicy) RunMissedOrDefault(def bool) bool {
+	if p.RunMissed == nil {
+		return def
+	}
+
+	return *p.RunMissed
+}
+
 // IsManualSnapshot returns the SchedulingPolicy manual value from the given policy tree.
 func IsManualSnapshot(policyTree *Tree) bool {
 	return policyTree.EffectivePolicy().SchedulingPolicy.Manual
@@ -110,13 +197,38 @@ func SetManual(ctx context.Context, rep repo.RepositoryWriter, sourceInfo snapsh
 	return nil
 }
 
-// ValidateSchedulingPolicy returns an error if manual field is set along with scheduling fields.
+// ValidateSchedulingPolicy returns an error if manual field is set along with scheduling fields
+// or if any of the cron expressions is invalid.
 func ValidateSchedulingPolicy(p SchedulingPolicy) error {
 	if p.Manual && !reflect.DeepEqual(p, SchedulingPolicy{Manual: true}) {
 		return errors.New("invalid scheduling policy: manual cannot be combined with other scheduling policies")
 	}
 
+	for _, expr := range p.Cron {
+		if _, err := cron.Parse(expr); err != nil {
+			return errors.Wrap(err, "invalid scheduling policy")
+		}
+	}
+
 	return nil
 }
 
+func dedupeStrings(s []string) []string {
+	var result []string
+
+	seen := map[string]bool{}
+
+	for _, v := range s {
+		if seen[v] {
+			continue
+		}
+
+		seen[v] = true
+
+		result = append(result, v)
+	}
+
+	return result
+}
+
 var defaultSchedulingPolicy = SchedulingPolicy{}
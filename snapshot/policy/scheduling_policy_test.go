@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSchedulingPolicyNextSnapshotTime(t *testing.T) {
+	// Friday
+	now := time.Date(2021, time.October, 15, 13, 37, 0, 0, time.Local)
+	lastSnapshot := time.Date(2021, time.October, 15, 12, 10, 0, 0, time.Local)
+
+	cases := []struct {
+		name         string
+		pol          SchedulingPolicy
+		lastSnapshot time.Time
+		want         time.Time
+		wantOK       bool
+	}{
+		{
+			name: "no schedule",
+		},
+		{
+			name:         "interval",
+			pol:          SchedulingPolicy{IntervalSeconds: 3600},
+			lastSnapshot: lastSnapshot,
+			want:         lastSnapshot.Add(time.Hour).Truncate(time.Hour),
+			wantOK:       true,
+		},
+		{
+			name: "interval without previous snapshot",
+			pol:  SchedulingPolicy{IntervalSeconds: 3600},
+		},
+		{
+			name:   "time of day tomorrow",
+			pol:    SchedulingPolicy{TimesOfDay: []TimeOfDay{{11, 0}}},
+			want:   time.Date(2021, time.October, 16, 11, 0, 0, 0, time.Local),
+			wantOK: true,
+		},
+		{
+			name:   "cron",
+			pol:    SchedulingPolicy{Cron: []string{"0 2 * * mon-fri"}},
+			want:   time.Date(2021, time.October, 18, 2, 0, 0, 0, time.Local),
+			wantOK: true,
+		},
+		{
+			name: "earliest wins",
+			pol: SchedulingPolicy{
+				TimesOfDay: []TimeOfDay{{23, 0}},
+				Cron:       []string{"0 2 * * mon-fri", "30 15 * * *"},
+			},
+			want:   time.Date(2021, time.October, 15, 15, 30, 0, 0, time.Local),
+			wantOK: true,
+		},
+	}
+
+	for _, tc := range cases {
+		got, ok := tc.pol.NextSnapshotTime(tc.lastSnapshot, now)
+		if ok != tc.wantOK || !got.Equal(tc.want) {
+			t.Errorf("%v: NextSnapshotTime() = %v, %v, want %v, %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestSchedulingPolicyMergeCron(t *testing.T) {
+	p := SchedulingPolicy{Cron: []string{"0 2 * * *", "@weekly"}}
+	p.Merge(SchedulingPolicy{Cron: []string{"@weekly", "0 3 1 * *"}})
+
+	if want := []string{"@weekly", "0 3 1 * *", "0 2 * * *"}; !reflect.DeepEqual(p.Cron, want) {
+		t.Errorf("unexpected merged cron expressions: %v, want %v", p.Cron, want)
+	}
+}
+
+func TestValidateSchedulingPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		pol     SchedulingPolicy
+		wantErr bool
+	}{
+		{SchedulingPolicy{}, false},
+		{SchedulingPolicy{Manual: true}, false},
+		{SchedulingPolicy{Cron: []string{"0 2 * * *"}}, false},
+		{SchedulingPolicy{Cron: []string{"0 2 * *"}}, true},
+		{SchedulingPolicy{Cron: []string{"0 2 * * *"}, Manual: true}, true},
+	} {
+		if err := ValidateSchedulingPolicy(tc.pol); (err != nil) != tc.wantErr {
+			t.Errorf("unexpected error for %+v: %v", tc.pol, err)
+		}
+	}
+}
+
+func TestSchedulingPolicyMissedSnapshotTime(t *testing.T) {
+	now := time.Date(2021, time.October, 15, 13, 37, 0, 0, time.Local)
+
+	pol := SchedulingPolicy{
+		IntervalSeconds: 600,
+		TimesOfDay:      []TimeOfDay{{2, 0}},
+	}
+
+	// last snapshot was taken before yesterday's slot
+	missed, ok := pol.MissedSnapshotTime(time.Date(2021, time.October, 13, 23, 0, 0, 0, time.Local), now)
+	if want := time.Date(2021, time.October, 14, 2, 0, 0, 0, time.Local); !ok || !missed.Equal(want) {
+		t.Errorf("unexpected missed snapshot time: %v, %v, want %v", missed, ok, want)
+	}
+
+	// last snapshot was taken after today's slot
+	if missed, ok := pol.MissedSnapshotTime(time.Date(2021, time.October, 15, 2, 0, 5, 0, time.Local), now); ok {
+		t.Errorf("unexpected missed snapshot time: %v", missed)
+	}
+
+	// interval-only schedules never miss snapshots
+	intervalOnly := SchedulingPolicy{IntervalSeconds: 600}
+	if missed, ok := intervalOnly.MissedSnapshotTime(time.Date(2021, time.October, 1, 0, 0, 0, 0, time.Local), now); ok {
+		t.Errorf("unexpected missed snapshot time: %v", missed)
+	}
+}
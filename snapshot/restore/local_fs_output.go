@@ -2,11 +2,14 @@
 package restore
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,7 +23,11 @@
 const (
 	modBits                           = os.ModePerm | os.ModeSetgid | os.ModeSetuid | os.ModeSticky
 	outputDirMode                     = 0o700 // default mode to create directories in before setting their ACLs
+	outputFileMode                    = 0o600 // default mode to create special files in before setting their ACLs
 	maxTimeDeltaToConsiderFileTheSame = 2 * time.Second
+
+	// extended attributes in this namespace hold security labels, such as SELinux contexts.
+	securityXattrPrefix = "security."
 )
 
 // FilesystemOutput contains the options for outputting a file system tree.
@@ -52,6 +59,11 @@ type FilesystemOutput struct {
 
 	// SkipTimes when set to true causes restore to skip restoring modification times.
 	SkipTimes bool `json:"skipTimes"`
+
+	// SkipExtendedAttributes when set to true causes restore to skip restoring extended attributes and ACLs.
+	// Otherwise extended attributes of the target which are not present in the snapshot are removed,
+	// except for security.* attributes.
+	SkipExtendedAttributes bool `json:"skipExtendedAttributes"`
 }
 
 // Parallelizable implements restore.Output interface.
@@ -73,7 +85,7 @@ func (o *FilesystemOutput) BeginDirectory(ctx context.Context, relativePath stri
 // FinishDirectory implements restore.Output interface.
 func (o *FilesystemOutput) FinishDirectory(ctx context.Context, relativePath string, e fs.Directory) error {
 	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
-	if err := o.setAttributes(path, e, os.FileMode(0)); err != nil {
+	if err := o.setAttributes(ctx, path, e, os.FileMode(0)); err != nil {
 		return errors.Wrap(err, "error setting attributes")
 	}
 
@@ -99,13 +111,41 @@ func (o *FilesystemOutput) WriteFile(ctx context.Context, relativePath string, f
 		return errors.Wrap(err, "error creating file")
 	}
 
-	if err := o.setAttributes(path, f, os.FileMode(0)); err != nil {
+	if err := o.setAttributes(ctx, path, f, os.FileMode(0)); err != nil {
 		return errors.Wrap(err, "error setting attributes")
 	}
 
 	return SafeRemoveAll(path)
 }
 
+// CreateHardLink implements restore.HardLinkOutput interface.
+func (o *FilesystemOutput) CreateHardLink(ctx context.Context, relativePath, existingRelativePath string, f fs.File) error {
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+	existingPath := filepath.Join(o.TargetPath, filepath.FromSlash(existingRelativePath))
+
+	log(ctx).Debugf("CreateHardLink %v => %v", path, existingPath)
+
+	switch _, err := os.Lstat(path); {
+	case os.IsNotExist(err): // Proceed to link creation
+	case err != nil:
+		return errors.Wrap(err, "lstat error at hard link path")
+	default:
+		if !o.OverwriteFiles {
+			return errors.Errorf("unable to create %q, it already exists", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return errors.Wrap(err, "removing existing file")
+		}
+	}
+
+	if err := os.Link(existingPath, path); err != nil {
+		return errors.Wrap(err, "error creating hard link")
+	}
+
+	return nil
+}
+
 // FileExists implements restore.Output interface.
 func (o *FilesystemOutput) FileExists(ctx context.Context, relativePath string, e fs.File) bool {
 	st, err := os.Lstat(filepath.Join(o.TargetPath, relativePath))
@@ -164,7 +204,43 @@ func (o *FilesystemOutput) CreateSymlink(ctx context.Context, relativePath strin
 		return errors.Wrap(err, "error creating symlink")
 	}
 
-	if err := o.setAttributes(path, e, os.FileMode(0)); err != nil {
+	if err := o.setAttributes(ctx, path, e, os.FileMode(0)); err != nil {
+		return errors.Wrap(err, "error setting attributes")
+	}
+
+	return nil
+}
+
+// CreateSpecialFile implements restore.SpecialFileOutput interface.
+func (o *FilesystemOutput) CreateSpecialFile(ctx context.Context, relativePath string, e fs.SpecialFile) error {
+	path := filepath.Join(o.TargetPath, filepath.FromSlash(relativePath))
+
+	log(ctx).Debugf("CreateSpecialFile %v (%v), device %x", path, e.Mode(), e.Device().Rdev)
+
+	switch _, err := os.Lstat(path); {
+	case os.IsNotExist(err): // Proceed to special file creation
+	case err != nil:
+		return errors.Wrap(err, "lstat error at special file path")
+	default:
+		if !o.OverwriteFiles {
+			return errors.Errorf("unable to create %q, it already exists", path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return errors.Wrap(err, "removing existing file")
+		}
+	}
+
+	if err := createSpecialFile(path, e); err != nil {
+		if o.maybeIgnorePermissionError(err) == nil {
+			// creating device nodes requires elevated privileges.
+			return errors.Wrapf(ErrInsufficientPrivileges, "unable to create %v", path)
+		}
+
+		return errors.Wrap(err, "error creating special file")
+	}
+
+	if err := o.setAttributes(ctx, path, e, os.FileMode(0)); err != nil {
 		return errors.Wrap(err, "error setting attributes")
 	}
 
@@ -185,10 +261,10 @@ func (o *FilesystemOutput) SymlinkExists(ctx context.Context, relativePath strin
 	return (st.Mode() & os.ModeType) == os.ModeSymlink
 }
 
-// setAttributes sets permission, modification time and user/group ids
+// setAttributes sets permission, modification time, user/group ids and extended attributes
 // on targetPath. modclear will clear the specified FileMod bits. Pass 0
 // to not clear any.
-func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear os.FileMode) error {
+func (o *FilesystemOutput) setAttributes(ctx context.Context, targetPath string, e fs.Entry, modclear os.FileMode) error {
 	le, err := localfs.NewEntry(targetPath)
 	if err != nil {
 		return errors.Wrap(err, "could not create local FS entry for "+targetPath)
@@ -216,6 +292,14 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear
 		}
 	}
 
+	// Set extended attributes from e after changing the owner, which clears some of them,
+	// but before permissions, which are affected by POSIX ACLs.
+	if !o.SkipExtendedAttributes {
+		if err = o.setExtendedAttributes(ctx, targetPath, e); err != nil {
+			return errors.Wrap(err, "could not set extended attributes on "+targetPath)
+		}
+	}
+
 	// Set file permissions from e
 	if o.shouldUpdatePermissions(le, e, modclear) {
 		if err = o.maybeIgnorePermissionError(osChmod(targetPath, (e.Mode()&modBits)&^modclear)); err != nil {
@@ -232,6 +316,43 @@ func (o *FilesystemOutput) setAttributes(targetPath string, e fs.Entry, modclear
 	return nil
 }
 
+func (o *FilesystemOutput) setExtendedAttributes(ctx context.Context, targetPath string, e fs.Entry) error {
+	xattrs, err := fs.GetExtendedAttributes(ctx, e)
+	if err != nil {
+		return errors.Wrap(err, "unable to get extended attributes")
+	}
+
+	// read attributes of targetPath itself, shallow placeholder entries report the path without their suffix.
+	existing, err := localfs.ReadExtendedAttributes(targetPath)
+	if err != nil {
+		return errors.Wrap(err, "unable to get existing extended attributes")
+	}
+
+	// remove attributes left over from previous contents of the target, except for security
+	// labels which are managed by the operating system.
+	for name := range existing {
+		if _, ok := xattrs[name]; ok || strings.HasPrefix(name, securityXattrPrefix) {
+			continue
+		}
+
+		if err := o.maybeIgnorePermissionError(removeExtendedAttribute(targetPath, name)); err != nil {
+			return errors.Wrapf(err, "unable to remove %q", name)
+		}
+	}
+
+	for name, value := range xattrs {
+		if v, ok := existing[name]; ok && bytes.Equal(v, value) {
+			continue
+		}
+
+		if err := o.maybeIgnorePermissionError(setExtendedAttribute(targetPath, name, value)); err != nil {
+			return errors.Wrapf(err, "unable to set %q", name)
+		}
+	}
+
+	return nil
+}
+
 func isSymlink(e fs.Entry) bool {
 	_, ok := e.(fs.Symlink)
 	return ok
@@ -318,12 +439,76 @@ func (o *FilesystemOutput) copyFileContent(ctx context.Context, targetPath strin
 	}
 	defer r.Close() //nolint:errcheck
 
+	regions, err := fs.GetDataRegions(r)
+	if err != nil {
+		return errors.Wrap(err, "unable to determine data regions of "+targetPath)
+	}
+
+	if regions != nil {
+		log(ctx).Debugf("copying sparse file contents to: %v", targetPath)
+
+		return writeSparseFile(targetPath, r, regions, f.Size())
+	}
+
 	log(ctx).Debugf("copying file contents to: %v", targetPath)
 
 	// nolint:wrapcheck
 	return atomicfile.Write(targetPath, r)
 }
 
+// writeSparseFile atomically replaces the target file with a file containing only the provided
+// data regions, leaving holes between them unallocated.
+func writeSparseFile(targetPath string, r fs.Reader, regions []fs.Region, size int64) (err error) {
+	targetPath = atomicfile.MaybePrefixLongFilenameOnWindows(targetPath)
+
+	f, err := ioutil.TempFile(filepath.Dir(targetPath), filepath.Base(targetPath)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary file")
+	}
+
+	tempName := f.Name()
+
+	defer func() {
+		if err != nil {
+			f.Close()           //nolint:errcheck,gosec
+			os.Remove(tempName) //nolint:errcheck
+		}
+	}()
+
+	for _, rg := range regions {
+		if _, err = r.Seek(rg.Offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek source file")
+		}
+
+		if _, err = f.Seek(rg.Offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek target file")
+		}
+
+		if _, err = io.CopyN(f, r, rg.Length); err != nil {
+			return errors.Wrap(err, "unable to copy data region")
+		}
+	}
+
+	// extend the file to its full size, which creates a trailing hole if needed.
+	if err = f.Truncate(size); err != nil {
+		return errors.Wrap(err, "unable to set file size")
+	}
+
+	if err = f.Sync(); err != nil {
+		return errors.Wrap(err, "unable to sync file")
+	}
+
+	if err = f.Close(); err != nil {
+		return errors.Wrap(err, "unable to close file")
+	}
+
+	if err = os.Rename(tempName, targetPath); err != nil {
+		return errors.Wrap(err, "unable to rename file")
+	}
+
+	return nil
+}
+
 func isEmptyDirectory(name string) (bool, error) {
 	f, err := os.Open(name) //nolint:gosec
 	if err != nil {
@@ -339,4 +524,8 @@ func isEmptyDirectory(name string) (bool, error) {
 	return false, errors.Wrap(err, "error reading directory") // Either not empty or error
 }
 
-var _ Output = (*FilesystemOutput)(nil)
+var (
+	_ Output            = (*FilesystemOutput)(nil)
+	_ HardLinkOutput    = (*FilesystemOutput)(nil)
+	_ SpecialFileOutput = (*FilesystemOutput)(nil)
+)
@@ -0,0 +1,37 @@
+// +build linux darwin
+
+package restore
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func createSpecialFile(path string, e fs.SpecialFile) error {
+	var mode uint32
+
+	switch e.SpecialFileType() {
+	case os.ModeDevice:
+		mode = unix.S_IFBLK
+	case os.ModeDevice | os.ModeCharDevice:
+		mode = unix.S_IFCHR
+	case os.ModeNamedPipe:
+		mode = unix.S_IFIFO
+	case os.ModeSocket:
+		mode = unix.S_IFSOCK
+	default:
+		return errors.Errorf("unsupported special file type: %v", e.Mode())
+	}
+
+	// permissions are set later, create the node with restrictive ones.
+	// nolint:wrapcheck
+	return unix.Mknod(path, mode|outputFileMode, int(e.Device().Rdev))
+}
+
+func deviceNumbers(rdev uint64) (major, minor int64) {
+	return int64(unix.Major(rdev)), int64(unix.Minor(rdev))
+}
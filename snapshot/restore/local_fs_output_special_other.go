@@ -0,0 +1,17 @@
+// +build !linux,!darwin
+
+package restore
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+func createSpecialFile(path string, e fs.SpecialFile) error {
+	return errors.Errorf("special files are not supported on this platform")
+}
+
+func deviceNumbers(rdev uint64) (major, minor int64) {
+	return 0, 0
+}
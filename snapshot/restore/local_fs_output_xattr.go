@@ -0,0 +1,31 @@
+// +build linux darwin
+
+package restore
+
+import (
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// setExtendedAttribute sets the extended attribute on the path without following symlinks.
+// Attributes are silently skipped if the target filesystem does not support them.
+func setExtendedAttribute(path, name string, value []byte) error {
+	err := unix.Lsetxattr(path, name, value, 0)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return nil
+	}
+
+	// nolint:wrapcheck
+	return err
+}
+
+// removeExtendedAttribute removes the extended attribute from the path without following symlinks.
+func removeExtendedAttribute(path, name string) error {
+	err := unix.Lremovexattr(path, name)
+	if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+		return nil
+	}
+
+	// nolint:wrapcheck
+	return err
+}
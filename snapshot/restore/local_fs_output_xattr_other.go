@@ -0,0 +1,13 @@
+// +build !linux,!darwin
+
+package restore
+
+func setExtendedAttribute(path, name string, value []byte) error {
+	// extended attributes are not supported on this platform.
+	return nil
+}
+
+func removeExtendedAttribute(path, name string) error {
+	// extended attributes are not supported on this platform.
+	return nil
+}
@@ -4,6 +4,7 @@
 	"context"
 	"path"
 	"runtime"
+	"sync"
 	"sync/atomic"
 
 	"github.com/pkg/errors"
@@ -17,6 +18,14 @@
 
 var log = logging.GetContextLoggerFunc("restore")
 
+// ErrSpecialFileNotSupported is returned by SpecialFileOutput that can't store a particular type of special file,
+// such files are skipped.
+var ErrSpecialFileNotSupported = errors.New("special file type not supported by output")
+
+// ErrInsufficientPrivileges is returned by SpecialFileOutput that is not permitted to create a special file,
+// such as a device node, when permission errors are ignored. Such files are skipped.
+var ErrInsufficientPrivileges = errors.New("insufficient privileges")
+
 // Output encapsulates output for restore operation.
 type Output interface {
 	Parallelizable() bool
@@ -30,6 +39,17 @@ type Output interface {
 	Close(ctx context.Context) error
 }
 
+// HardLinkOutput is optionally implemented by Output that can recreate hard links between restored files.
+type HardLinkOutput interface {
+	CreateHardLink(ctx context.Context, relativePath, existingRelativePath string, e fs.File) error
+}
+
+// SpecialFileOutput is optionally implemented by Output that can recreate device nodes, named pipes and sockets.
+// Special files are skipped when restoring to outputs that don't implement it.
+type SpecialFileOutput interface {
+	CreateSpecialFile(ctx context.Context, relativePath string, e fs.SpecialFile) error
+}
+
 // Stats represents restore statistics.
 type Stats struct {
 	RestoredTotalFileSize int64
@@ -127,6 +147,15 @@ type copier struct {
 	incremental   bool
 	ignoreErrors  bool
 	cancel        chan struct{}
+
+	hardLinks sync.Map // map[string]*restoredHardLink
+}
+
+// restoredHardLink tracks the first restored file of a group of hard links.
+type restoredHardLink struct {
+	targetPath string
+	done       chan struct{}
+	err        error
 }
 
 func (c *copier) copyEntry(ctx context.Context, e fs.Entry, targetPath string, currentdepth, maxdepth int32, onCompletion func() error) error {
@@ -192,7 +221,7 @@ func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath s
 				return errors.Wrap(err, "copy file")
 			}
 		} else {
-			if err := c.output.WriteFile(ctx, targetPath, e); err != nil {
+			if err := c.writeFileOrHardLink(ctx, targetPath, e); err != nil {
 				return errors.Wrap(err, "copy file")
 			}
 		}
@@ -209,11 +238,78 @@ func (c *copier) copyEntryInternal(ctx context.Context, e fs.Entry, targetPath s
 
 		return onCompletion()
 
+	case fs.SpecialFile:
+		log(ctx).Debugf("special file: '%v'", targetPath)
+
+		if err := c.createSpecialFile(ctx, targetPath, e); err != nil {
+			if !errors.Is(err, ErrSpecialFileNotSupported) && !errors.Is(err, ErrInsufficientPrivileges) {
+				return errors.Wrap(err, "create special file")
+			}
+
+			atomic.AddInt32(&c.stats.SkippedCount, 1)
+			log(ctx).Infof("WARNING: skipping %v: %v", targetPath, err)
+
+			return onCompletion()
+		}
+
+		atomic.AddInt32(&c.stats.RestoredFileCount, 1)
+
+		return onCompletion()
+
 	default:
 		return errors.Errorf("invalid FS entry type for %q: %#v", targetPath, e)
 	}
 }
 
+func (c *copier) createSpecialFile(ctx context.Context, targetPath string, e fs.SpecialFile) error {
+	sfo, ok := c.output.(SpecialFileOutput)
+	if !ok {
+		return ErrSpecialFileNotSupported
+	}
+
+	// nolint:wrapcheck
+	return sfo.CreateSpecialFile(ctx, targetPath, e)
+}
+
+// writeFileOrHardLink writes the file to the output unless it is a hard link to a file that has already been
+// restored, in which case the link is recreated instead.
+func (c *copier) writeFileOrHardLink(ctx context.Context, targetPath string, f fs.File) error {
+	hlo, ok := c.output.(HardLinkOutput)
+	if !ok {
+		return c.output.WriteFile(ctx, targetPath, f)
+	}
+
+	de, ok := f.(snapshot.HasDirEntry)
+	if !ok || de.DirEntry().LinkGroup == "" {
+		return c.output.WriteFile(ctx, targetPath, f)
+	}
+
+	rhl := &restoredHardLink{targetPath: targetPath, done: make(chan struct{})}
+
+	v, loaded := c.hardLinks.LoadOrStore(de.DirEntry().LinkGroup, rhl)
+	if !loaded {
+		rhl.err = c.output.WriteFile(ctx, targetPath, f)
+		close(rhl.done)
+
+		return rhl.err
+	}
+
+	existing := v.(*restoredHardLink) // nolint:forcetypeassert
+
+	// wait for the first file in the group to be restored, which is already in progress.
+	<-existing.done
+
+	if existing.err != nil {
+		// first file could not be restored, fall back to writing a separate copy.
+		return c.output.WriteFile(ctx, targetPath, f)
+	}
+
+	log(ctx).Debugf("hard link: '%v' => '%v'", targetPath, existing.targetPath)
+
+	// nolint:wrapcheck
+	return hlo.CreateHardLink(ctx, targetPath, existing.targetPath, f)
+}
+
 func (c *copier) copyDirectory(ctx context.Context, d fs.Directory, targetPath string, currentdepth, maxdepth int32, onCompletion parallelwork.CallbackFunc) error {
 	atomic.AddInt32(&c.stats.RestoredDirCount, 1)
 
@@ -0,0 +1,413 @@
+// +build linux darwin
+
+package restore_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/fs/localfs"
+	"github.com/kopia/kopia/internal/repotesting"
+	"github.com/kopia/kopia/internal/testutil"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/policy"
+	"github.com/kopia/kopia/snapshot/restore"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// snapshotDirectory snapshots the local directory using the provided policy and returns the root of the snapshot.
+func snapshotDirectory(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, src string, pol *policy.Policy) fs.Entry {
+	t.Helper()
+
+	dir, err := localfs.Directory(src)
+	require.NoError(t, err)
+
+	man, err := snapshotfs.NewUploader(rep).Upload(ctx, dir, policy.BuildTree(map[string]*policy.Policy{".": pol}, policy.DefaultPolicy), snapshot.SourceInfo{})
+	require.NoError(t, err)
+
+	root, err := snapshotfs.SnapshotRoot(rep, man)
+	require.NoError(t, err)
+
+	return root
+}
+
+// restoreToDirectory restores the entry to the provided local directory, overwriting existing files.
+func restoreToDirectory(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, root fs.Entry, dst string) {
+	t.Helper()
+
+	_, err := restore.Entry(ctx, rep, &restore.FilesystemOutput{
+		TargetPath:             dst,
+		OverwriteDirectories:   true,
+		OverwriteFiles:         true,
+		IgnorePermissionErrors: true,
+	}, root, restore.Options{RestoreDirEntryAtDepth: math.MaxInt32})
+	require.NoError(t, err)
+}
+
+// restoreToTar restores the entry to a tar archive and returns its contents.
+func restoreToTar(ctx context.Context, t *testing.T, rep repo.RepositoryWriter, root fs.Entry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	_, err := restore.Entry(ctx, rep, restore.NewTarOutput(nopWriteCloser{&buf}), root, restore.Options{RestoreDirEntryAtDepth: math.MaxInt32})
+	require.NoError(t, err)
+
+	return &buf
+}
+
+func TestRestoreHardLinks(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	src := testutil.TempDirectory(t)
+
+	require.NoError(t, os.Mkdir(filepath.Join(src, "sub"), 0o700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "f1"), []byte{1, 2, 3}, 0o600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(src, "f4"), []byte{1, 2, 3}, 0o600))
+	require.NoError(t, os.Link(filepath.Join(src, "f1"), filepath.Join(src, "f2")))
+	require.NoError(t, os.Link(filepath.Join(src, "f1"), filepath.Join(src, "sub", "f3")))
+
+	root := snapshotDirectory(ctx, t, env.RepositoryWriter, src, policy.DefaultPolicy)
+
+	linkGroup := func(name ...string) string {
+		e := root
+
+		for _, n := range name {
+			var err error
+
+			e, err = e.(fs.Directory).Child(ctx, n)
+			require.NoError(t, err)
+		}
+
+		return e.(snapshot.HasDirEntry).DirEntry().LinkGroup
+	}
+
+	require.NotEmpty(t, linkGroup("f1"))
+	require.Equal(t, linkGroup("f1"), linkGroup("f2"))
+	require.Equal(t, linkGroup("f1"), linkGroup("sub", "f3"))
+	require.Empty(t, linkGroup("f4"))
+
+	t.Run("Filesystem", func(t *testing.T) {
+		dst := testutil.TempDirectory(t)
+
+		restoreToDirectory(ctx, t, env.RepositoryWriter, root, dst)
+
+		stat := func(name ...string) os.FileInfo {
+			st, err := os.Stat(filepath.Join(append([]string{dst}, name...)...))
+			require.NoError(t, err)
+
+			return st
+		}
+
+		require.True(t, os.SameFile(stat("f1"), stat("f2")))
+		require.True(t, os.SameFile(stat("f1"), stat("sub", "f3")))
+		require.False(t, os.SameFile(stat("f1"), stat("f4")))
+	})
+
+	t.Run("Tar", func(t *testing.T) {
+		buf := restoreToTar(ctx, t, env.RepositoryWriter, root)
+
+		links := map[string]string{}
+		tr := tar.NewReader(buf)
+
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+
+			require.NoError(t, err)
+
+			if h.Typeflag == tar.TypeLink {
+				links[h.Name] = h.Linkname
+			}
+		}
+
+		// the first file in the group is stored, other ones are links to it.
+		require.Len(t, links, 2)
+
+		for _, target := range links {
+			require.Contains(t, []string{"f1", "f2", "sub/f3"}, target)
+			require.NotContains(t, links, target)
+		}
+	})
+}
+
+func TestRestoreSpecialFiles(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	// unix socket paths are limited to ~108 bytes, so avoid the long temporary directory names.
+	src := t.TempDir()
+
+	require.NoError(t, unix.Mkfifo(filepath.Join(src, "fifo"), 0o640))
+	require.NoError(t, os.Chmod(filepath.Join(src, "fifo"), 0o640))
+
+	// creating device nodes requires root privileges.
+	isRoot := os.Geteuid() == 0
+	if isRoot {
+		require.NoError(t, unix.Mknod(filepath.Join(src, "null"), unix.S_IFCHR|0o666, int(unix.Mkdev(1, 3))))
+		require.NoError(t, os.Chmod(filepath.Join(src, "null"), 0o666))
+	}
+
+	l, err := net.Listen("unix", filepath.Join(src, "sock"))
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+
+	// without the policy, special files are treated as entries of unknown type.
+	ignoreUnknownTypes := false
+
+	root := snapshotDirectory(ctx, t, env.RepositoryWriter, src, &policy.Policy{
+		ErrorHandlingPolicy: policy.ErrorHandlingPolicy{IgnoreUnknownTypes: &ignoreUnknownTypes},
+	})
+
+	_, err = root.(fs.Directory).Child(ctx, "fifo")
+	require.Error(t, err)
+	require.Equal(t, 2+boolToInt(isRoot), root.(snapshot.HasDirEntry).DirEntry().DirSummary.FatalErrorCount)
+
+	specialFiles := true
+
+	root = snapshotDirectory(ctx, t, env.RepositoryWriter, src, &policy.Policy{
+		FilesPolicy: policy.FilesPolicy{SpecialFiles: &specialFiles},
+	})
+
+	fifo, err := root.(fs.Directory).Child(ctx, "fifo")
+	require.NoError(t, err)
+	require.Equal(t, snapshot.EntryTypeNamedPipe, fifo.(snapshot.HasDirEntry).DirEntry().Type)
+	require.Equal(t, os.ModeNamedPipe|0o640, fifo.Mode())
+
+	// special files have no contents, so no object is written for them.
+	require.Empty(t, fifo.(snapshot.HasDirEntry).DirEntry().ObjectID)
+
+	t.Run("Filesystem", func(t *testing.T) {
+		dst := testutil.TempDirectory(t)
+
+		restoreToDirectory(ctx, t, env.RepositoryWriter, root, dst)
+
+		st, err := os.Lstat(filepath.Join(dst, "fifo"))
+		require.NoError(t, err)
+		require.Equal(t, os.ModeNamedPipe|0o640, st.Mode())
+
+		if isRoot {
+			st, err := os.Lstat(filepath.Join(dst, "null"))
+			require.NoError(t, err)
+			require.Equal(t, os.ModeDevice|os.ModeCharDevice|0o666, st.Mode())
+			require.Equal(t, unix.Mkdev(1, 3), uint64(st.Sys().(*syscall.Stat_t).Rdev)) // nolint:unconvert
+		}
+	})
+
+	t.Run("Tar", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		st, err := restore.Entry(ctx, env.RepositoryWriter, restore.NewTarOutput(nopWriteCloser{&buf}), root, restore.Options{})
+		require.NoError(t, err)
+
+		// sockets can't be stored in tar.
+		require.Equal(t, int32(1), st.SkippedCount)
+
+		headers := map[string]*tar.Header{}
+		tr := tar.NewReader(&buf)
+
+		for {
+			h, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+
+			require.NoError(t, err)
+
+			headers[h.Name] = h
+		}
+
+		require.Equal(t, byte(tar.TypeFifo), headers["fifo"].Typeflag)
+		require.NotContains(t, headers, "sock")
+
+		if isRoot {
+			require.Equal(t, byte(tar.TypeChar), headers["null"].Typeflag)
+			require.Equal(t, int64(1), headers["null"].Devmajor)
+			require.Equal(t, int64(3), headers["null"].Devminor)
+		}
+	})
+
+	t.Run("Zip", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		st, err := restore.Entry(ctx, env.RepositoryWriter, restore.NewZipOutput(nopWriteCloser{&buf}, zip.Store), root, restore.Options{})
+		require.NoError(t, err)
+
+		// zip can't store special files.
+		require.Equal(t, int32(2+boolToInt(isRoot)), st.SkippedCount)
+		require.Equal(t, int32(0), st.RestoredFileCount)
+	})
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func TestRestoreSparseFile(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t, repotesting.Options{
+		NewRepositoryOptions: func(o *repo.NewRepositoryOptions) {
+			o.ObjectFormat.SparseObjects = true
+		},
+	})
+
+	// clients that don't support objects with holes must refuse to open the repository.
+	require.Equal(t, content.FormatVersion2, env.RepositoryWriter.ContentReader().ContentFormat().Version)
+
+	src := testutil.TempDirectory(t)
+
+	const (
+		fileSize   = 4 << 20
+		dataOffset = 1 << 20
+	)
+
+	// name longer than 100 bytes, which doesn't fit in the ustar header.
+	fileName := "sparse-" + strings.Repeat("x", 150)
+
+	f, err := os.Create(filepath.Join(src, fileName))
+	require.NoError(t, err)
+	require.NoError(t, f.Truncate(fileSize))
+	_, err = f.WriteAt([]byte("hello"), dataOffset)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	expected := make([]byte, fileSize)
+	copy(expected[dataOffset:], "hello")
+
+	root := snapshotDirectory(ctx, t, env.RepositoryWriter, src, policy.DefaultPolicy)
+
+	e, err := root.(fs.Directory).Child(ctx, fileName)
+	require.NoError(t, err)
+	require.Equal(t, int64(fileSize), e.Size())
+
+	r, err := e.(fs.File).Open(ctx)
+	require.NoError(t, err)
+
+	regions, err := fs.GetDataRegions(r)
+	require.NoError(t, err)
+	r.Close()
+
+	if regions == nil {
+		t.Skip("holes were not detected in the source file")
+	}
+
+	t.Run("Filesystem", func(t *testing.T) {
+		dst := testutil.TempDirectory(t)
+
+		restoreToDirectory(ctx, t, env.RepositoryWriter, root, dst)
+
+		got, err := ioutil.ReadFile(filepath.Join(dst, fileName))
+		require.NoError(t, err)
+		require.Equal(t, expected, got)
+
+		st, err := os.Stat(filepath.Join(dst, fileName))
+		require.NoError(t, err)
+		require.Less(t, st.Sys().(*syscall.Stat_t).Blocks*512, int64(fileSize))
+	})
+
+	t.Run("Tar", func(t *testing.T) {
+		buf := restoreToTar(ctx, t, env.RepositoryWriter, root)
+
+		// holes are not stored in tar.
+		require.Less(t, buf.Len(), fileSize)
+
+		tr := tar.NewReader(buf)
+
+		h, err := tr.Next()
+		require.NoError(t, err)
+		require.Equal(t, fileName, h.Name)
+		require.Equal(t, int64(fileSize), h.Size)
+
+		got, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		require.Equal(t, expected, got)
+
+		_, err = tr.Next()
+		require.Equal(t, io.EOF, err)
+	})
+}
+
+func TestRestoreExtendedAttributes(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	src := testutil.TempDirectory(t)
+	fname := filepath.Join(src, "f1")
+
+	require.NoError(t, ioutil.WriteFile(fname, []byte{1, 2, 3}, 0o600))
+
+	if err := unix.Lsetxattr(fname, "user.kopia-test", []byte("some-value"), 0); err != nil {
+		if errors.Is(err, unix.ENOTSUP) || errors.Is(err, unix.EOPNOTSUPP) {
+			t.Skip("extended attributes are not supported")
+		}
+
+		t.Fatal(err)
+	}
+
+	xattrsEnabled := true
+
+	root := snapshotDirectory(ctx, t, env.RepositoryWriter, src, &policy.Policy{
+		FilesPolicy: policy.FilesPolicy{ExtendedAttributes: &xattrsEnabled},
+	})
+
+	t.Run("Filesystem", func(t *testing.T) {
+		dst := testutil.TempDirectory(t)
+		target := filepath.Join(dst, "f1")
+
+		// attributes of existing target which are not in the snapshot are removed.
+		require.NoError(t, ioutil.WriteFile(target, []byte{4, 5, 6}, 0o600))
+		require.NoError(t, unix.Lsetxattr(target, "user.kopia-stale", []byte("stale"), 0))
+		require.NoError(t, unix.Lsetxattr(target, "user.kopia-test", []byte("old-value"), 0))
+
+		restoreToDirectory(ctx, t, env.RepositoryWriter, root, dst)
+
+		le, err := localfs.NewEntry(target)
+		require.NoError(t, err)
+
+		got, err := fs.GetExtendedAttributes(ctx, le)
+		require.NoError(t, err)
+		require.Equal(t, []byte("some-value"), got["user.kopia-test"])
+		require.NotContains(t, got, "user.kopia-stale")
+	})
+
+	t.Run("Tar", func(t *testing.T) {
+		buf := restoreToTar(ctx, t, env.RepositoryWriter, root)
+
+		tr := tar.NewReader(buf)
+
+		h, err := tr.Next()
+		require.NoError(t, err)
+		require.Equal(t, "f1", h.Name)
+		require.Equal(t, "some-value", h.PAXRecords["SCHILY.xattr.user.kopia-test"])
+	})
+}
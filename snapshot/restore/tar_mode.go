@@ -0,0 +1,29 @@
+package restore
+
+import "os"
+
+// mode bits as stored in tar headers.
+const (
+	tarModeSetuid = 0o4000
+	tarModeSetgid = 0o2000
+	tarModeSticky = 0o1000
+)
+
+// tarMode converts file mode to the mode bits stored in tar headers.
+func tarMode(m os.FileMode) int64 {
+	result := int64(m.Perm())
+
+	if m&os.ModeSetuid != 0 {
+		result |= tarModeSetuid
+	}
+
+	if m&os.ModeSetgid != 0 {
+		result |= tarModeSetgid
+	}
+
+	if m&os.ModeSticky != 0 {
+		result |= tarModeSticky
+	}
+
+	return result
+}
@@ -4,6 +4,7 @@
 	"archive/tar"
 	"context"
 	"io"
+	"os"
 
 	"github.com/pkg/errors"
 
@@ -13,7 +14,7 @@
 
 // TarOutput contains the options for outputting a file system tree to a tar or .tar.gz file.
 type TarOutput struct {
-	w  io.Closer
+	w  io.WriteCloser
 	tf *tar.Writer
 }
 
@@ -37,6 +38,10 @@ func (o *TarOutput) BeginDirectory(ctx context.Context, relativePath string, d f
 		Typeflag: tar.TypeDir,
 	}
 
+	if err := setTarExtendedAttributes(ctx, h, d); err != nil {
+		return err
+	}
+
 	if err := o.tf.WriteHeader(h); err != nil {
 		return errors.Wrap(err, "error writing tar header")
 	}
@@ -65,6 +70,7 @@ func (o *TarOutput) Close(ctx context.Context) error {
 }
 
 // WriteFile implements restore.Output interface.
+// Sparse files are written as PAX sparse entries, which only store their data regions.
 func (o *TarOutput) WriteFile(ctx context.Context, relativePath string, f fs.File) error {
 	r, err := f.Open(ctx)
 	if err != nil {
@@ -72,6 +78,15 @@ func (o *TarOutput) WriteFile(ctx context.Context, relativePath string, f fs.Fil
 	}
 	defer r.Close() //nolint:errcheck
 
+	regions, err := fs.GetDataRegions(r)
+	if err != nil {
+		return errors.Wrap(err, "error determining data regions")
+	}
+
+	if regions != nil {
+		return o.writeSparseFile(ctx, relativePath, f, r, regions)
+	}
+
 	h := &tar.Header{
 		Name:     relativePath,
 		ModTime:  f.ModTime(),
@@ -82,6 +97,10 @@ func (o *TarOutput) WriteFile(ctx context.Context, relativePath string, f fs.Fil
 		Typeflag: tar.TypeReg,
 	}
 
+	if err := setTarExtendedAttributes(ctx, h, f); err != nil {
+		return err
+	}
+
 	if err := o.tf.WriteHeader(h); err != nil {
 		return errors.Wrap(err, "error writing tar header")
 	}
@@ -93,6 +112,60 @@ func (o *TarOutput) WriteFile(ctx context.Context, relativePath string, f fs.Fil
 	return nil
 }
 
+// CreateHardLink implements restore.HardLinkOutput interface.
+func (o *TarOutput) CreateHardLink(ctx context.Context, relativePath, existingRelativePath string, f fs.File) error {
+	h := &tar.Header{
+		Name:     relativePath,
+		ModTime:  f.ModTime(),
+		Mode:     tarMode(f.Mode()),
+		Uid:      int(f.Owner().UserID),
+		Gid:      int(f.Owner().GroupID),
+		Typeflag: tar.TypeLink,
+		Linkname: existingRelativePath,
+	}
+
+	if err := o.tf.WriteHeader(h); err != nil {
+		return errors.Wrap(err, "error writing tar header")
+	}
+
+	return nil
+}
+
+// CreateSpecialFile implements restore.SpecialFileOutput interface.
+// Sockets can't be stored in tar and are skipped.
+func (o *TarOutput) CreateSpecialFile(ctx context.Context, relativePath string, e fs.SpecialFile) error {
+	h := &tar.Header{
+		Name:    relativePath,
+		ModTime: e.ModTime(),
+		Mode:    int64(e.Mode().Perm()),
+		Uid:     int(e.Owner().UserID),
+		Gid:     int(e.Owner().GroupID),
+	}
+
+	switch e.SpecialFileType() {
+	case os.ModeDevice:
+		h.Typeflag = tar.TypeBlock
+		h.Devmajor, h.Devminor = deviceNumbers(e.Device().Rdev)
+	case os.ModeDevice | os.ModeCharDevice:
+		h.Typeflag = tar.TypeChar
+		h.Devmajor, h.Devminor = deviceNumbers(e.Device().Rdev)
+	case os.ModeNamedPipe:
+		h.Typeflag = tar.TypeFifo
+	default:
+		return errors.Wrapf(ErrSpecialFileNotSupported, "%v in tar", e.Mode())
+	}
+
+	if err := setTarExtendedAttributes(ctx, h, e); err != nil {
+		return err
+	}
+
+	if err := o.tf.WriteHeader(h); err != nil {
+		return errors.Wrap(err, "error writing tar header")
+	}
+
+	return nil
+}
+
 // FileExists implements restore.Output interface.
 func (o *TarOutput) FileExists(ctx context.Context, relativePath string, f fs.File) bool {
 	return false
@@ -115,6 +188,10 @@ func (o *TarOutput) CreateSymlink(ctx context.Context, relativePath string, l fs
 		Linkname: target,
 	}
 
+	if err := setTarExtendedAttributes(ctx, h, l); err != nil {
+		return err
+	}
+
 	if err := o.tf.WriteHeader(h); err != nil {
 		return errors.Wrap(err, "error writing tar header")
 	}
@@ -127,9 +204,38 @@ func (o *TarOutput) SymlinkExists(ctx context.Context, relativePath string, l fs
 	return false
 }
 
+// paxXattrPrefix is the prefix of PAX records holding extended attributes, understood by GNU tar and bsdtar.
+const paxXattrPrefix = "SCHILY.xattr."
+
+// setTarExtendedAttributes stores extended attributes of the entry as PAX records of the header.
+func setTarExtendedAttributes(ctx context.Context, h *tar.Header, e fs.Entry) error {
+	xattrs, err := fs.GetExtendedAttributes(ctx, e)
+	if err != nil {
+		return errors.Wrap(err, "unable to get extended attributes")
+	}
+
+	for name, value := range xattrs {
+		if h.PAXRecords == nil {
+			h.PAXRecords = map[string]string{}
+		}
+
+		h.PAXRecords[paxXattrPrefix+name] = string(value)
+	}
+
+	if h.PAXRecords != nil {
+		h.Format = tar.FormatPAX
+	}
+
+	return nil
+}
+
 // NewTarOutput creates new tar writer output.
 func NewTarOutput(w io.WriteCloser) *TarOutput {
 	return &TarOutput{w, tar.NewWriter(w)}
 }
 
-var _ Output = (*TarOutput)(nil)
+var (
+	_ Output            = (*TarOutput)(nil)
+	_ HardLinkOutput    = (*TarOutput)(nil)
+	_ SpecialFileOutput = (*TarOutput)(nil)
+)
@@ -0,0 +1,255 @@
+package restore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+)
+
+// archive/tar does not support writing sparse files, so they are written directly to the
+// underlying stream using the PAX format 1.0 sparse extension understood by GNU tar and
+// by archive/tar readers:
+//
+//	<PAX extended header with GNU.sparse.* records>
+//	<ustar header of GNUSparseFile.0/name with the size of the stored data>
+//	<sparse map: number of regions followed by offset and length of each, padded to a block>
+//	<contents of data regions, padded to a block>
+const (
+	tarBlockSize = 512
+
+	tarTypeRegular = '0'
+	tarTypePAX     = 'x'
+
+	// sizes of numeric ustar header fields, excluding the trailing NUL.
+	tarOctalDigits8  = 7
+	tarOctalDigits12 = 11
+)
+
+type tarRawHeader [tarBlockSize]byte
+
+func (h *tarRawHeader) setString(offset, length int, s string) {
+	if len(s) > length {
+		s = s[0:length]
+	}
+
+	copy(h[offset:offset+length], s)
+}
+
+func (h *tarRawHeader) setOctal(offset, digits int, v int64) {
+	h.setString(offset, digits, fmt.Sprintf("%0*o", digits, v))
+}
+
+func (h *tarRawHeader) setChecksum() {
+	const (
+		chksumOffset = 148
+		chksumLength = 8
+	)
+
+	for i := chksumOffset; i < chksumOffset+chksumLength; i++ {
+		h[i] = ' '
+	}
+
+	var sum int64
+	for _, b := range h {
+		sum += int64(b)
+	}
+
+	h.setString(chksumOffset, chksumLength, fmt.Sprintf("%06o\x00 ", sum))
+}
+
+type tarRawEntry struct {
+	name     string
+	mode     int64
+	uid, gid int64
+	size     int64
+	mtime    int64
+	typeflag byte
+}
+
+func (e *tarRawEntry) header() *tarRawHeader {
+	var h tarRawHeader
+
+	h.setString(0, 100, e.name) // nolint:gomnd
+	h.setOctal(100, tarOctalDigits8, e.mode)
+	h.setOctal(108, tarOctalDigits8, e.uid)
+	h.setOctal(116, tarOctalDigits8, e.gid)
+	h.setOctal(124, tarOctalDigits12, e.size)
+	h.setOctal(136, tarOctalDigits12, e.mtime)
+	h[156] = e.typeflag
+	h.setString(257, 6, "ustar\x00") // nolint:gomnd
+	h.setString(263, 2, "00")        // nolint:gomnd
+	h.setChecksum()
+
+	return &h
+}
+
+func fitsOctal(v int64, digits int) bool {
+	return v >= 0 && v < 1<<(3*digits)
+}
+
+// paxRecord formats a single PAX record, which is prefixed by its own length in decimal.
+func paxRecord(k, v string) string {
+	const padding = 3 // space, equal sign and newline
+
+	size := len(k) + len(v) + padding
+	size += len(strconv.Itoa(size))
+
+	record := strconv.Itoa(size) + " " + k + "=" + v + "\n"
+	if len(record) != size {
+		// adding the length changed the number of its digits.
+		record = strconv.Itoa(len(record)) + " " + k + "=" + v + "\n"
+	}
+
+	return record
+}
+
+func padToBlock(n int64) int64 {
+	if rem := n % tarBlockSize; rem != 0 {
+		return tarBlockSize - rem
+	}
+
+	return 0
+}
+
+func writeTarPadding(w io.Writer, n int64) error {
+	var zeros [tarBlockSize]byte
+
+	_, err := w.Write(zeros[0:padToBlock(n)])
+
+	return errors.Wrap(err, "error writing padding")
+}
+
+// writeSparseFile writes a sparse file entry containing the provided data regions to the underlying writer.
+func (o *TarOutput) writeSparseFile(ctx context.Context, relativePath string, f fs.File, r fs.Reader, regions []fs.Region) error {
+	// finish any padding of the previous entry, after which we're positioned at the header block boundary.
+	if err := o.tf.Flush(); err != nil {
+		return errors.Wrap(err, "error flushing tar")
+	}
+
+	var sparseMap bytes.Buffer
+
+	fmt.Fprintf(&sparseMap, "%d\n", len(regions))
+
+	var dataSize int64
+
+	for _, rg := range regions {
+		fmt.Fprintf(&sparseMap, "%d\n%d\n", rg.Offset, rg.Length)
+
+		dataSize += rg.Length
+	}
+
+	sparseMap.Write(make([]byte, padToBlock(int64(sparseMap.Len()))))
+
+	e := &tarRawEntry{
+		name:     path.Join(path.Dir(relativePath), "GNUSparseFile.0", path.Base(relativePath)),
+		mode:     tarMode(f.Mode()),
+		uid:      int64(f.Owner().UserID),
+		gid:      int64(f.Owner().GroupID),
+		size:     int64(sparseMap.Len()) + dataSize,
+		mtime:    f.ModTime().Unix(),
+		typeflag: tarTypeRegular,
+	}
+
+	records := map[string]string{
+		"GNU.sparse.major":    "1",
+		"GNU.sparse.minor":    "0",
+		"GNU.sparse.name":     relativePath,
+		"GNU.sparse.realsize": strconv.FormatInt(f.Size(), 10),
+	}
+
+	xattrs, err := fs.GetExtendedAttributes(ctx, f)
+	if err != nil {
+		return errors.Wrap(err, "unable to get extended attributes")
+	}
+
+	for name, value := range xattrs {
+		records[paxXattrPrefix+name] = string(value)
+	}
+
+	if !fitsOctal(e.size, tarOctalDigits12) {
+		records["size"] = strconv.FormatInt(e.size, 10)
+		e.size = 0
+	}
+
+	if !fitsOctal(e.uid, tarOctalDigits8) {
+		records["uid"] = strconv.FormatInt(e.uid, 10)
+		e.uid = 0
+	}
+
+	if !fitsOctal(e.gid, tarOctalDigits8) {
+		records["gid"] = strconv.FormatInt(e.gid, 10)
+		e.gid = 0
+	}
+
+	if !fitsOctal(e.mtime, tarOctalDigits12) {
+		records["mtime"] = strconv.FormatInt(e.mtime, 10)
+		e.mtime = 0
+	}
+
+	if err := o.writePAXHeader(e, records); err != nil {
+		return err
+	}
+
+	if _, err := o.w.Write(e.header()[:]); err != nil {
+		return errors.Wrap(err, "error writing tar header")
+	}
+
+	if _, err := o.w.Write(sparseMap.Bytes()); err != nil {
+		return errors.Wrap(err, "error writing sparse map")
+	}
+
+	for _, rg := range regions {
+		if _, err := r.Seek(rg.Offset, io.SeekStart); err != nil {
+			return errors.Wrap(err, "error seeking to data region")
+		}
+
+		if _, err := io.CopyN(o.w, r, rg.Length); err != nil {
+			return errors.Wrap(err, "error copying data to tar")
+		}
+	}
+
+	return writeTarPadding(o.w, dataSize)
+}
+
+func (o *TarOutput) writePAXHeader(e *tarRawEntry, records map[string]string) error {
+	keys := make([]string, 0, len(records))
+	for k := range records {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	var data bytes.Buffer
+
+	for _, k := range keys {
+		data.WriteString(paxRecord(k, records[k]))
+	}
+
+	ph := &tarRawEntry{
+		name:     path.Join(path.Dir(e.name), "PaxHeaders.0", path.Base(e.name)),
+		mode:     e.mode,
+		uid:      e.uid,
+		gid:      e.gid,
+		size:     int64(data.Len()),
+		mtime:    e.mtime,
+		typeflag: tarTypePAX,
+	}
+
+	if _, err := o.w.Write(ph.header()[:]); err != nil {
+		return errors.Wrap(err, "error writing PAX header")
+	}
+
+	if _, err := o.w.Write(data.Bytes()); err != nil {
+		return errors.Wrap(err, "error writing PAX records")
+	}
+
+	return writeTarPadding(o.w, int64(data.Len()))
+}
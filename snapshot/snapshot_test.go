@@ -1,6 +1,7 @@
 package snapshot_test
 
 import (
+	"context"
 	"errors"
 	"os"
 	"path/filepath"
@@ -249,3 +250,139 @@ func TestParseInvalidSourceInfo(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdatePins(t *testing.T) {
+	m := &snapshot.Manifest{}
+
+	if !m.UpdatePins([]string{"b", "a", "b"}, nil) {
+		t.Fatalf("expected change")
+	}
+
+	if got, want := m.Pins, []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pins %v, want %v", got, want)
+	}
+
+	if m.UpdatePins([]string{"a"}, []string{"c"}) {
+		t.Fatalf("unexpected change")
+	}
+
+	if !m.UpdatePins(nil, []string{"a", "b"}) {
+		t.Fatalf("expected change")
+	}
+
+	if len(m.Pins) != 0 {
+		t.Fatalf("unexpected pins %v", m.Pins)
+	}
+}
+
+func TestUpdateTags(t *testing.T) {
+	m := &snapshot.Manifest{}
+
+	if !m.UpdateTags(map[string]string{"a": "1", "b": "2"}, nil) {
+		t.Fatalf("expected change")
+	}
+
+	if got, want := m.Tags, map[string]string{"tag:a": "1", "tag:b": "2"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected tags %v, want %v", got, want)
+	}
+
+	if m.UpdateTags(map[string]string{"a": "1"}, []string{"c"}) {
+		t.Fatalf("unexpected change")
+	}
+
+	if !m.UpdateTags(map[string]string{"a": "3"}, []string{"b"}) {
+		t.Fatalf("expected change")
+	}
+
+	if got, want := m.Tags, map[string]string{"tag:a": "3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected tags %v, want %v", got, want)
+	}
+
+	if !m.UpdateTags(nil, []string{"a"}) {
+		t.Fatalf("expected change")
+	}
+
+	if m.Tags != nil {
+		t.Fatalf("unexpected tags %v", m.Tags)
+	}
+}
+
+func TestUpdateSnapshot(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	m := &snapshot.Manifest{
+		Source: snapshot.SourceInfo{Host: "host-1", UserName: "user-1", Path: "/some/path"},
+	}
+
+	oldID, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, m)
+	if err != nil {
+		t.Fatalf("error saving snapshot: %v", err)
+	}
+
+	m.UpdatePins([]string{"legal-hold"}, nil)
+
+	newID, err := snapshot.UpdateSnapshot(ctx, env.RepositoryWriter, m)
+	if err != nil {
+		t.Fatalf("error updating snapshot: %v", err)
+	}
+
+	if _, err := snapshot.LoadSnapshot(ctx, env.RepositoryWriter, oldID); !errors.Is(err, snapshot.ErrSnapshotNotFound) {
+		t.Fatalf("old snapshot not deleted: %v", err)
+	}
+
+	m2, err := snapshot.LoadSnapshot(ctx, env.RepositoryWriter, newID)
+	if err != nil {
+		t.Fatalf("error loading updated snapshot: %v", err)
+	}
+
+	if got, want := m2.Pins, []string{"legal-hold"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected pins %v, want %v", got, want)
+	}
+}
+
+// failingDeleteWriter fails to delete the manifest with the given ID.
+type failingDeleteWriter struct {
+	repo.RepositoryWriter
+
+	failID manifest.ID
+}
+
+func (w failingDeleteWriter) DeleteManifest(ctx context.Context, id manifest.ID) error {
+	if id == w.failID {
+		return errors.New("some error")
+	}
+
+	return w.RepositoryWriter.DeleteManifest(ctx, id)
+}
+
+func TestUpdateSnapshotRollback(t *testing.T) {
+	ctx, env := repotesting.NewEnvironment(t)
+
+	src := snapshot.SourceInfo{Host: "host-1", UserName: "user-1", Path: "/some/path"}
+	m := &snapshot.Manifest{Source: src}
+
+	oldID, err := snapshot.SaveSnapshot(ctx, env.RepositoryWriter, m)
+	if err != nil {
+		t.Fatalf("error saving snapshot: %v", err)
+	}
+
+	m.UpdatePins([]string{"legal-hold"}, nil)
+
+	if _, err = snapshot.UpdateSnapshot(ctx, failingDeleteWriter{env.RepositoryWriter, oldID}, m); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if m.ID != oldID {
+		t.Fatalf("unexpected manifest ID %v, want %v", m.ID, oldID)
+	}
+
+	// the new manifest has been removed, leaving only the old one.
+	ids, err := snapshot.ListSnapshotManifests(ctx, env.RepositoryWriter, &src, nil)
+	if err != nil {
+		t.Fatalf("error listing snapshots: %v", err)
+	}
+
+	if got, want := ids, []manifest.ID{oldID}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected snapshots %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package snapshotfs
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+)
+
+// EntryVersion describes a distinct version of a file or directory found in one or more snapshots.
+type EntryVersion struct {
+	ObjectID object.ID `json:"obj"`
+	IsDir    bool      `json:"isDir,omitempty"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+
+	FirstSnapshotID   manifest.ID `json:"firstSnapshotID"`
+	FirstSnapshotTime time.Time   `json:"firstSnapshotTime"`
+	LastSnapshotID    manifest.ID `json:"lastSnapshotID"`
+	LastSnapshotTime  time.Time   `json:"lastSnapshotTime"`
+	SnapshotCount     int         `json:"snapshotCount"`
+}
+
+// EntryHistory returns distinct versions of the entry at the provided slash-separated path relative to
+// snapshot root, de-duplicated by object ID and ordered by the time they first appeared in a snapshot.
+// Snapshots that don't contain the entry are skipped.
+func EntryHistory(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, relPath string) ([]*EntryVersion, error) {
+	sorted := append([]*snapshot.Manifest(nil), manifests...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartTime.Before(sorted[j].StartTime)
+	})
+
+	byObjectID := map[object.ID]*EntryVersion{}
+
+	var result []*EntryVersion
+
+	for _, m := range sorted {
+		root, err := SnapshotRoot(rep, m)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to get root of snapshot %v", m.ID)
+		}
+
+		e, err := GetNestedEntry(ctx, root, strings.Split(relPath, "/"))
+		if err != nil {
+			log(ctx).Debugf("%v not found in snapshot %v: %v", relPath, m.ID, err)
+			continue
+		}
+
+		h, ok := e.(object.HasObjectID)
+		if !ok {
+			continue
+		}
+
+		oid := h.ObjectID()
+
+		v := byObjectID[oid]
+		if v == nil {
+			v = &EntryVersion{
+				ObjectID:          oid,
+				IsDir:             e.IsDir(),
+				Size:              e.Size(),
+				ModTime:           e.ModTime(),
+				FirstSnapshotID:   m.ID,
+				FirstSnapshotTime: m.StartTime,
+			}
+
+			byObjectID[oid] = v
+			result = append(result, v)
+		}
+
+		v.LastSnapshotID = m.ID
+		v.LastSnapshotTime = m.StartTime
+		v.SnapshotCount++
+	}
+
+	return result, nil
+}
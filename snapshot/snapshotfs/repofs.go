@@ -37,6 +37,14 @@ func (e *repositoryEntry) Mode() os.FileMode {
 		return os.ModeSymlink | os.FileMode(e.metadata.Permissions)
 	case snapshot.EntryTypeFile:
 		return os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeBlockDevice:
+		return os.ModeDevice | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeCharDevice:
+		return os.ModeDevice | os.ModeCharDevice | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeNamedPipe:
+		return os.ModeNamedPipe | os.FileMode(e.metadata.Permissions)
+	case snapshot.EntryTypeSocket:
+		return os.ModeSocket | os.FileMode(e.metadata.Permissions)
 	case snapshot.EntryTypeUnknown:
 		return 0
 	default:
@@ -72,7 +80,13 @@ func (e *repositoryEntry) Owner() fs.OwnerInfo {
 }
 
 func (e *repositoryEntry) Device() fs.DeviceInfo {
-	return fs.DeviceInfo{}
+	return fs.DeviceInfo{
+		Rdev: e.metadata.DeviceNumber,
+	}
+}
+
+func (e *repositoryEntry) ExtendedAttributes(ctx context.Context) (fs.ExtendedAttributes, error) {
+	return e.metadata.ExtendedAttributes, nil
 }
 
 func (e *repositoryEntry) DirEntry() *snapshot.DirEntry {
@@ -96,6 +110,10 @@ type repositorySymlink struct {
 	repositoryEntry
 }
 
+type repositorySpecialFile struct {
+	repositoryEntry
+}
+
 type repositoryEntryError struct {
 	repositoryEntry
 	err error
@@ -172,6 +190,10 @@ func (rsl *repositorySymlink) Readlink(ctx context.Context) (string, error) {
 	return string(b), nil
 }
 
+func (rsf *repositorySpecialFile) SpecialFileType() os.FileMode {
+	return rsf.Mode() & fs.SpecialFileTypeMask
+}
+
 func (ee *repositoryEntryError) ErrorInfo() error {
 	return ee.err
 }
@@ -198,6 +220,9 @@ func EntryFromDirEntry(r repo.Repository, md *snapshot.DirEntry) fs.Entry {
 	case snapshot.EntryTypeFile:
 		return fs.File(&repositoryFile{re})
 
+	case snapshot.EntryTypeBlockDevice, snapshot.EntryTypeCharDevice, snapshot.EntryTypeNamedPipe, snapshot.EntryTypeSocket:
+		return fs.SpecialFile(&repositorySpecialFile{re})
+
 	default:
 		return fs.ErrorEntry(&repositoryEntryError{re, fs.ErrUnknown})
 	}
@@ -212,6 +237,27 @@ func (r *readCloserWithFileInfo) Entry() (fs.Entry, error) {
 	return r.e, nil
 }
 
+var _ fs.ReaderWithDataRegions = &readCloserWithFileInfo{}
+
+func (r *readCloserWithFileInfo) DataRegions() ([]fs.Region, error) {
+	dr, ok := r.Reader.(object.ReaderWithDataRegions)
+	if !ok {
+		return nil, nil
+	}
+
+	regions := dr.DataRegions()
+	if regions == nil {
+		return nil, nil
+	}
+
+	result := make([]fs.Region, 0, len(regions))
+	for _, rg := range regions {
+		result = append(result, fs.Region{Offset: rg.Offset, Length: rg.Length})
+	}
+
+	return result, nil
+}
+
 func withFileInfo(r object.Reader, e fs.Entry) fs.Reader {
 	return &readCloserWithFileInfo{r, e}
 }
@@ -293,10 +339,19 @@ func IsDirectoryID(oid object.ID) bool {
 	_ fs.Directory = (*repositoryDirectory)(nil)
 	_ fs.File      = (*repositoryFile)(nil)
 	_ fs.Symlink   = (*repositorySymlink)(nil)
+
+	_ fs.SpecialFile = (*repositorySpecialFile)(nil)
 )
 
 var (
 	_ snapshot.HasDirEntry = (*repositoryDirectory)(nil)
 	_ snapshot.HasDirEntry = (*repositoryFile)(nil)
 	_ snapshot.HasDirEntry = (*repositorySymlink)(nil)
+	_ snapshot.HasDirEntry = (*repositorySpecialFile)(nil)
+)
+
+var (
+	_ fs.EntryWithExtendedAttributes = (*repositoryDirectory)(nil)
+	_ fs.EntryWithExtendedAttributes = (*repositoryFile)(nil)
+	_ fs.EntryWithExtendedAttributes = (*repositorySymlink)(nil)
 )
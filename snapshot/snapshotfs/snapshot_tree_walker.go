@@ -2,6 +2,7 @@
 
 import (
 	"context"
+	"path"
 	"runtime"
 	"sync"
 
@@ -9,6 +10,7 @@
 
 	"github.com/kopia/kopia/fs"
 	"github.com/kopia/kopia/internal/parallelwork"
+	"github.com/kopia/kopia/repo/object"
 )
 
 const walkersPerCPU = 4
@@ -16,30 +18,56 @@
 // TreeWalker holds information for concurrently walking down FS trees specified
 // by their roots.
 type TreeWalker struct {
-	Parallelism    int
-	RootEntries    []fs.Entry
+	Parallelism int
+	RootEntries []fs.Entry
+	// ObjectCallback, if set, is invoked for each entry backed by an object, which excludes special files.
 	ObjectCallback func(entry fs.Entry) error
+	// EntryPathCallback, if set, is invoked for each entry along with its slash-separated
+	// path relative to the root, which is "." for the root itself.
+	EntryPathCallback func(entry fs.Entry, entryPath string) error
+	// DirectoryCallback, if set, is invoked with the entries of each directory after it has been read,
+	// including entries which are not visited again because of deduplication.
+	DirectoryCallback func(dir fs.Directory, entries fs.Entries) error
 	// EntryID extracts or generates an id from an fs.Entry.
-	// It can be used to eliminate duplicate entries when in a FS
+	// It can be used to eliminate duplicate entries when in a FS.
+	// When nil, all entries are visited.
 	EntryID func(entry fs.Entry) interface{}
+	// LogProgress causes progress of the walk to be logged at Info level instead of Debug level.
+	LogProgress bool
 
 	enqueued sync.Map
 	queue    *parallelwork.Queue
 }
 
-func (w *TreeWalker) enqueueEntry(ctx context.Context, entry fs.Entry) {
-	eid := w.EntryID(entry)
-	if _, existing := w.enqueued.LoadOrStore(eid, w); existing {
-		return
+func (w *TreeWalker) enqueueEntry(ctx context.Context, entry fs.Entry, entryPath string) {
+	if w.EntryID != nil {
+		eid := w.EntryID(entry)
+		if _, existing := w.enqueued.LoadOrStore(eid, w); existing {
+			return
+		}
 	}
 
-	w.queue.EnqueueBack(ctx, func() error { return w.processEntry(ctx, entry) })
+	w.queue.EnqueueBack(ctx, func() error { return w.processEntry(ctx, entry, entryPath) })
+}
+
+// hasObject returns true unless the entry is stored without an object, such as special files.
+func hasObject(entry fs.Entry) bool {
+	h, ok := entry.(object.HasObjectID)
+
+	return !ok || h.ObjectID() != ""
 }
 
-func (w *TreeWalker) processEntry(ctx context.Context, entry fs.Entry) error {
-	err := w.ObjectCallback(entry)
-	if err != nil {
-		return err
+func (w *TreeWalker) processEntry(ctx context.Context, entry fs.Entry, entryPath string) error {
+	if w.ObjectCallback != nil && hasObject(entry) {
+		if err := w.ObjectCallback(entry); err != nil {
+			return err
+		}
+	}
+
+	if w.EntryPathCallback != nil {
+		if err := w.EntryPathCallback(entry, entryPath); err != nil {
+			return err
+		}
 	}
 
 	if dir, ok := entry.(fs.Directory); ok {
@@ -48,8 +76,14 @@ func (w *TreeWalker) processEntry(ctx context.Context, entry fs.Entry) error {
 			return errors.Wrap(err, "error reading directory")
 		}
 
+		if w.DirectoryCallback != nil {
+			if err := w.DirectoryCallback(dir, entries); err != nil {
+				return err
+			}
+		}
+
 		for _, ent := range entries {
-			w.enqueueEntry(ctx, ent)
+			w.enqueueEntry(ctx, ent, path.Join(entryPath, ent.Name()))
 		}
 	}
 
@@ -59,11 +93,16 @@ func (w *TreeWalker) processEntry(ctx context.Context, entry fs.Entry) error {
 // Run walks the given tree roots.
 func (w *TreeWalker) Run(ctx context.Context) error {
 	for _, root := range w.RootEntries {
-		w.enqueueEntry(ctx, root)
+		w.enqueueEntry(ctx, root, ".")
+	}
+
+	logProgress := log(ctx).Debugf
+	if w.LogProgress {
+		logProgress = log(ctx).Infof
 	}
 
 	w.queue.ProgressCallback = func(ctx context.Context, enqueued, active, completed int64) {
-		log(ctx).Infof("  Processed %v contents, discovered %v...", completed, enqueued)
+		logProgress("  Processed %v contents, discovered %v...", completed, enqueued)
 	}
 
 	// nolint:wrapcheck
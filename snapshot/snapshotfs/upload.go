@@ -4,6 +4,7 @@
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"math/rand"
 	"os"
@@ -22,6 +23,7 @@
 	"github.com/kopia/kopia/fs/ignorefs"
 	"github.com/kopia/kopia/internal/clock"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/object"
 	"github.com/kopia/kopia/snapshot"
@@ -77,6 +79,9 @@ type Uploader struct {
 	stats    *snapshot.Stats
 	canceled int32
 
+	// set when the message about unreadable previous snapshot has been logged.
+	privateKeyUnavailableLogged int32
+
 	uploadBufPool sync.Pool
 
 	getTicker func(time.Duration) <-chan time.Time
@@ -86,6 +91,10 @@ type Uploader struct {
 
 	// disable snapshot size estimation
 	disableEstimation bool
+
+	// object IDs of hard-linked files uploaded so far, keyed by link group.
+	hardLinksMutex sync.Mutex
+	hardLinks      map[string]object.ID
 }
 
 // IsCanceled returns true if the upload is canceled.
@@ -151,12 +160,24 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 			return nil, nil
 		}
 
-		return newDirEntry(f, checkpointID)
+		return newDirEntry(ctx, f, checkpointID, pol)
 	})
 
 	defer parentCheckpointRegistry.removeCheckpointCallback(f)
 
-	written, err := u.copyWithProgress(writer, file, 0, f.Size())
+	regions, err := fs.GetDataRegions(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to determine data regions")
+	}
+
+	var written int64
+
+	if regions != nil {
+		written, err = u.copySparseWithProgress(writer, file, regions, f.Size())
+	} else {
+		written, err = u.copyWithProgress(writer, file, 0, f.Size())
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +192,7 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 		return nil, errors.Wrap(err, "unable to get result")
 	}
 
-	de, err := newDirEntry(fi2, r)
+	de, err := newDirEntry(ctx, fi2, r, pol)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
@@ -184,7 +205,7 @@ func (u *Uploader) uploadFileInternal(ctx context.Context, parentCheckpointRegis
 	return de, nil
 }
 
-func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath string, f fs.Symlink) (*snapshot.DirEntry, error) {
+func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath string, f fs.Symlink, pol *policy.Policy) (*snapshot.DirEntry, error) {
 	u.Progress.HashingFile(relativePath)
 	defer u.Progress.FinishedHashingFile(relativePath, f.Size())
 
@@ -208,7 +229,7 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath strin
 		return nil, errors.Wrap(err, "unable to get result")
 	}
 
-	de, err := newDirEntry(f, r)
+	de, err := newDirEntry(ctx, f, r, pol)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
@@ -218,7 +239,20 @@ func (u *Uploader) uploadSymlinkInternal(ctx context.Context, relativePath strin
 	return de, nil
 }
 
-func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath string, f fs.StreamingFile) (*snapshot.DirEntry, error) {
+// uploadSpecialFileInternal uploads a device node, named pipe or socket, which has no content
+// and is stored without an object.
+func (u *Uploader) uploadSpecialFileInternal(ctx context.Context, f fs.SpecialFile, pol *policy.Policy) (*snapshot.DirEntry, error) {
+	de, err := newDirEntry(ctx, f, "", pol)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create dir entry")
+	}
+
+	de.FileSize = 0
+
+	return de, nil
+}
+
+func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath string, f fs.StreamingFile, pol *policy.Policy) (*snapshot.DirEntry, error) {
 	reader, err := f.GetReader(ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get streaming file reader")
@@ -247,7 +281,7 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 		return nil, errors.Wrap(err, "unable to get result")
 	}
 
-	de, err := newDirEntry(f, r)
+	de, err := newDirEntry(ctx, f, r, pol)
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to create dir entry")
 	}
@@ -262,6 +296,46 @@ func (u *Uploader) uploadStreamingFileInternal(ctx context.Context, relativePath
 	return de, nil
 }
 
+// copySparseWithProgress copies data regions of a sparse file and records the holes between them
+// without reading or storing them.
+func (u *Uploader) copySparseWithProgress(dst object.Writer, src fs.Reader, regions []fs.Region, length int64) (int64, error) {
+	var written int64
+
+	writeHole := func(n int64) error {
+		if n <= 0 {
+			return nil
+		}
+
+		if err := dst.WriteHole(n); err != nil {
+			return errors.Wrap(err, "unable to write hole")
+		}
+
+		written += n
+		u.Progress.HashedBytes(n)
+
+		return nil
+	}
+
+	for _, rg := range regions {
+		if err := writeHole(rg.Offset - written); err != nil {
+			return written, err
+		}
+
+		if _, err := src.Seek(rg.Offset, io.SeekStart); err != nil {
+			return written, errors.Wrap(err, "unable to seek to data region")
+		}
+
+		n, err := u.copyWithProgress(dst, io.LimitReader(src, rg.Length), written, length)
+		written += n
+
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, writeHole(length - written)
+}
+
 func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, length int64) (int64, error) {
 	// nolint:forcetypeassert
 	uploadBufPtr := u.uploadBufPool.Get().(*[]byte)
@@ -316,8 +390,8 @@ func (u *Uploader) copyWithProgress(dst io.Writer, src io.Reader, completed, len
 }
 
 // newDirEntryWithSummary makes DirEntry objects for directory Entries that need a DirectorySummary.
-func newDirEntryWithSummary(d fs.Entry, oid object.ID, summ *fs.DirectorySummary) (*snapshot.DirEntry, error) {
-	de, err := newDirEntry(d, oid)
+func newDirEntryWithSummary(ctx context.Context, d fs.Entry, oid object.ID, summ *fs.DirectorySummary, pol *policy.Policy) (*snapshot.DirEntry, error) {
+	de, err := newDirEntry(ctx, d, oid, pol)
 	if err != nil {
 		return nil, err
 	}
@@ -328,7 +402,7 @@ func newDirEntryWithSummary(d fs.Entry, oid object.ID, summ *fs.DirectorySummary
 }
 
 // newDirEntry makes DirEntry objects for any type of Entry.
-func newDirEntry(md fs.Entry, oid object.ID) (*snapshot.DirEntry, error) {
+func newDirEntry(ctx context.Context, md fs.Entry, oid object.ID, pol *policy.Policy) (*snapshot.DirEntry, error) {
 	var entryType snapshot.EntryType
 
 	switch md := md.(type) {
@@ -338,11 +412,13 @@ func newDirEntry(md fs.Entry, oid object.ID) (*snapshot.DirEntry, error) {
 		entryType = snapshot.EntryTypeSymlink
 	case fs.File, fs.StreamingFile:
 		entryType = snapshot.EntryTypeFile
+	case fs.SpecialFile:
+		entryType = specialFileEntryType(md.SpecialFileType())
 	default:
 		return nil, errors.Errorf("invalid entry type %T", md)
 	}
 
-	return &snapshot.DirEntry{
+	de := &snapshot.DirEntry{
 		Name:        md.Name(),
 		Type:        entryType,
 		Permissions: snapshot.Permissions(md.Mode() & os.ModePerm),
@@ -351,7 +427,88 @@ func newDirEntry(md fs.Entry, oid object.ID) (*snapshot.DirEntry, error) {
 		UserID:      md.Owner().UserID,
 		GroupID:     md.Owner().GroupID,
 		ObjectID:    oid,
-	}, nil
+		LinkGroup:   hardLinkGroup(md),
+	}
+
+	if entryType == snapshot.EntryTypeBlockDevice || entryType == snapshot.EntryTypeCharDevice {
+		de.DeviceNumber = md.Device().Rdev
+	}
+
+	if pol.FilesPolicy.ExtendedAttributesOrDefault(false) {
+		xattrs, err := fs.GetExtendedAttributes(ctx, md)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read extended attributes")
+		}
+
+		de.ExtendedAttributes = xattrs
+	}
+
+	return de, nil
+}
+
+func specialFileEntryType(t os.FileMode) snapshot.EntryType {
+	switch t {
+	case os.ModeDevice:
+		return snapshot.EntryTypeBlockDevice
+	case os.ModeDevice | os.ModeCharDevice:
+		return snapshot.EntryTypeCharDevice
+	case os.ModeNamedPipe:
+		return snapshot.EntryTypeNamedPipe
+	case os.ModeSocket:
+		return snapshot.EntryTypeSocket
+	default:
+		return snapshot.EntryTypeUnknown
+	}
+}
+
+// hardLinkGroup returns the identifier shared by all hard links to the same file or
+// an empty string if the file is not hard-linked.
+func hardLinkGroup(e fs.Entry) string {
+	if _, isFile := e.(fs.File); !isFile {
+		return ""
+	}
+
+	h, ok := e.(fs.EntryWithHardLinkInfo)
+	if !ok {
+		return ""
+	}
+
+	li := h.HardLinkInfo()
+	if li.LinkCount < 2 { // nolint:gomnd
+		return ""
+	}
+
+	return fmt.Sprintf("%x:%x", e.Device().Dev, li.Inode)
+}
+
+// findUploadedHardLink returns the object ID of another hard link to the same file that has already been uploaded.
+func (u *Uploader) findUploadedHardLink(e fs.Entry) (object.ID, bool) {
+	group := hardLinkGroup(e)
+	if group == "" {
+		return "", false
+	}
+
+	u.hardLinksMutex.Lock()
+	defer u.hardLinksMutex.Unlock()
+
+	oid, ok := u.hardLinks[group]
+
+	return oid, ok
+}
+
+func (u *Uploader) addUploadedHardLink(de *snapshot.DirEntry) {
+	if de.LinkGroup == "" {
+		return
+	}
+
+	u.hardLinksMutex.Lock()
+	defer u.hardLinksMutex.Unlock()
+
+	if u.hardLinks == nil {
+		u.hardLinks = map[string]object.ID{}
+	}
+
+	u.hardLinks[de.LinkGroup] = de.ObjectID
 }
 
 // uploadFileWithCheckpointing uploads the specified File to the repository.
@@ -371,11 +528,11 @@ func (u *Uploader) uploadFileWithCheckpointing(ctx context.Context, relativePath
 		return nil, err
 	}
 
-	return newDirEntryWithSummary(file, res.ObjectID, &fs.DirectorySummary{
+	return newDirEntryWithSummary(ctx, file, res.ObjectID, &fs.DirectorySummary{
 		TotalFileCount: 1,
 		TotalFileSize:  res.FileSize,
 		MaxModTime:     res.ModTime,
-	})
+	}, pol)
 }
 
 // checkpointRoot invokes checkpoints on the provided registry and if a checkpoint entry was generated,
@@ -825,6 +982,15 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 			return nil
 		}
 
+		if _, ok := entry.(fs.SpecialFile); ok && !policyTree.Child(entry.Name()).EffectivePolicy().FilesPolicy.SpecialFilesOrDefault(false) {
+			// unless enabled by the policy, special files are treated as entries of unknown type.
+			isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreUnknownTypesOrDefault(true)
+
+			u.reportErrorAndMaybeCancel(fs.ErrUnknown, isIgnoredError, parentDirBuilder, entryRelativePath)
+
+			return nil
+		}
+
 		// See if we had this name during either of previous passes.
 		if cachedEntry := u.maybeIgnoreCachedEntry(ctx, findCachedEntry(ctx, entry, prevEntries)); cachedEntry != nil {
 			atomic.AddInt32(&u.stats.CachedFiles, 1)
@@ -832,7 +998,7 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 			u.Progress.CachedFile(filepath.Join(dirRelativePath, entry.Name()), entry.Size())
 
 			// compute entryResult now, cachedEntry is short-lived
-			cachedDirEntry, err := newDirEntry(entry, cachedEntry.(object.HasObjectID).ObjectID())
+			cachedDirEntry, err := newDirEntry(ctx, entry, cachedEntry.(object.HasObjectID).ObjectID(), policyTree.Child(entry.Name()).EffectivePolicy())
 			if err != nil {
 				return errors.Wrap(err, "unable to create dir entry")
 			}
@@ -841,9 +1007,24 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 			return nil
 		}
 
+		// See if another hard link to the same file was already uploaded.
+		if oid, ok := u.findUploadedHardLink(entry); ok {
+			atomic.AddInt32(&u.stats.CachedFiles, 1)
+			atomic.AddInt64(&u.stats.TotalFileSize, entry.Size())
+			u.Progress.CachedFile(filepath.Join(dirRelativePath, entry.Name()), entry.Size())
+
+			linkedDirEntry, err := newDirEntry(ctx, entry, oid, policyTree.Child(entry.Name()).EffectivePolicy())
+			if err != nil {
+				return errors.Wrap(err, "unable to create dir entry")
+			}
+
+			parentDirBuilder.addEntry(linkedDirEntry)
+			return nil
+		}
+
 		switch entry := entry.(type) {
 		case fs.Symlink:
-			de, err := u.uploadSymlinkInternal(ctx, entryRelativePath, entry)
+			de, err := u.uploadSymlinkInternal(ctx, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy())
 			if err != nil {
 				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
 
@@ -861,6 +1042,19 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 			if err != nil {
 				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
 
+				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
+			} else {
+				u.addUploadedHardLink(de)
+				parentDirBuilder.addEntry(de)
+			}
+
+			return nil
+
+		case fs.SpecialFile:
+			de, err := u.uploadSpecialFileInternal(ctx, entry, policyTree.Child(entry.Name()).EffectivePolicy())
+			if err != nil {
+				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
+
 				u.reportErrorAndMaybeCancel(err, isIgnoredError, parentDirBuilder, entryRelativePath)
 			} else {
 				parentDirBuilder.addEntry(de)
@@ -883,7 +1077,7 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 		case fs.StreamingFile:
 			atomic.AddInt32(&u.stats.NonCachedFiles, 1)
 
-			de, err := u.uploadStreamingFileInternal(ctx, entryRelativePath, entry)
+			de, err := u.uploadStreamingFileInternal(ctx, entryRelativePath, entry, policyTree.Child(entry.Name()).EffectivePolicy())
 			if err != nil {
 				isIgnoredError := policyTree.EffectivePolicy().ErrorHandlingPolicy.IgnoreFileErrorsOrDefault(false)
 
@@ -900,12 +1094,21 @@ func (u *Uploader) processNonDirectories(ctx context.Context, parentCheckpointRe
 	})
 }
 
-func maybeReadDirectoryEntries(ctx context.Context, dir fs.Directory) fs.Entries {
+func (u *Uploader) maybeReadDirectoryEntries(ctx context.Context, dir fs.Directory) fs.Entries {
 	if dir == nil {
 		return nil
 	}
 
 	ent, err := dir.Readdir(ctx)
+	if errors.Is(err, encryption.ErrPrivateKeyUnavailable) {
+		// clients without the private key can't read previous snapshots and must hash all files.
+		if atomic.CompareAndSwapInt32(&u.privateKeyUnavailableLogged, 0, 1) {
+			log(ctx).Infof("Previous snapshot can't be read without the private key, all files will be hashed.")
+		}
+
+		return nil
+	}
+
 	if err != nil {
 		log(ctx).Errorf("unable to read previous directory entries: %v", err)
 		return nil
@@ -1008,7 +1211,7 @@ func uploadDirInternal(
 	var prevEntries []fs.Entries
 
 	for _, d := range uniqueDirectories(previousDirs) {
-		if ent := maybeReadDirectoryEntries(ctx, d); ent != nil {
+		if ent := u.maybeReadDirectoryEntries(ctx, d); ent != nil {
 			prevEntries = append(prevEntries, ent)
 		}
 	}
@@ -1031,7 +1234,7 @@ func uploadDirInternal(
 			return nil, errors.Wrap(err, "error writing dir manifest")
 		}
 
-		return newDirEntryWithSummary(directory, oid, checkpointManifest.Summary)
+		return newDirEntryWithSummary(ctx, directory, oid, checkpointManifest.Summary, policyTree.EffectivePolicy())
 	})
 	defer thisCheckpointRegistry.removeCheckpointCallback(directory)
 
@@ -1046,7 +1249,7 @@ func uploadDirInternal(
 		return nil, errors.Wrapf(err, "error writing dir manifest: %v", directory.Name())
 	}
 
-	return newDirEntryWithSummary(directory, oid, dirManifest.Summary)
+	return newDirEntryWithSummary(ctx, directory, oid, dirManifest.Summary, policyTree.EffectivePolicy())
 }
 
 func (u *Uploader) writeDirManifest(ctx context.Context, dirRelativePath string, dirManifest *snapshot.DirManifest) (object.ID, error) {
@@ -1146,6 +1349,8 @@ func (u *Uploader) Upload(
 
 	u.stats = &snapshot.Stats{}
 	u.totalWrittenBytes = 0
+	u.privateKeyUnavailableLogged = 0
+	u.hardLinks = map[string]object.ID{}
 
 	var err error
 
@@ -667,3 +667,72 @@ func TestUpload_VirtualDirectoryWithStreamingFile(t *testing.T) {
 		t.Fatalf("unexpected manifest file count: %v, want %v", got, want)
 	}
 }
+
+func TestUpload_ExtendedAttributes(t *testing.T) {
+	ctx := testlogging.Context(t)
+	th := newUploadTestHarness(ctx, t)
+
+	defer th.cleanup()
+
+	xattrs := fs.ExtendedAttributes{
+		"user.foo":                []byte("bar"),
+		"system.posix_acl_access": {2, 0, 0, 0, 1, 0, 6, 0},
+	}
+
+	for _, name := range []string{"f1", "d1"} {
+		e, err := th.sourceDir.Child(ctx, name)
+		require.NoError(t, err)
+
+		e.(interface {
+			SetExtendedAttributes(xattrs fs.ExtendedAttributes)
+		}).SetExtendedAttributes(xattrs)
+	}
+
+	u := NewUploader(th.repo)
+
+	cases := []struct {
+		extendedAttributes *bool
+		want               fs.ExtendedAttributes
+	}{
+		{nil, nil},
+		{boolPtr(false), nil},
+		{boolPtr(true), xattrs},
+	}
+
+	for _, tc := range cases {
+		policyTree := policy.BuildTree(map[string]*policy.Policy{
+			".": {
+				FilesPolicy: policy.FilesPolicy{
+					ExtendedAttributes: tc.extendedAttributes,
+				},
+			},
+		}, policy.DefaultPolicy)
+
+		man, err := u.Upload(ctx, th.sourceDir, policyTree, snapshot.SourceInfo{})
+		require.NoError(t, err)
+
+		root, err := SnapshotRoot(th.repo, man)
+		require.NoError(t, err)
+
+		for _, name := range []string{"f1", "d1"} {
+			e, err := root.(fs.Directory).Child(ctx, name)
+			require.NoError(t, err)
+
+			got, err := fs.GetExtendedAttributes(ctx, e)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		}
+
+		// entries without extended attributes are not affected.
+		e, err := root.(fs.Directory).Child(ctx, "f2")
+		require.NoError(t, err)
+
+		got, err := fs.GetExtendedAttributes(ctx, e)
+		require.NoError(t, err)
+		require.Empty(t, got)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
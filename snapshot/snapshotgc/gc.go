@@ -12,6 +12,7 @@
 	"github.com/kopia/kopia/internal/units"
 	"github.com/kopia/kopia/repo"
 	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/encryption"
 	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/repo/manifest"
@@ -37,7 +38,19 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 		return errors.Wrap(err, "unable to load manifest IDs")
 	}
 
+	log(ctx).Infof("Looking for active contents...")
+
+	return walkSnapshotContents(ctx, rep, manifests, func(cid content.ID) error {
+		used.Store(cid, nil)
+		return nil
+	})
+}
+
+// walkSnapshotContents invokes the provided callback for all contents referenced by the provided snapshots.
+// The callback may be invoked concurrently and more than once for the same content.
+func walkSnapshotContents(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest, callback func(cid content.ID) error) error {
 	w := snapshotfs.NewTreeWalker()
+	w.LogProgress = true
 	w.EntryID = func(e fs.Entry) interface{} { return oidOf(e) }
 
 	for _, m := range manifests {
@@ -58,14 +71,14 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 		}
 
 		for _, cid := range contentIDs {
-			used.Store(cid, nil)
+			if err := callback(cid); err != nil {
+				return err
+			}
 		}
 
 		return nil
 	}
 
-	log(ctx).Infof("Looking for active contents...")
-
 	if err := w.Run(ctx); err != nil {
 		return errors.Wrap(err, "error walking snapshot tree")
 	}
@@ -77,6 +90,10 @@ func findInUseContentIDs(ctx context.Context, rep repo.Repository, used *sync.Ma
 func Run(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters) (Stats, error) {
 	var st Stats
 
+	if err := CheckCanReadSnapshots(rep); err != nil {
+		return st, err
+	}
+
 	err := maintenance.ReportRun(ctx, rep, maintenance.TaskSnapshotGarbageCollection, nil, func() error {
 		return runInternal(ctx, rep, gcDelete, safety, &st)
 	})
@@ -84,6 +101,16 @@ func Run(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, sa
 	return st, errors.Wrap(err, "error running snapshot gc")
 }
 
+// CheckCanReadSnapshots returns an error if the contents of snapshots can't be read, because the repository
+// uses public-key encryption and is connected without the private key.
+func CheckCanReadSnapshots(rep repo.DirectRepository) error {
+	if !rep.Crypter().CanDecryptContents() {
+		return errors.Wrap(encryption.ErrPrivateKeyUnavailable, "snapshot GC must be run by a client connected with the private key")
+	}
+
+	return nil
+}
+
 func runInternal(ctx context.Context, rep repo.DirectRepositoryWriter, gcDelete bool, safety maintenance.SafetyParameters, st *Stats) error {
 	var (
 		used sync.Map
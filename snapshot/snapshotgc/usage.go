@@ -0,0 +1,376 @@
+package snapshotgc
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kopia/kopia/fs"
+	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/content"
+	"github.com/kopia/kopia/repo/manifest"
+	"github.com/kopia/kopia/repo/object"
+	"github.com/kopia/kopia/snapshot"
+	"github.com/kopia/kopia/snapshot/snapshotfs"
+)
+
+// SnapshotUsage describes storage space attributed to a single snapshot.
+type SnapshotUsage struct {
+	ID        manifest.ID         `json:"id"`
+	Source    snapshot.SourceInfo `json:"source"`
+	StartTime time.Time           `json:"startTime"`
+
+	// ReferencedBytes is the total packed size of all unique contents referenced by the snapshot.
+	ReferencedBytes    int64 `json:"referencedBytes"`
+	ReferencedContents int   `json:"referencedContents"`
+
+	// ExclusiveBytes is the total packed size of contents not referenced by any other snapshot,
+	// which is approximately the amount of space freed by deleting the snapshot.
+	ExclusiveBytes    int64 `json:"exclusiveBytes"`
+	ExclusiveContents int   `json:"exclusiveContents"`
+}
+
+// SourceUsage describes storage space attributed to all snapshots of a single source.
+type SourceUsage struct {
+	Source        snapshot.SourceInfo `json:"source"`
+	SnapshotCount int                 `json:"snapshotCount"`
+
+	// ReferencedBytes is the total packed size of all unique contents referenced by snapshots of the source.
+	ReferencedBytes    int64 `json:"referencedBytes"`
+	ReferencedContents int   `json:"referencedContents"`
+
+	// ExclusiveBytes is the total packed size of contents not referenced by snapshots of any other source,
+	// which is approximately the amount of space freed by deleting all snapshots of the source.
+	ExclusiveBytes    int64 `json:"exclusiveBytes"`
+	ExclusiveContents int   `json:"exclusiveContents"`
+}
+
+// UsageReport attributes contents in the repository to snapshots and sources referencing them.
+type UsageReport struct {
+	Sources   []*SourceUsage   `json:"sources"`
+	Snapshots []*SnapshotUsage `json:"snapshots"`
+
+	// ReferencedBytes is the total packed size of contents referenced by at least one snapshot.
+	ReferencedBytes int64 `json:"referencedBytes"`
+
+	// SharedBytes is the total packed size of contents referenced by more than one source.
+	SharedBytes int64 `json:"sharedBytes"`
+
+	// UnreferencedBytes is the total packed size of contents not referenced by any snapshot,
+	// which will be removed by garbage collection.
+	UnreferencedBytes int64 `json:"unreferencedBytes"`
+
+	// SystemBytes is the total packed size of contents used by repository metadata.
+	SystemBytes int64 `json:"systemBytes"`
+}
+
+// snapshotSet is a bit set of snapshot indexes. Sets are shared between objects and contents
+// reachable from the same snapshots and never modified after they have been shared.
+type snapshotSet []uint64
+
+const snapshotSetWordBits = 64
+
+func newSnapshotSet(n int) snapshotSet {
+	return make(snapshotSet, (n+snapshotSetWordBits-1)/snapshotSetWordBits)
+}
+
+func (s snapshotSet) add(i int) {
+	s[i/snapshotSetWordBits] |= 1 << uint(i%snapshotSetWordBits)
+}
+
+func (s snapshotSet) contains(o snapshotSet) bool {
+	for i := range s {
+		if s[i]|o[i] != s[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s snapshotSet) forEach(cb func(i int)) {
+	for w, v := range s {
+		for b := 0; v != 0; b++ {
+			if v&1 != 0 {
+				cb(w*snapshotSetWordBits + b)
+			}
+
+			v >>= 1
+		}
+	}
+}
+
+// union returns the union of the provided sets, reusing one of them if it includes the other.
+func union(a, b *snapshotSet) *snapshotSet {
+	switch {
+	case a == nil || a == b:
+		return b
+	case b == nil || a.contains(*b):
+		return a
+	case b.contains(*a):
+		return b
+	}
+
+	result := append(snapshotSet(nil), *a...)
+	for i, v := range *b {
+		result[i] |= v
+	}
+
+	return &result
+}
+
+// usageGraph describes all objects reachable from snapshots, each unique object is visited exactly once.
+type usageGraph struct {
+	mu       sync.Mutex
+	children map[object.ID][]object.ID
+	contents map[object.ID][]content.ID
+}
+
+func (g *usageGraph) walk(ctx context.Context, rep repo.Repository, manifests []*snapshot.Manifest) (roots []object.ID, err error) {
+	w := snapshotfs.NewTreeWalker()
+	w.EntryID = func(e fs.Entry) interface{} { return oidOf(e) }
+
+	for _, m := range manifests {
+		root, err := snapshotfs.SnapshotRoot(rep, m)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to get snapshot root")
+		}
+
+		w.RootEntries = append(w.RootEntries, root)
+		roots = append(roots, oidOf(root))
+	}
+
+	w.ObjectCallback = func(entry fs.Entry) error {
+		oid := oidOf(entry)
+
+		contentIDs, err := rep.VerifyObject(ctx, oid)
+		if err != nil {
+			return errors.Wrapf(err, "error verifying %v", oid)
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		g.contents[oid] = contentIDs
+
+		return nil
+	}
+
+	w.DirectoryCallback = func(dir fs.Directory, entries fs.Entries) error {
+		var children []object.ID
+
+		for _, e := range entries {
+			if oid := oidOf(e); oid != "" {
+				children = append(children, oid)
+			}
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+
+		g.children[oidOf(dir)] = children
+
+		return nil
+	}
+
+	if err := w.Run(ctx); err != nil {
+		return nil, errors.Wrap(err, "error walking snapshot tree")
+	}
+
+	return roots, nil
+}
+
+// reachableFrom returns the set of snapshots each object is reachable from, processing objects
+// in topological order so that each object is processed after all its parents.
+func (g *usageGraph) reachableFrom(roots []object.ID) map[object.ID]*snapshotSet {
+	reach := map[object.ID]*snapshotSet{}
+	parentCount := map[object.ID]int{}
+
+	for _, children := range g.children {
+		for _, c := range children {
+			parentCount[c]++
+		}
+	}
+
+	for i, oid := range roots {
+		s := newSnapshotSet(len(roots))
+		s.add(i)
+		reach[oid] = union(reach[oid], &s)
+	}
+
+	var queue []object.ID
+
+	for oid := range g.contents {
+		if parentCount[oid] == 0 {
+			queue = append(queue, oid)
+		}
+	}
+
+	for len(queue) > 0 {
+		oid := queue[0]
+		queue = queue[1:]
+
+		for _, c := range g.children[oid] {
+			reach[c] = union(reach[c], reach[oid])
+
+			parentCount[c]--
+			if parentCount[c] == 0 {
+				queue = append(queue, c)
+			}
+		}
+	}
+
+	return reach
+}
+
+// ComputeUsage walks all snapshots in the repository and attributes contents referenced by them
+// to snapshots and sources, computing exclusive and shared sizes.
+//
+// All snapshots are walked at once visiting each unique object only once, after which the set of
+// snapshots referencing each content is computed by propagating snapshot sets down the tree.
+func ComputeUsage(ctx context.Context, rep repo.DirectRepository) (*UsageReport, error) {
+	ids, err := snapshot.ListSnapshotManifests(ctx, rep, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list snapshot manifest IDs")
+	}
+
+	manifests, err := snapshot.LoadSnapshots(ctx, rep, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load snapshot manifests")
+	}
+
+	sort.Slice(manifests, func(i, j int) bool {
+		if a, b := manifests[i].Source.String(), manifests[j].Source.String(); a != b {
+			return a < b
+		}
+
+		return manifests[i].StartTime.Before(manifests[j].StartTime)
+	})
+
+	report := &UsageReport{}
+	packedLength := map[content.ID]int64{}
+
+	if err := rep.ContentReader().IterateContents(ctx, content.IterateOptions{}, func(ci content.Info) error {
+		if manifest.ContentPrefix == ci.GetContentID().Prefix() {
+			report.SystemBytes += int64(ci.GetPackedLength())
+			return nil
+		}
+
+		packedLength[ci.GetContentID()] = int64(ci.GetPackedLength())
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating contents")
+	}
+
+	log(ctx).Infof("Attributing contents to %v snapshots...", len(manifests))
+
+	// sourceOf maps snapshot index to source index.
+	sourceOf := make([]int, len(manifests))
+
+	for i, m := range manifests {
+		if len(report.Sources) == 0 || report.Sources[len(report.Sources)-1].Source != m.Source {
+			report.Sources = append(report.Sources, &SourceUsage{Source: m.Source})
+		}
+
+		sourceOf[i] = len(report.Sources) - 1
+		report.Sources[sourceOf[i]].SnapshotCount++
+
+		report.Snapshots = append(report.Snapshots, &SnapshotUsage{
+			ID:        m.ID,
+			Source:    m.Source,
+			StartTime: m.StartTime,
+		})
+	}
+
+	g := &usageGraph{
+		children: map[object.ID][]object.ID{},
+		contents: map[object.ID][]content.ID{},
+	}
+
+	roots, err := g.walk(ctx, rep, manifests)
+	if err != nil {
+		return nil, err
+	}
+
+	reach := g.reachableFrom(roots)
+
+	contentReach := map[content.ID]*snapshotSet{}
+
+	for oid, cids := range g.contents {
+		for _, cid := range cids {
+			if _, ok := packedLength[cid]; ok {
+				contentReach[cid] = union(contentReach[cid], reach[oid])
+			}
+		}
+	}
+
+	// aggregate contents referenced by the same set of snapshots.
+	type setUsage struct {
+		bytes    int64
+		contents int
+	}
+
+	bySet := map[*snapshotSet]*setUsage{}
+
+	for cid, l := range packedLength {
+		s := contentReach[cid]
+		if s == nil {
+			report.UnreferencedBytes += l
+			continue
+		}
+
+		u := bySet[s]
+		if u == nil {
+			u = &setUsage{}
+			bySet[s] = u
+		}
+
+		u.bytes += l
+		u.contents++
+	}
+
+	for s, u := range bySet {
+		report.ReferencedBytes += u.bytes
+
+		var snapshots, sources []int
+
+		s.forEach(func(i int) {
+			snapshots = append(snapshots, i)
+
+			// snapshots are sorted by source, so the same source can only repeat consecutively.
+			if len(sources) == 0 || sources[len(sources)-1] != sourceOf[i] {
+				sources = append(sources, sourceOf[i])
+			}
+
+			snap := report.Snapshots[i]
+			snap.ReferencedBytes += u.bytes
+			snap.ReferencedContents += u.contents
+		})
+
+		if len(snapshots) == 1 {
+			report.Snapshots[snapshots[0]].ExclusiveBytes += u.bytes
+			report.Snapshots[snapshots[0]].ExclusiveContents += u.contents
+		}
+
+		for _, si := range sources {
+			src := report.Sources[si]
+			src.ReferencedBytes += u.bytes
+			src.ReferencedContents += u.contents
+
+			if len(sources) == 1 {
+				src.ExclusiveBytes += u.bytes
+				src.ExclusiveContents += u.contents
+			}
+		}
+
+		if len(sources) > 1 {
+			report.SharedBytes += u.bytes
+		}
+	}
+
+	return report, nil
+}
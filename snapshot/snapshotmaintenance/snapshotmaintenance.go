@@ -6,15 +6,25 @@
 
 	"github.com/pkg/errors"
 
+	"github.com/kopia/kopia/internal/notification"
 	"github.com/kopia/kopia/repo"
+	"github.com/kopia/kopia/repo/logging"
 	"github.com/kopia/kopia/repo/maintenance"
 	"github.com/kopia/kopia/snapshot/snapshotgc"
 )
 
+var log = logging.GetContextLoggerFunc("snapshotmaintenance")
+
 // Run runs the complete snapshot and repository maintenance.
 func Run(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.Mode, force bool, safety maintenance.SafetyParameters) error {
-	// nolint:wrapcheck
-	return maintenance.RunExclusive(ctx, dr, mode, force,
+	// full maintenance requires reading all snapshots, refuse it before updating the schedule.
+	if mode == maintenance.ModeFull {
+		if err := snapshotgc.CheckCanReadSnapshots(dr); err != nil {
+			return errors.Wrap(err, "unable to run full maintenance")
+		}
+	}
+
+	err := maintenance.RunExclusive(ctx, dr, mode, force,
 		func(runParams maintenance.RunParameters) error {
 			// run snapshot GC before full maintenance
 			if runParams.Mode == maintenance.ModeFull {
@@ -26,4 +36,20 @@ func(runParams maintenance.RunParameters) error {
 			// nolint:wrapcheck
 			return maintenance.Run(ctx, runParams, safety)
 		})
+	if err != nil {
+		notifyMaintenanceFailed(ctx, dr, mode, err)
+	}
+
+	// nolint:wrapcheck
+	return err
+}
+
+func notifyMaintenanceFailed(ctx context.Context, dr repo.DirectRepositoryWriter, mode maintenance.Mode, maintErr error) {
+	ev := notification.NewEvent(notification.EventMaintenanceFailed, "Maintenance of %v failed", dr.BlobReader().DisplayName())
+	ev.Message = maintErr.Error()
+	ev.Details["Mode"] = string(mode)
+
+	if err := notification.Send(ctx, dr, dr.ConfigFilename(), ev); err != nil {
+		log(ctx).Errorf("unable to send notification: %v", err)
+	}
 }